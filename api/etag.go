@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// versionedFields are the fields of the responses that have a version, which is returned as their ETag.
+var versionedFields = []string{"race", "event"}
+
+// setETag returns the version of the race or event of a response as its ETag, so clients can send it back
+// in If-Match when they update it.
+func setETag(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+	msg := resp.ProtoReflect()
+
+	for _, name := range versionedFields {
+		field := msg.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if field == nil || field.Message() == nil || !msg.Has(field) {
+			continue
+		}
+
+		resource := msg.Get(field).Message()
+		version := resource.Descriptor().Fields().ByName("version")
+		if version == nil || !resource.Has(version) {
+			continue
+		}
+
+		w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(resource.Get(version).Int(), 10)))
+	}
+
+	return nil
+}
+
+// route is a route of the gateway, matching the requests made with its method to the paths it matches.
+type route struct {
+	method string
+	path   *regexp.Regexp
+}
+
+// matchRoute reports whether a request is made to one of the routes.
+func matchRoute(routes []route, r *http.Request) bool {
+	for _, route := range routes {
+		if r.Method == route.method && route.path.MatchString(r.URL.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// versionedRoutes are the routes If-Match applies to: UpdateRace, UpdateEvent and SetEventVisibility. They take the
+// version of the race or event they change, in the version field of their body.
+var versionedRoutes = []route{
+	{method: http.MethodPatch, path: regexp.MustCompile(`^/v1/admin/race/[^/]+$`)},
+	{method: http.MethodPatch, path: regexp.MustCompile(`^/v1/admin/event/[^/]+$`)},
+	{method: http.MethodPost, path: regexp.MustCompile(`^/v1/admin/event/[^/]+/visibility$`)},
+}
+
+// isVersionedRoute reports whether a request is made to one of the versionedRoutes.
+func isVersionedRoute(r *http.Request) bool {
+	return matchRoute(versionedRoutes, r)
+}
+
+// ifMatchHandler sets the version of the race or event being updated to the one in the If-Match header, so
+// clients can update what they read without copying its version into the body. The header replaces any version
+// in the body. Only strong ETags of a single version can match, any other If-Match fails with 412.
+//
+// If-Match only applies to the versionedRoutes. They are answered with 428 when there is neither an If-Match nor a
+// version in the body, and writes to other routes with 400 when they have an If-Match, as it can't be honoured
+// without a version. * matches any version, so it is left to the version in the body.
+func ifMatchHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+
+		if !isVersionedRoute(r) {
+			if ifMatch != "" && ifMatch != "*" && r.Method != http.MethodGet && r.Method != http.MethodHead {
+				writeStatus(w, http.StatusBadRequest, codes.InvalidArgument, "If-Match isn't supported by "+r.Method+" "+r.URL.Path+", it doesn't take a version")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+		r.Body.Close()
+		if err != nil {
+			writeStatus(w, http.StatusBadRequest, codes.InvalidArgument, err.Error())
+			return
+		}
+
+		// The body is the race or event for updates, and the request for the endpoints taking a version
+		fields := make(map[string]json.RawMessage)
+		if len(bytes.TrimSpace(body)) > 0 {
+			if err := json.Unmarshal(body, &fields); err != nil {
+				writeStatus(w, http.StatusBadRequest, codes.InvalidArgument, "body must be a JSON object: "+err.Error())
+				return
+			}
+		}
+
+		switch ifMatch {
+		case "", "*":
+			if _, ok := fields["version"]; !ok {
+				writeStatus(w, http.StatusPreconditionRequired, codes.FailedPrecondition, "If-Match is required, it must be the ETag the race or event was read with")
+				return
+			}
+		default:
+			version, err := parseETag(ifMatch)
+			if err != nil {
+				writeStatus(w, http.StatusPreconditionFailed, codes.FailedPrecondition, err.Error())
+				return
+			}
+			fields["version"] = json.RawMessage(strconv.FormatInt(version, 10))
+		}
+
+		body, err = json.Marshal(fields)
+		if err != nil {
+			writeStatus(w, http.StatusInternalServerError, codes.Internal, err.Error())
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		r.Header.Del("Content-Length")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseETag returns the version of a strong ETag, e.g. "3".
+func parseETag(etag string) (int64, error) {
+	unquoted, err := strconv.Unquote(etag)
+	if err != nil || !strings.HasPrefix(etag, `"`) {
+		return 0, fmt.Errorf("If-Match must be a single strong ETag, e.g. \"3\"")
+	}
+
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("If-Match %s isn't the ETag of a version", etag)
+	}
+
+	return version, nil
+}
+
+// writeStatus writes an error the way the gateway does, so clients get the same body for every error.
+func writeStatus(w http.ResponseWriter, httpStatus int, code codes.Code, message string) {
+	body, _ := json.Marshal(map[string]interface{}{"code": code, "message": message})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	w.Write(body)
+}
+
+// versionErrorHandler answers writes made to a stale version with 412 Precondition Failed rather than the 409
+// Conflict of other Aborted errors, so If-Match behaves as HTTP clients expect.
+func versionErrorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	if isVersionChanged(err) {
+		w = &statusWriter{ResponseWriter: w, status: http.StatusPreconditionFailed}
+	}
+
+	runtime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+}
+
+// isVersionChanged reports whether an error has a VERSION precondition failure.
+func isVersionChanged(err error) bool {
+	for _, detail := range status.Convert(err).Details() {
+		if failure, ok := detail.(*errdetails.PreconditionFailure); ok {
+			for _, violation := range failure.Violations {
+				if violation.Type == "VERSION" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// statusWriter writes its status in place of the one given to WriteHeader.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(int) {
+	w.ResponseWriter.WriteHeader(w.status)
+}
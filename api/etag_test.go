@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSetETag(t *testing.T) {
+	w := httptest.NewRecorder()
+	assert.NoError(t, setETag(context.Background(), w, &racing.GetRaceResponse{Race: &racing.Race{Id: 1, Version: 3}}))
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+
+	w = httptest.NewRecorder()
+	assert.NoError(t, setETag(context.Background(), w, &sports.SetEventVisibilityResponse{Event: &sports.Event{Id: 1, Version: 12}}))
+	assert.Equal(t, `"12"`, w.Header().Get("ETag"))
+
+	// Lists have no single version
+	w = httptest.NewRecorder()
+	assert.NoError(t, setETag(context.Background(), w, &racing.ListRacesResponse{Races: []*racing.Race{{Id: 1, Version: 3}}}))
+	assert.Empty(t, w.Header().Get("ETag"))
+}
+
+func TestIfMatchHandler(t *testing.T) {
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		ifMatch      string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "VersionFromIfMatch",
+			method:       http.MethodPatch,
+			path:         "/v1/admin/race/1",
+			ifMatch:      `"3"`,
+			body:         `{"name": "Melbourne Cup", "version": 1}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"name": "Melbourne Cup", "version": 3}`,
+		},
+		{
+			name:         "VersionFromBody",
+			method:       http.MethodPost,
+			path:         "/v1/admin/event/1/visibility",
+			body:         `{"visible": true, "version": 2}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"visible": true, "version": 2}`,
+		},
+		{
+			name:         "AnyVersion",
+			method:       http.MethodPatch,
+			path:         "/v1/admin/event/1",
+			ifMatch:      "*",
+			body:         `{"name": "Chelsea v Arsenal", "version": 2}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"name": "Chelsea v Arsenal", "version": 2}`,
+		},
+		{
+			name:         "MissingVersion",
+			method:       http.MethodPatch,
+			path:         "/v1/admin/race/1",
+			body:         `{"name": "Melbourne Cup"}`,
+			expectedCode: http.StatusPreconditionRequired,
+		},
+		{
+			name:         "WeakETag",
+			method:       http.MethodPatch,
+			path:         "/v1/admin/race/1",
+			ifMatch:      `W/"3"`,
+			body:         `{"name": "Melbourne Cup"}`,
+			expectedCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:         "SeveralETags",
+			method:       http.MethodPatch,
+			path:         "/v1/admin/race/1",
+			ifMatch:      `"3", "4"`,
+			body:         `{"name": "Melbourne Cup"}`,
+			expectedCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:         "RouteWithoutVersion",
+			method:       http.MethodPost,
+			path:         "/v1/admin/race/1/status",
+			ifMatch:      `"3"`,
+			body:         `{"status": "SUSPENDED"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "RouteWithoutVersionNoIfMatch",
+			method:       http.MethodPost,
+			path:         "/v1/admin/race/1/status",
+			body:         `{"status": "SUSPENDED"}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"status": "SUSPENDED"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gateway := &gatewayHandler{status: http.StatusOK, response: `{}`}
+
+			r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.ifMatch != "" {
+				r.Header.Set("If-Match", tc.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			ifMatchHandler(gateway).ServeHTTP(w, r)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			if tc.expectedCode == http.StatusOK {
+				assert.JSONEq(t, tc.expectedBody, gateway.body)
+			} else {
+				assert.Empty(t, gateway.body)
+			}
+		})
+	}
+}
+
+func TestVersionErrorHandler(t *testing.T) {
+	versionChanged, _ := status.New(codes.Aborted, "Race with ID 1 was changed since version 3").WithDetails(&errdetails.PreconditionFailure{
+		Violations: []*errdetails.PreconditionFailure_Violation{{Type: "VERSION", Subject: "race/1"}},
+	})
+
+	testCases := []struct {
+		name         string
+		err          error
+		expectedCode int
+	}{
+		{name: "VersionChanged", err: versionChanged.Err(), expectedCode: http.StatusPreconditionFailed},
+		{name: "StatusChanged", err: status.Error(codes.Aborted, "Race with ID 1 status was changed by someone else"), expectedCode: http.StatusConflict},
+		{name: "NotFound", err: status.Error(codes.NotFound, "Race with ID 1 not found"), expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := runtime.NewServeMux()
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPatch, "/v1/admin/race/1", nil)
+
+			versionErrorHandler(context.Background(), mux, &runtime.JSONPb{}, w, r, tc.err)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+		})
+	}
+}
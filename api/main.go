@@ -2,20 +2,38 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"log"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	"git.neds.sh/matty/entain/api/proto/racing"
 	"git.neds.sh/matty/entain/api/proto/sports"
+	"git.neds.sh/matty/entain/common/tracing"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	// Registers the error details of the services, so the gateway can marshal them
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 )
 
 var (
+	adminEndpoint      = flag.String("admin-endpoint", "localhost:8002", "Admin API endpoint, which must only be reachable from the internal network")
+	allowedOrigins     = flag.String("allowed-origins", "", "Comma-separated origins allowed to open WebSockets besides the API itself, e.g. https://example.com, or * for any")
 	apiEndpoint        = flag.String("api-endpoint", "localhost:8000", "API endpoint")
+	grpcRacingAdmin    = flag.String("grpc-racing-admin-endpoint", "localhost:9002", "gRPC racing admin server endpoint")
 	grpcRacingEndpoint = flag.String("grpc-racing-endpoint", "localhost:9000", "gRPC racing server endpoint")
+	grpcSportsAdmin    = flag.String("grpc-sports-admin-endpoint", "localhost:9003", "gRPC sports admin server endpoint")
 	grpcSportsEndpoint = flag.String("grpc-sports-endpoint", "localhost:9001", "gRPC sports server endpoint")
+	metricsEndpoint    = flag.String("metrics-endpoint", "localhost:8001", "Endpoint serving the Prometheus metrics on /metrics, empty to not serve them")
+	nextToGoWindow     = flag.Duration("next-to-go-window", defaultNextToGoWindow, "How far ahead /v1/next-to-go looks for events, at least a minute")
+	otlpEndpoint       = flag.String("otlp-endpoint", "localhost:4317", "Endpoint of the OTLP gRPC collector the spans are exported to with -trace-exporter=otlp")
+	traceExporter      = flag.String("trace-exporter", "none", "Exporter of the spans, none, stdout or otlp")
+	traceSample        = flag.Float64("trace-sample-ratio", 1, "Ratio of the traces started by the gateway which are sampled, the ones of callers are sampled like the callers")
 )
 
 func main() {
@@ -27,31 +45,130 @@ func main() {
 }
 
 func run() error {
+	if *nextToGoWindow < time.Minute {
+		return errors.New("next-to-go-window must be at least a minute")
+	}
+
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	mux := runtime.NewServeMux()
+	// Metrics are served on an endpoint of their own, so they aren't exposed along with the API
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	metrics := newHTTPMetrics(registry)
+
+	if *metricsEndpoint != "" {
+		if err := serveMetrics(registry); err != nil {
+			return err
+		}
+	}
+
+	// Requests are traced from the gateway to the queries of the services, continuing the traces of their callers
+	tracerProvider, stopTracing, err := tracing.NewProvider("api", *traceExporter, *otlpEndpoint, *traceSample)
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+
+	// The connections are shared by the gateway and the stream endpoints
+	racingConn, err := grpc.DialContext(ctx, *grpcRacingEndpoint, append(traceDialOptions(tracerProvider), grpc.WithInsecure())...)
+	if err != nil {
+		return err
+	}
+	defer racingConn.Close()
+
+	racingAdminConn, err := grpc.DialContext(ctx, *grpcRacingAdmin, append(traceDialOptions(tracerProvider), grpc.WithInsecure())...)
+	if err != nil {
+		return err
+	}
+	defer racingAdminConn.Close()
+
+	sportsConn, err := grpc.DialContext(ctx, *grpcSportsEndpoint, append(traceDialOptions(tracerProvider), grpc.WithInsecure())...)
+	if err != nil {
+		return err
+	}
+	defer sportsConn.Close()
+
+	sportsAdminConn, err := grpc.DialContext(ctx, *grpcSportsAdmin, append(traceDialOptions(tracerProvider), grpc.WithInsecure())...)
+	if err != nil {
+		return err
+	}
+	defer sportsAdminConn.Close()
 
-	if err := racing.RegisterRacingHandlerFromEndpoint(
-		ctx,
-		mux,
-		*grpcRacingEndpoint,
-		[]grpc.DialOption{grpc.WithInsecure()},
-	); err != nil {
+	origins := strings.FieldsFunc(*allowedOrigins, func(c rune) bool { return c == ',' || c == ' ' })
+	handler, adminHandler, err := newHandlers(ctx, racingConn, racingAdminConn, sportsConn, sportsAdminConn, origins, *nextToGoWindow)
+	if err != nil {
 		return err
 	}
 
-	if err := sports.RegisterSportsHandlerFromEndpoint(
-		ctx,
-		mux,
-		*grpcSportsEndpoint,
-		[]grpc.DialOption{grpc.WithInsecure()},
-	); err != nil {
+	if err := serveAdmin(traceHandler(metrics.handler(adminHandler), adminHandler, tracerProvider)); err != nil {
 		return err
 	}
 
 	log.Printf("API server listening on: %s\n", *apiEndpoint)
 
-	return http.ListenAndServe(*apiEndpoint, mux)
+	return http.ListenAndServe(*apiEndpoint, traceHandler(metrics.handler(handler), handler, tracerProvider))
+}
+
+// newGatewayMux returns a mux of the gateway, which marshals, versions and traces the responses of the services.
+func newGatewayMux() *runtime.ServeMux {
+	return runtime.NewServeMux(
+		runtime.WithForwardResponseOption(setETag),
+		runtime.WithErrorHandler(versionErrorHandler),
+		runtime.WithMetadata(routeRPCMethod),
+		runtime.WithMetadata(nameSpan),
+	)
+}
+
+// newHandlers returns the handler of the public API, and the one of the admin API. The admin API changes the races
+// and events, so it isn't part of the public one, and is served on an endpoint of its own which mustn't be exposed.
+// The admin services are reached through connections of their own, as they are served on internal endpoints too.
+func newHandlers(ctx context.Context, racingConn, racingAdminConn, sportsConn, sportsAdminConn *grpc.ClientConn, origins []string, nextToGoWindow time.Duration) (*http.ServeMux, *http.ServeMux, error) {
+	mux := newGatewayMux()
+	if err := racing.RegisterRacingHandler(ctx, mux, racingConn); err != nil {
+		return nil, nil, err
+	}
+	if err := sports.RegisterSportsHandler(ctx, mux, sportsConn); err != nil {
+		return nil, nil, err
+	}
+
+	// Streams are bridged to Server-Sent Events and WebSockets, as browsers can't consume the gateway streams
+	handler := http.NewServeMux()
+	handler.Handle("/v1/stream/races", newStreamHandler(watchRaces(racing.NewRacingClient(racingConn)), origins))
+	handler.Handle("/v1/stream/events", newStreamHandler(watchEvents(sports.NewSportsClient(sportsConn)), origins))
+	// Next to go merges racing and sports, so it isn't part of either service
+	handler.Handle("/v1/next-to-go", newNextToGoHandler(racing.NewRacingClient(racingConn), sports.NewSportsClient(sportsConn), nextToGoWindow))
+	handler.Handle("/", maxBytesHandler(readMaskHandler(mux)))
+
+	adminMux := newGatewayMux()
+	if err := racing.RegisterRacingAdminHandler(ctx, adminMux, racingAdminConn); err != nil {
+		return nil, nil, err
+	}
+	if err := sports.RegisterSportsAdminHandler(ctx, adminMux, sportsAdminConn); err != nil {
+		return nil, nil, err
+	}
+
+	adminHandler := http.NewServeMux()
+	adminHandler.Handle("/", maxBytesHandler(ifMatchHandler(adminMux)))
+
+	return handler, adminHandler, nil
+}
+
+// serveAdmin serves the admin API on the admin endpoint in the background.
+func serveAdmin(handler http.Handler) error {
+	conn, err := net.Listen("tcp", *adminEndpoint)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := http.Serve(conn, handler); err != nil {
+			log.Printf("failed serving admin API: %s\n", err)
+		}
+	}()
+
+	log.Printf("admin API listening on: %s\n", *adminEndpoint)
+
+	return nil
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// dialClosed returns a connection to an endpoint nothing listens on, so the RPCs of the routes fail as unavailable.
+func dialClosed(t *testing.T) *grpc.ClientConn {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	listener.Close()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func TestNewHandlers_Admin(t *testing.T) {
+	handler, adminHandler, err := newHandlers(context.Background(), dialClosed(t), dialClosed(t), dialClosed(t), dialClosed(t), nil, defaultNextToGoWindow)
+	if err != nil {
+		t.Fatalf("failed to create handlers: %v", err)
+	}
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/v1/admin/race/1/status"},
+		{http.MethodPost, "/v1/admin/race/1/result"},
+		{http.MethodPost, "/v1/admin/prices"},
+		{http.MethodPost, "/v1/admin/races"},
+		{http.MethodPatch, "/v1/admin/race/1"},
+		{http.MethodDelete, "/v1/admin/race/1"},
+		{http.MethodPost, "/v1/admin/racing/time-travel"},
+		{http.MethodPost, "/v1/admin/events"},
+		{http.MethodPatch, "/v1/admin/event/1"},
+		{http.MethodDelete, "/v1/admin/event/1"},
+		{http.MethodPost, "/v1/admin/event/1/visibility"},
+		{http.MethodPost, "/v1/admin/sports/time-travel"},
+	}
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			// The admin routes aren't part of the public API, whatever the credentials of the request
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(route.method, route.path, strings.NewReader(`{"version": 1}`)))
+			assert.Equal(t, http.StatusNotFound, w.Code)
+
+			// The admin API calls the services, which aren't running
+			w = httptest.NewRecorder()
+			adminHandler.ServeHTTP(w, httptest.NewRequest(route.method, route.path, strings.NewReader(`{"version": 1}`)))
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		})
+	}
+}
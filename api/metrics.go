@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/metadata"
+)
+
+// httpMetrics records how many HTTP requests were served and how long they took, by route, method and status code.
+type httpMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func newHTTPMetrics(registerer prometheus.Registerer) *httpMetrics {
+	m := &httpMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests served, by status code.",
+		}, []string{"route", "method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time taken to serve the HTTP requests, which is the lifetime of the stream for streams.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+	}
+	registerer.MustRegister(m.requests, m.duration)
+
+	return m
+}
+
+// routeKey is the context key of the route of a request.
+type routeKey struct{}
+
+// handler returns a handler serving the requests with mux and recording their metrics. Requests are labelled with
+// the pattern of mux they matched, e.g. /v1/next-to-go, or with the RPC the gateway forwarded them to, e.g.
+// /racing.Racing/GetRace, so the ids in paths don't make labels of their own.
+func (m *httpMetrics) handler(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		_, pattern := mux.Handler(r)
+		route := &pattern
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), routeKey{}, route)))
+
+		m.requests.WithLabelValues(*route, r.Method, strconv.Itoa(recorder.status)).Inc()
+		m.duration.WithLabelValues(*route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routeRPCMethod is a metadata annotator of the gateway, which labels the metrics of its requests with the RPC they
+// are forwarded to. It doesn't add any metadata.
+func routeRPCMethod(ctx context.Context, r *http.Request) metadata.MD {
+	if method, ok := runtime.RPCMethod(ctx); ok {
+		if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+			*route = method
+		}
+	}
+
+	return nil
+}
+
+// statusRecorder records the status written to a response. Streams need it to flush and hijack the connection
+// like the response it wraps, and to set its deadlines through http.ResponseController.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap returns the response it wraps, for http.ResponseController.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response can't be hijacked")
+	}
+
+	// Hijacked connections are switched to WebSockets, whose handshake isn't written through the response
+	w.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// serveMetrics serves the metrics of the registry on /metrics of the metrics endpoint, in the background.
+func serveMetrics(registry *prometheus.Registry) error {
+	conn, err := net.Listen("tcp", *metricsEndpoint)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	go func() {
+		if err := http.Serve(conn, mux); err != nil {
+			log.Printf("failed serving metrics: %s\n", err)
+		}
+	}()
+
+	log.Printf("metrics listening on: %s\n", *metricsEndpoint)
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// defaultNextToGoCount is the number of items returned when no count is requested.
+	defaultNextToGoCount = 10
+	// maxNextToGoCount is the largest number of items that can be returned at once.
+	maxNextToGoCount = 100
+	// nextToGoTimeout is how long each backend has to answer, so a slow one can't hold up the other.
+	nextToGoTimeout = 2 * time.Second
+	// defaultNextToGoWindow is how far ahead events are looked for by default, as they are filtered by their start
+	// time.
+	defaultNextToGoWindow = 7 * 24 * time.Hour
+)
+
+const (
+	nextToGoRace  = "RACE"
+	nextToGoEvent = "EVENT"
+)
+
+// nextToGoItem is a race or an event, telling which one it is in type.
+type nextToGoItem struct {
+	Type                string          `json:"type"`
+	AdvertisedStartTime string          `json:"advertisedStartTime"`
+	Race                json.RawMessage `json:"race,omitempty"`
+	Event               json.RawMessage `json:"event,omitempty"`
+
+	startTime time.Time
+	id        int64
+}
+
+// nextToGoResponse has the items starting next across racing and sports. Warnings explain why items
+// may be missing, e.g. when one of the backends is down.
+type nextToGoResponse struct {
+	Items    []*nextToGoItem `json:"items"`
+	Warnings []string        `json:"warnings,omitempty"`
+}
+
+// nextToGoHandler returns the next open races and events, merged by advertised start time,
+// e.g. /v1/next-to-go?count=5
+type nextToGoHandler struct {
+	racingClient racing.RacingClient
+	sportsClient sports.SportsClient
+	marshaler    runtime.Marshaler
+	// eventWindowMinutes is how far ahead events are looked for.
+	eventWindowMinutes int32
+}
+
+// newNextToGoHandler creates a nextToGoHandler marshalling races and events the same way as the gateway. Events are
+// looked for up to eventWindow ahead, which is rounded down to minutes.
+func newNextToGoHandler(racingClient racing.RacingClient, sportsClient sports.SportsClient, eventWindow time.Duration) *nextToGoHandler {
+	return &nextToGoHandler{
+		racingClient: racingClient,
+		sportsClient: sportsClient,
+		marshaler: &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{EmitUnpopulated: true},
+		},
+		eventWindowMinutes: int32(eventWindow / time.Minute),
+	}
+}
+
+func (h *nextToGoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeStatus(w, http.StatusMethodNotAllowed, codes.Unimplemented, "method not allowed")
+		return
+	}
+
+	count := defaultNextToGoCount
+	if value := r.URL.Query().Get("count"); value != "" {
+		var err error
+		if count, err = strconv.Atoi(value); err != nil || count < 1 {
+			writeStatus(w, http.StatusBadRequest, codes.InvalidArgument, "count must be a positive number")
+			return
+		}
+		if count > maxNextToGoCount {
+			count = maxNextToGoCount
+		}
+	}
+
+	var (
+		wg                  sync.WaitGroup
+		races               []*racing.Race
+		events              []*sports.Event
+		racesErr, eventsErr error
+	)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		races, racesErr = h.nextRaces(r.Context(), count)
+	}()
+	go func() {
+		defer wg.Done()
+		events, eventsErr = h.nextEvents(r.Context(), count)
+	}()
+	wg.Wait()
+
+	if racesErr != nil && eventsErr != nil {
+		writeStatus(w, http.StatusServiceUnavailable, codes.Unavailable, "racing and sports are unavailable")
+		return
+	}
+
+	response := &nextToGoResponse{Items: []*nextToGoItem{}}
+	if racesErr != nil {
+		response.Warnings = append(response.Warnings, "races are missing, racing is unavailable: "+status.Convert(racesErr).Message())
+	}
+	if eventsErr != nil {
+		response.Warnings = append(response.Warnings, "events are missing, sports is unavailable: "+status.Convert(eventsErr).Message())
+	}
+
+	items, err := h.merge(races, events, count)
+	if err != nil {
+		writeStatus(w, http.StatusInternalServerError, codes.Internal, err.Error())
+		return
+	}
+	response.Items = append(response.Items, items...)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// nextRaces returns the first open races by advertised start time. Their status is kept by racing,
+// so races still open after their advertised start time are included.
+func (h *nextToGoHandler) nextRaces(ctx context.Context, count int) ([]*racing.Race, error) {
+	ctx, cancel := context.WithTimeout(ctx, nextToGoTimeout)
+	defer cancel()
+
+	response, err := h.racingClient.ListRaces(ctx, &racing.ListRacesRequest{
+		Filter: &racing.ListRacesRequestFilter{
+			VisibilityStatus: racing.VisibilityStatus_VISIBLE,
+			Statuses:         []racing.RaceStatus{racing.RaceStatus_OPEN},
+		},
+		OrderBy:  []*racing.ListRacesRequestOrderBy{{FieldName: "advertisedStartTime", Direction: racing.OrderByDirection_ASC}},
+		PageSize: int32(count),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Races, nil
+}
+
+// nextEvents returns the first open events by advertised start time. Events are open until they start, so the ones
+// starting from now are listed, as told by the clock of sports.
+func (h *nextToGoHandler) nextEvents(ctx context.Context, count int) ([]*sports.Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, nextToGoTimeout)
+	defer cancel()
+
+	response, err := h.sportsClient.ListEvents(ctx, &sports.ListEventsRequest{
+		Filter: &sports.ListEventsRequestFilter{
+			VisibilityStatus:    sports.VisibilityStatus_VISIBLE,
+			StartsWithinMinutes: h.eventWindowMinutes,
+		},
+		OrderBy:  []*sports.ListEventsRequestOrderBy{{FieldName: "advertisedStartTime", Direction: sports.OrderByDirection_ASC}},
+		PageSize: int32(count),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Events, nil
+}
+
+// merge returns the first count races and events by advertised start time. Races go first when they start
+// at the same time as an event, and items of the same kind keep their id order, so the order is stable.
+func (h *nextToGoHandler) merge(races []*racing.Race, events []*sports.Event, count int) ([]*nextToGoItem, error) {
+	items := make([]*nextToGoItem, 0, len(races)+len(events))
+
+	for _, race := range races {
+		item, err := h.newItem(nextToGoRace, race.Id, race.AdvertisedStartTime, race)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	for _, event := range events {
+		item, err := h.newItem(nextToGoEvent, event.Id, event.AdvertisedStartTime, event)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].startTime.Equal(items[j].startTime) {
+			return items[i].startTime.Before(items[j].startTime)
+		}
+		if items[i].Type != items[j].Type {
+			return items[i].Type == nextToGoRace
+		}
+		return items[i].id < items[j].id
+	})
+
+	if len(items) > count {
+		items = items[:count]
+	}
+
+	return items, nil
+}
+
+// newItem marshals a race or an event into a nextToGoItem.
+func (h *nextToGoHandler) newItem(itemType string, id int64, advertisedStartTime *timestamppb.Timestamp, msg proto.Message) (*nextToGoItem, error) {
+	data, err := h.marshaler.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed marshalling %s %d: %w", itemType, id, err)
+	}
+
+	item := &nextToGoItem{
+		Type:                itemType,
+		AdvertisedStartTime: advertisedStartTime.AsTime().UTC().Format(time.RFC3339),
+		startTime:           advertisedStartTime.AsTime(),
+		id:                  id,
+	}
+
+	if itemType == nextToGoRace {
+		item.Race = data
+	} else {
+		item.Event = data
+	}
+
+	return item, nil
+}
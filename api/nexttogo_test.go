@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeRacingClient lists its races, or fails with err, keeping the requests it was sent.
+type fakeRacingClient struct {
+	racing.RacingClient
+	races    []*racing.Race
+	err      error
+	requests []*racing.ListRacesRequest
+}
+
+func (c *fakeRacingClient) ListRaces(ctx context.Context, in *racing.ListRacesRequest, opts ...grpc.CallOption) (*racing.ListRacesResponse, error) {
+	c.requests = append(c.requests, in)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &racing.ListRacesResponse{Races: c.races}, nil
+}
+
+// fakeSportsClient lists its events, or fails with err, keeping the requests it was sent.
+type fakeSportsClient struct {
+	sports.SportsClient
+	events   []*sports.Event
+	err      error
+	requests []*sports.ListEventsRequest
+}
+
+func (c *fakeSportsClient) ListEvents(ctx context.Context, in *sports.ListEventsRequest, opts ...grpc.CallOption) (*sports.ListEventsResponse, error) {
+	c.requests = append(c.requests, in)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &sports.ListEventsResponse{Events: c.events, NextPageToken: "more"}, nil
+}
+
+// startingIn returns the start time of an item starting the given minutes from the test time.
+func startingIn(minutes int) *timestamppb.Timestamp {
+	return timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC).Add(time.Duration(minutes) * time.Minute))
+}
+
+func getNextToGo(t *testing.T, handler http.Handler, url string) (int, *nextToGoResponse) {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+
+	var response nextToGoResponse
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+
+	return w.Code, &response
+}
+
+// itemIDs returns the type and id of each item, in order.
+func itemIDs(items []*nextToGoItem) []string {
+	var ids []string
+	for _, item := range items {
+		var entity struct {
+			ID string `json:"id"`
+		}
+		if item.Race != nil {
+			json.Unmarshal(item.Race, &entity)
+		} else {
+			json.Unmarshal(item.Event, &entity)
+		}
+		ids = append(ids, item.Type+" "+entity.ID)
+	}
+	return ids
+}
+
+func TestNextToGoHandler(t *testing.T) {
+	racingClient := &fakeRacingClient{races: []*racing.Race{
+		{Id: 1, AdvertisedStartTime: startingIn(5)},
+		{Id: 2, AdvertisedStartTime: startingIn(10)},
+		{Id: 3, AdvertisedStartTime: startingIn(30)},
+	}}
+	sportsClient := &fakeSportsClient{events: []*sports.Event{
+		{Id: 7, AdvertisedStartTime: startingIn(1)},
+		{Id: 8, AdvertisedStartTime: startingIn(10)},
+		{Id: 9, AdvertisedStartTime: startingIn(20)},
+	}}
+	handler := newNextToGoHandler(racingClient, sportsClient, 2*time.Hour)
+
+	code, response := getNextToGo(t, handler, "/v1/next-to-go?count=4")
+
+	// Items are merged by start time, races going first when they start along with an event
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"EVENT 7", "RACE 1", "RACE 2", "EVENT 8"}, itemIDs(response.Items))
+	assert.Empty(t, response.Warnings)
+
+	// A single page of count items is read from each service, filtered by the services
+	if assert.Len(t, racingClient.requests, 1) {
+		assert.Equal(t, int32(4), racingClient.requests[0].PageSize)
+		assert.Equal(t, []racing.RaceStatus{racing.RaceStatus_OPEN}, racingClient.requests[0].Filter.Statuses)
+	}
+	if assert.Len(t, sportsClient.requests, 1) {
+		assert.Equal(t, int32(4), sportsClient.requests[0].PageSize)
+		assert.Equal(t, int32(120), sportsClient.requests[0].Filter.StartsWithinMinutes)
+	}
+}
+
+func TestNextToGoHandler_Count(t *testing.T) {
+	testCases := []struct {
+		name             string
+		url              string
+		expectedCode     int
+		expectedPageSize int32
+	}{
+		{name: "Default", url: "/v1/next-to-go", expectedCode: http.StatusOK, expectedPageSize: defaultNextToGoCount},
+		{name: "Limited", url: "/v1/next-to-go?count=1000", expectedCode: http.StatusOK, expectedPageSize: maxNextToGoCount},
+		{name: "Invalid", url: "/v1/next-to-go?count=0", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			racingClient := &fakeRacingClient{}
+			handler := newNextToGoHandler(racingClient, &fakeSportsClient{}, defaultNextToGoWindow)
+
+			code, _ := getNextToGo(t, handler, tc.url)
+
+			assert.Equal(t, tc.expectedCode, code)
+			if tc.expectedCode == http.StatusOK && assert.Len(t, racingClient.requests, 1) {
+				assert.Equal(t, tc.expectedPageSize, racingClient.requests[0].PageSize)
+			}
+		})
+	}
+}
+
+func TestNextToGoHandler_Unavailable(t *testing.T) {
+	races := []*racing.Race{{Id: 1, AdvertisedStartTime: startingIn(5)}}
+	events := []*sports.Event{{Id: 7, AdvertisedStartTime: startingIn(1)}}
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+
+	t.Run("SportsDown", func(t *testing.T) {
+		handler := newNextToGoHandler(&fakeRacingClient{races: races}, &fakeSportsClient{err: unavailable}, defaultNextToGoWindow)
+
+		code, response := getNextToGo(t, handler, "/v1/next-to-go")
+
+		// The races are still returned, with a warning the events are missing
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"RACE 1"}, itemIDs(response.Items))
+		if assert.Len(t, response.Warnings, 1) {
+			assert.Contains(t, response.Warnings[0], "events are missing")
+		}
+	})
+
+	t.Run("RacingDown", func(t *testing.T) {
+		handler := newNextToGoHandler(&fakeRacingClient{err: unavailable}, &fakeSportsClient{events: events}, defaultNextToGoWindow)
+
+		code, response := getNextToGo(t, handler, "/v1/next-to-go")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"EVENT 7"}, itemIDs(response.Items))
+		if assert.Len(t, response.Warnings, 1) {
+			assert.Contains(t, response.Warnings[0], "races are missing")
+		}
+	})
+
+	t.Run("BothDown", func(t *testing.T) {
+		handler := newNextToGoHandler(&fakeRacingClient{err: unavailable}, &fakeSportsClient{err: unavailable}, defaultNextToGoWindow)
+
+		code, _ := getNextToGo(t, handler, "/v1/next-to-go")
+
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+	})
+}
+
+func TestNextToGoHandler_Errors(t *testing.T) {
+	handler := newNextToGoHandler(&fakeRacingClient{}, &fakeSportsClient{}, defaultNextToGoWindow)
+
+	testCases := []struct {
+		name         string
+		method       string
+		url          string
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "MethodNotAllowed", method: http.MethodPost, url: "/v1/next-to-go", expectedCode: http.StatusMethodNotAllowed, expectedBody: `{"code":12,"message":"method not allowed"}`},
+		{name: "InvalidCount", method: http.MethodGet, url: "/v1/next-to-go?count=-1", expectedCode: http.StatusBadRequest, expectedBody: `{"code":3,"message":"count must be a positive number"}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tc.method, tc.url, nil))
+
+			// Errors have the same body as the ones of the gateway
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			assert.JSONEq(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+)
+
+// maxRequestBodyBytes is the largest body read by the gateway handlers, so a client can't exhaust the memory
+// of the gateway with a single request.
+const maxRequestBodyBytes = 1 << 20
+
+// readMaskRoutes are the routes taking a read mask: ListRaces, GetRace, ListEvents and GetEvent.
+var readMaskRoutes = []route{
+	{method: http.MethodPost, path: regexp.MustCompile(`^/v1/list-races$`)},
+	{method: http.MethodGet, path: regexp.MustCompile(`^/v1/race/[^/]+$`)},
+	{method: http.MethodPost, path: regexp.MustCompile(`^/v1/list-events$`)},
+	{method: http.MethodGet, path: regexp.MustCompile(`^/v1/event/[^/]+$`)},
+}
+
+// maskedFields are the fields of the responses with the races and events a read mask applies to.
+var maskedFields = []string{"race", "races", "event", "events"}
+
+// readMaskHandler leaves the fields that weren't requested out of the races and events of a response, when the
+// request has a read mask, either as the read_mask query parameter or as readMask in the request body. The gateway
+// returns every field, including the ones left out by the read mask, while the requested fields must be returned
+// even when they are at their default value, e.g. visible when it is false. Only the readMaskRoutes are buffered
+// and masked, the requests of the other routes are passed on as they are.
+func readMaskHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !matchRoute(readMaskRoutes, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		paths, err := readMaskPaths(w, r)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeStatus(w, http.StatusRequestEntityTooLarge, codes.InvalidArgument, "request body must be at most "+strconv.Itoa(maxRequestBodyBytes)+" bytes")
+				return
+			}
+			writeStatus(w, http.StatusBadRequest, codes.InvalidArgument, err.Error())
+			return
+		}
+
+		if len(paths) == 0 || paths["*"] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		body := recorder.body.Bytes()
+		if recorder.status == http.StatusOK {
+			if masked, err := maskResponse(body, paths); err == nil {
+				body = masked
+			}
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(recorder.status)
+		w.Write(body)
+	})
+}
+
+// maxBytesHandler bounds the bodies of the requests to maxRequestBodyBytes without reading them, so the handlers
+// that don't read the bodies themselves are bounded too. The gateway rejects larger bodies as invalid.
+func maxBytesHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readMaskPaths returns the normalised paths of the read mask of a request, if it has one. The body is put back for
+// the gateway.
+func readMaskPaths(w http.ResponseWriter, r *http.Request) (map[string]bool, error) {
+	query := r.URL.Query()
+	masks := append(query["read_mask"], query["readMask"]...)
+
+	if r.Body != nil && r.Method != http.MethodGet {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Bodies that aren't objects are left for the gateway to reject
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err == nil {
+			for _, name := range []string{"readMask", "read_mask"} {
+				var mask string
+				if err := json.Unmarshal(fields[name], &mask); err == nil && mask != "" {
+					masks = append(masks, mask)
+				}
+			}
+		}
+	}
+
+	paths := make(map[string]bool)
+	for _, mask := range masks {
+		for _, path := range strings.Split(mask, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				paths[normaliseFieldName(path)] = true
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// normaliseFieldName matches the JSON names of the fields with the paths of a read mask in either case,
+// e.g. advertisedStartTime with advertised_start_time.
+func normaliseFieldName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// maskResponse leaves the races and events of a response with the fields in the read mask only.
+func maskResponse(body []byte, paths map[string]bool) ([]byte, error) {
+	return filterObject(body, func(name string, value json.RawMessage) (json.RawMessage, bool, error) {
+		for _, field := range maskedFields {
+			if name == field {
+				masked, err := maskValue(value, paths)
+				return masked, true, err
+			}
+		}
+		return value, true, nil
+	})
+}
+
+// maskValue leaves a race or an event, or each of a list of them, with the fields in the read mask only.
+func maskValue(value json.RawMessage, paths map[string]bool) (json.RawMessage, error) {
+	keep := func(name string, value json.RawMessage) (json.RawMessage, bool, error) {
+		return value, paths[normaliseFieldName(name)], nil
+	}
+
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return filterObject(value, keep)
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(value, &items); err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		masked, err := filterObject(item, keep)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = masked
+	}
+
+	return json.Marshal(items)
+}
+
+// filterObject rewrites the fields of a JSON object with filter, which also tells whether each field is kept.
+// The fields are kept in their order, the one of the proto messages.
+func filterObject(data []byte, filter func(name string, value json.RawMessage) (json.RawMessage, bool, error)) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, errors.New("not a JSON object")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		name, _ := token.(string)
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		value, keep, err := filter(name, value)
+		if err != nil {
+			return nil, err
+		}
+		if !keep {
+			continue
+		}
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// responseRecorder keeps the response of the gateway, so it can be masked before it is written.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	return r.body.Write(data)
+}
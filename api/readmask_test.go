@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// gatewayResponse is a response of the gateway, which has every field of the races, at their default value or not.
+const gatewayResponse = `{"races":[{"id":"1","meetingId":"5","name":"North Dakota foes","number":0,"visible":false},` +
+	`{"id":"2","meetingId":"5","name":"Rhode Island ghosts","number":3,"visible":true}],"nextPageToken":"abc"}`
+
+// gatewayHandler answers with the response, keeping the body of the request it was sent.
+type gatewayHandler struct {
+	status   int
+	response string
+	body     string
+}
+
+func (h *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	h.body = string(body)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(h.status)
+	w.Write([]byte(h.response))
+}
+
+func TestReadMaskHandler(t *testing.T) {
+	testCases := []struct {
+		name     string
+		method   string
+		url      string
+		body     string
+		expected string
+	}{
+		{
+			name:     "NoReadMask",
+			method:   http.MethodPost,
+			url:      "/v1/list-races",
+			body:     `{}`,
+			expected: gatewayResponse,
+		},
+		{
+			name:     "QueryReadMask",
+			method:   http.MethodGet,
+			url:      "/v1/race/1?read_mask=id,visible",
+			expected: `{"races":[{"id":"1","visible":false},{"id":"2","visible":true}],"nextPageToken":"abc"}`,
+		},
+		{
+			name:     "BodyReadMask",
+			method:   http.MethodPost,
+			url:      "/v1/list-races",
+			body:     `{"readMask": "meeting_id,number"}`,
+			expected: `{"races":[{"meetingId":"5","number":0},{"meetingId":"5","number":3}],"nextPageToken":"abc"}`,
+		},
+		{
+			name:     "EveryField",
+			method:   http.MethodGet,
+			url:      "/v1/race/1?read_mask=*",
+			expected: gatewayResponse,
+		},
+		{
+			name:     "OtherRoute",
+			method:   http.MethodGet,
+			url:      "/v1/races:batchGet?ids=1&ids=2&read_mask=id",
+			expected: gatewayResponse,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gateway := &gatewayHandler{status: http.StatusOK, response: gatewayResponse}
+
+			w := httptest.NewRecorder()
+			readMaskHandler(gateway).ServeHTTP(w, httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body)))
+
+			// Masked fields are returned even at their default value, the others are left out
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, tc.expected, w.Body.String())
+			// The body is still sent to the gateway
+			if tc.method != http.MethodGet {
+				assert.Equal(t, tc.body, gateway.body)
+			}
+		})
+	}
+}
+
+func TestReadMaskHandler_Error(t *testing.T) {
+	gateway := &gatewayHandler{status: http.StatusNotFound, response: `{"code":5,"message":"Race with ID 9 not found"}`}
+
+	w := httptest.NewRecorder()
+	readMaskHandler(gateway).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/race/9?read_mask=id", nil))
+
+	// Errors aren't masked
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, gateway.response, w.Body.String())
+}
+
+func TestReadMaskHandler_BodyTooLarge(t *testing.T) {
+	gateway := &gatewayHandler{status: http.StatusOK, response: gatewayResponse}
+
+	body := `{"readMask": "id", "search": "` + strings.Repeat("x", maxRequestBodyBytes) + `"}`
+	w := httptest.NewRecorder()
+	readMaskHandler(gateway).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/list-races", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Empty(t, gateway.body)
+}
+
+func TestMaxBytesHandler(t *testing.T) {
+	var readErr error
+	handler := maxBytesHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	// Bodies are bounded even when they aren't read until they reach the gateway
+	body := strings.Repeat("x", maxRequestBodyBytes+1)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/admin/prices", strings.NewReader(body)))
+
+	var tooLarge *http.MaxBytesError
+	assert.True(t, errors.As(readErr, &tooLarge))
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"git.neds.sh/matty/entain/api/proto/sports"
+	"github.com/gorilla/websocket"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// heartbeatInterval is how often an idle stream is kept alive, so proxies don't drop the connection.
+	heartbeatInterval = 15 * time.Second
+	// streamBufferSize is how many upstream messages are buffered for a client before the upstream
+	// stream stops being read, letting gRPC flow control push back on the service.
+	streamBufferSize = 16
+	// writeTimeout is how long a client has to accept a message before it is disconnected.
+	writeTimeout = 10 * time.Second
+)
+
+// streamOpener opens an upstream server-streaming RPC for the HTTP request.
+// It returns a function receiving the next message, which returns io.EOF once the stream is finished.
+type streamOpener func(ctx context.Context, r *http.Request) (func() (proto.Message, error), error)
+
+// streamMessage is a message, or the error that ended the stream, received from upstream.
+type streamMessage struct {
+	msg proto.Message
+	err error
+}
+
+// streamHandler bridges a server-streaming RPC to browsers. Requests asking for a WebSocket upgrade
+// get each message as a text frame, any other request gets them as Server-Sent Events.
+type streamHandler struct {
+	open      streamOpener
+	marshaler runtime.Marshaler
+	upgrader  websocket.Upgrader
+	// writeTimeout is how long a client has to accept a message before it is disconnected
+	writeTimeout time.Duration
+}
+
+// newStreamHandler creates a streamHandler marshalling messages the same way as the gateway. WebSockets can only
+// be opened by pages of the allowed origins, see checkOrigin.
+func newStreamHandler(open streamOpener, allowedOrigins []string) *streamHandler {
+	return &streamHandler{
+		open: open,
+		marshaler: &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{EmitUnpopulated: true},
+		},
+		upgrader:     websocket.Upgrader{CheckOrigin: checkOrigin(allowedOrigins)},
+		writeTimeout: writeTimeout,
+	}
+}
+
+// checkOrigin returns the policy of the origins allowed to open WebSockets, as browsers don't apply the
+// same-origin policy to them. Pages served by the gateway itself and the allowed origins are accepted, e.g.
+// https://example.com, or any origin for *. Requests without an Origin don't come from browsers, so they are
+// accepted too.
+func checkOrigin(allowedOrigins []string) func(r *http.Request) bool {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || allowed["*"] || allowed[strings.ToLower(origin)] {
+			return true
+		}
+
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
+
+func (h *streamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Cancelling the context closes the upstream stream, which happens as soon as the client goes away.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// WebSockets of origins that aren't allowed are rejected before the upstream stream is opened
+	if websocket.IsWebSocketUpgrade(r) && !h.upgrader.CheckOrigin(r) {
+		http.Error(w, "origin is not allowed", http.StatusForbidden)
+		return
+	}
+
+	recv, err := h.open(ctx, r)
+	if err != nil {
+		http.Error(w, status.Convert(err).Message(), runtime.HTTPStatusFromCode(status.Code(err)))
+		return
+	}
+
+	messages := receive(ctx, recv)
+
+	if websocket.IsWebSocketUpgrade(r) {
+		h.serveWebSocket(ctx, cancel, w, r, messages)
+	} else {
+		h.serveEvents(ctx, cancel, w, messages)
+	}
+}
+
+// receive reads the upstream stream into a buffered channel, which is closed once the stream finishes.
+// When the client is slower than the stream the buffer fills up and reading stops until there is room again.
+func receive(ctx context.Context, recv func() (proto.Message, error)) <-chan streamMessage {
+	messages := make(chan streamMessage, streamBufferSize)
+
+	go func() {
+		defer close(messages)
+
+		for {
+			msg, err := recv()
+
+			select {
+			case messages <- streamMessage{msg: msg, err: err}:
+			case <-ctx.Done():
+				return
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return messages
+}
+
+// serveEvents writes each message as a Server-Sent Event. Heartbeats are sent as comments, which EventSource ignores.
+// Clients that don't accept an event within the write timeout are disconnected, cancelling the upstream stream, so
+// a stalled client can't hold it open.
+func (h *streamHandler) serveEvents(ctx context.Context, cancel context.CancelFunc, w http.ResponseWriter, messages <-chan streamMessage) {
+	defer cancel()
+
+	controller := http.NewResponseController(w)
+	// The deadline is set on the connection, which is reused by the next requests once the stream ends
+	defer controller.SetWriteDeadline(time.Time{})
+
+	// write writes an event before the write timeout, returning false when the client didn't accept it
+	write := func(event string) bool {
+		if err := controller.SetWriteDeadline(time.Now().Add(h.writeTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return false
+		}
+
+		if _, err := io.WriteString(w, event); err != nil {
+			return false
+		}
+
+		return controller.Flush() == nil
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if !write("") {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if !write(": heartbeat\n\n") {
+				return
+			}
+		case message, ok := <-messages:
+			if !ok || message.err == io.EOF {
+				return
+			}
+
+			if message.err != nil {
+				// Let the client know why the stream ended, using the same body as the gateway errors
+				data, _ := h.marshaler.Marshal(status.Convert(message.err).Proto())
+				write(fmt.Sprintf("event: error\ndata: %s\n\n", data))
+				return
+			}
+
+			data, err := h.marshaler.Marshal(message.msg)
+			if err != nil {
+				return
+			}
+
+			if !write(fmt.Sprintf("data: %s\n\n", data)) {
+				return
+			}
+		}
+	}
+}
+
+// serveWebSocket writes each message as a text frame, pinging the client while the stream is idle.
+// Clients that stop answering pings or reading messages are disconnected.
+func (h *streamHandler) serveWebSocket(ctx context.Context, cancel context.CancelFunc, w http.ResponseWriter, r *http.Request, messages <-chan streamMessage) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade already replied to the client
+		return
+	}
+	defer conn.Close()
+
+	// Reading is needed to handle pongs and close frames, any error means the client is gone.
+	conn.SetReadDeadline(time.Now().Add(2 * heartbeatInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * heartbeatInterval))
+	})
+	go func() {
+		defer cancel()
+
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(h.writeTimeout)); err != nil {
+				return
+			}
+		case message, ok := <-messages:
+			if !ok || message.err == io.EOF {
+				closeWebSocket(conn, websocket.CloseNormalClosure, "")
+				return
+			}
+
+			if message.err != nil {
+				closeWebSocket(conn, websocket.CloseInternalServerErr, status.Convert(message.err).Message())
+				return
+			}
+
+			data, err := h.marshaler.Marshal(message.msg)
+			if err != nil {
+				return
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(h.writeTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// closeWebSocket sends a close frame, trimming the reason to what fits in a control frame.
+func closeWebSocket(conn *websocket.Conn, code int, reason string) {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeTimeout))
+}
+
+// watchRaces opens a WatchRaces stream, reading the filter from the query string,
+// e.g. /v1/stream/races?filter.visibility_status=VISIBLE&filter.meeting_ids=1
+func watchRaces(client racing.RacingClient) streamOpener {
+	return func(ctx context.Context, r *http.Request) (func() (proto.Message, error), error) {
+		in := &racing.WatchRacesRequest{}
+		if err := runtime.PopulateQueryParameters(in, r.URL.Query(), utilities.NewDoubleArray(nil)); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		stream, err := client.WatchRaces(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+
+		return func() (proto.Message, error) { return stream.Recv() }, nil
+	}
+}
+
+// watchEvents opens a WatchEvents stream, reading the filter from the query string,
+// e.g. /v1/stream/events?filter.visibility_status=VISIBLE
+func watchEvents(client sports.SportsClient) streamOpener {
+	return func(ctx context.Context, r *http.Request) (func() (proto.Message, error), error) {
+		in := &sports.WatchEventsRequest{}
+		if err := runtime.PopulateQueryParameters(in, r.URL.Query(), utilities.NewDoubleArray(nil)); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		stream, err := client.WatchEvents(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+
+		return func() (proto.Message, error) { return stream.Recv() }, nil
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"git.neds.sh/matty/entain/api/proto/racing"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeStream is an upstream stream sending the messages of the test, which tells the context it was opened with.
+type fakeStream struct {
+	messages chan proto.Message
+	opened   chan context.Context
+}
+
+func newFakeStream() *fakeStream {
+	return &fakeStream{messages: make(chan proto.Message), opened: make(chan context.Context, 2)}
+}
+
+func (f *fakeStream) open(ctx context.Context, r *http.Request) (func() (proto.Message, error), error) {
+	f.opened <- ctx
+
+	return func() (proto.Message, error) {
+		select {
+		case msg := <-f.messages:
+			return msg, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}, nil
+}
+
+// assertCancelled asserts the upstream stream is cancelled shortly.
+func assertCancelled(t *testing.T, ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Error("upstream stream wasn't cancelled")
+	}
+}
+
+func TestStreamHandler_Events(t *testing.T) {
+	stream := newFakeStream()
+	server := httptest.NewServer(newStreamHandler(stream.open, nil))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	defer response.Body.Close()
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	upstream := <-stream.opened
+	stream.messages <- &racing.Race{Id: 1, Name: "North Dakota foes"}
+
+	line, err := bufio.NewReader(response.Body).ReadString('\n')
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(line, "data: "))
+	assert.Contains(t, line, `"name":"North Dakota foes"`)
+
+	// The client going away cancels the upstream stream
+	cancel()
+	assertCancelled(t, upstream)
+}
+
+func TestStreamHandler_EventsStalled(t *testing.T) {
+	stream := newFakeStream()
+	handler := newStreamHandler(stream.open, nil)
+	handler.writeTimeout = 100 * time.Millisecond
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// The client opens the stream and never reads it, so the events pile up until they can't be written
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	assert.NoError(t, err)
+
+	upstream := <-stream.opened
+	go func() {
+		race := &racing.Race{Id: 1, Name: strings.Repeat("x", 1<<20)}
+		for {
+			select {
+			case stream.messages <- race:
+			case <-upstream.Done():
+				return
+			}
+		}
+	}()
+
+	// Stalled clients are disconnected, cancelling the upstream stream
+	assertCancelled(t, upstream)
+}
+
+func TestStreamHandler_WebSocket(t *testing.T) {
+	stream := newFakeStream()
+	server := httptest.NewServer(newStreamHandler(stream.open, nil))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to open WebSocket: %v", err)
+	}
+
+	upstream := <-stream.opened
+	stream.messages <- &racing.Race{Id: 1, Name: "North Dakota foes"}
+
+	messageType, data, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, websocket.TextMessage, messageType)
+	assert.Contains(t, string(data), `"name":"North Dakota foes"`)
+
+	// The client going away cancels the upstream stream
+	conn.Close()
+	assertCancelled(t, upstream)
+}
+
+func TestStreamHandler_WebSocketOrigin(t *testing.T) {
+	stream := newFakeStream()
+	server := httptest.NewServer(newStreamHandler(stream.open, []string{"https://allowed.example.com"}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://allowed.example.com"}})
+	if assert.NoError(t, err) {
+		conn.Close()
+	}
+
+	_, response, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://other.example.com"}})
+	assert.Error(t, err)
+	if assert.NotNil(t, response) {
+		assert.Equal(t, http.StatusForbidden, response.StatusCode)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	testCases := []struct {
+		name           string
+		allowedOrigins []string
+		origin         string
+		expected       bool
+	}{
+		{name: "NoOrigin", origin: "", expected: true},
+		{name: "SameOrigin", origin: "http://localhost:8000", expected: true},
+		{name: "OtherOrigin", origin: "https://other.example.com", expected: false},
+		{name: "AllowedOrigin", allowedOrigins: []string{"https://Allowed.example.com/"}, origin: "https://allowed.example.com", expected: true},
+		{name: "AnyOrigin", allowedOrigins: []string{"*"}, origin: "https://other.example.com", expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://localhost:8000/v1/stream/races", nil)
+			if tc.origin != "" {
+				r.Header.Set("Origin", tc.origin)
+			}
+
+			assert.Equal(t, tc.expected, checkOrigin(tc.allowedOrigins)(r))
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"git.neds.sh/matty/entain/common/tracing"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// traceHandler returns a handler tracing the requests served by handler, which are the ones of mux, each in a span
+// named after its method and the pattern of mux it matched, e.g. GET /v1/next-to-go. The RPCs the requests make are
+// children of their spans.
+func traceHandler(handler http.Handler, mux *http.ServeMux, provider trace.TracerProvider) http.Handler {
+	return otelhttp.NewHandler(handler, "api",
+		otelhttp.WithTracerProvider(provider),
+		otelhttp.WithPropagators(tracing.Propagator),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			_, pattern := mux.Handler(r)
+			return r.Method + " " + pattern
+		}),
+	)
+}
+
+// nameSpan is a metadata annotator of the gateway, which names the spans of its requests after the path pattern of
+// the RPC they are forwarded to, e.g. POST /v1/list-races, rather than after the pattern the gateway is served on.
+// It doesn't add any metadata, the trace context is added by the interceptors of the connections.
+func nameSpan(ctx context.Context, r *http.Request) metadata.MD {
+	if pattern, ok := runtime.HTTPPathPattern(ctx); ok {
+		span := trace.SpanFromContext(ctx)
+		span.SetName(r.Method + " " + pattern)
+		span.SetAttributes(semconv.HTTPRouteKey.String(pattern))
+	}
+
+	return nil
+}
+
+// traceDialOptions are the options of the connections to the services, tracing the RPCs in spans whose context is
+// passed on in the metadata of the RPCs.
+func traceDialOptions(provider trace.TracerProvider) []grpc.DialOption {
+	options := []otelgrpc.Option{otelgrpc.WithTracerProvider(provider), otelgrpc.WithPropagators(tracing.Propagator)}
+
+	return []grpc.DialOption{
+		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor(options...)),
+		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor(options...)),
+	}
+}
@@ -0,0 +1,101 @@
+// Package clock tells the services, repositories and seeding the time, so races and events can be tested at any time and the
+// time can be moved in builds with time travel.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the clock of the machine the service runs on.
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Fake is a clock that stands still until it is moved, for tests.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake returns a fake clock stopped at now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+// Set moves the clock to now.
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = now
+}
+
+// Add moves the clock forward by d, or back when d is negative.
+func (f *Fake) Add(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+}
+
+// Travel is a clock running at the pace of another one, which can be moved to another time and keeps running from
+// there. It is the clock of builds with time travel, so QA can jump to the start of a race.
+type Travel struct {
+	base Clock
+
+	mu     sync.RWMutex
+	offset time.Duration
+}
+
+// NewTravel returns a clock telling the time of base until it is moved.
+func NewTravel(base Clock) *Travel {
+	return &Travel{base: base}
+}
+
+func (t *Travel) Now() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.base.Now().Add(t.offset)
+}
+
+// Set moves the clock to now.
+func (t *Travel) Set(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.offset = now.Sub(t.base.Now())
+}
+
+// Add moves the clock forward by d, or back when d is negative.
+func (t *Travel) Add(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.offset += d
+}
+
+// Reset moves the clock back to the time of its base.
+func (t *Travel) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.offset = 0
+}
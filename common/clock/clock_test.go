@@ -0,0 +1,47 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2023, 7, 16, 14, 30, 0, 0, time.UTC)
+	fake := NewFake(start)
+
+	assert.Equal(t, start, fake.Now())
+	assert.Equal(t, start, fake.Now(), "a fake clock only moves when it is told to")
+
+	fake.Add(time.Hour)
+	assert.Equal(t, start.Add(time.Hour), fake.Now())
+
+	fake.Add(-2 * time.Hour)
+	assert.Equal(t, start.Add(-time.Hour), fake.Now())
+
+	fake.Set(start)
+	assert.Equal(t, start, fake.Now())
+}
+
+func TestTravel(t *testing.T) {
+	start := time.Date(2023, 7, 16, 14, 30, 0, 0, time.UTC)
+	base := NewFake(start)
+	travel := NewTravel(base)
+
+	assert.Equal(t, start, travel.Now())
+
+	jump := time.Date(2023, 7, 17, 9, 0, 0, 0, time.UTC)
+	travel.Set(jump)
+	assert.Equal(t, jump, travel.Now())
+
+	// It keeps running at the pace of its base from where it was moved to
+	base.Add(time.Minute)
+	assert.Equal(t, jump.Add(time.Minute), travel.Now())
+
+	travel.Add(-time.Hour)
+	assert.Equal(t, jump.Add(time.Minute-time.Hour), travel.Now())
+
+	travel.Reset()
+	assert.Equal(t, base.Now(), travel.Now())
+}
@@ -0,0 +1,105 @@
+// Package dialect generates the parts of the SQL of the repositories of the services that differ between databases.
+package dialect
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Dialect is the flavour of SQL spoken by a database.
+type Dialect int
+
+const (
+	// SQLite is spoken by github.com/mattn/go-sqlite3, it is the default.
+	SQLite Dialect = iota
+	// Postgres is spoken by github.com/lib/pq.
+	Postgres
+)
+
+// drivers are the names the database/sql drivers of the dialects are registered with.
+var drivers = []string{SQLite: "sqlite3", Postgres: "postgres"}
+
+// ForDriver returns the dialect spoken through a database/sql driver.
+func ForDriver(driver string) (Dialect, error) {
+	for dialect, name := range drivers {
+		if name == driver {
+			return Dialect(dialect), nil
+		}
+	}
+
+	return SQLite, fmt.Errorf("database driver %q is not supported, it must be one of: %s", driver, strings.Join(drivers, ", "))
+}
+
+// Driver returns the name of the database/sql driver of the dialect.
+func (d Dialect) Driver() string {
+	return drivers[d]
+}
+
+func (d Dialect) String() string {
+	return d.Driver()
+}
+
+// Rebind rewrites the ? placeholders of a query into the ones of the dialect, $1, $2 and so on for Postgres.
+// Question marks in quoted strings and identifiers are left as they are.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var (
+		rebound strings.Builder
+		n       int
+		quote   rune
+	)
+
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			rebound.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		rebound.WriteRune(c)
+	}
+
+	return rebound.String()
+}
+
+// In returns the condition matching an expression against any of the values, which can't be empty, along with
+// the args to bind to it. SQLite binds each value to a placeholder of its own, Postgres binds them as one array,
+// so the statement is the same however many values there are.
+func (d Dialect) In(expression string, values []interface{}) (string, []interface{}) {
+	if d == Postgres {
+		return expression + " = ANY(?)", []interface{}{pq.Array(values)}
+	}
+
+	return expression + " IN (?" + strings.Repeat(",?", len(values)-1) + ")", values
+}
+
+// Like returns the operator matching a LIKE pattern ignoring case. SQLite ignores case with LIKE already.
+func (d Dialect) Like() string {
+	if d == Postgres {
+		return "ILIKE"
+	}
+
+	return "LIKE"
+}
+
+// SyncID returns the statement moving the id sequence of a table past its rows, which is needed after rows are
+// inserted with their ids. It is empty when ids are always generated after the existing rows, as in SQLite.
+func (d Dialect) SyncID(table string) string {
+	if d == Postgres {
+		return "SELECT setval(pg_get_serial_sequence('" + table + "', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM " + table
+	}
+
+	return ""
+}
@@ -0,0 +1,94 @@
+package dialect
+
+import (
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestForDriver(t *testing.T) {
+	testCases := []struct {
+		driver          string
+		expectedDialect Dialect
+		expectedErr     bool
+	}{
+		{driver: "sqlite3", expectedDialect: SQLite},
+		{driver: "postgres", expectedDialect: Postgres},
+		{driver: "mysql", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.driver, func(t *testing.T) {
+			dialect, err := ForDriver(tc.driver)
+
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedDialect, dialect)
+			assert.Equal(t, tc.driver, dialect.Driver())
+		})
+	}
+}
+
+func TestDialect_Rebind(t *testing.T) {
+	testCases := []struct {
+		name          string
+		dialect       Dialect
+		query         string
+		expectedQuery string
+	}{
+		{
+			name:          "SQLite",
+			dialect:       SQLite,
+			query:         "SELECT id FROM races WHERE meeting_id = ? AND name LIKE ?",
+			expectedQuery: "SELECT id FROM races WHERE meeting_id = ? AND name LIKE ?",
+		},
+		{
+			name:          "Postgres",
+			dialect:       Postgres,
+			query:         "SELECT id FROM races WHERE meeting_id = ? AND name LIKE ? LIMIT ?",
+			expectedQuery: "SELECT id FROM races WHERE meeting_id = $1 AND name LIKE $2 LIMIT $3",
+		},
+		{
+			name:          "PostgresQuoted",
+			dialect:       Postgres,
+			query:         `SELECT '?', "?" FROM races WHERE name = 'it''s?' AND id = ?`,
+			expectedQuery: `SELECT '?', "?" FROM races WHERE name = 'it''s?' AND id = $1`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedQuery, tc.dialect.Rebind(tc.query))
+		})
+	}
+}
+
+func TestDialect_In(t *testing.T) {
+	values := []interface{}{int64(1), int64(3)}
+
+	clause, args := SQLite.In("meeting_id", values)
+	assert.Equal(t, "meeting_id IN (?,?)", clause)
+	assert.Equal(t, values, args)
+
+	clause, args = Postgres.In("meeting_id", values)
+	assert.Equal(t, "meeting_id = ANY(?)", clause)
+	assert.Equal(t, []interface{}{pq.Array(values)}, args)
+
+	// Arrays are bound in the text format of Postgres
+	array, err := args[0].(pq.GenericArray).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "{1,3}", array)
+
+	_, args = Postgres.In("status", []interface{}{"OPEN", "CLOSED"})
+	array, err = args[0].(pq.GenericArray).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"OPEN","CLOSED"}`, array)
+}
+
+func TestDialect_SyncID(t *testing.T) {
+	assert.Empty(t, SQLite.SyncID("races"))
+	assert.Equal(t, "SELECT setval(pg_get_serial_sequence('races', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM races", Postgres.SyncID("races"))
+}
@@ -0,0 +1,295 @@
+// Package instrument calls hooks around the SQL queries of the services, e.g. to measure them.
+package instrument
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+)
+
+// QueryHook is called before each query run on a database opened with OpenDB, and the function it returns once the
+// query is done, with the number of rows it read or changed and its error. Queries returning rows are done once their
+// rows are closed, so their time includes reading the rows.
+type QueryHook func(ctx context.Context, query string) func(rows int64, err error)
+
+// OpenDB opens a database like sql.Open, with the hooks called around each query the repositories, migrations and
+// seeding run on it, e.g. to measure them.
+func OpenDB(driverName, dsn string, hooks ...QueryHook) (*sql.DB, error) {
+	// sql.Open only looks up the driver, the connections are opened by the connector
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	if err := db.Close(); err != nil {
+		return nil, err
+	}
+
+	var connector driver.Connector = dsnConnector{driver: d, dsn: dsn}
+	if driverContext, ok := d.(driver.DriverContext); ok {
+		if connector, err = driverContext.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+
+	return sql.OpenDB(&hookedConnector{Connector: connector, hooks: hooks}), nil
+}
+
+// dsnConnector opens connections of drivers that don't have a connector of their own.
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// hookedConnector opens connections calling the hooks around their queries.
+type hookedConnector struct {
+	driver.Connector
+	hooks []QueryHook
+}
+
+func (c *hookedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hookedConn{Conn: conn, hooks: c.hooks}, nil
+}
+
+// startQuery calls the hooks before a query, returning the function to call once it is done.
+func startQuery(ctx context.Context, hooks []QueryHook, query string) func(rows int64, err error) {
+	dones := make([]func(rows int64, err error), len(hooks))
+	for i, hook := range hooks {
+		dones[i] = hook(ctx, query)
+	}
+
+	return func(rows int64, err error) {
+		for _, done := range dones {
+			done(rows, err)
+		}
+	}
+}
+
+// hookedConn is a connection calling the hooks around its queries. Queries are run by the connection itself when its
+// driver supports it, and through prepared statements otherwise, so either way is hooked.
+type hookedConn struct {
+	driver.Conn
+	hooks []QueryHook
+}
+
+func (c *hookedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	done := startQuery(ctx, c.hooks, query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		done(0, err)
+		return nil, err
+	}
+
+	return &hookedRows{Rows: rows, done: done}, nil
+}
+
+func (c *hookedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	done := startQuery(ctx, c.hooks, query)
+	result, err := execer.ExecContext(ctx, query, args)
+	done(rowsAffected(result, err), err)
+
+	return result, err
+}
+
+func (c *hookedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &hookedStmt{Stmt: stmt, query: query, hooks: c.hooks}, nil
+}
+
+func (c *hookedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *hookedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+
+	return c.Conn.Begin()
+}
+
+func (c *hookedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (c *hookedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *hookedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+
+	return true
+}
+
+func (c *hookedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	// The arguments are converted by database/sql then
+	return driver.ErrSkip
+}
+
+// hookedStmt is a prepared statement calling the hooks every time it is run.
+type hookedStmt struct {
+	driver.Stmt
+	query string
+	hooks []QueryHook
+}
+
+func (s *hookedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	done := startQuery(ctx, s.hooks, s.query)
+
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+	if err != nil {
+		done(0, err)
+		return nil, err
+	}
+
+	return &hookedRows{Rows: rows, done: done}, nil
+}
+
+func (s *hookedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	done := startQuery(ctx, s.hooks, s.query)
+
+	var (
+		result driver.Result
+		err    error
+	)
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			result, err = s.Stmt.Exec(values)
+		}
+	}
+	done(rowsAffected(result, err), err)
+
+	return result, err
+}
+
+func (s *hookedStmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	return driver.ErrSkip
+}
+
+// hookedRows counts the rows read, and tells the hooks once they are closed.
+type hookedRows struct {
+	driver.Rows
+	done  func(rows int64, err error)
+	count int64
+	err   error
+}
+
+func (r *hookedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	switch err {
+	case nil:
+		r.count++
+	case io.EOF:
+	default:
+		r.err = err
+	}
+
+	return err
+}
+
+func (r *hookedRows) Close() error {
+	err := r.Rows.Close()
+	if r.err == nil {
+		r.err = err
+	}
+	r.done(r.count, r.err)
+
+	return err
+}
+
+// rowsAffected returns the number of rows changed by a statement, 0 when it failed or the driver doesn't know.
+func rowsAffected(result driver.Result, err error) int64 {
+	if err != nil || result == nil {
+		return 0
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0
+	}
+
+	return rows
+}
+
+// namedValues returns the values of positional arguments, for drivers that don't take named ones.
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("driver doesn't support the named argument %s", arg.Name)
+		}
+		values[i] = arg.Value
+	}
+
+	return values, nil
+}
@@ -0,0 +1,103 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// hookedQuery is a query the hook of a test was called for.
+type hookedQuery struct {
+	query string
+	rows  int64
+	err   error
+}
+
+func TestOpenDB_Hooks(t *testing.T) {
+	var queries []hookedQuery
+	hook := func(ctx context.Context, query string) func(rows int64, err error) {
+		return func(rows int64, err error) {
+			queries = append(queries, hookedQuery{query: query, rows: rows, err: err})
+		}
+	}
+
+	db, err := OpenDB("sqlite3", ":memory:", hook)
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+	// Every connection to :memory: has a database of its own
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)`)
+	assert.NoError(t, err)
+
+	// Prepared statements are hooked every time they are run
+	statement, err := db.PrepareContext(ctx, `INSERT INTO items(name) VALUES (?)`)
+	if err != nil {
+		t.Fatalf("failed to prepare statement: %v", err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		_, err := statement.ExecContext(ctx, name)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, statement.Close())
+
+	// Queries are done once their rows are closed, with the number of rows read
+	rows, err := db.QueryContext(ctx, `SELECT id FROM items`)
+	if err != nil {
+		t.Fatalf("failed to query items: %v", err)
+	}
+	assert.True(t, rows.Next())
+	assert.Len(t, queries, 4)
+	assert.NoError(t, rows.Close())
+
+	_, err = db.ExecContext(ctx, `UPDATE items SET name = 'd' WHERE id > 1`)
+	assert.NoError(t, err)
+
+	_, err = db.QueryContext(ctx, `SELECT id FROM missing`)
+	assert.Error(t, err)
+
+	// Queries of transactions are hooked too
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %v", err)
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM items`)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+
+	if assert.Len(t, queries, 8) {
+		assert.Equal(t, hookedQuery{query: `INSERT INTO items(name) VALUES (?)`, rows: 1}, queries[1])
+		assert.Equal(t, hookedQuery{query: `SELECT id FROM items`, rows: 1}, queries[4])
+		assert.Equal(t, hookedQuery{query: `UPDATE items SET name = 'd' WHERE id > 1`, rows: 2}, queries[5])
+		assert.Error(t, queries[6].err)
+		assert.Equal(t, hookedQuery{query: `DELETE FROM items`, rows: 3}, queries[7])
+	}
+}
+
+func TestOpenDB_ContextDone(t *testing.T) {
+	hooked := 0
+	hook := func(ctx context.Context, query string) func(rows int64, err error) {
+		hooked++
+		return func(rows int64, err error) {}
+	}
+
+	db, err := OpenDB("sqlite3", ":memory:", hook)
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE items (id INTEGER PRIMARY KEY)`)
+	assert.True(t, errors.Is(err, context.Canceled))
+	// Queries of a done context aren't run, so the hooks aren't called for them
+	assert.Zero(t, hooked)
+}
@@ -0,0 +1,95 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDescribeQuery(t *testing.T) {
+	testCases := []struct {
+		query             string
+		expectedStatement string
+		expectedTable     string
+	}{
+		{
+			query:             `SELECT id, (SELECT COUNT(*) FROM runners WHERE race_id = races.id) FROM races WHERE id = ?`,
+			expectedStatement: "select",
+			expectedTable:     "races",
+		},
+		{
+			query:             `INSERT INTO price_history(runner_id, win, place, time) VALUES (?,?,?,?)`,
+			expectedStatement: "insert",
+			expectedTable:     "price_history",
+		},
+		{
+			query:             "\n\t\tUPDATE races SET name = ?, version = version + 1 WHERE id = ?",
+			expectedStatement: "update",
+			expectedTable:     "races",
+		},
+		{
+			query:             `DELETE FROM race_placings WHERE race_id = ?`,
+			expectedStatement: "delete",
+			expectedTable:     "race_placings",
+		},
+		{
+			query:             `CREATE TRIGGER IF NOT EXISTS races_search_update AFTER UPDATE OF name ON races BEGIN SELECT 1; END`,
+			expectedStatement: "create",
+			expectedTable:     "",
+		},
+		{
+			query:             "-- Comments are skipped\nALTER TABLE races ADD COLUMN version INTEGER; /* (SELECT) */",
+			expectedStatement: "alter",
+			expectedTable:     "",
+		},
+		{
+			query:             ``,
+			expectedStatement: "",
+			expectedTable:     "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expectedStatement, func(t *testing.T) {
+			statement, table := DescribeQuery(tc.query)
+
+			assert.Equal(t, tc.expectedStatement, statement)
+			assert.Equal(t, tc.expectedTable, table)
+		})
+	}
+}
+
+func TestQueryMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	queryMetrics := NewQueryMetrics(registry)
+
+	queryMetrics.Hook(context.Background(), `SELECT id FROM races`)(3, nil)
+	queryMetrics.Hook(context.Background(), `SELECT id FROM races WHERE id = ?`)(1, nil)
+	queryMetrics.Hook(context.Background(), `DELETE FROM races WHERE id = ?`)(0, errors.New("failed"))
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	samples := make(map[string]uint64)
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			key := family.GetName()
+			for _, label := range metric.Label {
+				key += " " + label.GetValue()
+			}
+			samples[key] = metric.GetHistogram().GetSampleCount()
+		}
+	}
+
+	// Queries are labelled by statement and table, rather than by their text
+	assert.Equal(t, map[string]uint64{
+		"db_query_duration_seconds ok select races":    2,
+		"db_query_duration_seconds error delete races": 1,
+		"db_query_rows select races":                   2,
+		"db_query_rows delete races":                   1,
+	}, samples)
+}
@@ -0,0 +1,68 @@
+package instrument
+
+import (
+	"context"
+
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// rowsKey is the attribute of the number of rows a query read or changed.
+const rowsKey = attribute.Key("db.rows")
+
+// QueryTracer traces the SQL queries, each in a span named after its statement and table, e.g. select races.
+type QueryTracer struct {
+	tracer trace.Tracer
+	system attribute.KeyValue
+}
+
+// NewQueryTracer returns the tracer of the queries of a database of dialect d, whose spans are recorded by provider.
+// Its Hook traces the queries of a database opened with OpenDB.
+func NewQueryTracer(provider trace.TracerProvider, d dialect.Dialect) *QueryTracer {
+	system := semconv.DBSystemSqlite
+	if d == dialect.Postgres {
+		system = semconv.DBSystemPostgreSQL
+	}
+
+	return &QueryTracer{
+		tracer: provider.Tracer("git.neds.sh/matty/entain/common/db/instrument"),
+		system: system,
+	}
+}
+
+// Hook is the QueryHook tracing each query in a child span of the one of its context, which is the span of the RPC
+// running it. Queries run outside of a span, e.g. by migrations and seeding, aren't traced, so they don't start
+// traces of their own.
+func (t *QueryTracer) Hook(ctx context.Context, query string) func(rows int64, err error) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return func(rows int64, err error) {}
+	}
+
+	statement, table := DescribeQuery(query)
+	name := statement
+	if table != "" {
+		name += " " + table
+	}
+
+	_, span := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			t.system,
+			semconv.DBStatementKey.String(query),
+			semconv.DBOperationKey.String(statement),
+			semconv.DBSQLTableKey.String(table),
+		),
+	)
+
+	return func(rows int64, err error) {
+		span.SetAttributes(rowsKey.Int64(rows))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
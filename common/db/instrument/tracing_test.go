@@ -0,0 +1,51 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"testing"
+)
+
+func TestQueryTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	queryTracer := NewQueryTracer(provider, dialect.Postgres)
+
+	// Queries outside of a span aren't traced
+	queryTracer.Hook(context.Background(), `INSERT INTO races(name) VALUES (?)`)(1, nil)
+	assert.Empty(t, recorder.Ended())
+
+	ctx, rpc := provider.Tracer("test").Start(context.Background(), "racing.Racing/ListRaces")
+	queryTracer.Hook(ctx, `SELECT id FROM races WHERE id = ?`)(1, nil)
+	queryTracer.Hook(ctx, `DELETE FROM races WHERE id = ?`)(0, errors.New("failed"))
+	rpc.End()
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 3) {
+		return
+	}
+
+	// Queries are children of the span of their context, named by statement and table rather than by their text
+	selectSpan := spans[0]
+	assert.Equal(t, "select races", selectSpan.Name())
+	assert.Equal(t, rpc.SpanContext().SpanID(), selectSpan.Parent().SpanID())
+	assert.Subset(t, selectSpan.Attributes(), []interface{}{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBStatementKey.String(`SELECT id FROM races WHERE id = ?`),
+		semconv.DBOperationKey.String("select"),
+		semconv.DBSQLTableKey.String("races"),
+		rowsKey.Int64(1),
+	})
+	assert.Equal(t, codes.Unset, selectSpan.Status().Code)
+
+	deleteSpan := spans[1]
+	assert.Equal(t, "delete races", deleteSpan.Name())
+	assert.Equal(t, codes.Error, deleteSpan.Status().Code)
+	assert.Len(t, deleteSpan.Events(), 1)
+}
@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"strconv"
+
+	"git.neds.sh/matty/entain/common/db/dialect"
+)
+
+const commandUsage = "usage: migrate up | down [steps] | status"
+
+// Command runs the migrate command of a service, which applies or rolls back the migrations of files on the database
+// opened by open without starting the server. Its args are the ones following migrate:
+//
+//	migrate up            applies the pending migrations
+//	migrate down [steps]  rolls back the last steps migrations, 1 by default
+//	migrate status        lists the migrations and when they were applied
+func Command(args []string, open func() (*sql.DB, error), files fs.FS, d dialect.Dialect) error {
+	if len(args) == 0 {
+		return errors.New(commandUsage)
+	}
+
+	db, err := open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		return Migrate(db, files, d)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return fmt.Errorf("steps must be a positive number, got %q", args[1])
+			}
+		}
+
+		rolledBack, err := Down(db, files, d, steps)
+		for _, migration := range rolledBack {
+			log.Printf("rolled back migration %04d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+	case "status":
+		states, err := Status(db, files, d)
+		if err != nil {
+			return err
+		}
+
+		for _, state := range states {
+			appliedAt := "pending"
+			if state.Applied() {
+				appliedAt = "applied at " + state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", state.Version, state.Name, appliedAt)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q, %s", args[0], commandUsage)
+	}
+}
+
+// Migrate applies the pending migrations of files, logging each one applied. The services call it when they start.
+func Migrate(db *sql.DB, files fs.FS, d dialect.Dialect) error {
+	applied, err := Up(db, files, d)
+	for _, migration := range applied {
+		log.Printf("applied migration %04d_%s\n", migration.Version, migration.Name)
+	}
+	return err
+}
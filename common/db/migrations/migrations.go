@@ -0,0 +1,214 @@
+// Package migrations versions the schema of the databases of the services.
+//
+// Migrations are SQL files in a directory per driver, e.g. sqlite3/, named <version>_<name>.up.sql to apply them and
+// <version>_<name>.down.sql to roll them back. Each dialect has the same migrations, written in its own SQL. Each
+// service embeds the files of its own database, and gives them to the functions of this package.
+// Applied migrations are recorded in the schema_migrations table, so each one is only applied once, in the order
+// of their versions.
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"git.neds.sh/matty/entain/common/db/dialect"
+)
+
+// fileName matches the names of the migration files.
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a change to the schema of the database.
+type Migration struct {
+	// Version orders the migrations, it is the number the files of the migration start with.
+	Version int64
+	// Name describes the migration.
+	Name string
+
+	up   string
+	down string
+}
+
+// State is a migration along with whether it was applied.
+type State struct {
+	*Migration
+	// AppliedAt is when the migration was applied, it is zero when it is pending.
+	AppliedAt time.Time
+}
+
+// Applied reports whether the migration was applied.
+func (s *State) Applied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+// All returns every migration of a dialect in files, ordered by version.
+func All(files fs.FS, d dialect.Dialect) ([]*Migration, error) {
+	dir := d.Driver()
+
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %q must be named <version>_<name>.up.sql or <version>_<name>.down.sql", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migrations %q and %q have the same version", migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.up = string(content)
+		} else {
+			migration.down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Status returns every migration in files along with when it was applied, ordered by version.
+func Status(db *sql.DB, files fs.FS, d dialect.Dialect) ([]*State, error) {
+	migrations, err := All(files, d)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedAt(db)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]*State, 0, len(migrations))
+	for _, migration := range migrations {
+		states = append(states, &State{Migration: migration, AppliedAt: applied[migration.Version]})
+	}
+
+	return states, nil
+}
+
+// Up applies the migrations in files that are pending, in order, returning the ones it applied. It stops at the first
+// migration that fails, the ones before it stay applied.
+func Up(db *sql.DB, files fs.FS, d dialect.Dialect) ([]*Migration, error) {
+	states, err := Status(db, files, d)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []*Migration
+	for _, state := range states {
+		if state.Applied() {
+			continue
+		}
+
+		if err := run(db, state.Migration, state.up, d.Rebind(`INSERT INTO schema_migrations(version, name, applied_at) VALUES (?,?,?)`), state.Version, state.Name, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			return applied, err
+		}
+		applied = append(applied, state.Migration)
+	}
+
+	return applied, nil
+}
+
+// Down rolls back the given number of migrations, the last applied first, returning the ones it rolled back.
+func Down(db *sql.DB, files fs.FS, d dialect.Dialect, steps int) ([]*Migration, error) {
+	states, err := Status(db, files, d)
+	if err != nil {
+		return nil, err
+	}
+
+	var rolledBack []*Migration
+	for i := len(states) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		if !states[i].Applied() {
+			continue
+		}
+
+		if err := run(db, states[i].Migration, states[i].down, d.Rebind(`DELETE FROM schema_migrations WHERE version = ?`), states[i].Version); err != nil {
+			return rolledBack, err
+		}
+		rolledBack = append(rolledBack, states[i].Migration)
+	}
+
+	return rolledBack, nil
+}
+
+// run executes the SQL of a migration and records it in the same transaction, so a migration is either applied
+// and recorded or not at all.
+func run(db *sql.DB, migration *Migration, query string, record string, args ...interface{}) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+
+	if _, err := tx.Exec(record, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// appliedAt returns when each applied migration was applied, by version.
+func appliedAt(db *sql.DB) (map[int64]time.Time, error) {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT PRIMARY KEY, name TEXT, applied_at TIMESTAMP)`); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			version int64
+			at      time.Time
+		)
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+
+	return applied, rows.Err()
+}
@@ -0,0 +1,151 @@
+package migrations
+
+import (
+	"database/sql"
+	"git.neds.sh/matty/entain/common/db/dialect"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// testFiles are the migrations of a test database, in the order they are applied.
+var testFiles = fstest.MapFS{
+	"sqlite3/0001_create_teams.up.sql":    {Data: []byte(`CREATE TABLE teams (id INTEGER PRIMARY KEY, name TEXT);`)},
+	"sqlite3/0001_create_teams.down.sql":  {Data: []byte(`DROP TABLE teams;`)},
+	"sqlite3/0002_add_team_city.up.sql":   {Data: []byte(`ALTER TABLE teams ADD COLUMN city TEXT;`)},
+	"sqlite3/0002_add_team_city.down.sql": {Data: []byte(`ALTER TABLE teams DROP COLUMN city;`)},
+}
+
+func TestMigrations(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	applied, err := Up(db, testFiles, dialect.SQLite)
+	if err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+	if assert.Len(t, applied, 2) {
+		assert.Equal(t, int64(1), applied[0].Version)
+		assert.Equal(t, "create_teams", applied[0].Name)
+		assert.Equal(t, int64(2), applied[1].Version)
+		assert.Equal(t, "add_team_city", applied[1].Name)
+	}
+
+	_, err = db.Exec(`INSERT INTO teams(name, city) VALUES ('Swans', 'Sydney')`)
+	assert.NoError(t, err)
+
+	// The last migration is rolled back first, and is the only one pending afterwards
+	rolledBack, err := Down(db, testFiles, dialect.SQLite, 1)
+	if err != nil {
+		t.Fatalf("failed to roll back migrations: %v", err)
+	}
+	assert.Equal(t, applied[1:], rolledBack)
+
+	states, err := Status(db, testFiles, dialect.SQLite)
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if assert.Len(t, states, 2) {
+		assert.True(t, states[0].Applied())
+		assert.False(t, states[1].Applied())
+	}
+
+	applied, err = Up(db, testFiles, dialect.SQLite)
+	if err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+	assert.Len(t, applied, 1)
+}
+
+func TestMigrations_Failed(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	files := fstest.MapFS{
+		"sqlite3/0001_create_teams.up.sql":    testFiles["sqlite3/0001_create_teams.up.sql"],
+		"sqlite3/0001_create_teams.down.sql":  testFiles["sqlite3/0001_create_teams.down.sql"],
+		"sqlite3/0002_add_team_city.up.sql":   {Data: []byte(`ALTER TABLE teams ADD COLUMN city TEXT; ALTER TABLE players ADD COLUMN city TEXT;`)},
+		"sqlite3/0002_add_team_city.down.sql": testFiles["sqlite3/0002_add_team_city.down.sql"],
+	}
+
+	// The migrations before the failed one stay applied, the failed one is rolled back as a whole
+	applied, err := Up(db, files, dialect.SQLite)
+	assert.ErrorContains(t, err, "migration 2_add_team_city failed")
+	assert.Len(t, applied, 1)
+
+	_, err = db.Exec(`SELECT city FROM teams`)
+	assert.Error(t, err)
+}
+
+func TestAll_Errors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		files         fstest.MapFS
+		expectedError string
+	}{
+		{
+			name:          "BadName",
+			files:         fstest.MapFS{"sqlite3/create_teams.up.sql": {}},
+			expectedError: `migration file "create_teams.up.sql" must be named`,
+		},
+		{
+			name:          "MissingDown",
+			files:         fstest.MapFS{"sqlite3/0001_create_teams.up.sql": {Data: []byte(`SELECT 1;`)}},
+			expectedError: "migration 1_create_teams must have both an up and a down file",
+		},
+		{
+			name: "SameVersion",
+			files: fstest.MapFS{
+				"sqlite3/0001_create_teams.up.sql":   {Data: []byte(`SELECT 1;`)},
+				"sqlite3/0001_create_players.up.sql": {Data: []byte(`SELECT 1;`)},
+			},
+			expectedError: "have the same version",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := All(tc.files, dialect.SQLite)
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestCommand(t *testing.T) {
+	// The command closes the database it opens, so it is a file rather than an in-memory one
+	dsn := filepath.Join(t.TempDir(), "teams.db")
+	open := func() (*sql.DB, error) {
+		return sql.Open("sqlite3", dsn)
+	}
+
+	assert.NoError(t, Command([]string{"up"}, open, testFiles, dialect.SQLite))
+	assert.NoError(t, Command([]string{"down"}, open, testFiles, dialect.SQLite))
+	assert.NoError(t, Command([]string{"status"}, open, testFiles, dialect.SQLite))
+
+	db, err := open()
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	states, err := Status(db, testFiles, dialect.SQLite)
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if assert.Len(t, states, 2) {
+		assert.True(t, states[0].Applied())
+		assert.False(t, states[1].Applied())
+	}
+
+	assert.EqualError(t, Command(nil, open, testFiles, dialect.SQLite), "usage: migrate up | down [steps] | status")
+	assert.EqualError(t, Command([]string{"sideways"}, open, testFiles, dialect.SQLite), `unknown migrate command "sideways", usage: migrate up | down [steps] | status`)
+	assert.EqualError(t, Command([]string{"down", "0"}, open, testFiles, dialect.SQLite), `steps must be a positive number, got "0"`)
+}
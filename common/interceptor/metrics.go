@@ -0,0 +1,84 @@
+// Package interceptor has the gRPC server interceptors shared by the services, which measure the RPCs and bound how
+// long they can run for.
+package interceptor
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics records how many RPCs were handled and how long they took, by method and status code. The metrics
+// are named like the ones of go-grpc-prometheus, so its dashboards can be used.
+type Metrics struct {
+	handled  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewMetrics returns the metrics of the RPCs, registered with registerer. Its interceptors record them.
+func NewMetrics(registerer prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_handled_total",
+			Help: "RPCs completed on the server, regardless of success or failure.",
+		}, []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Time taken by the RPCs until they were completed by the server.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"grpc_type", "grpc_service", "grpc_method"}),
+	}
+	registerer.MustRegister(m.handled, m.duration)
+
+	return m
+}
+
+// UnaryInterceptor returns an interceptor recording the metrics of unary RPCs. It must come before UnaryTimeout, so
+// RPCs that ran out of time are recorded as DeadlineExceeded.
+func (m *Metrics) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observe("unary", info.FullMethod, start, err)
+
+		return resp, err
+	}
+}
+
+// StreamInterceptor returns an interceptor recording the metrics of streaming RPCs, which are completed once the
+// stream is closed.
+func (m *Metrics) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, stream)
+		m.observe(streamType(info), info.FullMethod, start, err)
+
+		return err
+	}
+}
+
+func (m *Metrics) observe(rpcType, fullMethod string, start time.Time, err error) {
+	// Full methods are /<service>/<method>, e.g. /racing.Racing/ListRaces
+	service, method := "unknown", "unknown"
+	if parts := strings.SplitN(strings.TrimPrefix(fullMethod, "/"), "/", 2); len(parts) == 2 {
+		service, method = parts[0], parts[1]
+	}
+
+	m.handled.WithLabelValues(rpcType, service, method, status.Code(err).String()).Inc()
+	m.duration.WithLabelValues(rpcType, service, method).Observe(time.Since(start).Seconds())
+}
+
+func streamType(info *grpc.StreamServerInfo) string {
+	switch {
+	case info.IsClientStream && info.IsServerStream:
+		return "bidi_stream"
+	case info.IsClientStream:
+		return "client_stream"
+	default:
+		return "server_stream"
+	}
+}
@@ -0,0 +1,50 @@
+package interceptor
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics(prometheus.NewRegistry())
+
+	// Metrics come before the timeout, so RPCs out of time are counted as DeadlineExceeded
+	call := func(method string, handler grpc.UnaryHandler) {
+		interceptor := metrics.UnaryInterceptor()
+		timeout := UnaryTimeout(10*time.Millisecond, nil)
+		info := &grpc.UnaryServerInfo{FullMethod: method}
+		_, _ = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return timeout(ctx, req, info, handler)
+		})
+	}
+
+	ok := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	call("/racing.Racing/ListRaces", ok)
+	call("/racing.Racing/ListRaces", ok)
+	call("/racing.Racing/GetRace", func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "Race with ID 1 not found")
+	})
+	call("/racing.Racing/GetRace", func(ctx context.Context, req interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	_ = metrics.StreamInterceptor()(nil, nil, &grpc.StreamServerInfo{FullMethod: "/racing.Racing/WatchRaces", IsServerStream: true}, func(srv interface{}, stream grpc.ServerStream) error {
+		return status.Error(codes.Canceled, "context canceled")
+	})
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.handled.WithLabelValues("unary", "racing.Racing", "ListRaces", "OK")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.handled.WithLabelValues("unary", "racing.Racing", "GetRace", "NotFound")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.handled.WithLabelValues("unary", "racing.Racing", "GetRace", "DeadlineExceeded")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.handled.WithLabelValues("server_stream", "racing.Racing", "WatchRaces", "Canceled")))
+	assert.Equal(t, 3, testutil.CollectAndCount(metrics.duration))
+}
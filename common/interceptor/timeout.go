@@ -0,0 +1,61 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"path"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultTimeout is how long an RPC can run for when it has no timeout of its own.
+const DefaultTimeout = 10 * time.Second
+
+// UnaryTimeout returns an interceptor bounding how long each unary RPC can run for, so its queries are cancelled
+// once it is over. Methods in timeouts get a timeout of their own, keyed by their full name, e.g.
+// /racing.Racing/ListRaces, or by their name alone, e.g. ListRaces. The rest get defaultTimeout. A shorter deadline
+// set by the client is kept.
+func UnaryTimeout(defaultTimeout time.Duration, timeouts map[string]time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		timeout, ok := timeouts[info.FullMethod]
+		if !ok {
+			if timeout, ok = timeouts[path.Base(info.FullMethod)]; !ok {
+				timeout = defaultTimeout
+			}
+		}
+
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		resp, err := handler(ctx, req)
+		return resp, contextError(ctx, err)
+	}
+}
+
+// contextError returns the error of an RPC as DeadlineExceeded when it ran out of time, and as Canceled when the
+// client went away. Repositories return the error of the context or of the database driver then, which would
+// reach the client as Unknown otherwise. Errors that already have a code are kept.
+func contextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		return err
+	}
+}
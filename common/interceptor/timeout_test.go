@@ -0,0 +1,105 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryTimeout(t *testing.T) {
+	interceptor := UnaryTimeout(time.Minute, map[string]time.Duration{
+		"/racing.Racing/ListRaces": time.Second,
+		"SearchRaces":              2 * time.Second,
+		"WatchRaces":               0,
+	})
+
+	// timeoutOf returns how long a method was given to run for, 0 when it has no deadline.
+	timeoutOf := func(ctx context.Context, method string) time.Duration {
+		var timeout time.Duration
+		_, _ = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			if deadline, ok := ctx.Deadline(); ok {
+				timeout = time.Until(deadline).Round(time.Second)
+			}
+			return nil, nil
+		})
+		return timeout
+	}
+
+	assert.Equal(t, time.Second, timeoutOf(context.Background(), "/racing.Racing/ListRaces"))
+	assert.Equal(t, 2*time.Second, timeoutOf(context.Background(), "/racing.Racing/SearchRaces"))
+	assert.Equal(t, time.Minute, timeoutOf(context.Background(), "/racing.Racing/GetRace"))
+	assert.Zero(t, timeoutOf(context.Background(), "/racing.Racing/WatchRaces"))
+
+	// The deadline of the client is kept when it is shorter
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	assert.Equal(t, 3*time.Second, timeoutOf(ctx, "/racing.Racing/GetRace"))
+}
+
+func TestUnaryTimeout_Errors(t *testing.T) {
+	interceptor := UnaryTimeout(10*time.Millisecond, nil)
+
+	testCases := []struct {
+		name         string
+		handler      grpc.UnaryHandler
+		expectedCode codes.Code
+	}{
+		{
+			name: "DeadlineExceeded",
+			handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+			expectedCode: codes.DeadlineExceeded,
+		},
+		{
+			// Drivers can return errors of their own when a query is interrupted
+			name: "DriverErrorAfterDeadline",
+			handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+				<-ctx.Done()
+				return nil, errors.New("interrupted")
+			},
+			expectedCode: codes.DeadlineExceeded,
+		},
+		{
+			name: "StatusKept",
+			handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+				<-ctx.Done()
+				return nil, status.Error(codes.NotFound, "Race with ID 1 not found")
+			},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name: "InTime",
+			handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			},
+			expectedCode: codes.OK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/racing.Racing/ListRaces"}, tc.handler)
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+		})
+	}
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/racing.Racing/ListRaces"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, ctx.Err()
+		})
+
+		assert.Equal(t, codes.Canceled, status.Code(err))
+	})
+}
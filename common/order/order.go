@@ -0,0 +1,131 @@
+// Package order resolves the fields a list is sorted by against a whitelist, into the columns it is sorted by in SQL.
+// Each repository keeps the whitelist of its entity, the resolution is shared by all of them.
+package order
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Field is a field entities can be sorted by.
+type Field struct {
+	// Name is the name of the field in order_by.
+	Name string
+	// Column is the column the field is sorted by, it also identifies the field in page tokens.
+	Column string
+	// Expression is used instead of the column when the field is derived, with the current time
+	// bound to each of its placeholders.
+	Expression string
+	// Value returns the value of the field for an entity, as it is compared in the database.
+	Value func(entity interface{}) interface{}
+}
+
+// Fields is the whitelist of fields entities can be sorted by. Fields are matched ignoring case and
+// underscores, so both advertisedStartTime and advertised_start_time are accepted.
+type Fields []*Field
+
+// Find returns the whitelisted field with the given name, or nil if there is none.
+func (f Fields) Find(name string) *Field {
+	normalised := NormaliseName(name)
+
+	for _, field := range f {
+		if NormaliseName(field.Name) == normalised {
+			return field
+		}
+	}
+
+	return nil
+}
+
+// FindColumn returns the whitelisted field sorted by the given column, or nil if there is none.
+func (f Fields) FindColumn(column string) *Field {
+	for _, field := range f {
+		if field.Column == column {
+			return field
+		}
+	}
+
+	return nil
+}
+
+// Names returns the names of the whitelisted fields, in order.
+func (f Fields) Names() []string {
+	names := make([]string, 0, len(f))
+	for _, field := range f {
+		names = append(names, field.Name)
+	}
+
+	return names
+}
+
+// By is a field of order_by, in either direction.
+type By struct {
+	Field string
+	Desc  bool
+}
+
+// Columns returns the columns to sort by, which must be in the whitelist. The id is always the last one,
+// so entities with the same values are kept in the same order across pages.
+func (f Fields) Columns(orderBy []By, currentDate time.Time) ([]Column, error) {
+	var (
+		columns []Column
+		hasID   bool
+	)
+
+	for _, by := range orderBy {
+		field := f.Find(by.Field)
+		if field == nil {
+			return nil, &FieldError{Field: by.Field, Supported: f.Names()}
+		}
+
+		columns = append(columns, NewColumn(field, by.Desc, currentDate))
+		hasID = hasID || field.Column == "id"
+	}
+
+	if hasID {
+		return columns, nil
+	}
+
+	return append(columns, NewColumn(f.Find("id"), false, currentDate)), nil
+}
+
+// FieldError is returned when entities are sorted by a field that isn't in the whitelist.
+type FieldError struct {
+	// Field is the requested field.
+	Field string
+	// Supported are the names of the whitelisted fields.
+	Supported []string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("order_by field %q is not supported, it must be one of: %s", e.Field, strings.Join(e.Supported, ", "))
+}
+
+// NormaliseName returns the name of a field ignoring case and underscores.
+func NormaliseName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// Column is a column entities are sorted by.
+type Column struct {
+	Name string
+	Desc bool
+	// SQL selects the column and Args are bound to its placeholders.
+	SQL  string
+	Args []interface{}
+}
+
+// NewColumn creates the column sorting by the given field.
+func NewColumn(field *Field, desc bool, currentDate time.Time) Column {
+	column := Column{Name: field.Column, Desc: desc, SQL: field.Column}
+
+	if field.Expression != "" {
+		column.SQL = field.Expression
+		for i := strings.Count(field.Expression, "?"); i > 0; i-- {
+			column.Args = append(column.Args, currentDate.UTC().Format(time.RFC3339))
+		}
+	}
+
+	return column
+}
@@ -0,0 +1,102 @@
+package order
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testEntity struct {
+	id   int64
+	name string
+}
+
+var testFields = Fields{
+	{
+		Name:   "id",
+		Column: "id",
+		Value: func(entity interface{}) interface{} {
+			return entity.(*testEntity).id
+		},
+	},
+	{
+		Name:   "displayName",
+		Column: "display_name",
+		Value: func(entity interface{}) interface{} {
+			return entity.(*testEntity).name
+		},
+	},
+	{
+		Name:       "status",
+		Column:     "status",
+		Expression: "CASE WHEN start < ? THEN 'CLOSED' ELSE 'OPEN' END",
+	},
+}
+
+func TestFields_Find(t *testing.T) {
+	for _, name := range []string{"displayName", "display_name", "DISPLAYNAME"} {
+		if field := testFields.Find(name); field != testFields[1] {
+			t.Errorf("Find(%q) = %v, expected displayName", name, field)
+		}
+	}
+
+	if field := testFields.Find("displayNme"); field != nil {
+		t.Errorf("Find(displayNme) = %v, expected nil", field)
+	}
+
+	if field := testFields.FindColumn("display_name"); field != testFields[1] {
+		t.Errorf("FindColumn(display_name) = %v, expected displayName", field)
+	}
+
+	if value := testFields.FindColumn("display_name").Value(&testEntity{name: "Melbourne"}); value != "Melbourne" {
+		t.Errorf("Value = %v, expected Melbourne", value)
+	}
+}
+
+func TestFields_Columns(t *testing.T) {
+	currentDate := time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)
+
+	t.Run("IDAppended", func(t *testing.T) {
+		columns, err := testFields.Columns([]By{{Field: "status", Desc: true}}, currentDate)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// Derived fields are sorted by their expression, with the current time bound to it
+		expected := []Column{
+			{Name: "status", Desc: true, SQL: testFields[2].Expression, Args: []interface{}{"2023-07-15T12:00:00Z"}},
+			{Name: "id", SQL: "id"},
+		}
+		if !reflect.DeepEqual(expected, columns) {
+			t.Errorf("Columns = %v, expected %v", columns, expected)
+		}
+	})
+
+	t.Run("IDRequested", func(t *testing.T) {
+		columns, err := testFields.Columns([]By{{Field: "id", Desc: true}, {Field: "display_name"}}, currentDate)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := []Column{
+			{Name: "id", Desc: true, SQL: "id"},
+			{Name: "display_name", SQL: "display_name"},
+		}
+		if !reflect.DeepEqual(expected, columns) {
+			t.Errorf("Columns = %v, expected %v", columns, expected)
+		}
+	})
+
+	t.Run("UnknownField", func(t *testing.T) {
+		_, err := testFields.Columns([]By{{Field: "displayNme"}}, currentDate)
+
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) {
+			t.Fatalf("Columns error = %v, expected a FieldError", err)
+		}
+		if expected := `order_by field "displayNme" is not supported, it must be one of: id, displayName, status`; err.Error() != expected {
+			t.Errorf("Error() = %q, expected %q", err.Error(), expected)
+		}
+	})
+}
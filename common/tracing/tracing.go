@@ -0,0 +1,67 @@
+// Package tracing sets up the OpenTelemetry tracing of the gateway and the services.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Propagator reads the trace context, and the baggage, of the callers from the headers of the requests or the
+// metadata of the RPCs, and passes it on in the metadata of the RPCs made to the services.
+var Propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// NewProvider returns the provider of the tracers of the service, e.g. racing, whose spans are exported with
+// exporter, one of none, stdout or otlp, along with the function exporting the spans left when the service stops.
+// The otlp exporter sends the spans to the collector on otlpEndpoint. The traces the service starts are sampled by
+// sampleRatio. Spans aren't recorded when there isn't an exporter, though the trace context of the callers is still
+// passed on.
+func NewProvider(service, exporter, otlpEndpoint string, sampleRatio float64) (trace.TracerProvider, func(), error) {
+	var (
+		spanExporter sdktrace.SpanExporter
+		err          error
+	)
+	switch exporter {
+	case "none":
+		return trace.NewNoopTracerProvider(), func() {}, nil
+	case "stdout":
+		spanExporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "otlp":
+		// The collector is connected to in the background, so the service starts without it
+		spanExporter, err = otlptracegrpc.New(context.Background(), otlptracegrpc.WithEndpoint(otlpEndpoint), otlptracegrpc.WithInsecure())
+	default:
+		return nil, nil, fmt.Errorf("trace exporter %q is not supported, it must be one of: none, stdout, otlp", exporter)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(service))),
+		// Requests and RPCs of sampled traces are sampled too, so the traces aren't missing the spans of the service
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+
+	log.Printf("exporting spans with: %s\n", exporter)
+
+	stop := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Printf("failed exporting spans: %s\n", err)
+		}
+	}
+
+	return provider, stop, nil
+}
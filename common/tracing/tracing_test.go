@@ -0,0 +1,27 @@
+package tracing
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"testing"
+)
+
+func TestNewProvider(t *testing.T) {
+	// Without an exporter, spans aren't recorded
+	provider, stop, err := NewProvider("racing", "none", "", 1)
+	if assert.NoError(t, err) {
+		_, span := provider.Tracer("test").Start(context.Background(), "ListRaces")
+		assert.False(t, span.IsRecording())
+		stop()
+	}
+
+	provider, stop, err = NewProvider("racing", "stdout", "", 1)
+	if assert.NoError(t, err) {
+		assert.IsType(t, &sdktrace.TracerProvider{}, provider)
+		stop()
+	}
+
+	_, _, err = NewProvider("racing", "jaeger", "", 1)
+	assert.EqualError(t, err, `trace exporter "jaeger" is not supported, it must be one of: none, stdout, otlp`)
+}
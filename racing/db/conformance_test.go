@@ -0,0 +1,266 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/common/db/migrations"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+// postgresTestDSN is the environment variable with the DSN of a Postgres database the conformance suite is run
+// against too, e.g. postgres://localhost/racing_test?sslmode=disable. Its tables are dropped and created again.
+const postgresTestDSN = "RACING_TEST_POSTGRES_DSN"
+
+// conformanceDB is a database the conformance suite is run against.
+type conformanceDB struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+}
+
+// conformanceDBs opens an in-memory SQLite database, along with the Postgres database in postgresTestDSN when it
+// is set, each with empty tables.
+func conformanceDBs(t *testing.T) []conformanceDB {
+	sqliteDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	// Every connection to :memory: has a database of its own
+	sqliteDB.SetMaxOpenConns(1)
+
+	dbs := []conformanceDB{{db: sqliteDB, dialect: dialect.SQLite}}
+
+	if dsn := os.Getenv(postgresTestDSN); dsn != "" {
+		postgresDB, err := sql.Open("postgres", dsn)
+		if err != nil {
+			t.Fatalf("failed to open postgres database: %v", err)
+		}
+
+		if _, err := migrations.Down(postgresDB, Migrations(), dialect.Postgres, math.MaxInt32); err != nil {
+			t.Fatalf("failed to drop postgres tables: %v", err)
+		}
+
+		dbs = append(dbs, conformanceDB{db: postgresDB, dialect: dialect.Postgres})
+	}
+
+	for _, conformance := range dbs {
+		if _, err := migrations.Up(conformance.db, Migrations(), conformance.dialect); err != nil {
+			t.Fatalf("failed to migrate %s database: %v", conformance.dialect, err)
+		}
+	}
+
+	return dbs
+}
+
+// TestRacesRepo_Conformance runs the same checks against the races repository of every dialect, so the SQL
+// generated for each of them returns the same races. Without postgresTestDSN it only runs against SQLite, which
+// still checks the statements shared by the dialects.
+func TestRacesRepo_Conformance(t *testing.T) {
+	for _, conformance := range conformanceDBs(t) {
+		t.Run(conformance.dialect.String(), func(t *testing.T) {
+			defer conformance.db.Close()
+
+			racesRepo := NewRacesRepo(conformance.db, WithDialect(conformance.dialect))
+			if err := racesRepo.Init(); err != nil {
+				t.Fatalf("failed to initialize races: %v", err)
+			}
+
+			// Races are created through the repository, so the test data doesn't depend on the dialect
+			if err := (&meetingsRepo{db: conformance.db, dialect: conformance.dialect}).seed(); err != nil {
+				t.Fatalf("failed to seed meetings: %v", err)
+			}
+			for _, race := range getAllTestData() {
+				if _, err := racesRepo.Create(context.Background(), race); err != nil {
+					t.Fatalf("failed to create race: %v", err)
+				}
+			}
+
+			testRacesRepoConformance(t, racesRepo)
+		})
+	}
+}
+
+func testRacesRepoConformance(t *testing.T, racesRepo RacesRepo) {
+	t.Run("ListFilter", func(t *testing.T) {
+		testCases := []struct {
+			name            string
+			filter          *racing.ListRacesRequestFilter
+			expectedRaceIds []int64
+		}{
+			{
+				name:            "MeetingIds",
+				filter:          &racing.ListRacesRequestFilter{MeetingIds: []int64{1, 8}},
+				expectedRaceIds: []int64{2, 3},
+			},
+			{
+				name:            "Visible",
+				filter:          &racing.ListRacesRequestFilter{VisibilityStatus: racing.VisibilityStatus_VISIBLE},
+				expectedRaceIds: []int64{2},
+			},
+			{
+				name:            "DerivedStatuses",
+				filter:          &racing.ListRacesRequestFilter{Statuses: []racing.RaceStatus{racing.RaceStatus_CLOSED, racing.RaceStatus_SUSPENDED}},
+				expectedRaceIds: []int64{1},
+			},
+			{
+				name: "StartTimeRange",
+				filter: &racing.ListRacesRequestFilter{
+					StartTimeFrom: timestamppb.New(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)),
+					StartTimeTo:   timestamppb.New(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+				},
+				expectedRaceIds: []int64{2},
+			},
+			{
+				// Searches ignore case in every dialect
+				name:            "SearchMeetingName",
+				filter:          &racing.ListRacesRequestFilter{Search: "FLEMINGTON"},
+				expectedRaceIds: []int64{2},
+			},
+			{
+				name:            "Combined",
+				filter:          &racing.ListRacesRequestFilter{MeetingIds: []int64{5, 8}, Statuses: []racing.RaceStatus{racing.RaceStatus_OPEN}, Search: "ghosts"},
+				expectedRaceIds: []int64{3},
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				races, _, err := racesRepo.List(context.Background(), tc.filter, nil, 0, "", nil, getDateNow())
+				if err != nil {
+					t.Fatalf("failed to list races: %v", err)
+				}
+
+				assert.Equal(t, tc.expectedRaceIds, raceIds(races))
+			})
+		}
+	})
+
+	t.Run("ListPages", func(t *testing.T) {
+		testCases := []struct {
+			name            string
+			orderBy         []*racing.ListRacesRequestOrderBy
+			expectedRaceIds []int64
+		}{
+			{
+				name:            "AdvertisedStartTimeDesc",
+				orderBy:         []*racing.ListRacesRequestOrderBy{{FieldName: "advertisedStartTime", Direction: racing.OrderByDirection_DESC}},
+				expectedRaceIds: []int64{3, 2, 1},
+			},
+			{
+				name:            "VisibleDesc",
+				orderBy:         []*racing.ListRacesRequestOrderBy{{FieldName: "visible", Direction: racing.OrderByDirection_DESC}},
+				expectedRaceIds: []int64{2, 1, 3},
+			},
+			{
+				name:            "DerivedStatus",
+				orderBy:         []*racing.ListRacesRequestOrderBy{{FieldName: "status"}},
+				expectedRaceIds: []int64{1, 2, 3},
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				var (
+					ids       []int64
+					pageToken string
+				)
+
+				// A race per page, so every race is read after a page token
+				for {
+					races, nextPageToken, err := racesRepo.List(context.Background(), nil, tc.orderBy, 1, pageToken, nil, getDateNow())
+					if err != nil {
+						t.Fatalf("failed to list races: %v", err)
+					}
+
+					ids = append(ids, raceIds(races)...)
+					if nextPageToken == "" || len(ids) > len(tc.expectedRaceIds) {
+						break
+					}
+					pageToken = nextPageToken
+				}
+
+				assert.Equal(t, tc.expectedRaceIds, ids)
+			})
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		race, err := racesRepo.Get(context.Background(), 2, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get race: %v", err)
+		}
+
+		expected := getAllTestData()[1]
+		assert.Equal(t, expected.Name, race.Name)
+		assert.Equal(t, expected.MeetingId, race.MeetingId)
+		assert.Equal(t, expected.Visible, race.Visible)
+		assert.True(t, expected.AdvertisedStartTime.AsTime().Equal(race.AdvertisedStartTime.AsTime()))
+		assert.Equal(t, racing.RaceStatus_OPEN, race.Status)
+		assert.Equal(t, int64(1), race.Version)
+
+		_, err = racesRepo.Get(context.Background(), 999, nil, getDateNow())
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		races, err := racesRepo.BatchGet(context.Background(), []int64{3, 999, 1}, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get races: %v", err)
+		}
+		assert.ElementsMatch(t, []int64{1, 3}, raceIds(races))
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		results, err := racesRepo.Search(context.Background(), "rhode", 10, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to search races: %v", err)
+		}
+
+		if assert.Len(t, results, 1) {
+			assert.Equal(t, int64(3), results[0].Race.Id)
+			assert.Contains(t, results[0].NameSnippet, highlightStart)
+		}
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		id, err := racesRepo.Create(context.Background(), &racing.Race{
+			MeetingId:           2,
+			Name:                "Randwick Cup",
+			Number:              7,
+			Visible:             true,
+			AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 16, 14, 30, 0, 0, time.UTC)),
+		})
+		if err != nil {
+			t.Fatalf("failed to create race: %v", err)
+		}
+		assert.Equal(t, int64(4), id)
+
+		err = racesRepo.Update(context.Background(), &racing.Race{Id: id, Name: "Randwick Cup (Group 1)", Visible: false, Version: 1}, []string{"name", "visible"})
+		assert.NoError(t, err)
+
+		err = racesRepo.Update(context.Background(), &racing.Race{Id: id, Name: "Stale", Version: 1}, []string{"name"})
+		assert.ErrorIs(t, err, ErrVersionChanged)
+
+		err = racesRepo.Update(context.Background(), &racing.Race{Id: 999, Name: "Missing", Version: 1}, []string{"name"})
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		err = racesRepo.UpdateStatus(context.Background(), id, racing.RaceStatus_OPEN, racing.RaceStatus_SUSPENDED, getDateNow())
+		assert.NoError(t, err)
+
+		race, err := racesRepo.Get(context.Background(), id, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get race: %v", err)
+		}
+		assert.Equal(t, "Randwick Cup (Group 1)", race.Name)
+		assert.False(t, race.Visible)
+		assert.Equal(t, racing.RaceStatus_SUSPENDED, race.Status)
+		assert.Equal(t, int64(3), race.Version)
+
+		assert.NoError(t, racesRepo.Delete(context.Background(), id))
+		assert.ErrorIs(t, racesRepo.Delete(context.Background(), id), sql.ErrNoRows)
+	})
+}
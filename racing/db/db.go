@@ -1,30 +1,326 @@
 package db
 
 import (
+	"database/sql"
+	"math"
+	"math/rand"
+	"sort"
 	"time"
 
 	"syreclabs.com/go/faker"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
 )
 
-func (r *racesRepo) seed() error {
-	statement, err := r.db.Prepare(`CREATE TABLE IF NOT EXISTS races (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME)`)
-	if err == nil {
-		_, err = statement.Exec()
+// Seed fills the database with dummy meetings, races, runners and prices, for test/example purposes. The tables
+// must have been created by the migrations. Dummy data that is already there is kept, so it can be run again.
+func Seed(db *sql.DB, opts ...Option) error {
+	o := newOptions(opts)
+	d := o.dialect
+	// Start times are seeded around the time of the clock, which is read once so all of them are around the same time
+	now := o.clock.Now()
+
+	// Races belong to the meetings, runners to the races and prices to the runners, so they are seeded in that order
+	seeds := []func() error{
+		(&meetingsRepo{db: db, dialect: d}).seed,
+		func() error { return (&racesRepo{db: db, dialect: d}).seed(now) },
+		(&runnersRepo{db: db, dialect: d}).seed,
+		func() error { return (&pricesRepo{db: db, dialect: d}).seed(now) },
+	}
+
+	for _, seed := range seeds {
+		if err := seed(); err != nil {
+			return err
+		}
+	}
+
+	// Meetings and races are seeded with their ids, so the ids generated for new ones must come after them
+	for _, table := range []string{"meetings", "races"} {
+		if query := d.SyncID(table); query != "" {
+			if _, err := db.Exec(query); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *racesRepo) seed(now time.Time) error {
+	statement, err := r.db.Prepare(r.dialect.Rebind(`INSERT INTO races(id, meeting_id, name, number, visible, advertised_start_time) VALUES (?,?,?,?,?,?) ON CONFLICT DO NOTHING`))
+	if err != nil {
+		return err
 	}
+	defer statement.Close()
 
 	for i := 1; i <= 100; i++ {
-		statement, err = r.db.Prepare(`INSERT OR IGNORE INTO races(id, meeting_id, name, number, visible, advertised_start_time) VALUES (?,?,?,?,?,?)`)
-		if err == nil {
-			_, err = statement.Exec(
-				i,
-				faker.Number().Between(1, 10),
-				faker.Team().Name(),
-				faker.Number().Between(1, 12),
-				faker.Number().Between(0, 1),
-				faker.Time().Between(time.Now().AddDate(0, 0, -1), time.Now().AddDate(0, 0, 2)).Format(time.RFC3339),
-			)
+		if _, err := statement.Exec(
+			i,
+			// Races belong to one of the dummy meetings
+			faker.Number().Between(1, len(seedMeetings)),
+			faker.Team().Name(),
+			faker.Number().Between(1, 12),
+			faker.Number().Between(0, 1),
+			// Stored in UTC so start times sort and compare correctly as text
+			faker.Time().Between(now.AddDate(0, 0, -1), now.AddDate(0, 0, 2)).UTC().Format(time.RFC3339),
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// seedMeetings are the dummy meetings, their ids are their position in the list starting from 1.
+var seedMeetings = []*racing.Meeting{
+	{Name: "Flemington", Venue: "Flemington Racecourse", Country: "AU", RaceType: racing.RaceType_THOROUGHBRED},
+	{Name: "Randwick", Venue: "Royal Randwick Racecourse", Country: "AU", RaceType: racing.RaceType_THOROUGHBRED},
+	{Name: "Eagle Farm", Venue: "Eagle Farm Racecourse", Country: "AU", RaceType: racing.RaceType_THOROUGHBRED},
+	{Name: "Ellerslie", Venue: "Ellerslie Racecourse", Country: "NZ", RaceType: racing.RaceType_THOROUGHBRED},
+	{Name: "Ascot", Venue: "Ascot Racecourse", Country: "GB", RaceType: racing.RaceType_THOROUGHBRED},
+	{Name: "Sha Tin", Venue: "Sha Tin Racecourse", Country: "HK", RaceType: racing.RaceType_THOROUGHBRED},
+	{Name: "Menangle", Venue: "Menangle Park Paceway", Country: "AU", RaceType: racing.RaceType_HARNESS},
+	{Name: "Addington", Venue: "Addington Raceway", Country: "NZ", RaceType: racing.RaceType_HARNESS},
+	{Name: "The Meadows", Venue: "The Meadows", Country: "AU", RaceType: racing.RaceType_GREYHOUND},
+	{Name: "Wentworth Park", Venue: "Wentworth Park", Country: "AU", RaceType: racing.RaceType_GREYHOUND},
+}
+
+func (r *meetingsRepo) seed() error {
+	statement, err := r.db.Prepare(r.dialect.Rebind(`INSERT INTO meetings(id, name, venue, country, race_type) VALUES (?,?,?,?,?) ON CONFLICT DO NOTHING`))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for i, meeting := range seedMeetings {
+		if _, err := statement.Exec(
+			i+1,
+			meeting.Name,
+			meeting.Venue,
+			meeting.Country,
+			meeting.RaceType.String(),
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// horseNamePrefixes and horseNameSuffixes are combined to name the dummy runners.
+var (
+	horseNamePrefixes = []string{"Midnight", "Golden", "Silver", "Royal", "Lucky", "Storm", "Desert", "Rapid", "Black", "Flying", "Northern", "Wild", "Bold", "Secret", "Star", "Ocean", "Iron", "Little", "Grand", "Sunny"}
+	horseNameSuffixes = []string{"Express", "Dancer", "Spirit", "Legend", "Warrior", "Prince", "Queen", "Arrow", "Thunder", "Charm", "Runner", "Flyer", "Empire", "Whisper", "Fortune", "Rebel", "Knight", "Gem", "Comet", "Boy"}
+)
+
+func (r *runnersRepo) seed() error {
+	// Fields have a random size, so runners are only seeded when there are none yet
+	var count int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM runners`).Scan(&count); err != nil || count > 0 {
+		return err
+	}
+
+	raceIDs, err := r.seedRaceIDs()
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement, err := tx.Prepare(r.dialect.Rebind(`INSERT INTO runners(race_id, barrier, saddle_number, name, jockey, trainer, weight, scratched) VALUES (?,?,?,?,?,?,?,?)`))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for _, raceID := range raceIDs {
+		fieldSize := faker.RandomInt(8, 16)
+		barriers := rand.Perm(fieldSize)
+		names := make(map[string]bool, fieldSize)
+
+		// Saddle numbers are allocated from the heaviest to the lightest runner, from 61.5kg down to 54kg
+		weight := 61.5
+		for i := 0; i < fieldSize; i++ {
+			name := faker.RandomChoice(horseNamePrefixes) + " " + faker.RandomChoice(horseNameSuffixes)
+			for names[name] {
+				name = faker.RandomChoice(horseNamePrefixes) + " " + faker.RandomChoice(horseNameSuffixes)
+			}
+			names[name] = true
+
+			if _, err := statement.Exec(
+				raceID,
+				barriers[i]+1,
+				i+1,
+				name,
+				faker.Name().FirstName()+" "+faker.Name().LastName(),
+				faker.Name().FirstName()+" "+faker.Name().LastName(),
+				weight,
+				storedFlag(faker.RandomInt(1, 20) == 1),
+			); err != nil {
+				return err
+			}
+
+			weight -= 0.5 * float64(faker.RandomInt(0, 2))
+			if weight < 54 {
+				weight = 54
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// seedRaceIDs returns the ids of the races the runners are seeded for.
+func (r *runnersRepo) seedRaceIDs() ([]int64, error) {
+	rows, err := r.db.Query(`SELECT id FROM races`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var raceIDs []int64
+	for rows.Next() {
+		var raceID int64
+		if err := rows.Scan(&raceID); err != nil {
+			return nil, err
+		}
+		raceIDs = append(raceIDs, raceID)
+	}
+
+	return raceIDs, rows.Err()
+}
+
+// seedOverround is how much the dummy win prices of a race add up to over 100%.
+const seedOverround = 1.2
+
+// seedRoundPrice rounds a price to the increments used on the betting boards, coarser as prices get longer.
+func seedRoundPrice(price float64) float64 {
+	step := 0.05
+	switch {
+	case price >= 20:
+		step = 1
+	case price >= 10:
+		step = 0.5
+	case price >= 5:
+		step = 0.1
+	}
+
+	price = math.Min(math.Max(math.Round(price/step)*step, 1.05), 101)
+
+	// Rounded to cents so the steps don't leave floating point noise behind
+	return math.Round(price*100) / 100
+}
+
+// seedPlacePrice derives the place price from the win price, paying on the first three places.
+func seedPlacePrice(win float64) float64 {
+	return seedRoundPrice(1 + (win-1)/4)
+}
+
+// seedRunner is a runner the dummy prices are seeded for.
+type seedRunner struct {
+	id                  int64
+	raceID              int64
+	scratched           bool
+	advertisedStartTime time.Time
+}
+
+func (r *pricesRepo) seed(now time.Time) error {
+	// Prices are random, so they are only seeded when there are none yet
+	var count int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM price_history`).Scan(&count); err != nil || count > 0 {
+		return err
+	}
+
+	runners, err := r.seedRunners()
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement, err := tx.Prepare(r.dialect.Rebind(`INSERT INTO price_history(runner_id, win, place, time) VALUES (?,?,?,?)`))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	// Each runner gets a random chance of winning, and the win prices of a race are shaped from those chances
+	chances := make(map[int64]float64, len(runners))
+	totals := make(map[int64]float64)
+	for _, runner := range runners {
+		if runner.scratched {
+			continue
+		}
+		chances[runner.id] = math.Pow(rand.Float64()+0.05, 2)
+		totals[runner.raceID] += chances[runner.id]
+	}
+
+	for _, runner := range runners {
+		if runner.scratched {
+			continue
+		}
+
+		// Markets open the day before the race, movements happen until the race starts or until now
+		opening := runner.advertisedStartTime.Add(-24 * time.Hour)
+		closing := runner.advertisedStartTime
+		if closing.After(now) {
+			closing = now
+		}
+		if !opening.Before(closing) {
+			opening = closing.Add(-2 * time.Hour)
+		}
+
+		win := seedRoundPrice(totals[runner.raceID] / (chances[runner.id] * seedOverround))
+		if _, err := statement.Exec(runner.id, win, seedPlacePrice(win), opening.UTC().Format(priceTimeFormat)); err != nil {
+			return err
+		}
+
+		times := make([]time.Time, faker.RandomInt(0, 6))
+		for i := range times {
+			times[i] = opening.Add(time.Duration(rand.Int63n(int64(closing.Sub(opening)))))
+		}
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+		for _, moved := range times {
+			next := seedRoundPrice(win * (0.8 + rand.Float64()*0.45))
+			if next == win {
+				continue
+			}
+			win = next
+
+			if _, err := statement.Exec(runner.id, win, seedPlacePrice(win), moved.UTC().Format(priceTimeFormat)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// seedRunners returns the runners the prices are seeded for, along with when their race starts.
+func (r *pricesRepo) seedRunners() ([]seedRunner, error) {
+	rows, err := r.db.Query(`SELECT runners.id, runners.race_id, runners.scratched, races.advertised_start_time FROM runners JOIN races ON races.id = runners.race_id ORDER BY runners.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runners []seedRunner
+	for rows.Next() {
+		var runner seedRunner
+		if err := rows.Scan(&runner.id, &runner.raceID, &runner.scratched, &runner.advertisedStartTime); err != nil {
+			return nil, err
 		}
+		runners = append(runners, runner)
 	}
 
-	return err
+	return runners, rows.Err()
 }
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"git.neds.sh/matty/entain/common/order"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// raceRow is a race as it is scanned from the selected columns.
+type raceRow struct {
+	race            racing.Race
+	advertisedStart time.Time
+	status          sql.NullString
+}
+
+// raceColumn is a column of the races table, along with where it is scanned to.
+type raceColumn struct {
+	name string
+	dest func(row *raceRow) interface{}
+}
+
+// raceColumns are the columns races can be read from, in the order they are selected.
+var raceColumns = []raceColumn{
+	{name: "id", dest: func(row *raceRow) interface{} { return &row.race.Id }},
+	{name: "meeting_id", dest: func(row *raceRow) interface{} { return &row.race.MeetingId }},
+	{name: "name", dest: func(row *raceRow) interface{} { return &row.race.Name }},
+	{name: "number", dest: func(row *raceRow) interface{} { return &row.race.Number }},
+	{name: "visible", dest: func(row *raceRow) interface{} { return &row.race.Visible }},
+	{name: "advertised_start_time", dest: func(row *raceRow) interface{} { return &row.advertisedStart }},
+	{name: "status", dest: func(row *raceRow) interface{} { return &row.status }},
+	{name: "version", dest: func(row *raceRow) interface{} { return &row.race.Version }},
+}
+
+// readFields has the columns each field of a race is read from, as named in read masks. Statuses are derived
+// from the advertised start time when none was set, and runners are read separately. Paths are matched ignoring
+// case and underscores, so both advertisedStartTime and advertised_start_time are accepted.
+var readFields = map[string][]string{
+	"id":                    {"id"},
+	"meeting_id":            {"meeting_id"},
+	"name":                  {"name"},
+	"number":                {"number"},
+	"visible":               {"visible"},
+	"advertised_start_time": {"advertised_start_time"},
+	"status":                {"status", "advertised_start_time"},
+	"runners":               nil,
+	"version":               {"version"},
+}
+
+// ReadMaskError is returned when a read mask has a path that isn't a field of a race.
+type ReadMaskError struct {
+	// Path is the requested path.
+	Path string
+}
+
+func (e *ReadMaskError) Error() string {
+	return fmt.Sprintf("read_mask path %q is not a field of a race", e.Path)
+}
+
+// findReadField returns the name of the field of a race matching a read mask path, or "" if there is none.
+func findReadField(path string) string {
+	normalised := order.NormaliseName(path)
+
+	for field := range readFields {
+		if order.NormaliseName(field) == normalised {
+			return field
+		}
+	}
+
+	return ""
+}
+
+// readSelection has the columns selected for a read mask, and the fields the races are returned with.
+type readSelection struct {
+	columns []raceColumn
+	// fields is nil when every field is returned.
+	fields map[string]bool
+}
+
+// newReadSelection selects the columns of the fields in the read mask. Every field is read when the mask
+// is empty or has "*". The id, and the fields in extra, are always selected as they are needed to read
+// the races, but they are only returned if they are in the mask.
+func newReadSelection(readMask *fieldmaskpb.FieldMask, extra ...string) (*readSelection, error) {
+	selection := &readSelection{}
+
+	paths := readMask.GetPaths()
+	for _, path := range paths {
+		if path == "*" {
+			paths = nil
+			break
+		}
+	}
+
+	if len(paths) == 0 {
+		selection.columns = raceColumns
+		return selection, nil
+	}
+
+	selected := map[string]bool{"id": true}
+	selection.fields = make(map[string]bool, len(paths))
+
+	for _, path := range paths {
+		field := findReadField(path)
+		if field == "" {
+			return nil, &ReadMaskError{Path: path}
+		}
+
+		selection.fields[field] = true
+		for _, column := range readFields[field] {
+			selected[column] = true
+		}
+	}
+
+	for _, field := range extra {
+		for _, column := range readFields[field] {
+			selected[column] = true
+		}
+	}
+
+	for _, column := range raceColumns {
+		if selected[column.name] {
+			selection.columns = append(selection.columns, column)
+		}
+	}
+
+	return selection, nil
+}
+
+// query returns the query selecting the columns from the races.
+func (s *readSelection) query() string {
+	names := make([]string, 0, len(s.columns))
+	for _, column := range s.columns {
+		names = append(names, column.name)
+	}
+
+	return fmt.Sprintf(getRaceQueries()[racesList], strings.Join(names, ", "))
+}
+
+// prune clears the fields of the races that weren't requested.
+func (s *readSelection) prune(races []*racing.Race) {
+	if s.fields == nil {
+		return
+	}
+
+	for _, race := range races {
+		msg := race.ProtoReflect()
+
+		var cleared []protoreflect.FieldDescriptor
+		msg.Range(func(field protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+			if !s.fields[string(field.Name())] {
+				cleared = append(cleared, field)
+			}
+			return true
+		})
+
+		for _, field := range cleared {
+			msg.Clear(field)
+		}
+	}
+}
+
+// ReadsField reports whether a field of a race is returned for a read mask.
+func ReadsField(readMask *fieldmaskpb.FieldMask, field string) bool {
+	paths := readMask.GetPaths()
+	if len(paths) == 0 {
+		return true
+	}
+
+	for _, path := range paths {
+		if path == "*" || findReadField(path) == field {
+			return true
+		}
+	}
+
+	return false
+}
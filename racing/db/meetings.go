@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// MeetingsRepo provides repository access to meetings.
+type MeetingsRepo interface {
+	// Init will initialise our meetings repository.
+	Init() error
+
+	// List will return a list of meetings.
+	List(ctx context.Context, filter *racing.ListMeetingsRequestFilter) ([]*racing.Meeting, error)
+
+	// Get will return a single meeting. It will return an error if no meeting is found
+	Get(ctx context.Context, id int64) (*racing.Meeting, error)
+}
+
+type meetingsRepo struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+}
+
+// NewMeetingsRepo creates a new meetings repository.
+func NewMeetingsRepo(db *sql.DB, opts ...Option) MeetingsRepo {
+	return &meetingsRepo{db: db, dialect: newOptions(opts).dialect}
+}
+
+// Init prepares the meetings repository. Its tables are created by the migrations.
+func (r *meetingsRepo) Init() error {
+	return nil
+}
+
+// List Returns a list of meetings
+func (r *meetingsRepo) List(ctx context.Context, filter *racing.ListMeetingsRequestFilter) ([]*racing.Meeting, error) {
+	query, args := r.applyFilter(getMeetingQueries()[meetingsList], filter)
+	query += " ORDER BY id"
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scanMeetings(rows)
+}
+
+func (r *meetingsRepo) applyFilter(query string, filter *racing.ListMeetingsRequestFilter) (string, []interface{}) {
+	var (
+		clauses []string
+		args    []interface{}
+	)
+
+	if filter == nil {
+		return query, args
+	}
+
+	if len(filter.RaceTypes) > 0 {
+		raceTypes := make([]interface{}, 0, len(filter.RaceTypes))
+		for _, raceType := range filter.RaceTypes {
+			raceTypes = append(raceTypes, raceType.String())
+		}
+
+		clause, inArgs := r.dialect.In("race_type", raceTypes)
+		clauses = append(clauses, clause)
+		args = append(args, inArgs...)
+	}
+
+	if len(filter.Countries) > 0 {
+		countries := make([]interface{}, 0, len(filter.Countries))
+		for _, country := range filter.Countries {
+			countries = append(countries, strings.ToUpper(country))
+		}
+
+		clause, inArgs := r.dialect.In("country", countries)
+		clauses = append(clauses, clause)
+		args = append(args, inArgs...)
+	}
+
+	if len(clauses) != 0 {
+		query += " WHERE " + strings.Join(clauses, " AND ")
+	}
+
+	return query, args
+}
+
+// Get Return a single meeting by id
+func (r *meetingsRepo) Get(ctx context.Context, id int64) (*racing.Meeting, error) {
+	query := getMeetingQueries()[meetingsList]
+	query += " WHERE id = ?"
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), id)
+	if err != nil {
+		return nil, err
+	}
+
+	meetings, err := r.scanMeetings(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(meetings) != 1 {
+		// in case a meeting is not found return an error for no rows
+		return nil, sql.ErrNoRows
+	}
+
+	return meetings[0], nil
+}
+
+func (r *meetingsRepo) scanMeetings(rows *sql.Rows) ([]*racing.Meeting, error) {
+	defer rows.Close()
+
+	var meetings []*racing.Meeting
+
+	for rows.Next() {
+		var meeting racing.Meeting
+		var raceType string
+
+		if err := rows.Scan(&meeting.Id, &meeting.Name, &meeting.Venue, &meeting.Country, &raceType); err != nil {
+			return nil, err
+		}
+
+		meeting.RaceType = racing.RaceType(racing.RaceType_value[raceType])
+		meetings = append(meetings, &meeting)
+	}
+
+	return meetings, rows.Err()
+}
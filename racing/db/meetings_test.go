@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMeetingsRepo_List(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	// Create a new MeetingsRepo, seeding the dummy meetings
+	if err := initTestMeetingsDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+	meetingsRepo := NewMeetingsRepo(db)
+
+	testCases := []struct {
+		name        string
+		filter      *racing.ListMeetingsRequestFilter
+		expectedIds []int64
+	}{
+		{
+			name:        "NoFilter",
+			filter:      nil,
+			expectedIds: []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		},
+		{
+			name: "FilterByRaceTypes",
+			filter: &racing.ListMeetingsRequestFilter{
+				RaceTypes: []racing.RaceType{racing.RaceType_HARNESS, racing.RaceType_GREYHOUND},
+			},
+			expectedIds: []int64{7, 8, 9, 10},
+		},
+		{
+			name: "FilterByCountries",
+			filter: &racing.ListMeetingsRequestFilter{
+				Countries: []string{"nz"},
+			},
+			expectedIds: []int64{4, 8},
+		},
+		{
+			name: "FilterByRaceTypesAndCountries",
+			filter: &racing.ListMeetingsRequestFilter{
+				RaceTypes: []racing.RaceType{racing.RaceType_THOROUGHBRED},
+				Countries: []string{"NZ", "GB"},
+			},
+			expectedIds: []int64{4, 5},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			meetings, err := meetingsRepo.List(context.Background(), tc.filter)
+			if err != nil {
+				t.Fatalf("failed to get meetings: %v", err)
+			}
+
+			var ids []int64
+			for _, meeting := range meetings {
+				ids = append(ids, meeting.Id)
+			}
+
+			assert.Equal(t, tc.expectedIds, ids)
+		})
+	}
+}
+
+func TestMeetingsRepo_Get(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	// Create a new MeetingsRepo, seeding the dummy meetings
+	if err := initTestMeetingsDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+	meetingsRepo := NewMeetingsRepo(db)
+
+	t.Run("GetById", func(t *testing.T) {
+		meeting, err := meetingsRepo.Get(context.Background(), 7)
+		if err != nil {
+			t.Fatalf("failed to get meeting: %v", err)
+		}
+
+		assert.Equal(t, &racing.Meeting{
+			Id:       7,
+			Name:     "Menangle",
+			Venue:    "Menangle Park Paceway",
+			Country:  "AU",
+			RaceType: racing.RaceType_HARNESS,
+		}, meeting)
+	})
+
+	t.Run("GetByIdNotFound", func(t *testing.T) {
+		_, err := meetingsRepo.Get(context.Background(), 999)
+		if err != sql.ErrNoRows {
+			t.Fatalf("failed to get meeting: %v", err)
+		}
+	})
+}
+
+// initTestMeetingsDB creates the tables of a test database and seeds the dummy meetings.
+func initTestMeetingsDB(db *sql.DB) error {
+	if err := migrateTestDB(db); err != nil {
+		return err
+	}
+
+	return (&meetingsRepo{db: db}).seed()
+}
@@ -0,0 +1,19 @@
+package db
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// migrationFiles are the SQL files of the migrations of the racing database, applied with the migrations package of
+// common.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migrations returns the migrations of the racing database, with a directory of SQL files per driver.
+func Migrations() fs.FS {
+	// The directory is embedded, so it is always there
+	files, _ := fs.Sub(migrationFiles, "migrations")
+	return files
+}
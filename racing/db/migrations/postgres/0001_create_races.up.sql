@@ -0,0 +1,2 @@
+-- Flags are stored as 0 or 1 and times as timestamps, so the rows are read the same way as from SQLite.
+CREATE TABLE IF NOT EXISTS races (id BIGSERIAL PRIMARY KEY, meeting_id BIGINT, name TEXT, number BIGINT, visible INTEGER, advertised_start_time TIMESTAMPTZ);
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS runners (id BIGSERIAL PRIMARY KEY, race_id BIGINT, barrier BIGINT, saddle_number BIGINT, name TEXT, jockey TEXT, trainer TEXT, weight DOUBLE PRECISION, scratched INTEGER);
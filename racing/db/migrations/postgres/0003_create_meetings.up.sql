@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS meetings (id BIGSERIAL PRIMARY KEY, name TEXT, venue TEXT, country TEXT, race_type TEXT);
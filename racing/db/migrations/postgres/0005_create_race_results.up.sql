@@ -0,0 +1,3 @@
+CREATE TABLE IF NOT EXISTS race_results (race_id BIGINT PRIMARY KEY, final INTEGER, official_time_ms BIGINT, updated_time TIMESTAMPTZ);
+
+CREATE TABLE IF NOT EXISTS race_placings (race_id BIGINT, position BIGINT, runner_id BIGINT, margin DOUBLE PRECISION, dead_heat INTEGER, PRIMARY KEY (race_id, runner_id));
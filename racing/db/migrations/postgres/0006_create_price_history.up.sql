@@ -0,0 +1,4 @@
+-- Price times are stored as text with milliseconds, like in SQLite, so they sort the same way
+CREATE TABLE IF NOT EXISTS price_history (id BIGSERIAL PRIMARY KEY, runner_id BIGINT, win DOUBLE PRECISION, place DOUBLE PRECISION, time TEXT);
+
+CREATE INDEX IF NOT EXISTS price_history_runner_time ON price_history (runner_id, time);
@@ -0,0 +1,2 @@
+-- The races table is only created when it doesn't exist, so databases created before the migrations are taken over as is.
+CREATE TABLE IF NOT EXISTS races (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME);
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS runners (id INTEGER PRIMARY KEY, race_id INTEGER, barrier INTEGER, saddle_number INTEGER, name TEXT, jockey TEXT, trainer TEXT, weight REAL, scratched INTEGER);
@@ -0,0 +1,2 @@
+-- Races without a status have it derived from their advertised start time
+ALTER TABLE races ADD COLUMN status TEXT;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS race_placings;
+DROP TABLE IF EXISTS race_results;
@@ -0,0 +1,3 @@
+CREATE TABLE IF NOT EXISTS race_results (race_id INTEGER PRIMARY KEY, final INTEGER, official_time_ms INTEGER, updated_time DATETIME);
+
+CREATE TABLE IF NOT EXISTS race_placings (race_id INTEGER, position INTEGER, runner_id INTEGER, margin REAL, dead_heat INTEGER, PRIMARY KEY (race_id, runner_id));
@@ -0,0 +1,3 @@
+CREATE TABLE IF NOT EXISTS price_history (id INTEGER PRIMARY KEY, runner_id INTEGER, win REAL, place REAL, time TEXT);
+
+CREATE INDEX IF NOT EXISTS price_history_runner_time ON price_history (runner_id, time);
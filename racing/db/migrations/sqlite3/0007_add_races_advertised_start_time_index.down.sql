@@ -0,0 +1 @@
+DROP INDEX IF EXISTS races_advertised_start_time;
@@ -0,0 +1,2 @@
+-- Races are listed by start time ranges, e.g. the next hour of racing
+CREATE INDEX IF NOT EXISTS races_advertised_start_time ON races (advertised_start_time);
@@ -0,0 +1,2 @@
+-- Races are updated at the version they were read at, so concurrent changes aren't overwritten
+ALTER TABLE races ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
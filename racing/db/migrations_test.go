@@ -0,0 +1,139 @@
+package db
+
+import (
+	"database/sql"
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/common/db/migrations"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	all, err := migrations.All(Migrations(), dialect.SQLite)
+	if err != nil {
+		t.Fatalf("failed to read migrations: %v", err)
+	}
+	assert.NotEmpty(t, all)
+
+	// Every migration is applied once, in order
+	applied, err := migrations.Up(db, Migrations(), dialect.SQLite)
+	if err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+	assert.Equal(t, all, applied)
+
+	applied, err = migrations.Up(db, Migrations(), dialect.SQLite)
+	if err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+	assert.Empty(t, applied)
+
+	_, err = db.Exec(`INSERT INTO races(meeting_id, name, number, visible, advertised_start_time, status) VALUES (1, 'Race', 1, 1, '2023-07-15T12:00:00Z', 'OPEN')`)
+	assert.NoError(t, err)
+
+	// The last migration is rolled back first
+	rolledBack, err := migrations.Down(db, Migrations(), dialect.SQLite, 1)
+	if err != nil {
+		t.Fatalf("failed to roll back migrations: %v", err)
+	}
+	assert.Equal(t, all[len(all)-1:], rolledBack)
+
+	states, err := migrations.Status(db, Migrations(), dialect.SQLite)
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	assert.Len(t, states, len(all))
+	for i, state := range states {
+		assert.Equal(t, all[i], state.Migration)
+		assert.Equal(t, i < len(all)-1, state.Applied(), "migration %d_%s", state.Version, state.Name)
+	}
+
+	// Rolling back more migrations than were applied rolls back all of them
+	rolledBack, err = migrations.Down(db, Migrations(), dialect.SQLite, len(all)+1)
+	if err != nil {
+		t.Fatalf("failed to roll back migrations: %v", err)
+	}
+	assert.Len(t, rolledBack, len(all)-1)
+
+	var tables int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name != 'schema_migrations'`).Scan(&tables)
+	assert.NoError(t, err)
+	assert.Zero(t, tables)
+
+	applied, err = migrations.Up(db, Migrations(), dialect.SQLite)
+	if err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+	assert.Equal(t, all, applied)
+}
+
+func TestMigrations_Dialects(t *testing.T) {
+	sqliteAll, err := migrations.All(Migrations(), dialect.SQLite)
+	if err != nil {
+		t.Fatalf("failed to read migrations: %v", err)
+	}
+
+	postgresAll, err := migrations.All(Migrations(), dialect.Postgres)
+	if err != nil {
+		t.Fatalf("failed to read migrations: %v", err)
+	}
+
+	// Every dialect has the same migrations, so databases of either are at the same version
+	assert.Len(t, postgresAll, len(sqliteAll))
+	for i, migration := range sqliteAll {
+		if i < len(postgresAll) {
+			assert.Equal(t, migration.Version, postgresAll[i].Version)
+			assert.Equal(t, migration.Name, postgresAll[i].Name)
+		}
+	}
+}
+
+func TestMigrations_BaselineDatabase(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	// A database seeded before the migrations, with the races table as it was then
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS races (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME)`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO races(id, meeting_id, name, number, visible, advertised_start_time) VALUES (1, 5, 'North Dakota foes', 3, 1, '2023-07-15T12:00:00Z')`)
+	assert.NoError(t, err)
+
+	all, err := migrations.All(Migrations(), dialect.SQLite)
+	if err != nil {
+		t.Fatalf("failed to read migrations: %v", err)
+	}
+
+	applied, err := migrations.Up(db, Migrations(), dialect.SQLite)
+	if err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+	assert.Equal(t, all, applied)
+
+	// The races are kept, with their status left to be derived and at their first version
+	var (
+		name    string
+		status  sql.NullString
+		version int64
+	)
+	err = db.QueryRow(`SELECT name, status, version FROM races WHERE id = 1`).Scan(&name, &status, &version)
+	assert.NoError(t, err)
+	assert.Equal(t, "North Dakota foes", name)
+	assert.False(t, status.Valid)
+	assert.Equal(t, int64(1), version)
+
+	for _, table := range []string{"meetings", "runners", "race_results", "race_placings", "price_history"} {
+		var count int
+		assert.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM `+table).Scan(&count), table)
+	}
+}
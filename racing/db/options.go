@@ -0,0 +1,38 @@
+package db
+
+import (
+	"git.neds.sh/matty/entain/common/clock"
+	"git.neds.sh/matty/entain/common/db/dialect"
+)
+
+// Option configures a repository.
+type Option func(o *options)
+
+// options are the settings shared by the repositories.
+type options struct {
+	dialect dialect.Dialect
+	clock   clock.Clock
+}
+
+// WithDialect sets the SQL dialect of the database the repository is backed by, SQLite by default.
+func WithDialect(d dialect.Dialect) Option {
+	return func(o *options) {
+		o.dialect = d
+	}
+}
+
+// WithClock sets the clock the dummy data is seeded around, the system clock by default.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{clock: clock.System}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"time"
+
+	"git.neds.sh/matty/entain/common/order"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// orderFields is the whitelist of fields races can be sorted by.
+var orderFields = order.Fields{
+	{
+		Name:   "id",
+		Column: "id",
+		Value: raceValue(func(race *racing.Race) interface{} {
+			return race.Id
+		}),
+	},
+	{
+		Name:   "name",
+		Column: "name",
+		Value: raceValue(func(race *racing.Race) interface{} {
+			return race.Name
+		}),
+	},
+	{
+		Name:   "number",
+		Column: "number",
+		Value: raceValue(func(race *racing.Race) interface{} {
+			return int64(race.Number)
+		}),
+	},
+	{
+		Name:   "meetingId",
+		Column: "meeting_id",
+		Value: raceValue(func(race *racing.Race) interface{} {
+			return race.MeetingId
+		}),
+	},
+	{
+		Name:   "visible",
+		Column: "visible",
+		Value: raceValue(func(race *racing.Race) interface{} {
+			return storedFlag(race.Visible)
+		}),
+	},
+	{
+		// Statuses are sorted by name, including the ones derived from the advertised start time
+		Name:       "status",
+		Column:     "status",
+		Expression: raceStatus,
+		Value: raceValue(func(race *racing.Race) interface{} {
+			return race.Status.String()
+		}),
+	},
+	{
+		Name:   "advertisedStartTime",
+		Column: "advertised_start_time",
+		Value: raceValue(func(race *racing.Race) interface{} {
+			return race.AdvertisedStartTime.AsTime().UTC().Format(time.RFC3339)
+		}),
+	},
+}
+
+// raceValue adapts a function returning the value of a field for a race to the order package.
+func raceValue(value func(race *racing.Race) interface{}) func(entity interface{}) interface{} {
+	return func(entity interface{}) interface{} {
+		return value(entity.(*racing.Race))
+	}
+}
+
+// OrderFieldError is returned when races are sorted by a field that isn't in the whitelist.
+type OrderFieldError = order.FieldError
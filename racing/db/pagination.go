@@ -0,0 +1,122 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"git.neds.sh/matty/entain/common/order"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// ErrInvalidPageToken is returned when a page token can't be decoded or was issued for a different query.
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// pageCursor points at the last race of a page. The next page starts right after it.
+type pageCursor struct {
+	// Query is a fingerprint of the filter and order the cursor was issued for.
+	Query string `json:"q"`
+	// Values has the value of each order column for the last race.
+	Values []interface{} `json:"v"`
+}
+
+// queryFingerprint identifies the filter and order of a list, so a page token can't be used with a different one.
+func queryFingerprint(filter *racing.ListRacesRequestFilter, columns []order.Column) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(filter)
+	if err != nil {
+		return "", err
+	}
+
+	hash := fnv.New64a()
+	hash.Write(data)
+	for _, column := range columns {
+		hash.Write([]byte(column.Name))
+		if column.Desc {
+			hash.Write([]byte(" desc"))
+		}
+	}
+
+	return strconv.FormatUint(hash.Sum64(), 36), nil
+}
+
+// encodePageToken creates the opaque token for the page following the given race.
+func encodePageToken(fingerprint string, columns []order.Column, race *racing.Race) (string, error) {
+	cursor := pageCursor{Query: fingerprint}
+	for _, column := range columns {
+		cursor.Values = append(cursor.Values, orderFields.FindColumn(column.Name).Value(race))
+	}
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodePageToken reads a token created by encodePageToken, making sure it was issued for the same query.
+func decodePageToken(token string, fingerprint string, columns []order.Column) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+
+	var cursor pageCursor
+	if err := decoder.Decode(&cursor); err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	if cursor.Query != fingerprint || len(cursor.Values) != len(columns) {
+		return nil, ErrInvalidPageToken
+	}
+
+	// JSON numbers are kept as int64 so they are bound as integers
+	for i, value := range cursor.Values {
+		if number, ok := value.(json.Number); ok {
+			if cursor.Values[i], err = number.Int64(); err != nil {
+				return nil, ErrInvalidPageToken
+			}
+		}
+	}
+
+	return &cursor, nil
+}
+
+// clause returns the condition selecting the races sorted after the cursor. For columns (a, b) it is
+// (a > ?) OR (a = ? AND b > ?), with < used instead of > for descending columns.
+func (c *pageCursor) clause(columns []order.Column) (string, []interface{}) {
+	var (
+		alternatives []string
+		args         []interface{}
+	)
+
+	for i, column := range columns {
+		var conditions []string
+
+		for j := 0; j < i; j++ {
+			conditions = append(conditions, columns[j].SQL+" = ?")
+			args = append(args, columns[j].Args...)
+			args = append(args, c.Values[j])
+		}
+
+		if column.Desc {
+			conditions = append(conditions, column.SQL+" < ?")
+		} else {
+			conditions = append(conditions, column.SQL+" > ?")
+		}
+		args = append(args, column.Args...)
+		args = append(args, c.Values[i])
+
+		alternatives = append(alternatives, "("+strings.Join(conditions, " AND ")+")")
+	}
+
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
@@ -0,0 +1,226 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// priceTimeFormat is how price times are stored. Milliseconds are kept, as the price feed can move a price
+// more than once a second, and are always written so times sort correctly as text.
+const priceTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// PricesRepo provides repository access to the fixed-odds prices of runners.
+type PricesRepo interface {
+	// Init will initialise our prices repository.
+	Init() error
+
+	// Record will store a batch of price updates in a single transaction, skipping the ones that don't
+	// change the price of the runner. It returns the number of updates stored.
+	Record(ctx context.Context, updates []*racing.PriceUpdate) (int32, error)
+
+	// Latest will return the prices of each runner along with their latest price movements, keyed by runner id.
+	// Runners without prices are left out.
+	Latest(ctx context.Context, runnerIDs []int64, flucs int32) (map[int64]*racing.RunnerPrices, error)
+
+	// Flucs will return the prices of a runner along with its price movements between from and to.
+	// When limit is positive only the latest movements are returned.
+	Flucs(ctx context.Context, runnerID int64, from time.Time, to time.Time, limit int32) (*racing.RunnerPrices, error)
+}
+
+type pricesRepo struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+}
+
+// NewPricesRepo creates a new prices repository.
+func NewPricesRepo(db *sql.DB, opts ...Option) PricesRepo {
+	return &pricesRepo{db: db, dialect: newOptions(opts).dialect}
+}
+
+// Init prepares the prices repository. Its tables are created by the migrations.
+func (r *pricesRepo) Init() error {
+	return nil
+}
+
+// Record Stores a batch of price updates
+func (r *pricesRepo) Record(ctx context.Context, updates []*racing.PriceUpdate) (int32, error) {
+	// Updates are applied in time order, so each one is compared with the price it moved from
+	sorted := make([]*racing.PriceUpdate, len(updates))
+	copy(sorted, updates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.AsTime().Before(sorted[j].Time.AsTime())
+	})
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	previous, err := tx.PrepareContext(ctx, r.dialect.Rebind(getPriceQueries()[pricesPrevious]))
+	if err != nil {
+		return 0, err
+	}
+	defer previous.Close()
+
+	insert, err := tx.PrepareContext(ctx, r.dialect.Rebind(`INSERT INTO price_history(runner_id, win, place, time) VALUES (?,?,?,?)`))
+	if err != nil {
+		return 0, err
+	}
+	defer insert.Close()
+
+	var recorded int32
+	for _, update := range sorted {
+		updateTime := update.Time.AsTime().UTC().Format(priceTimeFormat)
+
+		var win, place float64
+		err := previous.QueryRowContext(ctx, update.RunnerId, updateTime).Scan(&win, &place)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return 0, err
+		case win == update.Win && place == update.Place:
+			continue
+		}
+
+		if _, err := insert.ExecContext(ctx, update.RunnerId, update.Win, update.Place, updateTime); err != nil {
+			return 0, err
+		}
+		recorded++
+	}
+
+	return recorded, tx.Commit()
+}
+
+// Latest Returns the prices of the runners with their latest movements
+func (r *pricesRepo) Latest(ctx context.Context, runnerIDs []int64, flucs int32) (map[int64]*racing.RunnerPrices, error) {
+	prices := make(map[int64]*racing.RunnerPrices, len(runnerIDs))
+	if len(runnerIDs) == 0 {
+		return prices, nil
+	}
+
+	ids := make([]interface{}, 0, len(runnerIDs))
+	for _, runnerID := range runnerIDs {
+		ids = append(ids, runnerID)
+	}
+
+	clause, args := r.dialect.In("runner_id", ids)
+	query := fmt.Sprintf(getPriceQueries()[pricesLatest], clause)
+
+	// The current price is always needed, even when no movements are
+	if flucs < 1 {
+		args = append(args, 1)
+	} else {
+		args = append(args, flucs)
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			runnerID         int64
+			latest, earliest int32
+		)
+
+		price, err := scanPrice(rows, &runnerID, &latest, &earliest)
+		if err != nil {
+			return nil, err
+		}
+
+		runnerPrices, ok := prices[runnerID]
+		if !ok {
+			runnerPrices = &racing.RunnerPrices{}
+			prices[runnerID] = runnerPrices
+		}
+
+		if earliest == 1 {
+			runnerPrices.Opening = price
+		}
+		if latest == 1 {
+			runnerPrices.Current = price
+		}
+		if latest <= flucs {
+			runnerPrices.Flucs = append(runnerPrices.Flucs, price)
+		}
+	}
+
+	return prices, rows.Err()
+}
+
+// Flucs Returns the prices of a runner with its movements in a time range
+func (r *pricesRepo) Flucs(ctx context.Context, runnerID int64, from time.Time, to time.Time, limit int32) (*racing.RunnerPrices, error) {
+	prices, err := r.Latest(ctx, []int64{runnerID}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	runnerPrices, ok := prices[runnerID]
+	if !ok {
+		return &racing.RunnerPrices{}, nil
+	}
+
+	query := getPriceQueries()[pricesFlucs]
+	args := []interface{}{runnerID, from.UTC().Format(priceTimeFormat), to.UTC().Format(priceTimeFormat)}
+
+	if limit > 0 {
+		// The latest movements are kept, in the same order as the others
+		query = "SELECT * FROM (" + query + " ORDER BY time DESC, id DESC LIMIT ?) AS flucs ORDER BY time, id"
+		args = append(args, limit)
+	} else {
+		query += " ORDER BY time, id"
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+
+		price, err := scanPrice(rows, &id)
+		if err != nil {
+			return nil, err
+		}
+
+		runnerPrices.Flucs = append(runnerPrices.Flucs, price)
+	}
+
+	return runnerPrices, rows.Err()
+}
+
+// scanPrice reads a price from the current row, preceded by the given extra columns.
+func scanPrice(rows *sql.Rows, extra ...interface{}) (*racing.RunnerPrice, error) {
+	var (
+		price racing.RunnerPrice
+		when  string
+	)
+
+	if err := rows.Scan(append(extra, &price.Win, &price.Place, &when)...); err != nil {
+		return nil, err
+	}
+
+	parsed, err := time.Parse(priceTimeFormat, when)
+	if err != nil {
+		return nil, err
+	}
+
+	if price.Time, err = ptypes.TimestampProto(parsed); err != nil {
+		return nil, err
+	}
+
+	return &price, nil
+}
@@ -0,0 +1,241 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+)
+
+func TestPricesRepo_Record(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	// Prices aren't seeded, so the price history starts empty
+	pricesRepo, err := initTestPricesDB(db)
+	if err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	recorded, err := pricesRepo.Record(context.Background(), []*racing.PriceUpdate{
+		{RunnerId: 1, Win: 5, Place: 2, Time: testPriceTime(9, 0)},
+		// Out of order updates are recorded in time order
+		{RunnerId: 1, Win: 6, Place: 2.25, Time: testPriceTime(8, 0)},
+		// The price didn't move, so it isn't recorded
+		{RunnerId: 1, Win: 5, Place: 2, Time: testPriceTime(9, 30)},
+		{RunnerId: 1, Win: 4.2, Place: 1.8, Time: testPriceTime(10, 0)},
+		{RunnerId: 2, Win: 3, Place: 1.5, Time: testPriceTime(8, 0)},
+	})
+	if err != nil {
+		t.Fatalf("failed to record prices: %v", err)
+	}
+
+	assert.Equal(t, int32(4), recorded)
+
+	// Sending the same batch again records nothing new
+	recorded, err = pricesRepo.Record(context.Background(), []*racing.PriceUpdate{
+		{RunnerId: 1, Win: 4.2, Place: 1.8, Time: testPriceTime(10, 0)},
+	})
+	if err != nil {
+		t.Fatalf("failed to record prices: %v", err)
+	}
+
+	assert.Equal(t, int32(0), recorded)
+}
+
+func TestPricesRepo_Latest(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	pricesRepo, err := initTestPricesDB(db)
+	if err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	if _, err := pricesRepo.Record(context.Background(), getTestPriceUpdates()); err != nil {
+		t.Fatalf("failed to record prices: %v", err)
+	}
+
+	t.Run("WithoutFlucs", func(t *testing.T) {
+		prices, err := pricesRepo.Latest(context.Background(), []int64{1, 2, 3}, 0)
+		if err != nil {
+			t.Fatalf("failed to get prices: %v", err)
+		}
+
+		assert.Len(t, prices, 2)
+		assert.Equal(t, 6.0, prices[1].Opening.Win)
+		assert.Equal(t, 4.2, prices[1].Current.Win)
+		assert.Empty(t, prices[1].Flucs)
+		// A runner with a single price opened at its current price
+		assert.Equal(t, prices[2].Opening, prices[2].Current)
+	})
+
+	t.Run("LastMovements", func(t *testing.T) {
+		prices, err := pricesRepo.Latest(context.Background(), []int64{1}, 2)
+		if err != nil {
+			t.Fatalf("failed to get prices: %v", err)
+		}
+
+		assert.Equal(t, []float64{5, 4.2}, flucWins(prices[1].Flucs))
+		assert.Equal(t, testPriceTime(10, 0).AsTime(), prices[1].Current.Time.AsTime())
+	})
+}
+
+func TestPricesRepo_Flucs(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	pricesRepo, err := initTestPricesDB(db)
+	if err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	if _, err := pricesRepo.Record(context.Background(), getTestPriceUpdates()); err != nil {
+		t.Fatalf("failed to record prices: %v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		runnerID     int64
+		from         time.Time
+		to           time.Time
+		limit        int32
+		expectedWins []float64
+	}{
+		{
+			name:         "AllMovements",
+			runnerID:     1,
+			to:           testPriceTime(12, 0).AsTime(),
+			expectedWins: []float64{6, 5.5, 5, 4.2},
+		},
+		{
+			name:         "TimeRange",
+			runnerID:     1,
+			from:         testPriceTime(8, 30).AsTime(),
+			to:           testPriceTime(10, 0).AsTime(),
+			expectedWins: []float64{5.5, 5},
+		},
+		{
+			name:         "LatestMovementsInRange",
+			runnerID:     1,
+			to:           testPriceTime(12, 0).AsTime(),
+			limit:        3,
+			expectedWins: []float64{5.5, 5, 4.2},
+		},
+		{
+			name:     "RunnerWithoutPrices",
+			runnerID: 3,
+			to:       testPriceTime(12, 0).AsTime(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prices, err := pricesRepo.Flucs(context.Background(), tc.runnerID, tc.from, tc.to, tc.limit)
+			if err != nil {
+				t.Fatalf("failed to get flucs: %v", err)
+			}
+
+			assert.Equal(t, tc.expectedWins, flucWins(prices.Flucs))
+		})
+	}
+}
+
+func TestPricesRepo_Seed(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	// Prices are seeded for the runners of the existing races
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	if err := (&runnersRepo{db: db}).seed(); err != nil {
+		t.Fatalf("failed to seed runners: %v", err)
+	}
+	runnersRepo := NewRunnersRepo(db)
+
+	if err := (&pricesRepo{db: db}).seed(getDateNow()); err != nil {
+		t.Fatalf("failed to seed prices: %v", err)
+	}
+	pricesRepo := NewPricesRepo(db)
+
+	runners, err := runnersRepo.List(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to get runners: %v", err)
+	}
+
+	var runnerIDs []int64
+	for _, runner := range runners {
+		runnerIDs = append(runnerIDs, runner.Id)
+	}
+
+	prices, err := pricesRepo.Latest(context.Background(), runnerIDs, 10)
+	if err != nil {
+		t.Fatalf("failed to get prices: %v", err)
+	}
+
+	for _, runner := range runners {
+		runnerPrices, ok := prices[runner.Id]
+		if runner.Scratched {
+			assert.False(t, ok, "scratched runner %d has prices", runner.Id)
+			continue
+		}
+
+		if assert.True(t, ok, "runner %d has no prices", runner.Id) {
+			assert.True(t, runnerPrices.Current.Win > runnerPrices.Current.Place)
+			assert.True(t, runnerPrices.Current.Place > 1)
+			assert.Equal(t, runnerPrices.Opening, runnerPrices.Flucs[0])
+		}
+	}
+}
+
+func initTestPricesDB(db *sql.DB) (PricesRepo, error) {
+	if err := initTestDB(db); err != nil {
+		return nil, err
+	}
+
+	return NewPricesRepo(db), nil
+}
+
+func getTestPriceUpdates() []*racing.PriceUpdate {
+	return []*racing.PriceUpdate{
+		{RunnerId: 1, Win: 6, Place: 2.25, Time: testPriceTime(8, 0)},
+		{RunnerId: 1, Win: 5.5, Place: 2.1, Time: testPriceTime(9, 0)},
+		{RunnerId: 1, Win: 5, Place: 2, Time: testPriceTime(9, 59)},
+		{RunnerId: 1, Win: 4.2, Place: 1.8, Time: testPriceTime(10, 0)},
+		{RunnerId: 2, Win: 3, Place: 1.5, Time: testPriceTime(8, 0)},
+	}
+}
+
+func testPriceTime(hour int, minute int) *timestamppb.Timestamp {
+	return timestamppb.New(time.Date(2023, 7, 15, hour, minute, 0, 0, time.UTC))
+}
+
+func flucWins(flucs []*racing.RunnerPrice) []float64 {
+	var wins []float64
+	for _, fluc := range flucs {
+		wins = append(wins, fluc.Win)
+	}
+	return wins
+}
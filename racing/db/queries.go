@@ -1,20 +1,128 @@
 package db
 
 const (
-	racesList = "list"
+	racesList      = "list"
+	runnersList    = "list"
+	meetingsList   = "list"
+	resultsGet     = "get"
+	placingsList   = "list"
+	pricesLatest   = "latest"
+	pricesFlucs    = "flucs"
+	pricesPrevious = "previous"
 )
 
 func getRaceQueries() map[string]string {
 	return map[string]string{
+		// The columns are selected according to the read mask, see readSelection
 		racesList: `
+			SELECT 
+				%s 
+			FROM races
+		`,
+	}
+}
+
+func getRunnerQueries() map[string]string {
+	return map[string]string{
+		runnersList: `
 			SELECT 
 				id, 
-				meeting_id, 
+				race_id, 
+				barrier, 
+				saddle_number, 
 				name, 
-				number, 
-				visible, 
-				advertised_start_time 
-			FROM races
+				jockey, 
+				trainer, 
+				weight, 
+				scratched 
+			FROM runners
+		`,
+	}
+}
+
+func getMeetingQueries() map[string]string {
+	return map[string]string{
+		meetingsList: `
+			SELECT 
+				id, 
+				name, 
+				venue, 
+				country, 
+				race_type 
+			FROM meetings
+		`,
+	}
+}
+
+func getResultQueries() map[string]string {
+	return map[string]string{
+		resultsGet: `
+			SELECT 
+				race_id, 
+				final, 
+				official_time_ms, 
+				updated_time 
+			FROM race_results 
+			WHERE race_id = ?
+		`,
+		placingsList: `
+			SELECT 
+				position, 
+				runner_id, 
+				margin, 
+				dead_heat 
+			FROM race_placings 
+			WHERE race_id = ? 
+			ORDER BY position, runner_id
+		`,
+	}
+}
+
+func getPriceQueries() map[string]string {
+	return map[string]string{
+		// Rows are numbered from the latest and the earliest price of each runner, so the opening price,
+		// the current price and the latest movements are all read at once. The runners are matched by the
+		// IN clause of the dialect
+		pricesLatest: `
+			SELECT 
+				runner_id, 
+				latest, 
+				earliest, 
+				win, 
+				place, 
+				time 
+			FROM (
+				SELECT 
+					id, 
+					runner_id, 
+					win, 
+					place, 
+					time, 
+					ROW_NUMBER() OVER (PARTITION BY runner_id ORDER BY time DESC, id DESC) AS latest, 
+					ROW_NUMBER() OVER (PARTITION BY runner_id ORDER BY time, id) AS earliest 
+				FROM price_history 
+				WHERE %s
+			) AS prices 
+			WHERE latest <= ? OR earliest = 1 
+			ORDER BY runner_id, time, id
+		`,
+		pricesFlucs: `
+			SELECT 
+				id, 
+				win, 
+				place, 
+				time 
+			FROM price_history 
+			WHERE runner_id = ? AND time >= ? AND time < ?
+		`,
+		pricesPrevious: `
+			SELECT 
+				win, 
+				place 
+			FROM price_history 
+			WHERE runner_id = ? AND time <= ? 
+			ORDER BY time DESC, id DESC 
+			LIMIT 1
 		`,
 	}
 }
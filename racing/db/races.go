@@ -1,14 +1,21 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/golang/protobuf/ptypes"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/common/order"
 	"git.neds.sh/matty/entain/racing/proto/racing"
 )
 
@@ -17,80 +24,212 @@ type RacesRepo interface {
 	// Init will initialise our races repository.
 	Init() error
 
-	// List will return a list of races.
-	List(filter *racing.ListRacesRequestFilter, orderBy []*racing.ListRacesRequestOrderBy, currentDate time.Time) ([]*racing.Race, error)
+	// List will return a page of races, along with the token for the next page.
+	// A pageSize of 0 returns every race, the token is empty when there are no more races.
+	// Only the fields in the read mask are read, every field is read when it is nil or empty.
+	List(ctx context.Context, filter *racing.ListRacesRequestFilter, orderBy []*racing.ListRacesRequestOrderBy, pageSize int32, pageToken string, readMask *fieldmaskpb.FieldMask, currentDate time.Time) ([]*racing.Race, string, error)
 
-	// Get will return a single race. It will return an error if no race is found
-	Get(id int64, currentDate time.Time) (*racing.Race, error)
+	// Get will return a single race with the fields in the read mask. It will return an error if no race is found
+	Get(ctx context.Context, id int64, readMask *fieldmaskpb.FieldMask, currentDate time.Time) (*racing.Race, error)
+
+	// BatchGet will return the races with the given ids, in no particular order. Races that aren't found are left out.
+	BatchGet(ctx context.Context, ids []int64, currentDate time.Time) ([]*racing.Race, error)
+
+	// Search will return the races whose name or meeting name match every word of the query,
+	// the most relevant first.
+	Search(ctx context.Context, query string, limit int32, currentDate time.Time) ([]*racing.RaceSearchResult, error)
+
+	// Create will add a race, returning its id. Its status is left to be derived from its advertised start time.
+	Create(ctx context.Context, race *racing.Race) (int64, error)
+
+	// Update will change the given fields of a race, as named in RaceWriteFields, and increment its version.
+	// The race is only changed at its version, otherwise ErrVersionChanged is returned.
+	// It will return an error if no race is found
+	Update(ctx context.Context, race *racing.Race, fields []string) error
+
+	// Delete will remove a race along with its runners and their prices.
+	// It will return an error if no race is found
+	Delete(ctx context.Context, id int64) error
+
+	// UpdateStatus will set the status of a race, as long as it is still in the from status.
+	// It will return ErrStatusChanged if the race is no longer in that status.
+	UpdateStatus(ctx context.Context, id int64, from racing.RaceStatus, to racing.RaceStatus, currentDate time.Time) error
 }
 
+// ErrStatusChanged is returned when the status of a race was changed by someone else in the meantime.
+var ErrStatusChanged = errors.New("race status has changed")
+
+// ErrVersionChanged is returned when a race was changed by someone else since the version it was read at.
+var ErrVersionChanged = errors.New("race version has changed")
+
+// raceStatus is the status of a race, derived from its advertised start time when no status was set.
+// It expects the current time as its argument.
+const raceStatus = "COALESCE(status, CASE WHEN advertised_start_time < ? THEN 'CLOSED' ELSE 'OPEN' END)"
+
 type racesRepo struct {
-	db   *sql.DB
-	init sync.Once
+	db      *sql.DB
+	dialect dialect.Dialect
+	init    sync.Once
 }
 
 // NewRacesRepo creates a new races repository.
-func NewRacesRepo(db *sql.DB) RacesRepo {
-	return &racesRepo{db: db}
+func NewRacesRepo(db *sql.DB, opts ...Option) RacesRepo {
+	return &racesRepo{db: db, dialect: newOptions(opts).dialect}
 }
 
-// Init prepares the race repository dummy data.
+// Init prepares the search index of the race repository. Its tables are created by the migrations.
 func (r *racesRepo) Init() error {
 	var err error
 
 	r.init.Do(func() {
-		// For test/example purposes, we seed the DB with some dummy races.
-		err = r.seed()
+		// The index depends on whether SQLite has FTS5, so it is set up by the build rather than migrated
+		err = r.initSearch()
 	})
 
 	return err
 }
 
-// List Returns a list of races
-func (r *racesRepo) List(filter *racing.ListRacesRequestFilter, orderBy []*racing.ListRacesRequestOrderBy, currentDate time.Time) ([]*racing.Race, error) {
+// List Returns a page of races
+func (r *racesRepo) List(ctx context.Context, filter *racing.ListRacesRequestFilter, orderBy []*racing.ListRacesRequestOrderBy, pageSize int32, pageToken string, readMask *fieldmaskpb.FieldMask, currentDate time.Time) ([]*racing.Race, string, error) {
 	var (
-		err   error
-		query string
-		args  []interface{}
+		err    error
+		query  string
+		args   []interface{}
+		cursor *pageCursor
 	)
 
-	query = getRaceQueries()[racesList]
+	columns, err := r.orderColumns(orderBy, currentDate)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The order columns are needed for the page token
+	orderFieldNames := make([]string, 0, len(columns))
+	for _, column := range columns {
+		orderFieldNames = append(orderFieldNames, column.Name)
+	}
 
-	query, args = r.applyFilter(query, filter)
+	selection, err := newReadSelection(readMask, orderFieldNames...)
+	if err != nil {
+		return nil, "", err
+	}
 
-	query = r.applyOrderBy(query, orderBy)
+	query = selection.query()
 
-	rows, err := r.db.Query(query, args...)
+	fingerprint, err := queryFingerprint(filter, columns)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	if pageToken != "" {
+		if cursor, err = decodePageToken(pageToken, fingerprint, columns); err != nil {
+			return nil, "", err
+		}
+	}
+
+	query, args = r.applyFilter(query, filter, cursor, columns, currentDate)
+
+	query, orderArgs := r.applyOrderBy(query, columns)
+	args = append(args, orderArgs...)
+
+	if pageSize > 0 {
+		// Fetch an extra race to find out if there is a next page
+		query += " LIMIT ?"
+		args = append(args, pageSize+1)
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	races, err := r.scanRaces(rows, selection, currentDate)
+	if err != nil {
+		return nil, "", err
 	}
 
-	return r.scanRaces(rows, currentDate)
+	var nextPageToken string
+	if pageSize > 0 && len(races) > int(pageSize) {
+		races = races[:pageSize]
+
+		if nextPageToken, err = encodePageToken(fingerprint, columns, races[len(races)-1]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	selection.prune(races)
+
+	return races, nextPageToken, nil
 }
 
-func (r *racesRepo) applyFilter(query string, filter *racing.ListRacesRequestFilter) (string, []interface{}) {
+func (r *racesRepo) applyFilter(query string, filter *racing.ListRacesRequestFilter, cursor *pageCursor, columns []order.Column, currentDate time.Time) (string, []interface{}) {
 	var (
 		clauses []string
 		args    []interface{}
 	)
 
-	if filter == nil {
-		return query, args
+	if cursor != nil {
+		clause, cursorArgs := cursor.clause(columns)
+		clauses = append(clauses, clause)
+		args = append(args, cursorArgs...)
 	}
 
-	if len(filter.MeetingIds) > 0 {
-		clauses = append(clauses, "meeting_id IN ("+strings.Repeat("?,", len(filter.MeetingIds)-1)+"?)")
+	if filter != nil {
+		if len(filter.MeetingIds) > 0 {
+			meetingIDs := make([]interface{}, 0, len(filter.MeetingIds))
+			for _, meetingID := range filter.MeetingIds {
+				meetingIDs = append(meetingIDs, meetingID)
+			}
 
-		for _, meetingID := range filter.MeetingIds {
-			args = append(args, meetingID)
+			clause, inArgs := r.dialect.In("meeting_id", meetingIDs)
+			clauses = append(clauses, clause)
+			args = append(args, inArgs...)
+		}
+
+		switch filter.VisibilityStatus {
+		case racing.VisibilityStatus_VISIBLE:
+			clauses = append(clauses, "visible = 1")
+		case racing.VisibilityStatus_HIDDEN:
+			clauses = append(clauses, "visible = 0")
+		}
+
+		if len(filter.Statuses) > 0 {
+			statuses := make([]interface{}, 0, len(filter.Statuses))
+			for _, status := range filter.Statuses {
+				statuses = append(statuses, status.String())
+			}
+
+			clause, inArgs := r.dialect.In(raceStatus, statuses)
+			clauses = append(clauses, clause)
+			args = append(args, currentDate.UTC().Format(time.RFC3339))
+			args = append(args, inArgs...)
 		}
-	}
 
-	switch filter.VisibilityStatus {
-	case racing.VisibilityStatus_VISIBLE:
-		clauses = append(clauses, "visible = 1")
-	case racing.VisibilityStatus_HIDDEN:
-		clauses = append(clauses, "visible = 0")
+		if terms := searchTerms(filter.Search); len(terms) > 0 {
+			clause, searchArgs := r.searchClause(terms)
+			clauses = append(clauses, clause)
+			args = append(args, searchArgs...)
+		}
+
+		// Start times are compared directly, so the advertised_start_time index can be used
+		if filter.StartTimeFrom != nil {
+			clauses = append(clauses, "advertised_start_time >= ?")
+			args = append(args, formatStartTime(filter.StartTimeFrom.AsTime()))
+		}
+
+		if filter.StartTimeTo != nil {
+			clauses = append(clauses, "advertised_start_time < ?")
+			args = append(args, formatStartTime(filter.StartTimeTo.AsTime()))
+		}
+
+		if filter.StartsWithinMinutes > 0 {
+			clauses = append(clauses, "advertised_start_time >= ?", "advertised_start_time < ?")
+			args = append(args,
+				formatStartTime(currentDate),
+				formatStartTime(currentDate.Add(time.Duration(filter.StartsWithinMinutes)*time.Minute)),
+			)
+		}
 	}
 
 	if len(clauses) != 0 {
@@ -100,67 +239,297 @@ func (r *racesRepo) applyFilter(query string, filter *racing.ListRacesRequestFil
 	return query, args
 }
 
-func (r *racesRepo) applyOrderBy(query string, orderBy []*racing.ListRacesRequestOrderBy) string {
+// formatStartTime formats a time the way start times are stored. Start times are stored to the second,
+// so the time is rounded up to the next second to keep the bounds the same.
+func formatStartTime(t time.Time) string {
+	if truncated := t.Truncate(time.Second); !truncated.Equal(t) {
+		t = truncated.Add(time.Second)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// orderColumns returns the columns to sort races by, which must be in the whitelist. The id is always
+// the last one, so races with the same values are kept in the same order across pages.
+func (r *racesRepo) orderColumns(orderBy []*racing.ListRacesRequestOrderBy, currentDate time.Time) ([]order.Column, error) {
+	by := make([]order.By, 0, len(orderBy))
+	for _, orderByClause := range orderBy {
+		by = append(by, order.By{Field: orderByClause.FieldName, Desc: orderByClause.Direction == racing.OrderByDirection_DESC})
+	}
+
+	return orderFields.Columns(by, currentDate)
+}
+
+func (r *racesRepo) applyOrderBy(query string, columns []order.Column) (string, []interface{}) {
 	var (
 		clauses []string
+		args    []interface{}
 	)
 
-	if orderBy == nil {
-		return query
-	}
-
-	for _, orderByClause := range orderBy {
-		if strings.ToLower(orderByClause.FieldName) == "advertisedstarttime" {
-			if orderByClause.Direction == racing.OrderByDirection_DESC {
-				clauses = append(clauses, "advertised_start_time desc")
-			} else {
-				clauses = append(clauses, "advertised_start_time")
-			}
+	for _, column := range columns {
+		if column.Desc {
+			clauses = append(clauses, column.SQL+" desc")
+		} else {
+			clauses = append(clauses, column.SQL)
 		}
+		args = append(args, column.Args...)
 	}
 
 	if len(clauses) != 0 {
 		query += " ORDER BY " + strings.Join(clauses, ",")
 	}
 
-	return query
+	return query, args
 }
 
 // Get Return a single race by id
-func (r *racesRepo) Get(id int64, currentDate time.Time) (*racing.Race, error) {
+func (r *racesRepo) Get(ctx context.Context, id int64, readMask *fieldmaskpb.FieldMask, currentDate time.Time) (*racing.Race, error) {
 	var (
 		err   error
 		query string
 		args  []interface{}
 	)
 
-	query = getRaceQueries()[racesList]
+	selection, err := newReadSelection(readMask)
+	if err != nil {
+		return nil, err
+	}
+
+	query = selection.query()
 	query += " WHERE Id = ?"
 	args = append(args, id)
 
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	races, err := r.scanRaces(rows, currentDate)
+	races, err := r.scanRaces(rows, selection, currentDate)
+	if err != nil {
+		return nil, err
+	}
+	selection.prune(races)
 
 	if len(races) == 1 {
-		return races[0], err
+		return races[0], nil
 	} else {
 		// in case a race is not found return an error for no rows
 		return nil, sql.ErrNoRows
 	}
 }
 
-func (r *racesRepo) scanRaces(rows *sql.Rows, currentDate time.Time) ([]*racing.Race, error) {
+// BatchGet Returns the races with the given ids
+func (r *racesRepo) BatchGet(ctx context.Context, ids []int64, currentDate time.Time) ([]*racing.Race, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	selection, err := newReadSelection(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	clause, args := r.dialect.In("id", args)
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(selection.query()+" WHERE "+clause), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanRaces(rows, selection, currentDate)
+}
+
+// RaceWriteFields are the fields of a race that can be written, as named in update masks.
+var RaceWriteFields = []string{"meeting_id", "name", "number", "visible", "advertised_start_time"}
+
+// raceWriteValue returns the value of a field of a race as it is stored, for the fields in RaceWriteFields.
+func raceWriteValue(race *racing.Race, field string) (interface{}, bool) {
+	switch field {
+	case "meeting_id":
+		return race.MeetingId, true
+	case "name":
+		return race.Name, true
+	case "number":
+		return race.Number, true
+	case "visible":
+		return storedFlag(race.Visible), true
+	case "advertised_start_time":
+		return formatStartTime(race.AdvertisedStartTime.AsTime()), true
+	default:
+		return nil, false
+	}
+}
+
+// storedFlag returns a flag the way it is stored, as 0 or 1 in every dialect.
+func storedFlag(flag bool) int64 {
+	if flag {
+		return 1
+	}
+	return 0
+}
+
+// Create Adds a race
+func (r *racesRepo) Create(ctx context.Context, race *racing.Race) (int64, error) {
+	var (
+		columns      []string
+		placeholders []string
+		args         []interface{}
+	)
+
+	for _, field := range RaceWriteFields {
+		value, _ := raceWriteValue(race, field)
+		columns = append(columns, field)
+		placeholders = append(placeholders, "?")
+		args = append(args, value)
+	}
+
+	// The id is returned by the insert itself, as Postgres has no last insert id
+	query := "INSERT INTO races (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ") RETURNING id"
+
+	var id int64
+	err := r.db.QueryRowContext(ctx, r.dialect.Rebind(query), args...).Scan(&id)
+
+	return id, err
+}
+
+// Update Changes the given fields of a race
+func (r *racesRepo) Update(ctx context.Context, race *racing.Race, fields []string) error {
+	var (
+		clauses []string
+		args    []interface{}
+	)
+
+	for _, field := range fields {
+		value, ok := raceWriteValue(race, field)
+		if !ok {
+			return fmt.Errorf("race field %q can't be updated", field)
+		}
+		clauses = append(clauses, field+" = ?")
+		args = append(args, value)
+	}
+
+	if len(clauses) == 0 {
+		return nil
+	}
+
+	// The version is checked in the same statement, so concurrent changes can't be overwritten
+	query := "UPDATE races SET " + strings.Join(clauses, ", ") + ", version = version + 1 WHERE id = ? AND version = ?"
+	args = append(args, race.Id, race.Version)
+
+	result, err := r.db.ExecContext(ctx, r.dialect.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if updated == 0 {
+		return r.notUpdated(ctx, race.Id)
+	}
+
+	return nil
+}
+
+// notUpdated returns why a race wasn't updated, sql.ErrNoRows if it doesn't exist and ErrVersionChanged otherwise.
+func (r *racesRepo) notUpdated(ctx context.Context, id int64) error {
+	var exists int
+	if err := r.db.QueryRowContext(ctx, r.dialect.Rebind("SELECT 1 FROM races WHERE id = ?"), id).Scan(&exists); err != nil {
+		return err
+	}
+
+	return ErrVersionChanged
+}
+
+// Delete Removes a race with its runners and their prices
+func (r *racesRepo) Delete(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, r.dialect.Rebind("DELETE FROM races WHERE id = ?"), id)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+
+	// Runners and prices are only read through their race, so they would be left behind otherwise
+	if _, err := tx.ExecContext(ctx, r.dialect.Rebind("DELETE FROM price_history WHERE runner_id IN (SELECT id FROM runners WHERE race_id = ?)"), id); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, r.dialect.Rebind("DELETE FROM runners WHERE race_id = ?"), id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateStatus Sets the status of a race
+func (r *racesRepo) UpdateStatus(ctx context.Context, id int64, from racing.RaceStatus, to racing.RaceStatus, currentDate time.Time) error {
+	return updateRaceStatus(ctx, r.db, r.dialect, id, from, to, currentDate)
+}
+
+// execer runs a statement, on the database or in a transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// updateRaceStatus sets the status of a race with e, as long as it is still in the from status, so it can be part of
+// the transaction of another change.
+func updateRaceStatus(ctx context.Context, e execer, d dialect.Dialect, id int64, from racing.RaceStatus, to racing.RaceStatus, currentDate time.Time) error {
+	// The current status is checked in the same statement, so concurrent changes can't be overwritten
+	result, err := e.ExecContext(ctx,
+		d.Rebind("UPDATE races SET status = ?, version = version + 1 WHERE id = ? AND "+raceStatus+" = ?"),
+		to.String(), id, currentDate.UTC().Format(time.RFC3339), from.String(),
+	)
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if updated == 0 {
+		return ErrStatusChanged
+	}
+
+	return nil
+}
+
+// scanRaces reads the races from the selected columns. The status is only derived when its columns are selected.
+func (r *racesRepo) scanRaces(rows *sql.Rows, selection *readSelection, currentDate time.Time) ([]*racing.Race, error) {
 	var races []*racing.Race
 
 	for rows.Next() {
-		var race racing.Race
-		var advertisedStart time.Time
+		var row raceRow
+
+		dest := make([]interface{}, 0, len(selection.columns))
+		for _, column := range selection.columns {
+			dest = append(dest, column.dest(&row))
+		}
 
-		if err := rows.Scan(&race.Id, &race.MeetingId, &race.Name, &race.Number, &race.Visible, &advertisedStart); err != nil {
+		if err := rows.Scan(dest...); err != nil {
 			if err == sql.ErrNoRows {
 				return nil, nil
 			}
@@ -168,19 +537,25 @@ func (r *racesRepo) scanRaces(rows *sql.Rows, currentDate time.Time) ([]*racing.
 			return nil, err
 		}
 
-		ts, err := ptypes.TimestampProto(advertisedStart)
-		if err != nil {
-			return nil, err
-		}
+		race := &row.race
+		if !row.advertisedStart.IsZero() {
+			ts, err := ptypes.TimestampProto(row.advertisedStart)
+			if err != nil {
+				return nil, err
+			}
 
-		race.AdvertisedStartTime = ts
-		if advertisedStart.Before(currentDate) {
-			race.Status = "CLOSED"
-		} else {
-			race.Status = "OPEN"
+			race.AdvertisedStartTime = ts
+			switch {
+			case row.status.Valid:
+				race.Status = racing.RaceStatus(racing.RaceStatus_value[row.status.String])
+			case row.advertisedStart.Before(currentDate):
+				race.Status = racing.RaceStatus_CLOSED
+			default:
+				race.Status = racing.RaceStatus_OPEN
+			}
 		}
-		races = append(races, &race)
+		races = append(races, race)
 	}
 
-	return races, nil
+	return races, rows.Err()
 }
@@ -1,10 +1,16 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"git.neds.sh/matty/entain/common/clock"
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/common/db/instrument"
+	"git.neds.sh/matty/entain/common/db/migrations"
 	"git.neds.sh/matty/entain/racing/proto/racing"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"testing"
 	"time"
@@ -43,8 +49,9 @@ func TestRacesRepo_List(t *testing.T) {
 					Name:                "North Dakota foes",
 					Number:              12,
 					Visible:             false,
-					Status:              "CLOSED",
+					Status:              racing.RaceStatus_CLOSED,
 					AdvertisedStartTime: timestamppb.New(time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 				{
 					Id:                  2,
@@ -52,8 +59,9 @@ func TestRacesRepo_List(t *testing.T) {
 					Name:                "Connecticut griffins",
 					Number:              12,
 					Visible:             true,
-					Status:              "OPEN",
+					Status:              racing.RaceStatus_OPEN,
 					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 				{
 					Id:                  3,
@@ -61,8 +69,9 @@ func TestRacesRepo_List(t *testing.T) {
 					Name:                "Rhode Island ghosts",
 					Number:              3,
 					Visible:             false,
-					Status:              "OPEN",
+					Status:              racing.RaceStatus_OPEN,
 					AdvertisedStartTime: timestamppb.New(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 			},
 		},
@@ -78,8 +87,9 @@ func TestRacesRepo_List(t *testing.T) {
 					Name:                "North Dakota foes",
 					Number:              12,
 					Visible:             false,
-					Status:              "CLOSED",
+					Status:              racing.RaceStatus_CLOSED,
 					AdvertisedStartTime: timestamppb.New(time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 				{
 					Id:                  3,
@@ -87,8 +97,9 @@ func TestRacesRepo_List(t *testing.T) {
 					Name:                "Rhode Island ghosts",
 					Number:              3,
 					Visible:             false,
-					Status:              "OPEN",
+					Status:              racing.RaceStatus_OPEN,
 					AdvertisedStartTime: timestamppb.New(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 			},
 		},
@@ -105,8 +116,9 @@ func TestRacesRepo_List(t *testing.T) {
 					Name:                "Connecticut griffins",
 					Number:              12,
 					Visible:             true,
-					Status:              "OPEN",
+					Status:              racing.RaceStatus_OPEN,
 					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 			},
 		},
@@ -123,8 +135,9 @@ func TestRacesRepo_List(t *testing.T) {
 					Name:                "Connecticut griffins",
 					Number:              12,
 					Visible:             true,
-					Status:              "OPEN",
+					Status:              racing.RaceStatus_OPEN,
 					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 			},
 		},
@@ -140,8 +153,9 @@ func TestRacesRepo_List(t *testing.T) {
 					Name:                "North Dakota foes",
 					Number:              12,
 					Visible:             false,
-					Status:              "CLOSED",
+					Status:              racing.RaceStatus_CLOSED,
 					AdvertisedStartTime: timestamppb.New(time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 				{
 					Id:                  3,
@@ -149,8 +163,82 @@ func TestRacesRepo_List(t *testing.T) {
 					Name:                "Rhode Island ghosts",
 					Number:              3,
 					Visible:             false,
-					Status:              "OPEN",
+					Status:              racing.RaceStatus_OPEN,
 					AdvertisedStartTime: timestamppb.New(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
+				},
+			},
+		},
+		{
+			name: "FilterByStatus",
+			filter: &racing.ListRacesRequestFilter{
+				Statuses: []racing.RaceStatus{racing.RaceStatus_CLOSED, racing.RaceStatus_SUSPENDED},
+			},
+			expectedRaces: []*racing.Race{
+				{
+					Id:                  1,
+					MeetingId:           5,
+					Name:                "North Dakota foes",
+					Number:              12,
+					Visible:             false,
+					Status:              racing.RaceStatus_CLOSED,
+					AdvertisedStartTime: timestamppb.New(time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
+				},
+			},
+		},
+		{
+			name: "FilterByStartTimeRange",
+			filter: &racing.ListRacesRequestFilter{
+				StartTimeFrom: timestamppb.New(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)),
+				StartTimeTo:   timestamppb.New(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)),
+			},
+			expectedRaces: []*racing.Race{
+				{
+					Id:                  2,
+					MeetingId:           1,
+					Name:                "Connecticut griffins",
+					Number:              12,
+					Visible:             true,
+					Status:              racing.RaceStatus_OPEN,
+					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
+				},
+			},
+		},
+		{
+			name: "FilterByStartTimeToWithinASecond",
+			filter: &racing.ListRacesRequestFilter{
+				StartTimeTo: timestamppb.New(time.Date(2022, 7, 15, 12, 0, 0, 500, time.UTC)),
+			},
+			expectedRaces: []*racing.Race{
+				{
+					Id:                  1,
+					MeetingId:           5,
+					Name:                "North Dakota foes",
+					Number:              12,
+					Visible:             false,
+					Status:              racing.RaceStatus_CLOSED,
+					AdvertisedStartTime: timestamppb.New(time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
+				},
+			},
+		},
+		{
+			name: "FilterByStartsWithinMinutes",
+			filter: &racing.ListRacesRequestFilter{
+				StartsWithinMinutes: 60,
+			},
+			expectedRaces: []*racing.Race{
+				{
+					Id:                  2,
+					MeetingId:           1,
+					Name:                "Connecticut griffins",
+					Number:              12,
+					Visible:             true,
+					Status:              racing.RaceStatus_OPEN,
+					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 			},
 		},
@@ -168,8 +256,9 @@ func TestRacesRepo_List(t *testing.T) {
 					Name:                "Rhode Island ghosts",
 					Number:              3,
 					Visible:             false,
-					Status:              "OPEN",
+					Status:              racing.RaceStatus_OPEN,
 					AdvertisedStartTime: timestamppb.New(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 				{
 					Id:                  2,
@@ -177,8 +266,9 @@ func TestRacesRepo_List(t *testing.T) {
 					Name:                "Connecticut griffins",
 					Number:              12,
 					Visible:             true,
-					Status:              "OPEN",
+					Status:              racing.RaceStatus_OPEN,
 					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 				{
 					Id:                  1,
@@ -186,8 +276,9 @@ func TestRacesRepo_List(t *testing.T) {
 					Name:                "North Dakota foes",
 					Number:              12,
 					Visible:             false,
-					Status:              "CLOSED",
+					Status:              racing.RaceStatus_CLOSED,
 					AdvertisedStartTime: timestamppb.New(time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 			},
 		},
@@ -197,7 +288,7 @@ func TestRacesRepo_List(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// Call the List method with the filter
-			races, err := racesRepo.List(tc.filter, tc.orderBy, getDateNow())
+			races, _, err := racesRepo.List(context.Background(), tc.filter, tc.orderBy, 0, "", nil, getDateNow())
 			if err != nil {
 				t.Fatalf("failed to get races: %v", err)
 			}
@@ -213,6 +304,134 @@ func TestRacesRepo_List(t *testing.T) {
 	}
 }
 
+func TestRacesRepo_UpdateStatus(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	// Create a new RacesRepo using the test database
+	racesRepo := NewRacesRepo(db)
+
+	// Initialize the test database with dummy data
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	t.Run("SetStatusOverridesDerivedStatus", func(t *testing.T) {
+		// Race 3 starts after getDateNow, so it is derived as OPEN
+		if err := racesRepo.UpdateStatus(context.Background(), 3, racing.RaceStatus_OPEN, racing.RaceStatus_POSTPONED, getDateNow()); err != nil {
+			t.Fatalf("failed to update status: %v", err)
+		}
+
+		race, err := racesRepo.Get(context.Background(), 3, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get race: %v", err)
+		}
+		assert.Equal(t, racing.RaceStatus_POSTPONED, race.Status)
+		assert.Equal(t, int64(2), race.Version)
+
+		// The set status is kept after the advertised start time
+		race, err = racesRepo.Get(context.Background(), 3, nil, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("failed to get race: %v", err)
+		}
+		assert.Equal(t, racing.RaceStatus_POSTPONED, race.Status)
+
+		races, _, err := racesRepo.List(context.Background(), &racing.ListRacesRequestFilter{Statuses: []racing.RaceStatus{racing.RaceStatus_POSTPONED}}, nil, 0, "", nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get races: %v", err)
+		}
+		assert.Equal(t, []int64{3}, raceIds(races))
+	})
+
+	t.Run("StatusChanged", func(t *testing.T) {
+		// Race 1 is derived as CLOSED, so it can't be updated from OPEN
+		err := racesRepo.UpdateStatus(context.Background(), 1, racing.RaceStatus_OPEN, racing.RaceStatus_SUSPENDED, getDateNow())
+		assert.ErrorIs(t, err, ErrStatusChanged)
+	})
+}
+
+func TestRacesRepo_ListPagination(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	// Create a new RacesRepo using the test database
+	racesRepo := NewRacesRepo(db)
+
+	// Initialize the test database with dummy data
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	orderBy := []*racing.ListRacesRequestOrderBy{
+		{FieldName: "advertisedStartTime", Direction: racing.OrderByDirection_DESC},
+	}
+
+	t.Run("PagesFollowOrderBy", func(t *testing.T) {
+		races, nextPageToken, err := racesRepo.List(context.Background(), nil, orderBy, 2, "", nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get races: %v", err)
+		}
+		assert.Equal(t, []int64{3, 2}, raceIds(races))
+		assert.NotEmpty(t, nextPageToken)
+
+		races, nextPageToken, err = racesRepo.List(context.Background(), nil, orderBy, 2, nextPageToken, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get races: %v", err)
+		}
+		assert.Equal(t, []int64{1}, raceIds(races))
+		assert.Empty(t, nextPageToken)
+	})
+
+	t.Run("PagesWithFilter", func(t *testing.T) {
+		filter := &racing.ListRacesRequestFilter{VisibilityStatus: racing.VisibilityStatus_HIDDEN}
+
+		races, nextPageToken, err := racesRepo.List(context.Background(), filter, nil, 1, "", nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get races: %v", err)
+		}
+		assert.Equal(t, []int64{1}, raceIds(races))
+
+		races, nextPageToken, err = racesRepo.List(context.Background(), filter, nil, 1, nextPageToken, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get races: %v", err)
+		}
+		assert.Equal(t, []int64{3}, raceIds(races))
+		assert.Empty(t, nextPageToken)
+	})
+
+	t.Run("LastPageIsFull", func(t *testing.T) {
+		races, nextPageToken, err := racesRepo.List(context.Background(), nil, nil, 3, "", nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get races: %v", err)
+		}
+		assert.Len(t, races, 3)
+		assert.Empty(t, nextPageToken)
+	})
+
+	t.Run("InvalidPageToken", func(t *testing.T) {
+		_, _, err := racesRepo.List(context.Background(), nil, orderBy, 2, "not a token", nil, getDateNow())
+		assert.ErrorIs(t, err, ErrInvalidPageToken)
+	})
+
+	t.Run("PageTokenForDifferentQuery", func(t *testing.T) {
+		_, nextPageToken, err := racesRepo.List(context.Background(), nil, orderBy, 2, "", nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get races: %v", err)
+		}
+
+		_, _, err = racesRepo.List(context.Background(), nil, nil, 2, nextPageToken, nil, getDateNow())
+		assert.ErrorIs(t, err, ErrInvalidPageToken)
+	})
+}
+
 func TestRacesRepo_Get(t *testing.T) {
 	// Open an in-memory SQLite database for testing
 	db, err := sql.Open("sqlite3", ":memory:")
@@ -231,7 +450,7 @@ func TestRacesRepo_Get(t *testing.T) {
 
 	t.Run("GetById", func(t *testing.T) {
 		// Call the List method with the filter
-		race, err := racesRepo.Get(2, getDateNow())
+		race, err := racesRepo.Get(context.Background(), 2, nil, getDateNow())
 		if err != nil {
 			t.Fatalf("failed to get races: %v", err)
 		}
@@ -243,23 +462,170 @@ func TestRacesRepo_Get(t *testing.T) {
 
 	t.Run("GetByIdNotFound", func(t *testing.T) {
 		// Call the List method with the filter
-		_, err := racesRepo.Get(999, getDateNow())
+		_, err := racesRepo.Get(context.Background(), 999, nil, getDateNow())
 		if err != sql.ErrNoRows {
 			t.Fatalf("failed to get races: %v", err)
 		}
 	})
 }
 
+func TestRacesRepo_ContextDone(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	racesRepo := NewRacesRepo(db)
+
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	// Queries aren't run once the request they are for is cancelled or out of time
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
+	_, _, err = racesRepo.List(cancelled, nil, nil, 0, "", nil, getDateNow())
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = racesRepo.Get(expired, 2, nil, getDateNow())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	err = racesRepo.Update(expired, &racing.Race{Id: 2, Name: "Renamed"}, []string{"name"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	race, err := racesRepo.Get(context.Background(), 2, nil, getDateNow())
+	if err != nil {
+		t.Fatalf("failed to get race: %v", err)
+	}
+	assert.NotEqual(t, "Renamed", race.Name)
+}
+
+func TestRacesRepo_ContextCancelledWhileReading(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The request is cancelled once its query was sent, so it is only noticed while the races are read
+	db, err := instrument.OpenDB("sqlite3", ":memory:", func(queryCtx context.Context, query string) func(int64, error) {
+		if queryCtx == ctx {
+			cancel()
+		}
+		return func(int64, error) {}
+	})
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+	// Each connection would have a database of its own
+	db.SetMaxOpenConns(1)
+
+	racesRepo := NewRacesRepo(db)
+
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	// The races read before the cancellation aren't returned as if they were all of them
+	races, _, err := racesRepo.List(ctx, nil, nil, 0, "", nil, getDateNow())
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, races)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	race, err := racesRepo.Get(ctx, 2, nil, getDateNow())
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, race)
+
+	// The connection is released, so the next requests still run
+	_, err = racesRepo.Get(context.Background(), 2, nil, getDateNow())
+	assert.NoError(t, err)
+}
+
+func TestSeed_Clock(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	if err := migrateTestDB(db); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	// Start times are seeded from the day before the clock to two days after it
+	now := getDateNow()
+	if err := Seed(db, WithClock(clock.NewFake(now))); err != nil {
+		t.Fatalf("failed to seed test database: %v", err)
+	}
+
+	races, _, err := NewRacesRepo(db).List(context.Background(), nil, nil, 0, "", nil, now)
+	if err != nil {
+		t.Fatalf("failed to list races: %v", err)
+	}
+
+	assert.NotEmpty(t, races)
+	for _, race := range races {
+		startTime := race.AdvertisedStartTime.AsTime()
+		assert.False(t, startTime.Before(now.AddDate(0, 0, -1)), "race %d starts at %s", race.Id, startTime)
+		assert.False(t, startTime.After(now.AddDate(0, 0, 2)), "race %d starts at %s", race.Id, startTime)
+	}
+}
+
+func TestRacesRepo_BatchGet(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	racesRepo := NewRacesRepo(db)
+
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	t.Run("FoundOnly", func(t *testing.T) {
+		races, err := racesRepo.BatchGet(context.Background(), []int64{3, 999, 1}, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get races: %v", err)
+		}
+
+		assert.ElementsMatch(t, []int64{1, 3}, raceIds(races))
+		for _, race := range races {
+			assert.NotNil(t, race.AdvertisedStartTime)
+		}
+	})
+
+	t.Run("NoIds", func(t *testing.T) {
+		races, err := racesRepo.BatchGet(context.Background(), nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get races: %v", err)
+		}
+
+		assert.Empty(t, races)
+	})
+}
+
+// migrateTestDB creates the tables of a test database with the migrations.
+func migrateTestDB(db *sql.DB) error {
+	_, err := migrations.Up(db, Migrations(), dialect.SQLite)
+	return err
+}
+
 func initTestDB(db *sql.DB) error {
-	statement, err := db.Prepare(`CREATE TABLE IF NOT EXISTS races (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, number INTEGER, visible INTEGER, advertised_start_time DATETIME)`)
-	if err == nil {
-		_, err = statement.Exec()
+	if err := migrateTestDB(db); err != nil {
+		return err
 	}
 
 	races := getAllTestData()
 
 	for _, s := range races {
-		statement, err = db.Prepare(`INSERT OR IGNORE INTO races(id, meeting_id, name, number, visible, advertised_start_time) VALUES (?,?,?,?,?,?)`)
+		statement, err := db.Prepare(`INSERT OR IGNORE INTO races(id, meeting_id, name, number, visible, advertised_start_time) VALUES (?,?,?,?,?,?)`)
 		if err == nil {
 			_, err = statement.Exec(
 				s.Id,
@@ -304,6 +670,316 @@ func getAllTestData() []*racing.Race {
 	}
 }
 
+func raceIds(races []*racing.Race) []int64 {
+	var ids []int64
+	for _, race := range races {
+		ids = append(ids, race.Id)
+	}
+	return ids
+}
+
 func getDateNow() time.Time {
 	return time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)
 }
+
+func TestRacesRepo_ListOrderBy(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	racesRepo := NewRacesRepo(db)
+
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		orderBy     []*racing.ListRacesRequestOrderBy
+		expectedIDs []int64
+	}{
+		{
+			name: "MultipleFields",
+			orderBy: []*racing.ListRacesRequestOrderBy{
+				{FieldName: "number", Direction: racing.OrderByDirection_DESC},
+				{FieldName: "name"},
+			},
+			expectedIDs: []int64{2, 1, 3},
+		},
+		{
+			name:        "SnakeCaseField",
+			orderBy:     []*racing.ListRacesRequestOrderBy{{FieldName: "meeting_id"}},
+			expectedIDs: []int64{2, 1, 3},
+		},
+		{
+			name:        "DerivedStatus",
+			orderBy:     []*racing.ListRacesRequestOrderBy{{FieldName: "status", Direction: racing.OrderByDirection_DESC}},
+			expectedIDs: []int64{2, 3, 1},
+		},
+		{
+			name:        "IdDescending",
+			orderBy:     []*racing.ListRacesRequestOrderBy{{FieldName: "id", Direction: racing.OrderByDirection_DESC}},
+			expectedIDs: []int64{3, 2, 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Races are listed a page at a time, so the page tokens are checked for every order too
+			var (
+				ids       []int64
+				pageToken string
+			)
+			for {
+				races, nextPageToken, err := racesRepo.List(context.Background(), nil, tc.orderBy, 1, pageToken, nil, getDateNow())
+				if err != nil {
+					t.Fatalf("failed to get races: %v", err)
+				}
+				for _, race := range races {
+					ids = append(ids, race.Id)
+				}
+				if nextPageToken == "" {
+					break
+				}
+				pageToken = nextPageToken
+			}
+
+			assert.Equal(t, tc.expectedIDs, ids)
+		})
+	}
+
+	t.Run("UnknownField", func(t *testing.T) {
+		_, _, err := racesRepo.List(context.Background(), nil, []*racing.ListRacesRequestOrderBy{{FieldName: "advertisedStartTme"}}, 0, "", nil, getDateNow())
+
+		var orderFieldErr *OrderFieldError
+		if assert.ErrorAs(t, err, &orderFieldErr) {
+			assert.Equal(t, "advertisedStartTme", orderFieldErr.Field)
+			assert.Contains(t, err.Error(), "id, name, number, meetingId, visible, status, advertisedStartTime")
+		}
+	})
+}
+
+func TestRacesRepo_ReadMask(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	racesRepo := NewRacesRepo(db)
+
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	t.Run("ListRequestedFieldsOnly", func(t *testing.T) {
+		readMask := &fieldmaskpb.FieldMask{Paths: []string{"name", "advertisedStartTime"}}
+
+		races, _, err := racesRepo.List(context.Background(), nil, nil, 0, "", readMask, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get races: %v", err)
+		}
+
+		if assert.Len(t, races, 3) {
+			assert.Equal(t, "North Dakota foes", races[0].Name)
+			assert.NotNil(t, races[0].AdvertisedStartTime)
+			// The id is read to sort the races, but it isn't returned
+			assert.Zero(t, races[0].Id)
+			assert.Zero(t, races[0].MeetingId)
+			assert.Zero(t, races[0].Number)
+			assert.Equal(t, racing.RaceStatus_RACE_STATUS_UNSPECIFIED, races[0].Status)
+		}
+	})
+
+	t.Run("ListPagesByFieldsNotRequested", func(t *testing.T) {
+		var (
+			names     []string
+			pageToken string
+		)
+
+		readMask := &fieldmaskpb.FieldMask{Paths: []string{"name"}}
+		orderBy := []*racing.ListRacesRequestOrderBy{{FieldName: "status"}, {FieldName: "number", Direction: racing.OrderByDirection_DESC}}
+
+		for {
+			races, nextPageToken, err := racesRepo.List(context.Background(), nil, orderBy, 1, pageToken, readMask, getDateNow())
+			if err != nil {
+				t.Fatalf("failed to get races: %v", err)
+			}
+			for _, race := range races {
+				names = append(names, race.Name)
+			}
+			if nextPageToken == "" {
+				break
+			}
+			pageToken = nextPageToken
+		}
+
+		assert.Equal(t, []string{"North Dakota foes", "Connecticut griffins", "Rhode Island ghosts"}, names)
+	})
+
+	t.Run("GetDerivedStatus", func(t *testing.T) {
+		race, err := racesRepo.Get(context.Background(), 3, &fieldmaskpb.FieldMask{Paths: []string{"status"}}, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get race: %v", err)
+		}
+
+		assert.Equal(t, racing.RaceStatus_OPEN, race.Status)
+		assert.Nil(t, race.AdvertisedStartTime)
+		assert.Empty(t, race.Name)
+	})
+
+	t.Run("GetEveryField", func(t *testing.T) {
+		race, err := racesRepo.Get(context.Background(), 2, &fieldmaskpb.FieldMask{Paths: []string{"*"}}, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get race: %v", err)
+		}
+
+		assert.Equal(t, int64(2), race.Id)
+		assert.Equal(t, int64(1), race.MeetingId)
+		assert.Equal(t, "Connecticut griffins", race.Name)
+		assert.NotNil(t, race.AdvertisedStartTime)
+	})
+
+	t.Run("UnknownPath", func(t *testing.T) {
+		_, err := racesRepo.Get(context.Background(), 2, &fieldmaskpb.FieldMask{Paths: []string{"name", "runners.name"}}, getDateNow())
+
+		var readMaskErr *ReadMaskError
+		if assert.ErrorAs(t, err, &readMaskErr) {
+			assert.Equal(t, "runners.name", readMaskErr.Path)
+		}
+	})
+}
+
+func TestRacesRepo_Write(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	racesRepo := NewRacesRepo(db)
+
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	for _, query := range []string{
+		`INSERT INTO runners (id, race_id, name) VALUES (1, 2, 'Winx'), (2, 3, 'Black Caviar')`,
+		`INSERT INTO price_history (runner_id, win, place, time) VALUES (1, 3.5, 1.6, '2023-07-15T11:00:00.000Z'), (2, 2.1, 1.2, '2023-07-15T11:00:00.000Z')`,
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("failed to initialize test database: %v", err)
+		}
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		id, err := racesRepo.Create(context.Background(), &racing.Race{
+			MeetingId:           8,
+			Name:                "Addington Cup",
+			Number:              4,
+			Visible:             true,
+			AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 16, 14, 30, 0, 0, time.UTC)),
+		})
+		if err != nil {
+			t.Fatalf("failed to create race: %v", err)
+		}
+
+		race, err := racesRepo.Get(context.Background(), id, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get race: %v", err)
+		}
+
+		assert.Equal(t, int64(8), race.MeetingId)
+		assert.Equal(t, "Addington Cup", race.Name)
+		assert.Equal(t, int64(4), race.Number)
+		assert.True(t, race.Visible)
+		assert.Equal(t, time.Date(2023, 7, 16, 14, 30, 0, 0, time.UTC), race.AdvertisedStartTime.AsTime())
+		assert.Equal(t, racing.RaceStatus_OPEN, race.Status)
+	})
+
+	t.Run("UpdateGivenFieldsOnly", func(t *testing.T) {
+		err := racesRepo.Update(context.Background(), &racing.Race{Id: 2, Name: "Renamed", Number: 99, Version: 1}, []string{"name"})
+		if err != nil {
+			t.Fatalf("failed to update race: %v", err)
+		}
+
+		race, err := racesRepo.Get(context.Background(), 2, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get race: %v", err)
+		}
+
+		assert.Equal(t, "Renamed", race.Name)
+		assert.Equal(t, int64(12), race.Number)
+	})
+
+	t.Run("UpdateAtVersion", func(t *testing.T) {
+		race, err := racesRepo.Get(context.Background(), 3, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get race: %v", err)
+		}
+
+		if err := racesRepo.Update(context.Background(), &racing.Race{Id: 3, Name: "First", Version: race.Version}, []string{"name"}); err != nil {
+			t.Fatalf("failed to update race: %v", err)
+		}
+
+		// The race was changed since it was read
+		err = racesRepo.Update(context.Background(), &racing.Race{Id: 3, Name: "Second", Version: race.Version}, []string{"name"})
+		assert.Equal(t, ErrVersionChanged, err)
+
+		updated, err := racesRepo.Get(context.Background(), 3, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get race: %v", err)
+		}
+
+		assert.Equal(t, "First", updated.Name)
+		assert.Equal(t, race.Version+1, updated.Version)
+	})
+
+	t.Run("UpdateWithoutVersion", func(t *testing.T) {
+		// Races are always updated at a version, so one without it can't overwrite changes
+		err := racesRepo.Update(context.Background(), &racing.Race{Id: 3, Name: "Unversioned"}, []string{"name"})
+		assert.Equal(t, ErrVersionChanged, err)
+	})
+
+	t.Run("UpdateNotFound", func(t *testing.T) {
+		err := racesRepo.Update(context.Background(), &racing.Race{Id: 999, Name: "Renamed"}, []string{"name"})
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+
+	t.Run("UpdateNotFoundAtVersion", func(t *testing.T) {
+		err := racesRepo.Update(context.Background(), &racing.Race{Id: 999, Name: "Renamed", Version: 1}, []string{"name"})
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+
+	t.Run("UpdateFieldNotWritable", func(t *testing.T) {
+		err := racesRepo.Update(context.Background(), &racing.Race{Id: 2, Status: racing.RaceStatus_CLOSED}, []string{"status"})
+		assert.Error(t, err)
+	})
+
+	t.Run("DeleteWithRunnersAndPrices", func(t *testing.T) {
+		if err := racesRepo.Delete(context.Background(), 2); err != nil {
+			t.Fatalf("failed to delete race: %v", err)
+		}
+
+		_, err := racesRepo.Get(context.Background(), 2, nil, getDateNow())
+		assert.Equal(t, sql.ErrNoRows, err)
+
+		var runners, prices int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM runners`).Scan(&runners); err != nil {
+			t.Fatalf("failed to count runners: %v", err)
+		}
+		if err := db.QueryRow(`SELECT COUNT(*) FROM price_history`).Scan(&prices); err != nil {
+			t.Fatalf("failed to count prices: %v", err)
+		}
+
+		// The runners of other races are kept
+		assert.Equal(t, 1, runners)
+		assert.Equal(t, 1, prices)
+	})
+
+	t.Run("DeleteNotFound", func(t *testing.T) {
+		assert.Equal(t, sql.ErrNoRows, racesRepo.Delete(context.Background(), 999))
+	})
+}
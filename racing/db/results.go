@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// ResultsRepo provides repository access to race results.
+type ResultsRepo interface {
+	// Init will initialise our results repository.
+	Init() error
+
+	// Get will return the result of a race. It will return an error if the race has no result yet
+	Get(ctx context.Context, raceID int64) (*racing.RaceResult, error)
+
+	// Save will store the result of a race, replacing any previous result and its placings, and move the race from
+	// a status to another along with it. It will return ErrStatusChanged, and store nothing, if the race is no
+	// longer in the from status.
+	Save(ctx context.Context, result *racing.RaceResult, from racing.RaceStatus, to racing.RaceStatus, currentDate time.Time) error
+}
+
+type resultsRepo struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+}
+
+// NewResultsRepo creates a new results repository.
+func NewResultsRepo(db *sql.DB, opts ...Option) ResultsRepo {
+	return &resultsRepo{db: db, dialect: newOptions(opts).dialect}
+}
+
+// Init prepares the results repository. Its tables are created by the migrations.
+func (r *resultsRepo) Init() error {
+	return nil
+}
+
+// Get Return the result of a race with its placings
+func (r *resultsRepo) Get(ctx context.Context, raceID int64) (*racing.RaceResult, error) {
+	var (
+		result         racing.RaceResult
+		officialTimeMs int64
+		updatedTime    time.Time
+	)
+
+	err := r.db.QueryRowContext(ctx, r.dialect.Rebind(getResultQueries()[resultsGet]), raceID).Scan(&result.RaceId, &result.Final, &officialTimeMs, &updatedTime)
+	if err != nil {
+		return nil, err
+	}
+
+	result.OfficialTime = ptypes.DurationProto(time.Duration(officialTimeMs) * time.Millisecond)
+
+	if result.UpdatedTime, err = ptypes.TimestampProto(updatedTime); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(getResultQueries()[placingsList]), raceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Placings, err = r.scanPlacings(rows); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Save Stores the result of a race, the result, its placings and the status of the race are changed in a single
+// transaction, so the status of a race always tells whether it has a result
+func (r *resultsRepo) Save(ctx context.Context, result *racing.RaceResult, from racing.RaceStatus, to racing.RaceStatus, currentDate time.Time) error {
+	officialTime, err := ptypes.Duration(result.OfficialTime)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateRaceStatus(ctx, tx, r.dialect, result.RaceId, from, to, currentDate); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		r.dialect.Rebind(`
+			INSERT INTO race_results(race_id, final, official_time_ms, updated_time) VALUES (?,?,?,?) 
+			ON CONFLICT (race_id) DO UPDATE SET 
+				final = excluded.final, 
+				official_time_ms = excluded.official_time_ms, 
+				updated_time = excluded.updated_time
+		`),
+		result.RaceId,
+		storedFlag(result.Final),
+		officialTime.Milliseconds(),
+		result.UpdatedTime.AsTime().UTC().Format(time.RFC3339),
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, r.dialect.Rebind(`DELETE FROM race_placings WHERE race_id = ?`), result.RaceId); err != nil {
+		return err
+	}
+
+	statement, err := tx.PrepareContext(ctx, r.dialect.Rebind(`INSERT INTO race_placings(race_id, position, runner_id, margin, dead_heat) VALUES (?,?,?,?,?)`))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for _, placing := range result.Placings {
+		if _, err := statement.ExecContext(ctx, result.RaceId, placing.Position, placing.RunnerId, placing.Margin, storedFlag(placing.DeadHeat)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *resultsRepo) scanPlacings(rows *sql.Rows) ([]*racing.Placing, error) {
+	defer rows.Close()
+
+	var placings []*racing.Placing
+
+	for rows.Next() {
+		var placing racing.Placing
+
+		if err := rows.Scan(&placing.Position, &placing.RunnerId, &placing.Margin, &placing.DeadHeat); err != nil {
+			return nil, err
+		}
+
+		placings = append(placings, &placing)
+	}
+
+	return placings, rows.Err()
+}
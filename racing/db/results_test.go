@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+)
+
+func TestResultsRepo_SaveAndGet(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	// Results aren't seeded, so their tables start empty
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE races SET status = 'CLOSED' WHERE id = 1`); err != nil {
+		t.Fatalf("failed to close race: %v", err)
+	}
+	resultsRepo := NewResultsRepo(db)
+	racesRepo := NewRacesRepo(db)
+
+	t.Run("RaceWithoutResult", func(t *testing.T) {
+		_, err := resultsRepo.Get(context.Background(), 1)
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+
+	interim := &racing.RaceResult{
+		RaceId:       1,
+		OfficialTime: durationpb.New(95*time.Second + 320*time.Millisecond),
+		Placings: []*racing.Placing{
+			{Position: 2, RunnerId: 3, Margin: 1.5},
+			{Position: 1, RunnerId: 7},
+			{Position: 3, RunnerId: 5, Margin: 0.5, DeadHeat: true},
+			{Position: 3, RunnerId: 4, DeadHeat: true},
+		},
+		UpdatedTime: timestamppb.New(time.Date(2023, 7, 15, 12, 2, 0, 0, time.UTC)),
+	}
+
+	t.Run("PlacingsOrderedByPosition", func(t *testing.T) {
+		if err := resultsRepo.Save(context.Background(), interim, racing.RaceStatus_CLOSED, racing.RaceStatus_INTERIM, getDateNow()); err != nil {
+			t.Fatalf("failed to save result: %v", err)
+		}
+
+		result, err := resultsRepo.Get(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("failed to get result: %v", err)
+		}
+
+		assert.False(t, result.Final)
+		assert.Equal(t, 95*time.Second+320*time.Millisecond, result.OfficialTime.AsDuration())
+		assert.Equal(t, interim.UpdatedTime.AsTime(), result.UpdatedTime.AsTime())
+		assert.Equal(t, []*racing.Placing{
+			interim.Placings[1],
+			interim.Placings[0],
+			interim.Placings[3],
+			interim.Placings[2],
+		}, result.Placings)
+	})
+
+	t.Run("ReplacesPreviousResult", func(t *testing.T) {
+		final := &racing.RaceResult{
+			RaceId:       1,
+			Final:        true,
+			OfficialTime: durationpb.New(95 * time.Second),
+			Placings: []*racing.Placing{
+				{Position: 1, RunnerId: 3},
+				{Position: 2, RunnerId: 7, Margin: 0.1},
+			},
+			UpdatedTime: timestamppb.New(time.Date(2023, 7, 15, 12, 10, 0, 0, time.UTC)),
+		}
+
+		if err := resultsRepo.Save(context.Background(), final, racing.RaceStatus_INTERIM, racing.RaceStatus_FINAL, getDateNow()); err != nil {
+			t.Fatalf("failed to save result: %v", err)
+		}
+
+		result, err := resultsRepo.Get(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("failed to get result: %v", err)
+		}
+
+		assert.True(t, result.Final)
+		assert.Equal(t, final.Placings, result.Placings)
+
+		race, err := racesRepo.Get(context.Background(), 1, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get race: %v", err)
+		}
+		assert.Equal(t, racing.RaceStatus_FINAL, race.Status)
+	})
+
+	t.Run("StatusChanged", func(t *testing.T) {
+		// The race is FINAL, so it can't be moved from INTERIM again
+		err := resultsRepo.Save(context.Background(), interim, racing.RaceStatus_INTERIM, racing.RaceStatus_INTERIM, getDateNow())
+		assert.Equal(t, ErrStatusChanged, err)
+
+		result, err := resultsRepo.Get(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("failed to get result: %v", err)
+		}
+		assert.True(t, result.Final)
+	})
+
+	t.Run("FailedSaveKeepsStatus", func(t *testing.T) {
+		if _, err := db.Exec(`UPDATE races SET status = 'CLOSED' WHERE id = 2`); err != nil {
+			t.Fatalf("failed to close race: %v", err)
+		}
+
+		// Placing a runner twice breaks the key of the placings after the race was moved
+		invalid := &racing.RaceResult{
+			RaceId:       2,
+			OfficialTime: durationpb.New(95 * time.Second),
+			Placings: []*racing.Placing{
+				{Position: 1, RunnerId: 3},
+				{Position: 2, RunnerId: 3, Margin: 0.1},
+			},
+			UpdatedTime: timestamppb.New(time.Date(2023, 7, 15, 12, 2, 0, 0, time.UTC)),
+		}
+		assert.Error(t, resultsRepo.Save(context.Background(), invalid, racing.RaceStatus_CLOSED, racing.RaceStatus_INTERIM, getDateNow()))
+
+		race, err := racesRepo.Get(context.Background(), 2, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get race: %v", err)
+		}
+		assert.Equal(t, racing.RaceStatus_CLOSED, race.Status)
+
+		_, err = resultsRepo.Get(context.Background(), 2)
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// RunnersRepo provides repository access to runners.
+type RunnersRepo interface {
+	// Init will initialise our runners repository.
+	Init() error
+
+	// List will return the runners of a race, ordered by saddle number.
+	List(ctx context.Context, raceID int64) ([]*racing.Runner, error)
+
+	// Get will return a single runner. It will return an error if no runner is found
+	Get(ctx context.Context, id int64) (*racing.Runner, error)
+}
+
+type runnersRepo struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+}
+
+// NewRunnersRepo creates a new runners repository.
+func NewRunnersRepo(db *sql.DB, opts ...Option) RunnersRepo {
+	return &runnersRepo{db: db, dialect: newOptions(opts).dialect}
+}
+
+// Init prepares the runners repository. Its tables are created by the migrations.
+func (r *runnersRepo) Init() error {
+	return nil
+}
+
+// List Returns the runners of a race
+func (r *runnersRepo) List(ctx context.Context, raceID int64) ([]*racing.Runner, error) {
+	query := getRunnerQueries()[runnersList]
+	query += " WHERE race_id = ? ORDER BY saddle_number"
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), raceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scanRunners(rows)
+}
+
+// Get Return a single runner by id
+func (r *runnersRepo) Get(ctx context.Context, id int64) (*racing.Runner, error) {
+	query := getRunnerQueries()[runnersList]
+	query += " WHERE id = ?"
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), id)
+	if err != nil {
+		return nil, err
+	}
+
+	runners, err := r.scanRunners(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(runners) != 1 {
+		// in case a runner is not found return an error for no rows
+		return nil, sql.ErrNoRows
+	}
+
+	return runners[0], nil
+}
+
+func (r *runnersRepo) scanRunners(rows *sql.Rows) ([]*racing.Runner, error) {
+	defer rows.Close()
+
+	var runners []*racing.Runner
+
+	for rows.Next() {
+		var runner racing.Runner
+
+		if err := rows.Scan(&runner.Id, &runner.RaceId, &runner.Barrier, &runner.SaddleNumber, &runner.Name, &runner.Jockey, &runner.Trainer, &runner.Weight, &runner.Scratched); err != nil {
+			return nil, err
+		}
+
+		runners = append(runners, &runner)
+	}
+
+	return runners, rows.Err()
+}
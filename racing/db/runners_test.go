@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRunnersRepo_List(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	// Create a new RunnersRepo using the test database
+	runnersRepo := NewRunnersRepo(db)
+
+	// Initialize the test database with dummy data
+	if err := initTestRunnersDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	testCases := []struct {
+		name            string
+		raceID          int64
+		expectedRunners []*racing.Runner
+	}{
+		{
+			name:   "OrderedBySaddleNumber",
+			raceID: 2,
+			expectedRunners: []*racing.Runner{
+				getAllTestRunners()[1],
+				getAllTestRunners()[0],
+			},
+		},
+		{
+			name:            "RaceWithoutRunners",
+			raceID:          1,
+			expectedRunners: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runners, err := runnersRepo.List(context.Background(), tc.raceID)
+			if err != nil {
+				t.Fatalf("failed to get runners: %v", err)
+			}
+
+			assert.Equal(t, tc.expectedRunners, runners)
+		})
+	}
+}
+
+func TestRunnersRepo_Seed(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	// Runners are seeded for the existing races
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	if err := (&runnersRepo{db: db}).seed(); err != nil {
+		t.Fatalf("failed to seed runners: %v", err)
+	}
+	runnersRepo := NewRunnersRepo(db)
+
+	runners, err := runnersRepo.List(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to get runners: %v", err)
+	}
+
+	assert.GreaterOrEqual(t, len(runners), 8)
+	for i, runner := range runners {
+		assert.Equal(t, int64(i+1), runner.SaddleNumber)
+		assert.NotEmpty(t, runner.Name)
+		assert.NotEmpty(t, runner.Jockey)
+		assert.True(t, runner.Weight >= 54 && runner.Weight <= 61.5, "unexpected weight %v", runner.Weight)
+	}
+}
+
+func initTestRunnersDB(db *sql.DB) error {
+	if err := migrateTestDB(db); err != nil {
+		return err
+	}
+
+	var err error
+	for _, s := range getAllTestRunners() {
+		var statement *sql.Stmt
+		statement, err = db.Prepare(`INSERT OR IGNORE INTO runners(id, race_id, barrier, saddle_number, name, jockey, trainer, weight, scratched) VALUES (?,?,?,?,?,?,?,?,?)`)
+		if err == nil {
+			_, err = statement.Exec(
+				s.Id,
+				s.RaceId,
+				s.Barrier,
+				s.SaddleNumber,
+				s.Name,
+				s.Jockey,
+				s.Trainer,
+				s.Weight,
+				s.Scratched,
+			)
+		}
+	}
+
+	return err
+}
+
+func getAllTestRunners() []*racing.Runner {
+	return []*racing.Runner{
+		{
+			Id:           1,
+			RaceId:       2,
+			Barrier:      4,
+			SaddleNumber: 2,
+			Name:         "Midnight Express",
+			Jockey:       "Jamie Kah",
+			Trainer:      "Chris Waller",
+			Weight:       57.5,
+		},
+		{
+			Id:           2,
+			RaceId:       2,
+			Barrier:      1,
+			SaddleNumber: 1,
+			Name:         "Golden Arrow",
+			Jockey:       "James McDonald",
+			Trainer:      "Ciaron Maher",
+			Weight:       59,
+			Scratched:    true,
+		},
+		{
+			Id:           3,
+			RaceId:       3,
+			Barrier:      2,
+			SaddleNumber: 1,
+			Name:         "Storm Legend",
+			Jockey:       "Craig Williams",
+			Trainer:      "Gai Waterhouse",
+			Weight:       58,
+		},
+	}
+}
@@ -0,0 +1,174 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+)
+
+// Matched words are wrapped in highlightStart and highlightEnd in the search snippets.
+const (
+	highlightStart = "<mark>"
+	highlightEnd   = "</mark>"
+)
+
+// racesLikeSearch selects the names of the races, to rank the ones matched with LIKE.
+const racesLikeSearch = `
+	SELECT
+		races.id,
+		races.name,
+		COALESCE(meetings.name, '')
+	FROM races
+	LEFT JOIN meetings ON meetings.id = races.meeting_id
+`
+
+// raceMatch is a race found by a search, before the race itself is read.
+type raceMatch struct {
+	id                 int64
+	score              float64
+	nameSnippet        string
+	meetingNameSnippet string
+}
+
+// searchTerms splits a search query into its words in lower case, ignoring punctuation.
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Search Returns the races matching a query, the most relevant first
+func (r *racesRepo) Search(ctx context.Context, query string, limit int32, currentDate time.Time) ([]*racing.RaceSearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	matches, err := r.searchMatches(ctx, terms, limit)
+	if err != nil || len(matches) == 0 {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, match.id)
+	}
+
+	races, err := r.BatchGet(ctx, ids, currentDate)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*racing.Race, len(races))
+	for _, race := range races {
+		byID[race.Id] = race
+	}
+
+	results := make([]*racing.RaceSearchResult, 0, len(matches))
+	for _, match := range matches {
+		race, ok := byID[match.id]
+		if !ok {
+			continue
+		}
+
+		results = append(results, &racing.RaceSearchResult{
+			Race:               race,
+			Score:              match.score,
+			NameSnippet:        match.nameSnippet,
+			MeetingNameSnippet: match.meetingNameSnippet,
+		})
+	}
+
+	return results, nil
+}
+
+// likeSearchClause returns the condition selecting the races matching every term anywhere in their names.
+func (r *racesRepo) likeSearchClause(terms []string) (string, []interface{}) {
+	var (
+		clauses []string
+		args    []interface{}
+		like    = r.dialect.Like()
+	)
+
+	for _, term := range terms {
+		clauses = append(clauses, "(name "+like+" ? OR meeting_id IN (SELECT id FROM meetings WHERE name "+like+" ?))")
+		args = append(args, "%"+term+"%", "%"+term+"%")
+	}
+
+	return strings.Join(clauses, " AND "), args
+}
+
+// likeSearchMatches returns the races matching every term anywhere in their names. Races are ranked by how many
+// times the terms match, counting race names twice as much as meeting names.
+func (r *racesRepo) likeSearchMatches(ctx context.Context, terms []string, limit int32) ([]*raceMatch, error) {
+	var (
+		clauses []string
+		args    []interface{}
+		like    = r.dialect.Like()
+	)
+
+	for _, term := range terms {
+		clauses = append(clauses, "(races.name "+like+" ? OR meetings.name "+like+" ?)")
+		args = append(args, "%"+term+"%", "%"+term+"%")
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(racesLikeSearch+" WHERE "+strings.Join(clauses, " AND ")), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pattern := termsPattern(terms)
+
+	var matches []*raceMatch
+	for rows.Next() {
+		var (
+			match             raceMatch
+			name, meetingName string
+		)
+
+		if err := rows.Scan(&match.id, &name, &meetingName); err != nil {
+			return nil, err
+		}
+
+		nameMatches := len(pattern.FindAllStringIndex(name, -1))
+		meetingNameMatches := len(pattern.FindAllStringIndex(meetingName, -1))
+
+		match.score = float64(2*nameMatches + meetingNameMatches)
+		match.nameSnippet = pattern.ReplaceAllString(name, highlightStart+"$0"+highlightEnd)
+		match.meetingNameSnippet = pattern.ReplaceAllString(meetingName, highlightStart+"$0"+highlightEnd)
+		matches = append(matches, &match)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].id < matches[j].id
+	})
+
+	if len(matches) > int(limit) {
+		matches = matches[:limit]
+	}
+
+	return matches, nil
+}
+
+// termsPattern returns the case insensitive pattern matching any of the terms.
+func termsPattern(terms []string) *regexp.Regexp {
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, regexp.QuoteMeta(term))
+	}
+
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
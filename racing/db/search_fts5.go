@@ -0,0 +1,104 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package db
+
+import (
+	"context"
+	"strings"
+
+	"git.neds.sh/matty/entain/common/db/dialect"
+)
+
+// searchIndexQueries create the full-text index of the races, along with the triggers keeping it up to date.
+// Race names are weighted twice as much as meeting names when ranking the races.
+var searchIndexQueries = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS races_search USING fts5(name, meeting_name, tokenize = 'unicode61 remove_diacritics 2')`,
+	`CREATE TRIGGER IF NOT EXISTS races_search_insert AFTER INSERT ON races BEGIN
+		INSERT INTO races_search(rowid, name, meeting_name) VALUES (new.id, new.name, COALESCE((SELECT name FROM meetings WHERE id = new.meeting_id), ''));
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS races_search_update AFTER UPDATE OF name, meeting_id ON races BEGIN
+		DELETE FROM races_search WHERE rowid = old.id;
+		INSERT INTO races_search(rowid, name, meeting_name) VALUES (new.id, new.name, COALESCE((SELECT name FROM meetings WHERE id = new.meeting_id), ''));
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS races_search_delete AFTER DELETE ON races BEGIN
+		DELETE FROM races_search WHERE rowid = old.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS races_search_meeting_update AFTER UPDATE OF name ON meetings BEGIN
+		UPDATE races_search SET meeting_name = new.name WHERE rowid IN (SELECT id FROM races WHERE meeting_id = new.id);
+	END`,
+	// Races stored before the triggers existed are indexed again
+	`DELETE FROM races_search`,
+	`INSERT INTO races_search(rowid, name, meeting_name) SELECT races.id, races.name, COALESCE(meetings.name, '') FROM races LEFT JOIN meetings ON meetings.id = races.meeting_id`,
+}
+
+const racesSearch = `
+	SELECT
+		rowid,
+		-bm25(races_search, 2.0, 1.0),
+		snippet(races_search, 0, '` + highlightStart + `', '` + highlightEnd + `', '…', 16),
+		snippet(races_search, 1, '` + highlightStart + `', '` + highlightEnd + `', '…', 16)
+	FROM races_search
+	WHERE races_search MATCH ?
+	ORDER BY bm25(races_search, 2.0, 1.0), rowid
+	LIMIT ?
+`
+
+// initSearch creates the full-text index of the races and the names of their meetings.
+func (r *racesRepo) initSearch() error {
+	// FTS5 is only in SQLite, other databases are searched with LIKE
+	if r.dialect != dialect.SQLite {
+		return nil
+	}
+
+	for _, query := range searchIndexQueries {
+		if _, err := r.db.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matchExpression returns the full-text query matching every term at the start of a word.
+func matchExpression(terms []string) string {
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+term+`"*`)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// searchClause returns the condition selecting the races matching every term.
+func (r *racesRepo) searchClause(terms []string) (string, []interface{}) {
+	if r.dialect != dialect.SQLite {
+		return r.likeSearchClause(terms)
+	}
+
+	return "id IN (SELECT rowid FROM races_search WHERE races_search MATCH ?)", []interface{}{matchExpression(terms)}
+}
+
+// searchMatches returns the races matching every term, ranked by relevance.
+func (r *racesRepo) searchMatches(ctx context.Context, terms []string, limit int32) ([]*raceMatch, error) {
+	if r.dialect != dialect.SQLite {
+		return r.likeSearchMatches(ctx, terms, limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, racesSearch, matchExpression(terms), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []*raceMatch
+	for rows.Next() {
+		var match raceMatch
+		if err := rows.Scan(&match.id, &match.score, &match.nameSnippet, &match.meetingNameSnippet); err != nil {
+			return nil, err
+		}
+		matches = append(matches, &match)
+	}
+
+	return matches, rows.Err()
+}
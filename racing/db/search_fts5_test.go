@@ -0,0 +1,76 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRacesRepo_SearchFTS5(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	racesRepo, err := initTestSearchDB(db)
+	if err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	search := func(query string) ([]int64, []string) {
+		results, err := racesRepo.Search(context.Background(), query, 10, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to search races: %v", err)
+		}
+
+		var (
+			ids      []int64
+			snippets []string
+		)
+		for _, result := range results {
+			ids = append(ids, result.Race.Id)
+			snippets = append(snippets, result.NameSnippet+" @ "+result.MeetingNameSnippet)
+		}
+		return ids, snippets
+	}
+
+	t.Run("Snippets", func(t *testing.T) {
+		_, snippets := search("griff flem")
+		assert.Equal(t, []string{"Connecticut <mark>griffins</mark> @ <mark>Flemington</mark>"}, snippets)
+	})
+
+	t.Run("RaceNamesRankedFirst", func(t *testing.T) {
+		// The new race is indexed by the insert trigger
+		if _, err := db.Exec(`INSERT INTO races(id, meeting_id, name, number, visible, advertised_start_time) VALUES (4, 2, 'Flemington Cup', 1, 1, '2023-07-15T13:00:00Z')`); err != nil {
+			t.Fatalf("failed to insert race: %v", err)
+		}
+
+		ids, _ := search("flemington")
+		assert.Equal(t, []int64{4, 2}, ids)
+	})
+
+	t.Run("IndexFollowsUpdates", func(t *testing.T) {
+		if _, err := db.Exec(`UPDATE races SET name = 'Randwick Cup' WHERE id = 4`); err != nil {
+			t.Fatalf("failed to update race: %v", err)
+		}
+		if _, err := db.Exec(`UPDATE meetings SET name = 'Flemington Park' WHERE id = 5`); err != nil {
+			t.Fatalf("failed to update meeting: %v", err)
+		}
+
+		ids, _ := search("flemington")
+		assert.ElementsMatch(t, []int64{1, 2}, ids)
+
+		if _, err := db.Exec(`DELETE FROM races WHERE id = 1`); err != nil {
+			t.Fatalf("failed to delete race: %v", err)
+		}
+
+		ids, _ = search("flemington")
+		assert.Equal(t, []int64{2}, ids)
+	})
+}
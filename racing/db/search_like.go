@@ -0,0 +1,39 @@
+//go:build !sqlite_fts5
+// +build !sqlite_fts5
+
+package db
+
+import (
+	"context"
+	"git.neds.sh/matty/entain/common/db/dialect"
+)
+
+// searchTriggers keep the full-text index up to date when built with FTS5. SQLite only has FTS5 when built with the
+// sqlite_fts5 tag, so without it the races are searched with LIKE instead.
+var searchTriggers = []string{"races_search_insert", "races_search_update", "races_search_delete", "races_search_meeting_update"}
+
+// initSearch drops the triggers of the full-text index, as the database may have been indexed by a build with
+// FTS5 and the races can't be written while they exist. The index is rebuilt the next time FTS5 is used.
+func (r *racesRepo) initSearch() error {
+	if r.dialect != dialect.SQLite {
+		return nil
+	}
+
+	for _, trigger := range searchTriggers {
+		if _, err := r.db.Exec(`DROP TRIGGER IF EXISTS ` + trigger); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// searchClause returns the condition selecting the races matching every term.
+func (r *racesRepo) searchClause(terms []string) (string, []interface{}) {
+	return r.likeSearchClause(terms)
+}
+
+// searchMatches returns the races matching every term, ranked by relevance.
+func (r *racesRepo) searchMatches(ctx context.Context, terms []string, limit int32) ([]*raceMatch, error) {
+	return r.likeSearchMatches(ctx, terms, limit)
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRacesRepo_Search(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	racesRepo, err := initTestSearchDB(db)
+	if err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		query       string
+		expectedIds []int64
+	}{
+		{
+			name:        "RaceName",
+			query:       "griffins",
+			expectedIds: []int64{2},
+		},
+		{
+			name:        "IgnoresCase",
+			query:       "CONNECTICUT",
+			expectedIds: []int64{2},
+		},
+		{
+			name:        "StartOfWord",
+			query:       "ghost",
+			expectedIds: []int64{3},
+		},
+		{
+			name:        "MeetingName",
+			query:       "flemington",
+			expectedIds: []int64{2},
+		},
+		{
+			name:        "EveryWordAcrossNames",
+			query:       "north, ascot!",
+			expectedIds: []int64{1},
+		},
+		{
+			name:  "NotEveryWordMatched",
+			query: "north flemington",
+		},
+		{
+			name:  "NoWords",
+			query: "!!",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := racesRepo.Search(context.Background(), tc.query, 10, getDateNow())
+			if err != nil {
+				t.Fatalf("failed to search races: %v", err)
+			}
+
+			var ids []int64
+			for _, result := range results {
+				ids = append(ids, result.Race.Id)
+				assert.Greater(t, result.Score, 0.0)
+			}
+			assert.Equal(t, tc.expectedIds, ids)
+		})
+	}
+
+	t.Run("RaceIsReturned", func(t *testing.T) {
+		results, err := racesRepo.Search(context.Background(), "griffins", 10, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to search races: %v", err)
+		}
+
+		assert.Equal(t, getAllTestData()[1].Name, results[0].Race.Name)
+		assert.Equal(t, racing.RaceStatus_OPEN, results[0].Race.Status)
+		assert.Contains(t, results[0].NameSnippet, highlightStart)
+		assert.NotContains(t, results[0].MeetingNameSnippet, highlightStart)
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		results, err := racesRepo.Search(context.Background(), "a", 1, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to search races: %v", err)
+		}
+
+		assert.Len(t, results, 1)
+	})
+
+	t.Run("ListFilter", func(t *testing.T) {
+		races, _, err := racesRepo.List(context.Background(), &racing.ListRacesRequestFilter{Search: "ghosts"}, nil, 0, "", nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get races: %v", err)
+		}
+
+		assert.Equal(t, []int64{3}, raceIds(races))
+	})
+}
+
+// initTestSearchDB creates the test races along with their meetings, and indexes them for searching.
+func initTestSearchDB(db *sql.DB) (*racesRepo, error) {
+	if err := initTestMeetingsDB(db); err != nil {
+		return nil, err
+	}
+
+	if err := initTestDB(db); err != nil {
+		return nil, err
+	}
+
+	racesRepo := &racesRepo{db: db}
+	return racesRepo, racesRepo.initSearch()
+}
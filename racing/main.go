@@ -3,22 +3,59 @@ package main
 import (
 	"database/sql"
 	"flag"
+	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
 
+	"git.neds.sh/matty/entain/common/clock"
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/common/db/instrument"
+	"git.neds.sh/matty/entain/common/db/migrations"
+	"git.neds.sh/matty/entain/common/interceptor"
+	"git.neds.sh/matty/entain/common/tracing"
 	"git.neds.sh/matty/entain/racing/db"
 	"git.neds.sh/matty/entain/racing/proto/racing"
 	"git.neds.sh/matty/entain/racing/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 )
 
 var (
-	grpcEndpoint = flag.String("grpc-racing-endpoint", "localhost:9000", "gRPC racing server endpoint")
+	dbDriver        = flag.String("db-driver", "sqlite3", "Driver of the racing database, sqlite3 or postgres")
+	dbDSN           = flag.String("db-dsn", "./db/racing.db", "Data source name of the racing database, a file path for sqlite3 or a connection string for postgres")
+	grpcEndpoint    = flag.String("grpc-racing-endpoint", "localhost:9000", "gRPC racing server endpoint")
+	grpcAdmin       = flag.String("grpc-racing-admin-endpoint", "localhost:9002", "gRPC racing admin server endpoint, which must only be reachable from the internal network")
+	maxBatchIDs     = flag.Int("max-batch-ids", service.DefaultMaxBatchIDs, "Largest number of races BatchGetRaces returns at once")
+	metricsEndpoint = flag.String("metrics-endpoint", "localhost:9100", "Endpoint serving the Prometheus metrics on /metrics, empty to not serve them")
+	otlpEndpoint    = flag.String("otlp-endpoint", "localhost:4317", "Endpoint of the OTLP gRPC collector the spans are exported to with -trace-exporter=otlp")
+	seed            = flag.Bool("seed", true, "Seed the database with dummy meetings, races, runners and prices. Defaults to false with -db-driver=postgres")
+	rpcTimeout      = flag.Duration("rpc-timeout", interceptor.DefaultTimeout, "Longest time a unary RPC can run for, streams are not limited. 0 for no limit")
+	rpcTimeouts     = methodTimeouts{}
+	traceExporter   = flag.String("trace-exporter", "none", "Exporter of the spans, none, stdout or otlp")
+	traceSample     = flag.Float64("trace-sample-ratio", 1, "Ratio of the traces started by the server which are sampled, the ones of callers are sampled like the callers")
 )
 
+func init() {
+	flag.Var(rpcTimeouts, "rpc-method-timeout", "Timeout of a single RPC overriding -rpc-timeout, as <method>=<duration>, e.g. ListRaces=2s. It can be repeated")
+}
+
 func main() {
 	flag.Parse()
 
+	if flag.Arg(0) == "migrate" {
+		if err := migrate(flag.Args()[1:]); err != nil {
+			log.Fatalf("failed migrating database: %s\n", err)
+		}
+		return
+	}
+
 	if err := run(); err != nil {
 		log.Fatalf("failed running grpc server: %s\n", err)
 	}
@@ -30,25 +67,128 @@ func run() error {
 		return err
 	}
 
-	racingDB, err := sql.Open("sqlite3", "./db/racing.db")
+	// Metrics are served on an endpoint of their own, so they aren't exposed along with the API
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	queryMetrics := instrument.NewQueryMetrics(registry)
+	rpcMetrics := interceptor.NewMetrics(registry)
+
+	tracerProvider, stopTracing, err := tracing.NewProvider("racing", *traceExporter, *otlpEndpoint, *traceSample)
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+
+	d, err := dialect.ForDriver(*dbDriver)
+	if err != nil {
+		return err
+	}
+	queryTracer := instrument.NewQueryTracer(tracerProvider, d)
+
+	racingDB, err := openDB(d, queryMetrics.Hook, queryTracer.Hook)
 	if err != nil {
 		return err
 	}
+	registry.MustRegister(collectors.NewDBStatsCollector(racingDB, "racing"))
+
+	if *metricsEndpoint != "" {
+		if err := serveMetrics(registry); err != nil {
+			return err
+		}
+	}
 
-	racesRepo := db.NewRacesRepo(racingDB)
+	if err := migrations.Migrate(racingDB, db.Migrations(), d); err != nil {
+		return err
+	}
+
+	meetingsRepo := db.NewMeetingsRepo(racingDB, db.WithDialect(d))
+	if err := meetingsRepo.Init(); err != nil {
+		return err
+	}
+
+	racesRepo := db.NewRacesRepo(racingDB, db.WithDialect(d))
 	if err := racesRepo.Init(); err != nil {
 		return err
 	}
 
-	grpcServer := grpc.NewServer()
+	runnersRepo := db.NewRunnersRepo(racingDB, db.WithDialect(d))
+	if err := runnersRepo.Init(); err != nil {
+		return err
+	}
+
+	resultsRepo := db.NewResultsRepo(racingDB, db.WithDialect(d))
+	if err := resultsRepo.Init(); err != nil {
+		return err
+	}
+
+	pricesRepo := db.NewPricesRepo(racingDB, db.WithDialect(d))
+	if err := pricesRepo.Init(); err != nil {
+		return err
+	}
+
+	// The services, repositories and seeding tell the time with the same clock, which can be moved in builds with
+	// the timetravel tag
+	var clk clock.Clock = clock.System
+	if service.TimeTravelEnabled {
+		clk = clock.NewTravel(clock.System)
+		log.Println("time travel is enabled, this build must not be used in production")
+	}
+
+	// For test/example purposes, the DB is seeded with dummy data unless it is turned off
+	if seedEnabled(d) {
+		if err := db.Seed(racingDB, db.WithDialect(d), db.WithClock(clk)); err != nil {
+			return err
+		}
+	}
+
+	// RPCs are traced in spans continuing the traces of their callers, whose context is in the metadata of the RPCs.
+	// The spans of the queries are children of the ones of the RPCs running them
+	traceOptions := []otelgrpc.Option{otelgrpc.WithTracerProvider(tracerProvider), otelgrpc.WithPropagators(tracing.Propagator)}
+	serverOptions := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			otelgrpc.UnaryServerInterceptor(traceOptions...),
+			rpcMetrics.UnaryInterceptor(),
+			interceptor.UnaryTimeout(*rpcTimeout, rpcTimeouts),
+		),
+		grpc.ChainStreamInterceptor(
+			otelgrpc.StreamServerInterceptor(traceOptions...),
+			rpcMetrics.StreamInterceptor(),
+		),
+	}
+	grpcServer := grpc.NewServer(serverOptions...)
 
 	racing.RegisterRacingServer(
 		grpcServer,
 		service.NewRacingService(
 			racesRepo,
+			runnersRepo,
+			meetingsRepo,
+			resultsRepo,
+			pricesRepo,
+			service.WithMaxBatchIDs(*maxBatchIDs),
+			service.WithClock(clk),
 		),
 	)
 
+	// RacingAdmin changes the races, so it is served by a server of its own on the internal admin endpoint rather
+	// than along with the public Racing service
+	adminServer := grpc.NewServer(serverOptions...)
+	racing.RegisterRacingAdminServer(
+		adminServer,
+		service.NewRacingAdminService(
+			racesRepo,
+			runnersRepo,
+			meetingsRepo,
+			resultsRepo,
+			pricesRepo,
+			service.WithAdminClock(clk),
+		),
+	)
+
+	if err := serveAdmin(adminServer); err != nil {
+		return err
+	}
+
 	log.Printf("gRPC racing server listening on: %s\n", *grpcEndpoint)
 
 	if err := grpcServer.Serve(conn); err != nil {
@@ -57,3 +197,99 @@ func run() error {
 
 	return nil
 }
+
+// openDB opens the racing database of the db-dsn flag, which is migrated when the server starts or by the migrate
+// command. The dialect is the one of the db-driver flag. The hooks are called around each query run on it.
+func openDB(d dialect.Dialect, hooks ...instrument.QueryHook) (*sql.DB, error) {
+	return instrument.OpenDB(d.Driver(), *dbDSN, hooks...)
+}
+
+// seedEnabled reports whether the database is seeded. Unless the seed flag is given, only SQLite databases are
+// seeded, so dummy data doesn't end up in a PostgreSQL database by accident.
+func seedEnabled(d dialect.Dialect) bool {
+	enabled := *seed && d != dialect.Postgres
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			enabled = *seed
+		}
+	})
+
+	return enabled
+}
+
+// migrate applies or rolls back the migrations of the racing database without starting the server.
+func migrate(args []string) error {
+	d, err := dialect.ForDriver(*dbDriver)
+	if err != nil {
+		return err
+	}
+
+	return migrations.Command(args, func() (*sql.DB, error) { return openDB(d) }, db.Migrations(), d)
+}
+
+// serveAdmin serves the admin server on the admin endpoint in the background.
+func serveAdmin(server *grpc.Server) error {
+	conn, err := net.Listen("tcp", *grpcAdmin)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := server.Serve(conn); err != nil {
+			log.Printf("failed serving grpc admin server: %s\n", err)
+		}
+	}()
+
+	log.Printf("gRPC racing admin server listening on: %s\n", *grpcAdmin)
+
+	return nil
+}
+
+// serveMetrics serves the metrics of the registry on /metrics of the metrics endpoint, in the background.
+func serveMetrics(registry *prometheus.Registry) error {
+	conn, err := net.Listen("tcp", *metricsEndpoint)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	go func() {
+		if err := http.Serve(conn, mux); err != nil {
+			log.Printf("failed serving metrics: %s\n", err)
+		}
+	}()
+
+	log.Printf("metrics listening on: %s\n", *metricsEndpoint)
+
+	return nil
+}
+
+// methodTimeouts are the timeouts of single RPCs, keyed by method. It is set by repeating a flag.
+type methodTimeouts map[string]time.Duration
+
+func (t methodTimeouts) String() string {
+	timeouts := make([]string, 0, len(t))
+	for method, timeout := range t {
+		timeouts = append(timeouts, method+"="+timeout.String())
+	}
+	sort.Strings(timeouts)
+
+	return strings.Join(timeouts, ",")
+}
+
+func (t methodTimeouts) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("method timeout %q must be <method>=<duration>", value)
+	}
+
+	timeout, err := time.ParseDuration(parts[1])
+	if err != nil {
+		return err
+	}
+
+	t[parts[0]] = timeout
+	return nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+
+	"git.neds.sh/matty/entain/common/clock"
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type RacingAdmin interface {
+	// UpdateRaceStatus will move a race to a new status
+	UpdateRaceStatus(ctx context.Context, in *racing.UpdateRaceStatusRequest) (*racing.UpdateRaceStatusResponse, error)
+	// SubmitRaceResult will record the interim or final result of a race
+	SubmitRaceResult(ctx context.Context, in *racing.SubmitRaceResultRequest) (*racing.SubmitRaceResultResponse, error)
+	// IngestPrices will record a batch of price updates
+	IngestPrices(ctx context.Context, in *racing.IngestPricesRequest) (*racing.IngestPricesResponse, error)
+	// CreateRace will add a race to a meeting
+	CreateRace(ctx context.Context, in *racing.CreateRaceRequest) (*racing.CreateRaceResponse, error)
+	// UpdateRace will change the fields of a race in the update mask
+	UpdateRace(ctx context.Context, in *racing.UpdateRaceRequest) (*racing.UpdateRaceResponse, error)
+	// DeleteRace will remove a race
+	DeleteRace(ctx context.Context, in *racing.DeleteRaceRequest) (*racing.DeleteRaceResponse, error)
+	// TimeTravel will move the clock of the service, in builds with the timetravel tag
+	TimeTravel(ctx context.Context, in *racing.TimeTravelRequest) (*racing.TimeTravelResponse, error)
+}
+
+// racingAdminService implements the RacingAdmin interface.
+type racingAdminService struct {
+	racesRepo    db.RacesRepo
+	runnersRepo  db.RunnersRepo
+	meetingsRepo db.MeetingsRepo
+	resultsRepo  db.ResultsRepo
+	pricesRepo   db.PricesRepo
+	// clock tells the time the statuses of the races are derived at, and prices and results are recorded at
+	clock clock.Clock
+}
+
+// AdminOption configures the racingAdminService.
+type AdminOption func(s *racingAdminService)
+
+// WithAdminClock sets the clock the admin service tells the time with, the system clock by default.
+func WithAdminClock(c clock.Clock) AdminOption {
+	return func(s *racingAdminService) {
+		s.clock = c
+	}
+}
+
+// NewRacingAdminService instantiates and returns a new racingAdminService.
+func NewRacingAdminService(racesRepo db.RacesRepo, runnersRepo db.RunnersRepo, meetingsRepo db.MeetingsRepo, resultsRepo db.ResultsRepo, pricesRepo db.PricesRepo, opts ...AdminOption) RacingAdmin {
+	s := &racingAdminService{
+		racesRepo:    racesRepo,
+		runnersRepo:  runnersRepo,
+		meetingsRepo: meetingsRepo,
+		resultsRepo:  resultsRepo,
+		pricesRepo:   pricesRepo,
+		clock:        clock.System,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *racingAdminService) UpdateRaceStatus(ctx context.Context, in *racing.UpdateRaceStatusRequest) (*racing.UpdateRaceStatusResponse, error) {
+	if in.Status == racing.RaceStatus_RACE_STATUS_UNSPECIFIED {
+		return nil, status.Error(codes.InvalidArgument, "status is required")
+	}
+
+	race, err := s.racesRepo.Get(ctx, in.Id, nil, s.clock.Now())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// If the race is not found, return a 404 status code
+			return nil, status.Errorf(codes.NotFound, "Race with ID %d not found", in.Id)
+		}
+		return nil, err
+	}
+
+	// Setting the current status again is a no-op, so requests can be retried
+	if race.Status == in.Status {
+		return &racing.UpdateRaceStatusResponse{Race: race}, nil
+	}
+
+	if !canTransition(race.Status, in.Status) {
+		return nil, status.Errorf(codes.FailedPrecondition, "Race with ID %d can't move from %s to %s", in.Id, race.Status, in.Status)
+	}
+
+	if resultedStatuses[in.Status] {
+		return nil, status.Errorf(codes.FailedPrecondition, "Race with ID %d can only move to %s when its result is submitted", in.Id, in.Status)
+	}
+
+	if err := s.racesRepo.UpdateStatus(ctx, in.Id, race.Status, in.Status, s.clock.Now()); err != nil {
+		if errors.Is(err, db.ErrStatusChanged) {
+			return nil, status.Errorf(codes.Aborted, "Race with ID %d status was changed by someone else, try again", in.Id)
+		}
+		return nil, err
+	}
+
+	race, err = s.racesRepo.Get(ctx, in.Id, nil, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.UpdateRaceStatusResponse{Race: race}, nil
+}
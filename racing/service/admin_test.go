@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"testing"
+	"time"
+)
+
+// adminRacesRepo is a MockRacesRepo keeping the races it updates.
+type adminRacesRepo struct {
+	MockRacesRepo
+	races map[int64]*racing.Race
+	// conflict makes the next update fail as if the race was changed by someone else
+	conflict bool
+}
+
+func newAdminRacesRepo() *adminRacesRepo {
+	races := make(map[int64]*racing.Race)
+	for _, race := range getAllTestData() {
+		race.Version = 1
+		races[race.Id] = race
+	}
+	return &adminRacesRepo{races: races}
+}
+
+func (m *adminRacesRepo) Get(ctx context.Context, id int64, readMask *fieldmaskpb.FieldMask, currentDate time.Time) (*racing.Race, error) {
+	race, ok := m.races[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return race, nil
+}
+
+func (m *adminRacesRepo) Create(ctx context.Context, race *racing.Race) (int64, error) {
+	id := int64(len(m.races) + 1)
+	for m.races[id] != nil {
+		id++
+	}
+
+	created := proto.Clone(race).(*racing.Race)
+	created.Id = id
+	created.Status = racing.RaceStatus_OPEN
+	m.races[id] = created
+	return id, nil
+}
+
+func (m *adminRacesRepo) Update(ctx context.Context, race *racing.Race, fields []string) error {
+	updated, ok := m.races[race.Id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	if race.Version != updated.Version {
+		return db.ErrVersionChanged
+	}
+
+	updated = proto.Clone(updated).(*racing.Race)
+	for _, field := range fields {
+		descriptor := updated.ProtoReflect().Descriptor().Fields().ByName(protoreflect.Name(field))
+		updated.ProtoReflect().Set(descriptor, race.ProtoReflect().Get(descriptor))
+	}
+	updated.Version++
+	m.races[race.Id] = updated
+	return nil
+}
+
+func (m *adminRacesRepo) Delete(ctx context.Context, id int64) error {
+	if _, ok := m.races[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.races, id)
+	return nil
+}
+
+func (m *adminRacesRepo) UpdateStatus(ctx context.Context, id int64, from racing.RaceStatus, to racing.RaceStatus, currentDate time.Time) error {
+	if m.conflict || m.races[id].Status != from {
+		return db.ErrStatusChanged
+	}
+	m.races[id].Status = to
+	return nil
+}
+
+func TestRacingAdminService_UpdateRaceStatus(t *testing.T) {
+	testCases := []struct {
+		name           string
+		id             int64
+		from           racing.RaceStatus
+		status         racing.RaceStatus
+		conflict       bool
+		expectedCode   codes.Code
+		expectedStatus racing.RaceStatus
+	}{
+		{
+			name:           "OpenToSuspended",
+			id:             2,
+			status:         racing.RaceStatus_SUSPENDED,
+			expectedCode:   codes.OK,
+			expectedStatus: racing.RaceStatus_SUSPENDED,
+		},
+		{
+			name:         "ClosedToInterim",
+			id:           1,
+			status:       racing.RaceStatus_INTERIM,
+			expectedCode: codes.FailedPrecondition,
+		},
+		{
+			name:         "InterimToFinal",
+			id:           1,
+			from:         racing.RaceStatus_INTERIM,
+			status:       racing.RaceStatus_FINAL,
+			expectedCode: codes.FailedPrecondition,
+		},
+		{
+			name:           "InterimToAbandoned",
+			id:             1,
+			from:           racing.RaceStatus_INTERIM,
+			status:         racing.RaceStatus_ABANDONED,
+			expectedCode:   codes.OK,
+			expectedStatus: racing.RaceStatus_ABANDONED,
+		},
+		{
+			name:           "SameStatus",
+			id:             2,
+			status:         racing.RaceStatus_OPEN,
+			expectedCode:   codes.OK,
+			expectedStatus: racing.RaceStatus_OPEN,
+		},
+		{
+			name:         "InvalidTransition",
+			id:           2,
+			status:       racing.RaceStatus_FINAL,
+			expectedCode: codes.FailedPrecondition,
+		},
+		{
+			name:         "MissingStatus",
+			id:           2,
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "RaceNotFound",
+			id:           999,
+			status:       racing.RaceStatus_CLOSED,
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:         "ChangedConcurrently",
+			id:           2,
+			status:       racing.RaceStatus_CLOSED,
+			conflict:     true,
+			expectedCode: codes.Aborted,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			racesRepo := newAdminRacesRepo()
+			racesRepo.conflict = tc.conflict
+			if tc.from != racing.RaceStatus_RACE_STATUS_UNSPECIFIED {
+				racesRepo.races[tc.id].Status = tc.from
+			}
+			adminSvc := NewRacingAdminService(racesRepo, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{})
+
+			response, err := adminSvc.UpdateRaceStatus(context.Background(), &racing.UpdateRaceStatusRequest{Id: tc.id, Status: tc.status})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode == codes.OK {
+				assert.Equal(t, tc.expectedStatus, response.Race.Status)
+			}
+		})
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	// FINAL and ABANDONED races can't be moved anywhere
+	for _, to := range []racing.RaceStatus{racing.RaceStatus_OPEN, racing.RaceStatus_CLOSED, racing.RaceStatus_INTERIM} {
+		assert.False(t, canTransition(racing.RaceStatus_FINAL, to))
+		assert.False(t, canTransition(racing.RaceStatus_ABANDONED, to))
+	}
+
+	assert.True(t, canTransition(racing.RaceStatus_INTERIM, racing.RaceStatus_FINAL))
+	assert.False(t, canTransition(racing.RaceStatus_OPEN, racing.RaceStatus_INTERIM))
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultMaxBatchIDs is the largest number of races BatchGetRaces returns at once, unless configured otherwise.
+const DefaultMaxBatchIDs = 50
+
+func (s *racingService) BatchGetRaces(ctx context.Context, in *racing.BatchGetRacesRequest) (*racing.BatchGetRacesResponse, error) {
+	ids := uniqueIDs(in.Ids)
+
+	switch {
+	case len(ids) == 0:
+		return nil, status.Error(codes.InvalidArgument, "ids are required")
+	case len(ids) > s.maxBatchIDs:
+		return nil, status.Errorf(codes.InvalidArgument, "no more than %d ids can be requested at once", s.maxBatchIDs)
+	}
+
+	races, err := s.racesRepo.BatchGet(ctx, ids, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*racing.Race, len(races))
+	for _, race := range races {
+		byID[race.Id] = race
+	}
+
+	// Races are returned in the order they were requested, so the betslip doesn't have to sort them again
+	response := &racing.BatchGetRacesResponse{Races: make([]*racing.Race, 0, len(races))}
+	for _, id := range ids {
+		if race, ok := byID[id]; ok {
+			response.Races = append(response.Races, race)
+		} else {
+			response.MissingIds = append(response.MissingIds, id)
+		}
+	}
+
+	return response, nil
+}
+
+// uniqueIDs returns the ids without the repeated ones, keeping their order.
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	unique := make([]int64, 0, len(ids))
+
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/protobuf/proto"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxPriceUpdates is the largest batch of price updates that can be ingested at once.
+const maxPriceUpdates = 1000
+
+// IngestPrices validates the whole batch before recording it, so the price feed can resend a rejected batch
+// once it is fixed without duplicating the updates that were valid.
+func (s *racingAdminService) IngestPrices(ctx context.Context, in *racing.IngestPricesRequest) (*racing.IngestPricesResponse, error) {
+	if len(in.Updates) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "updates are required")
+	}
+	if len(in.Updates) > maxPriceUpdates {
+		return nil, status.Errorf(codes.InvalidArgument, "no more than %d updates can be sent at once", maxPriceUpdates)
+	}
+
+	now, err := ptypes.TimestampProto(s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	runners := make(map[int64]*racing.Runner)
+	updates := make([]*racing.PriceUpdate, 0, len(in.Updates))
+
+	for i, update := range in.Updates {
+		runner, ok := runners[update.RunnerId]
+		if !ok {
+			if runner, err = s.runnersRepo.Get(ctx, update.RunnerId); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return nil, status.Errorf(codes.InvalidArgument, "updates[%d]: Runner with ID %d not found", i, update.RunnerId)
+				}
+				return nil, err
+			}
+			runners[update.RunnerId] = runner
+		}
+
+		switch {
+		case runner.Scratched:
+			return nil, status.Errorf(codes.InvalidArgument, "updates[%d]: Runner with ID %d was scratched and can't be priced", i, update.RunnerId)
+		case update.Win <= 1 || update.Place <= 1:
+			return nil, status.Errorf(codes.InvalidArgument, "updates[%d]: prices must be greater than 1", i)
+		case update.Place > update.Win:
+			return nil, status.Errorf(codes.InvalidArgument, "updates[%d]: place price can't be greater than the win price", i)
+		}
+
+		if update.Time != nil {
+			if err := update.Time.CheckValid(); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "updates[%d]: time is invalid: %v", i, err)
+			}
+		}
+
+		// Updates without a time are priced as they are received
+		if update.Time == nil {
+			update = proto.Clone(update).(*racing.PriceUpdate)
+			update.Time = now
+		}
+		updates = append(updates, update)
+	}
+
+	recorded, err := s.pricesRepo.Record(ctx, updates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.IngestPricesResponse{Recorded: recorded}, nil
+}
+
+func (s *racingService) GetRunnerFlucs(ctx context.Context, in *racing.GetRunnerFlucsRequest) (*racing.GetRunnerFlucsResponse, error) {
+	if in.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit can't be negative")
+	}
+
+	if _, err := s.runnersRepo.Get(ctx, in.RunnerId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "Runner with ID %d not found", in.RunnerId)
+		}
+		return nil, err
+	}
+
+	var from, to time.Time
+	if in.From != nil {
+		from = in.From.AsTime()
+	}
+	if in.To != nil {
+		to = in.To.AsTime()
+	} else {
+		to = s.clock.Now()
+	}
+
+	if !from.Before(to) {
+		return nil, status.Error(codes.InvalidArgument, "from must be before to")
+	}
+
+	prices, err := s.pricesRepo.Flucs(ctx, in.RunnerId, from, to, in.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.GetRunnerFlucsResponse{Prices: prices}, nil
+}
+
+// includePrices sets the prices of the runners, along with their latest price movements.
+func (s *racingService) includePrices(ctx context.Context, runners []*racing.Runner, flucs int32) error {
+	if flucs < 0 {
+		return status.Error(codes.InvalidArgument, "flucs can't be negative")
+	}
+
+	runnerIDs := make([]int64, 0, len(runners))
+	for _, runner := range runners {
+		runnerIDs = append(runnerIDs, runner.Id)
+	}
+
+	prices, err := s.pricesRepo.Latest(ctx, runnerIDs, flucs)
+	if err != nil {
+		return err
+	}
+
+	for _, runner := range runners {
+		runner.Prices = prices[runner.Id]
+	}
+
+	return nil
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+)
+
+// MockPricesRepo is a mock implementation of the db.PricesRepo interface keeping the updates it records.
+type MockPricesRepo struct {
+	// prices are returned by Latest, keyed by runner id
+	prices   map[int64]*racing.RunnerPrices
+	recorded []*racing.PriceUpdate
+	// flucs has the arguments of the last Flucs call
+	from, to time.Time
+	limit    int32
+}
+
+func (m *MockPricesRepo) Init() error {
+	return nil
+}
+
+func (m *MockPricesRepo) Record(ctx context.Context, updates []*racing.PriceUpdate) (int32, error) {
+	m.recorded = append(m.recorded, updates...)
+	return int32(len(updates)), nil
+}
+
+func (m *MockPricesRepo) Latest(ctx context.Context, runnerIDs []int64, flucs int32) (map[int64]*racing.RunnerPrices, error) {
+	prices := make(map[int64]*racing.RunnerPrices)
+	for _, runnerID := range runnerIDs {
+		if runnerPrices, ok := m.prices[runnerID]; ok {
+			if int(flucs) < len(runnerPrices.Flucs) {
+				runnerPrices.Flucs = runnerPrices.Flucs[len(runnerPrices.Flucs)-int(flucs):]
+			}
+			prices[runnerID] = runnerPrices
+		}
+	}
+	return prices, nil
+}
+
+func (m *MockPricesRepo) Flucs(ctx context.Context, runnerID int64, from time.Time, to time.Time, limit int32) (*racing.RunnerPrices, error) {
+	m.from, m.to, m.limit = from, to, limit
+	return getTestRunnerPrices(), nil
+}
+
+func getTestRunnerPrices() *racing.RunnerPrices {
+	opening := &racing.RunnerPrice{Win: 6, Place: 2.25, Time: timestamppb.New(time.Date(2023, 7, 14, 12, 0, 0, 0, time.UTC))}
+	current := &racing.RunnerPrice{Win: 4.2, Place: 1.8, Time: timestamppb.New(time.Date(2023, 7, 15, 11, 0, 0, 0, time.UTC))}
+	return &racing.RunnerPrices{
+		Opening: opening,
+		Current: current,
+		Flucs: []*racing.RunnerPrice{
+			opening,
+			{Win: 5, Place: 2, Time: timestamppb.New(time.Date(2023, 7, 15, 9, 0, 0, 0, time.UTC))},
+			current,
+		},
+	}
+}
+
+func TestRacingService_ListRunnersWithPrices(t *testing.T) {
+	pricesRepo := &MockPricesRepo{prices: map[int64]*racing.RunnerPrices{1: getTestRunnerPrices()}}
+	racingSvc := NewRacingService(&MockRacesRepo{}, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, pricesRepo)
+
+	t.Run("LatestFlucs", func(t *testing.T) {
+		response, err := racingSvc.ListRunners(context.Background(), &racing.ListRunnersRequest{RaceId: 2, Flucs: 2})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		prices := getTestRunnerPrices()
+		assert.Equal(t, prices.Current, response.Runners[0].Prices.Current)
+		assert.Equal(t, prices.Flucs[1:], response.Runners[0].Prices.Flucs)
+		// Runners without prices are left without them
+		assert.Nil(t, response.Runners[1].Prices)
+	})
+
+	t.Run("NegativeFlucs", func(t *testing.T) {
+		_, err := racingSvc.ListRunners(context.Background(), &racing.ListRunnersRequest{RaceId: 2, Flucs: -1})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestRacingService_GetRunnerFlucs(t *testing.T) {
+	t.Run("DefaultsToNow", func(t *testing.T) {
+		pricesRepo := &MockPricesRepo{}
+		racingSvc := NewRacingService(&MockRacesRepo{}, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, pricesRepo)
+
+		response, err := racingSvc.GetRunnerFlucs(context.Background(), &racing.GetRunnerFlucsRequest{RunnerId: 1, Limit: 5})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, getTestRunnerPrices(), response.Prices)
+		assert.True(t, pricesRepo.from.IsZero())
+		assert.WithinDuration(t, time.Now(), pricesRepo.to, time.Minute)
+		assert.Equal(t, int32(5), pricesRepo.limit)
+	})
+
+	testCases := []struct {
+		name         string
+		request      *racing.GetRunnerFlucsRequest
+		expectedCode codes.Code
+	}{
+		{
+			name: "TimeRange",
+			request: &racing.GetRunnerFlucsRequest{
+				RunnerId: 1,
+				From:     timestamppb.New(time.Date(2023, 7, 15, 0, 0, 0, 0, time.UTC)),
+				To:       timestamppb.New(time.Date(2023, 7, 16, 0, 0, 0, 0, time.UTC)),
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name: "FromAfterTo",
+			request: &racing.GetRunnerFlucsRequest{
+				RunnerId: 1,
+				From:     timestamppb.New(time.Date(2023, 7, 16, 0, 0, 0, 0, time.UTC)),
+				To:       timestamppb.New(time.Date(2023, 7, 15, 0, 0, 0, 0, time.UTC)),
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "NegativeLimit",
+			request:      &racing.GetRunnerFlucsRequest{RunnerId: 1, Limit: -1},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "RunnerNotFound",
+			request:      &racing.GetRunnerFlucsRequest{RunnerId: 999},
+			expectedCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			racingSvc := NewRacingService(&MockRacesRepo{}, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{})
+
+			_, err := racingSvc.GetRunnerFlucs(context.Background(), tc.request)
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestRacingAdminService_IngestPrices(t *testing.T) {
+	testCases := []struct {
+		name         string
+		updates      []*racing.PriceUpdate
+		expectedCode codes.Code
+	}{
+		{
+			name: "Batch",
+			updates: []*racing.PriceUpdate{
+				{RunnerId: 1, Win: 4.2, Place: 1.8, Time: timestamppb.New(time.Date(2023, 7, 15, 11, 0, 0, 0, time.UTC))},
+				{RunnerId: 3, Win: 2.5, Place: 1.35},
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "Empty",
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "RunnerNotFound",
+			updates: []*racing.PriceUpdate{
+				{RunnerId: 1, Win: 4.2, Place: 1.8},
+				{RunnerId: 999, Win: 4.2, Place: 1.8},
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "ScratchedRunner",
+			updates:      []*racing.PriceUpdate{{RunnerId: 2, Win: 4.2, Place: 1.8}},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "PriceTooLow",
+			updates:      []*racing.PriceUpdate{{RunnerId: 1, Win: 1, Place: 1}},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "PlaceLongerThanWin",
+			updates:      []*racing.PriceUpdate{{RunnerId: 1, Win: 2, Place: 3}},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "InvalidTime",
+			updates: []*racing.PriceUpdate{
+				{RunnerId: 1, Win: 4.2, Place: 1.8},
+				{RunnerId: 3, Win: 2.5, Place: 1.35, Time: &timestamppb.Timestamp{Seconds: 1689418800, Nanos: -1}},
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "TooManyUpdates",
+			updates:      make([]*racing.PriceUpdate, maxPriceUpdates+1),
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pricesRepo := &MockPricesRepo{}
+			adminSvc := NewRacingAdminService(newAdminRacesRepo(), &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, pricesRepo)
+
+			response, err := adminSvc.IngestPrices(context.Background(), &racing.IngestPricesRequest{Updates: tc.updates})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode != codes.OK {
+				// Invalid batches are rejected as a whole
+				assert.Empty(t, pricesRepo.recorded)
+				return
+			}
+
+			assert.Equal(t, int32(len(tc.updates)), response.Recorded)
+			for i, update := range pricesRepo.recorded {
+				assert.Equal(t, tc.updates[i].RunnerId, update.RunnerId)
+				// Updates without a time are priced when they are received
+				assert.NotNil(t, update.Time)
+			}
+		})
+	}
+}
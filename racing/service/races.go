@@ -0,0 +1,216 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *racingAdminService) CreateRace(ctx context.Context, in *racing.CreateRaceRequest) (*racing.CreateRaceResponse, error) {
+	if in.Race == nil {
+		return nil, status.Error(codes.InvalidArgument, "race is required")
+	}
+
+	violations, err := s.validateRace(ctx, in.Race, db.RaceWriteFields)
+	if err != nil {
+		return nil, err
+	}
+	if len(violations) > 0 {
+		return nil, invalidArgument("race is invalid", violations)
+	}
+
+	id, err := s.racesRepo.Create(ctx, in.Race)
+	if err != nil {
+		return nil, err
+	}
+
+	race, err := s.racesRepo.Get(ctx, id, nil, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.CreateRaceResponse{Race: race}, nil
+}
+
+// UpdateRace only validates the fields in the update mask, so a race can be amended without restating the rest of it.
+// The race must be at the version it was read at, so changes made in the meantime aren't overwritten.
+func (s *racingAdminService) UpdateRace(ctx context.Context, in *racing.UpdateRaceRequest) (*racing.UpdateRaceResponse, error) {
+	if in.Race == nil {
+		return nil, status.Error(codes.InvalidArgument, "race is required")
+	}
+
+	fields, violations := updateFields(in.UpdateMask.GetPaths())
+	if in.Race.Version <= 0 {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "race.version",
+			Description: "version is required, it must be the version the race was read at",
+		})
+	}
+
+	fieldViolations, err := s.validateRace(ctx, in.Race, fields)
+	if err != nil {
+		return nil, err
+	}
+	if violations = append(violations, fieldViolations...); len(violations) > 0 {
+		return nil, invalidArgument("race is invalid", violations)
+	}
+
+	if err := s.racesRepo.Update(ctx, in.Race, fields); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "Race with ID %d not found", in.Race.Id)
+		}
+		if errors.Is(err, db.ErrVersionChanged) {
+			return nil, raceVersionChanged(in.Race.Id, in.Race.Version)
+		}
+		return nil, err
+	}
+
+	race, err := s.racesRepo.Get(ctx, in.Race.Id, nil, s.clock.Now())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "Race with ID %d not found", in.Race.Id)
+		}
+		return nil, err
+	}
+
+	return &racing.UpdateRaceResponse{Race: race}, nil
+}
+
+func (s *racingAdminService) DeleteRace(ctx context.Context, in *racing.DeleteRaceRequest) (*racing.DeleteRaceResponse, error) {
+	// Results are kept for settlement, so races that were run can't be deleted
+	if _, err := s.resultsRepo.Get(ctx, in.Id); err == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "Race with ID %d has a result and can't be deleted", in.Id)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if err := s.racesRepo.Delete(ctx, in.Id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "Race with ID %d not found", in.Id)
+		}
+		return nil, err
+	}
+
+	return &racing.DeleteRaceResponse{}, nil
+}
+
+// updateFields returns the fields of a race to update for the paths of an update mask, every field that can be
+// written when there are none. Paths that can't be updated are returned as violations.
+func updateFields(paths []string) ([]string, []*errdetails.BadRequest_FieldViolation) {
+	if len(paths) == 0 {
+		return db.RaceWriteFields, nil
+	}
+
+	var (
+		fields     []string
+		violations []*errdetails.BadRequest_FieldViolation
+	)
+
+	for _, path := range paths {
+		// The version is what the update is made to rather than a field to write, the gateway adds it to the mask
+		// along with the rest of the body
+		if path == "version" {
+			continue
+		}
+		if !isRaceWriteField(path) {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       "update_mask",
+				Description: fmt.Sprintf("field %q can't be updated, it must be one of: %s", path, strings.Join(db.RaceWriteFields, ", ")),
+			})
+			continue
+		}
+		fields = append(fields, path)
+	}
+
+	if len(fields) == 0 && len(violations) == 0 {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "update_mask",
+			Description: "there are no fields to update, it must have some of: " + strings.Join(db.RaceWriteFields, ", "),
+		})
+	}
+
+	return fields, violations
+}
+
+func isRaceWriteField(field string) bool {
+	for _, writeField := range db.RaceWriteFields {
+		if writeField == field {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRace checks the given fields of a race, returning a violation for each invalid one.
+func (s *racingAdminService) validateRace(ctx context.Context, race *racing.Race, fields []string) ([]*errdetails.BadRequest_FieldViolation, error) {
+	var violations []*errdetails.BadRequest_FieldViolation
+
+	violate := func(field string, description string) {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: "race." + field, Description: description})
+	}
+
+	for _, field := range fields {
+		switch field {
+		case "name":
+			if strings.TrimSpace(race.Name) == "" {
+				violate(field, "name is required")
+			}
+		case "number":
+			if race.Number <= 0 {
+				violate(field, "number must be positive")
+			}
+		case "advertised_start_time":
+			if race.AdvertisedStartTime == nil {
+				violate(field, "advertised_start_time is required")
+			} else if err := race.AdvertisedStartTime.CheckValid(); err != nil {
+				violate(field, "advertised_start_time is invalid: "+err.Error())
+			}
+		case "meeting_id":
+			if race.MeetingId <= 0 {
+				violate(field, "meeting_id is required")
+				continue
+			}
+			if _, err := s.meetingsRepo.Get(ctx, race.MeetingId); err != nil {
+				if !errors.Is(err, sql.ErrNoRows) {
+					return nil, err
+				}
+				violate(field, fmt.Sprintf("Meeting with ID %d not found", race.MeetingId))
+			}
+		}
+	}
+
+	return violations, nil
+}
+
+// raceVersionChanged returns an Aborted error for a write made to a stale version of a race. The
+// PreconditionFailure details tell it apart from other conflicts, so the gateway can answer with 412 Precondition
+// Failed.
+func raceVersionChanged(id int64, version int64) error {
+	message := fmt.Sprintf("Race with ID %d was changed since version %d", id, version)
+
+	st, err := status.New(codes.Aborted, message).WithDetails(&errdetails.PreconditionFailure{
+		Violations: []*errdetails.PreconditionFailure_Violation{{Type: "VERSION", Subject: fmt.Sprintf("race/%d", id), Description: message}},
+	})
+	if err != nil {
+		return status.Error(codes.Aborted, message)
+	}
+	return st.Err()
+}
+
+// invalidArgument returns an InvalidArgument error with the violations as BadRequest details, so clients can
+// show each of them next to its field.
+func invalidArgument(message string, violations []*errdetails.BadRequest_FieldViolation) error {
+	st, err := status.New(codes.InvalidArgument, message).WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, message)
+	}
+	return st.Err()
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// preconditionTypes returns the types of the PreconditionFailure details of an error.
+func preconditionTypes(err error) []string {
+	var types []string
+	for _, detail := range status.Convert(err).Details() {
+		if failure, ok := detail.(*errdetails.PreconditionFailure); ok {
+			for _, violation := range failure.Violations {
+				types = append(types, violation.Type)
+			}
+		}
+	}
+	return types
+}
+
+// violatedFields returns the fields of the BadRequest details of an error.
+func violatedFields(err error) []string {
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				fields = append(fields, violation.Field)
+			}
+		}
+	}
+	return fields
+}
+
+func TestRacingAdminService_CreateRace(t *testing.T) {
+	startTime := timestamppb.New(time.Date(2023, 7, 16, 14, 30, 0, 0, time.UTC))
+
+	testCases := []struct {
+		name           string
+		race           *racing.Race
+		expectedCode   codes.Code
+		expectedFields []string
+	}{
+		{
+			name:         "Created",
+			race:         &racing.Race{MeetingId: 5, Name: "Randwick Cup", Number: 7, Visible: true, AdvertisedStartTime: startTime},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "NoRace",
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:           "Invalid",
+			race:           &racing.Race{MeetingId: 5, Name: " ", Number: -1},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"race.name", "race.number", "race.advertised_start_time"},
+		},
+		{
+			name:           "UnknownMeeting",
+			race:           &racing.Race{MeetingId: 999, Name: "Randwick Cup", Number: 7, AdvertisedStartTime: startTime},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"race.meeting_id"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			racesRepo := newAdminRacesRepo()
+			adminSvc := NewRacingAdminService(racesRepo, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{})
+
+			response, err := adminSvc.CreateRace(context.Background(), &racing.CreateRaceRequest{Race: tc.race})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			assert.ElementsMatch(t, tc.expectedFields, violatedFields(err))
+			if err == nil {
+				assert.NotZero(t, response.Race.Id)
+				assert.Equal(t, tc.race.Name, response.Race.Name)
+				assert.Equal(t, response.Race, racesRepo.races[response.Race.Id])
+			}
+		})
+	}
+}
+
+func TestRacingAdminService_UpdateRace(t *testing.T) {
+	testCases := []struct {
+		name           string
+		race           *racing.Race
+		updateMask     []string
+		expectedCode   codes.Code
+		expectedFields []string
+	}{
+		{
+			name:         "Renamed",
+			race:         &racing.Race{Id: 2, Name: "Renamed", Version: 1},
+			updateMask:   []string{"name"},
+			expectedCode: codes.OK,
+		},
+		{
+			name:           "OnlyMaskedFieldsValidated",
+			race:           &racing.Race{Id: 2, Name: "Renamed", MeetingId: 999, Version: 1},
+			updateMask:     []string{"name", "number"},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"race.number"},
+		},
+		{
+			name:           "EveryFieldWithoutMask",
+			race:           &racing.Race{Id: 2, Name: "Renamed", Version: 1},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"race.meeting_id", "race.number", "race.advertised_start_time"},
+		},
+		{
+			name:           "FieldNotWritable",
+			race:           &racing.Race{Id: 2, Status: racing.RaceStatus_CLOSED, Version: 1},
+			updateMask:     []string{"status"},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"update_mask"},
+		},
+		{
+			name:         "VersionInMask",
+			race:         &racing.Race{Id: 2, Name: "Renamed", Version: 1},
+			updateMask:   []string{"name", "version"},
+			expectedCode: codes.OK,
+		},
+		{
+			name:           "OnlyVersionInMask",
+			race:           &racing.Race{Id: 2, Version: 1},
+			updateMask:     []string{"version"},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"update_mask"},
+		},
+		{
+			name:           "NoVersion",
+			race:           &racing.Race{Id: 2, Name: "Renamed"},
+			updateMask:     []string{"name"},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"race.version"},
+		},
+		{
+			name:         "StaleVersion",
+			race:         &racing.Race{Id: 2, Name: "Renamed", Version: 2},
+			updateMask:   []string{"name"},
+			expectedCode: codes.Aborted,
+		},
+		{
+			name:         "NotFound",
+			race:         &racing.Race{Id: 999, Name: "Renamed", Version: 1},
+			updateMask:   []string{"name"},
+			expectedCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			racesRepo := newAdminRacesRepo()
+			adminSvc := NewRacingAdminService(racesRepo, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{})
+
+			var updateMask *fieldmaskpb.FieldMask
+			if tc.updateMask != nil {
+				updateMask = &fieldmaskpb.FieldMask{Paths: tc.updateMask}
+			}
+
+			response, err := adminSvc.UpdateRace(context.Background(), &racing.UpdateRaceRequest{Race: tc.race, UpdateMask: updateMask})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			assert.ElementsMatch(t, tc.expectedFields, violatedFields(err))
+			if tc.expectedCode == codes.Aborted {
+				assert.Equal(t, []string{"VERSION"}, preconditionTypes(err))
+			}
+			if err == nil {
+				assert.Equal(t, "Renamed", response.Race.Name)
+				// Fields that aren't in the mask are kept
+				assert.Equal(t, int64(1), response.Race.MeetingId)
+				assert.Equal(t, int64(2), response.Race.Version)
+			}
+		})
+	}
+}
+
+func TestRacingAdminService_DeleteRace(t *testing.T) {
+	testCases := []struct {
+		name         string
+		id           int64
+		results      map[int64]*racing.RaceResult
+		expectedCode codes.Code
+	}{
+		{
+			name:         "Deleted",
+			id:           2,
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "NotFound",
+			id:           999,
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:         "HasResult",
+			id:           1,
+			results:      map[int64]*racing.RaceResult{1: {RaceId: 1, Final: true}},
+			expectedCode: codes.FailedPrecondition,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			racesRepo := newAdminRacesRepo()
+			adminSvc := NewRacingAdminService(racesRepo, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{results: tc.results}, &MockPricesRepo{})
+
+			_, err := adminSvc.DeleteRace(context.Background(), &racing.DeleteRaceRequest{Id: tc.id})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if err == nil {
+				assert.NotContains(t, racesRepo.races, tc.id)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"database/sql"
 	"errors"
+	"git.neds.sh/matty/entain/common/clock"
 	"git.neds.sh/matty/entain/racing/db"
 	"git.neds.sh/matty/entain/racing/proto/racing"
 	"golang.org/x/net/context"
@@ -11,41 +12,289 @@ import (
 	"time"
 )
 
+const (
+	// defaultPageSize is the number of races listed when no page size is requested.
+	defaultPageSize = 100
+	// maxPageSize is the largest number of races that can be listed at once.
+	maxPageSize = 1000
+)
+
+// meetingRacesOrderBy lists the races of a meeting in the order they are run.
+var meetingRacesOrderBy = []*racing.ListRacesRequestOrderBy{
+	{FieldName: "advertisedStartTime", Direction: racing.OrderByDirection_ASC},
+}
+
 type Racing interface {
 	// ListRaces will return a collection of races.
 	ListRaces(ctx context.Context, in *racing.ListRacesRequest) (*racing.ListRacesResponse, error)
 	// GetRace will return a single race by id
 	GetRace(ctx context.Context, in *racing.GetRaceRequest) (*racing.GetRaceResponse, error)
+	// BatchGetRaces will return the races with the given ids, along with the ids that weren't found
+	BatchGetRaces(ctx context.Context, in *racing.BatchGetRacesRequest) (*racing.BatchGetRacesResponse, error)
+	// Search will return the races matching a query, the most relevant first
+	Search(ctx context.Context, in *racing.SearchRacesRequest) (*racing.SearchRacesResponse, error)
+	// ListRunners will return the runners of a race
+	ListRunners(ctx context.Context, in *racing.ListRunnersRequest) (*racing.ListRunnersResponse, error)
+	// ListMeetings will return a collection of meetings
+	ListMeetings(ctx context.Context, in *racing.ListMeetingsRequest) (*racing.ListMeetingsResponse, error)
+	// GetMeeting will return a single meeting by id
+	GetMeeting(ctx context.Context, in *racing.GetMeetingRequest) (*racing.GetMeetingResponse, error)
+	// GetRaceResult will return the result of a race
+	GetRaceResult(ctx context.Context, in *racing.GetRaceResultRequest) (*racing.GetRaceResultResponse, error)
+	// GetRunnerFlucs will return the price movements of a runner
+	GetRunnerFlucs(ctx context.Context, in *racing.GetRunnerFlucsRequest) (*racing.GetRunnerFlucsResponse, error)
+	// WatchRaces will stream a snapshot of races followed by their changes
+	WatchRaces(in *racing.WatchRacesRequest, stream racing.Racing_WatchRacesServer) error
 }
 
 // racingService implements the Racing interface.
 type racingService struct {
-	racesRepo db.RacesRepo
+	racesRepo    db.RacesRepo
+	runnersRepo  db.RunnersRepo
+	meetingsRepo db.MeetingsRepo
+	resultsRepo  db.ResultsRepo
+	pricesRepo   db.PricesRepo
+	// watchInterval is how often WatchRaces checks the repository for changes
+	watchInterval time.Duration
+	// watches are polling the races being watched, shared by the clients watching the same filter
+	watches *raceWatches
+	// maxBatchIDs is the largest number of races BatchGetRaces returns at once
+	maxBatchIDs int
+	// clock tells the time the statuses of the races are derived at
+	clock clock.Clock
+}
+
+// Option configures the racingService.
+type Option func(s *racingService)
+
+// WithMaxBatchIDs sets the largest number of races BatchGetRaces returns at once.
+func WithMaxBatchIDs(maxBatchIDs int) Option {
+	return func(s *racingService) {
+		s.maxBatchIDs = maxBatchIDs
+	}
+}
+
+// WithClock sets the clock the service tells the time with, the system clock by default.
+func WithClock(c clock.Clock) Option {
+	return func(s *racingService) {
+		s.clock = c
+	}
 }
 
 // NewRacingService instantiates and returns a new racingService.
-func NewRacingService(racesRepo db.RacesRepo) Racing {
-	return &racingService{racesRepo}
+func NewRacingService(racesRepo db.RacesRepo, runnersRepo db.RunnersRepo, meetingsRepo db.MeetingsRepo, resultsRepo db.ResultsRepo, pricesRepo db.PricesRepo, opts ...Option) Racing {
+	s := &racingService{
+		racesRepo:     racesRepo,
+		runnersRepo:   runnersRepo,
+		meetingsRepo:  meetingsRepo,
+		resultsRepo:   resultsRepo,
+		pricesRepo:    pricesRepo,
+		watchInterval: defaultWatchInterval,
+		watches:       newRaceWatches(),
+		maxBatchIDs:   DefaultMaxBatchIDs,
+		clock:         clock.System,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 func (s *racingService) ListRaces(ctx context.Context, in *racing.ListRacesRequest) (*racing.ListRacesResponse, error) {
-	races, err := s.racesRepo.List(in.Filter, in.OrderBy, time.Now())
+	pageSize, err := getPageSize(in.PageSize)
 	if err != nil {
 		return nil, err
 	}
 
-	return &racing.ListRacesResponse{Races: races}, nil
+	if err := validateStartTimes(in.Filter); err != nil {
+		return nil, err
+	}
+
+	races, nextPageToken, err := s.racesRepo.List(ctx, in.Filter, in.OrderBy, pageSize, in.PageToken, in.ReadMask, s.clock.Now())
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidPageToken) {
+			return nil, status.Error(codes.InvalidArgument, "page_token is invalid or doesn't match the request")
+		}
+		var orderFieldErr *db.OrderFieldError
+		if errors.As(err, &orderFieldErr) {
+			return nil, status.Error(codes.InvalidArgument, orderFieldErr.Error())
+		}
+		var readMaskErr *db.ReadMaskError
+		if errors.As(err, &readMaskErr) {
+			return nil, status.Error(codes.InvalidArgument, readMaskErr.Error())
+		}
+		return nil, err
+	}
+
+	return &racing.ListRacesResponse{Races: races, NextPageToken: nextPageToken}, nil
+}
+
+// validateStartTimes checks the start time bounds of the filter make a valid range.
+func validateStartTimes(filter *racing.ListRacesRequestFilter) error {
+	if filter == nil {
+		return nil
+	}
+
+	if filter.StartTimeFrom != nil {
+		if err := filter.StartTimeFrom.CheckValid(); err != nil {
+			return status.Errorf(codes.InvalidArgument, "start_time_from is invalid: %v", err)
+		}
+	}
+	if filter.StartTimeTo != nil {
+		if err := filter.StartTimeTo.CheckValid(); err != nil {
+			return status.Errorf(codes.InvalidArgument, "start_time_to is invalid: %v", err)
+		}
+	}
+	if filter.StartTimeFrom != nil && filter.StartTimeTo != nil && !filter.StartTimeFrom.AsTime().Before(filter.StartTimeTo.AsTime()) {
+		return status.Error(codes.InvalidArgument, "start_time_from must be before start_time_to")
+	}
+	if filter.StartsWithinMinutes < 0 {
+		return status.Error(codes.InvalidArgument, "starts_within_minutes can't be negative")
+	}
+
+	return nil
+}
+
+// getPageSize returns the number of races to list, applying the default and maximum page sizes.
+func getPageSize(requested int32) (int32, error) {
+	switch {
+	case requested < 0:
+		return 0, status.Error(codes.InvalidArgument, "page_size can't be negative")
+	case requested == 0:
+		return defaultPageSize, nil
+	case requested > maxPageSize:
+		return maxPageSize, nil
+	default:
+		return requested, nil
+	}
 }
 
 func (s *racingService) GetRace(ctx context.Context, in *racing.GetRaceRequest) (*racing.GetRaceResponse, error) {
-	race, err := s.racesRepo.Get(in.Id, time.Now())
+	race, err := s.racesRepo.Get(ctx, in.Id, in.ReadMask, s.clock.Now())
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			// If the race is not found, return a 404 status code
 			return nil, status.Errorf(codes.NotFound, "Race with ID %d not found", in.Id)
 		}
+		var readMaskErr *db.ReadMaskError
+		if errors.As(err, &readMaskErr) {
+			return nil, status.Error(codes.InvalidArgument, readMaskErr.Error())
+		}
 		return nil, err
 	}
 
+	if in.IncludeRunners && db.ReadsField(in.ReadMask, "runners") {
+		if race.Runners, err = s.runnersRepo.List(ctx, in.Id); err != nil {
+			return nil, err
+		}
+
+		if err := s.includePrices(ctx, race.Runners, in.Flucs); err != nil {
+			return nil, err
+		}
+	}
+
 	return &racing.GetRaceResponse{Race: race}, nil
 }
+
+func (s *racingService) ListRunners(ctx context.Context, in *racing.ListRunnersRequest) (*racing.ListRunnersResponse, error) {
+	// An unknown race is a 404, rather than a race without runners
+	if _, err := s.racesRepo.Get(ctx, in.RaceId, nil, s.clock.Now()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "Race with ID %d not found", in.RaceId)
+		}
+		return nil, err
+	}
+
+	runners, err := s.runnersRepo.List(ctx, in.RaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.includePrices(ctx, runners, in.Flucs); err != nil {
+		return nil, err
+	}
+
+	return &racing.ListRunnersResponse{Runners: runners}, nil
+}
+
+func (s *racingService) GetRaceResult(ctx context.Context, in *racing.GetRaceResultRequest) (*racing.GetRaceResultResponse, error) {
+	if _, err := s.racesRepo.Get(ctx, in.RaceId, nil, s.clock.Now()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "Race with ID %d not found", in.RaceId)
+		}
+		return nil, err
+	}
+
+	result, err := s.resultsRepo.Get(ctx, in.RaceId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// The race hasn't been run or its result wasn't submitted yet
+			return nil, status.Errorf(codes.NotFound, "Result for race with ID %d not found", in.RaceId)
+		}
+		return nil, err
+	}
+
+	return &racing.GetRaceResultResponse{Result: result}, nil
+}
+
+func (s *racingService) ListMeetings(ctx context.Context, in *racing.ListMeetingsRequest) (*racing.ListMeetingsResponse, error) {
+	meetings, err := s.meetingsRepo.List(ctx, in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.IncludeRaces {
+		if err := s.includeRaces(ctx, meetings); err != nil {
+			return nil, err
+		}
+	}
+
+	return &racing.ListMeetingsResponse{Meetings: meetings}, nil
+}
+
+func (s *racingService) GetMeeting(ctx context.Context, in *racing.GetMeetingRequest) (*racing.GetMeetingResponse, error) {
+	meeting, err := s.meetingsRepo.Get(ctx, in.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// If the meeting is not found, return a 404 status code
+			return nil, status.Errorf(codes.NotFound, "Meeting with ID %d not found", in.Id)
+		}
+		return nil, err
+	}
+
+	if in.IncludeRaces {
+		if err := s.includeRaces(ctx, []*racing.Meeting{meeting}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &racing.GetMeetingResponse{Meeting: meeting}, nil
+}
+
+// includeRaces sets the races of the meetings, fetching them all in a single query.
+func (s *racingService) includeRaces(ctx context.Context, meetings []*racing.Meeting) error {
+	if len(meetings) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*racing.Meeting, len(meetings))
+	filter := &racing.ListRacesRequestFilter{}
+	for _, meeting := range meetings {
+		byID[meeting.Id] = meeting
+		filter.MeetingIds = append(filter.MeetingIds, meeting.Id)
+	}
+
+	races, _, err := s.racesRepo.List(ctx, filter, meetingRacesOrderBy, 0, "", nil, s.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, race := range races {
+		meeting := byID[race.MeetingId]
+		meeting.Races = append(meeting.Races, race)
+	}
+
+	return nil
+}
@@ -2,22 +2,35 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"git.neds.sh/matty/entain/common/clock"
+	"git.neds.sh/matty/entain/racing/db"
 	"git.neds.sh/matty/entain/racing/proto/racing"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
 // MockRacesRepo is a mock implementation of the db.RacesRepo interface.
-type MockRacesRepo struct{}
+type MockRacesRepo struct {
+	// searchLimit is the limit of the last search
+	searchLimit int32
+}
 
 func (m *MockRacesRepo) Init() error {
 	return nil
 }
 
-func (m *MockRacesRepo) List(filter *racing.ListRacesRequestFilter, orderBy []*racing.ListRacesRequestOrderBy, currentDate time.Time) ([]*racing.Race, error) {
+func (m *MockRacesRepo) List(ctx context.Context, filter *racing.ListRacesRequestFilter, orderBy []*racing.ListRacesRequestOrderBy, pageSize int32, pageToken string, readMask *fieldmaskpb.FieldMask, currentDate time.Time) ([]*racing.Race, string, error) {
 	// Mock the behavior here and return a predefined response.
 	// For simplicity, we'll return a predefined list of races.
 	races := getAllTestData()
@@ -70,7 +83,7 @@ func (m *MockRacesRepo) List(filter *racing.ListRacesRequestFilter, orderBy []*r
 		})
 	}
 
-	return filteredRaces, nil
+	return filteredRaces, "", nil
 }
 
 // Compare a field in the race using racing.ListRacesRequestOrderBy considering the direction ASC or DESC
@@ -98,14 +111,84 @@ func compareField(orderBy *racing.ListRacesRequestOrderBy, a *racing.Race, b *ra
 	return 0
 }
 
-func (m *MockRacesRepo) Get(id int64, currentDate time.Time) (*racing.Race, error) {
+func (m *MockRacesRepo) Get(ctx context.Context, id int64, readMask *fieldmaskpb.FieldMask, currentDate time.Time) (*racing.Race, error) {
+	if readMask != nil && !readMask.IsValid(&racing.Race{}) {
+		return nil, &db.ReadMaskError{Path: readMask.Paths[0]}
+	}
+
 	races := getAllTestData()
 	for _, race := range races {
 		if race.Id == id {
 			return race, nil
 		}
 	}
-	return nil, nil
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockRacesRepo) BatchGet(ctx context.Context, ids []int64, currentDate time.Time) ([]*racing.Race, error) {
+	var races []*racing.Race
+	for _, race := range getAllTestData() {
+		for _, id := range ids {
+			if race.Id == id {
+				races = append(races, race)
+			}
+		}
+	}
+	return races, nil
+}
+
+func (m *MockRacesRepo) Create(ctx context.Context, race *racing.Race) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockRacesRepo) Update(ctx context.Context, race *racing.Race, fields []string) error {
+	return nil
+}
+
+func (m *MockRacesRepo) Delete(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *MockRacesRepo) UpdateStatus(ctx context.Context, id int64, from racing.RaceStatus, to racing.RaceStatus, currentDate time.Time) error {
+	return nil
+}
+
+func (m *MockRacesRepo) Search(ctx context.Context, query string, limit int32, currentDate time.Time) ([]*racing.RaceSearchResult, error) {
+	m.searchLimit = limit
+
+	var results []*racing.RaceSearchResult
+	for _, race := range getAllTestData() {
+		if strings.Contains(strings.ToLower(race.Name), strings.ToLower(query)) {
+			results = append(results, &racing.RaceSearchResult{Race: race, Score: 1, NameSnippet: race.Name})
+		}
+	}
+	return results, nil
+}
+
+// MockRunnersRepo is a mock implementation of the db.RunnersRepo interface.
+type MockRunnersRepo struct{}
+
+func (m *MockRunnersRepo) Init() error {
+	return nil
+}
+
+func (m *MockRunnersRepo) List(ctx context.Context, raceID int64) ([]*racing.Runner, error) {
+	var runners []*racing.Runner
+	for _, runner := range getAllTestRunners() {
+		if runner.RaceId == raceID {
+			runners = append(runners, runner)
+		}
+	}
+	return runners, nil
+}
+
+func (m *MockRunnersRepo) Get(ctx context.Context, id int64) (*racing.Runner, error) {
+	for _, runner := range getAllTestRunners() {
+		if runner.Id == id {
+			return runner, nil
+		}
+	}
+	return nil, sql.ErrNoRows
 }
 
 func TestRacingService_ListRaces(t *testing.T) {
@@ -137,7 +220,7 @@ func TestRacingService_ListRaces(t *testing.T) {
 					Name:                "Connecticut griffins",
 					Number:              12,
 					Visible:             true,
-					Status:              "OPEN",
+					Status:              racing.RaceStatus_OPEN,
 					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
 				},
 			},
@@ -155,7 +238,7 @@ func TestRacingService_ListRaces(t *testing.T) {
 					Name:                "Connecticut griffins",
 					Number:              12,
 					Visible:             true,
-					Status:              "OPEN",
+					Status:              racing.RaceStatus_OPEN,
 					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
 				},
 			},
@@ -172,7 +255,7 @@ func TestRacingService_ListRaces(t *testing.T) {
 					Name:                "North Dakota foes",
 					Number:              12,
 					Visible:             false,
-					Status:              "CLOSED",
+					Status:              racing.RaceStatus_CLOSED,
 					AdvertisedStartTime: timestamppb.New(time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)),
 				},
 				{
@@ -181,7 +264,7 @@ func TestRacingService_ListRaces(t *testing.T) {
 					Name:                "Rhode Island ghosts",
 					Number:              3,
 					Visible:             false,
-					Status:              "OPEN",
+					Status:              racing.RaceStatus_OPEN,
 					AdvertisedStartTime: timestamppb.New(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)),
 				},
 			},
@@ -201,7 +284,7 @@ func TestRacingService_ListRaces(t *testing.T) {
 					Name:                "Rhode Island ghosts",
 					Number:              3,
 					Visible:             false,
-					Status:              "OPEN",
+					Status:              racing.RaceStatus_OPEN,
 					AdvertisedStartTime: timestamppb.New(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)),
 				},
 				{
@@ -210,7 +293,7 @@ func TestRacingService_ListRaces(t *testing.T) {
 					Name:                "Connecticut griffins",
 					Number:              12,
 					Visible:             true,
-					Status:              "OPEN",
+					Status:              racing.RaceStatus_OPEN,
 					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
 				},
 				{Id: 1,
@@ -218,7 +301,7 @@ func TestRacingService_ListRaces(t *testing.T) {
 					Name:                "North Dakota foes",
 					Number:              12,
 					Visible:             false,
-					Status:              "CLOSED",
+					Status:              racing.RaceStatus_CLOSED,
 					AdvertisedStartTime: timestamppb.New(time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)),
 				},
 			},
@@ -228,7 +311,7 @@ func TestRacingService_ListRaces(t *testing.T) {
 
 	// Create a mock RacesRepo and pass it to the racingService
 	racesRepo := &MockRacesRepo{}
-	racingSvc := NewRacingService(racesRepo)
+	racingSvc := NewRacingService(racesRepo, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{})
 
 	// Run the test cases
 	for _, tc := range testCases {
@@ -260,7 +343,7 @@ func TestRacingService_ListRaces(t *testing.T) {
 func TestRacingService_GetRace(t *testing.T) {
 	t.Run("GetById", func(t *testing.T) {
 		racesRepo := &MockRacesRepo{}
-		racingSvc := NewRacingService(racesRepo)
+		racingSvc := NewRacingService(racesRepo, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{})
 
 		// Prepare the request
 		request := &racing.GetRaceRequest{
@@ -276,9 +359,168 @@ func TestRacingService_GetRace(t *testing.T) {
 		}
 
 		assert.Equal(t, response.Race.Id, request.GetId())
+		assert.Empty(t, response.Race.Runners)
+	})
+
+	t.Run("GetByIdWithRunners", func(t *testing.T) {
+		racingSvc := NewRacingService(&MockRacesRepo{}, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{})
+
+		response, err := racingSvc.GetRace(context.Background(), &racing.GetRaceRequest{Id: 2, IncludeRunners: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, getAllTestRunners()[:2], response.Race.Runners)
+	})
+
+	t.Run("GetRunnersNotInReadMask", func(t *testing.T) {
+		racingSvc := NewRacingService(&MockRacesRepo{}, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{})
+
+		readMask := &fieldmaskpb.FieldMask{Paths: []string{"id", "name"}}
+		response, err := racingSvc.GetRace(context.Background(), &racing.GetRaceRequest{Id: 2, IncludeRunners: true, ReadMask: readMask})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Empty(t, response.Race.Runners)
+	})
+
+	t.Run("GetInvalidReadMask", func(t *testing.T) {
+		racingSvc := NewRacingService(&MockRacesRepo{}, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{})
+
+		readMask := &fieldmaskpb.FieldMask{Paths: []string{"jockey"}}
+		_, err := racingSvc.GetRace(context.Background(), &racing.GetRaceRequest{Id: 2, ReadMask: readMask})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestRacingService_ListRunners(t *testing.T) {
+	racingSvc := NewRacingService(&MockRacesRepo{}, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{})
+
+	t.Run("ListByRaceId", func(t *testing.T) {
+		response, err := racingSvc.ListRunners(context.Background(), &racing.ListRunnersRequest{RaceId: 2})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, getAllTestRunners()[:2], response.Runners)
+	})
+
+	t.Run("RaceNotFound", func(t *testing.T) {
+		_, err := racingSvc.ListRunners(context.Background(), &racing.ListRunnersRequest{RaceId: 999})
+		assert.Equal(t, codes.NotFound, status.Code(err))
 	})
 }
 
+// MockMeetingsRepo is a mock implementation of the db.MeetingsRepo interface.
+type MockMeetingsRepo struct{}
+
+func (m *MockMeetingsRepo) Init() error {
+	return nil
+}
+
+func (m *MockMeetingsRepo) List(ctx context.Context, filter *racing.ListMeetingsRequestFilter) ([]*racing.Meeting, error) {
+	return getAllTestMeetings(), nil
+}
+
+func (m *MockMeetingsRepo) Get(ctx context.Context, id int64) (*racing.Meeting, error) {
+	for _, meeting := range getAllTestMeetings() {
+		if meeting.Id == id {
+			return meeting, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func TestRacingService_ListMeetings(t *testing.T) {
+	racingSvc := NewRacingService(&MockRacesRepo{}, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{})
+
+	t.Run("WithoutRaces", func(t *testing.T) {
+		response, err := racingSvc.ListMeetings(context.Background(), &racing.ListMeetingsRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, getAllTestMeetings(), response.Meetings)
+	})
+
+	t.Run("WithRaces", func(t *testing.T) {
+		response, err := racingSvc.ListMeetings(context.Background(), &racing.ListMeetingsRequest{IncludeRaces: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		races := getAllTestData()
+		assert.Equal(t, []*racing.Race{races[1]}, response.Meetings[0].Races)
+		assert.Equal(t, []*racing.Race{races[0]}, response.Meetings[1].Races)
+		assert.Empty(t, response.Meetings[2].Races)
+	})
+}
+
+func TestRacingService_GetMeeting(t *testing.T) {
+	racingSvc := NewRacingService(&MockRacesRepo{}, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{})
+
+	t.Run("GetByIdWithRaces", func(t *testing.T) {
+		response, err := racingSvc.GetMeeting(context.Background(), &racing.GetMeetingRequest{Id: 5, IncludeRaces: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, "Randwick", response.Meeting.Name)
+		assert.Equal(t, []*racing.Race{getAllTestData()[0]}, response.Meeting.Races)
+	})
+
+	t.Run("GetByIdNotFound", func(t *testing.T) {
+		_, err := racingSvc.GetMeeting(context.Background(), &racing.GetMeetingRequest{Id: 999})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func getAllTestMeetings() []*racing.Meeting {
+	return []*racing.Meeting{
+		{Id: 1, Name: "Flemington", Venue: "Flemington Racecourse", Country: "AU", RaceType: racing.RaceType_THOROUGHBRED},
+		{Id: 5, Name: "Randwick", Venue: "Royal Randwick Racecourse", Country: "AU", RaceType: racing.RaceType_THOROUGHBRED},
+		{Id: 9, Name: "The Meadows", Venue: "The Meadows", Country: "AU", RaceType: racing.RaceType_GREYHOUND},
+	}
+}
+
+func getAllTestRunners() []*racing.Runner {
+	return []*racing.Runner{
+		{
+			Id:           1,
+			RaceId:       2,
+			Barrier:      4,
+			SaddleNumber: 1,
+			Name:         "Midnight Express",
+			Jockey:       "Jamie Kah",
+			Trainer:      "Chris Waller",
+			Weight:       59,
+		},
+		{
+			Id:           2,
+			RaceId:       2,
+			Barrier:      1,
+			SaddleNumber: 2,
+			Name:         "Golden Arrow",
+			Jockey:       "James McDonald",
+			Trainer:      "Ciaron Maher",
+			Weight:       57.5,
+			Scratched:    true,
+		},
+		{
+			Id:           3,
+			RaceId:       3,
+			Barrier:      2,
+			SaddleNumber: 1,
+			Name:         "Storm Legend",
+			Jockey:       "Craig Williams",
+			Trainer:      "Gai Waterhouse",
+			Weight:       58,
+		},
+	}
+}
+
 func getAllTestData() []*racing.Race {
 	return []*racing.Race{
 		{Id: 1,
@@ -286,7 +528,7 @@ func getAllTestData() []*racing.Race {
 			Name:                "North Dakota foes",
 			Number:              12,
 			Visible:             false,
-			Status:              "CLOSED",
+			Status:              racing.RaceStatus_CLOSED,
 			AdvertisedStartTime: timestamppb.New(time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)),
 		},
 		{
@@ -295,7 +537,7 @@ func getAllTestData() []*racing.Race {
 			Name:                "Connecticut griffins",
 			Number:              12,
 			Visible:             true,
-			Status:              "OPEN",
+			Status:              racing.RaceStatus_OPEN,
 			AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
 		},
 		{
@@ -304,8 +546,362 @@ func getAllTestData() []*racing.Race {
 			Name:                "Rhode Island ghosts",
 			Number:              3,
 			Visible:             false,
-			Status:              "OPEN",
+			Status:              racing.RaceStatus_OPEN,
 			AdvertisedStartTime: timestamppb.New(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)),
 		},
 	}
 }
+
+// watchRacesRepo is a MockRacesRepo whose races can be changed while they are being watched.
+type watchRacesRepo struct {
+	MockRacesRepo
+	mu    sync.Mutex
+	races []*racing.Race
+}
+
+func (m *watchRacesRepo) List(ctx context.Context, filter *racing.ListRacesRequestFilter, orderBy []*racing.ListRacesRequestOrderBy, pageSize int32, pageToken string, readMask *fieldmaskpb.FieldMask, currentDate time.Time) ([]*racing.Race, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.races, "", nil
+}
+
+func (m *watchRacesRepo) setRaces(races []*racing.Race) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.races = races
+}
+
+// mockWatchRacesServer collects the messages sent through a WatchRaces stream.
+type mockWatchRacesServer struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *racing.WatchRacesResponse
+}
+
+func (m *mockWatchRacesServer) Context() context.Context {
+	return m.ctx
+}
+
+func (m *mockWatchRacesServer) Send(response *racing.WatchRacesResponse) error {
+	m.sent <- response
+	return nil
+}
+
+func TestRacingService_WatchRaces(t *testing.T) {
+	racesRepo := &watchRacesRepo{races: getAllTestData()}
+	racingSvc := &racingService{racesRepo: racesRepo, watchInterval: time.Millisecond, watches: newRaceWatches(), clock: clock.System}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &mockWatchRacesServer{ctx: ctx, sent: make(chan *racing.WatchRacesResponse, 10)}
+
+	done := make(chan error)
+	go func() {
+		done <- racingSvc.WatchRaces(&racing.WatchRacesRequest{}, stream)
+	}()
+
+	// The initial snapshot contains every race
+	for _, race := range getAllTestData() {
+		response := <-stream.sent
+		assert.Equal(t, racing.ChangeType_SNAPSHOT, response.ChangeType)
+		assert.Equal(t, race, response.Race)
+	}
+
+	// Closing a race is pushed as a status change
+	races := getAllTestData()
+	races[1].Status = racing.RaceStatus_CLOSED
+	racesRepo.setRaces(races)
+
+	response := <-stream.sent
+	assert.Equal(t, racing.ChangeType_STATUS_CHANGED, response.ChangeType)
+	assert.Equal(t, races[1], response.Race)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestRacingService_WatchRaces_Shared(t *testing.T) {
+	racesRepo := &watchRacesRepo{races: getAllTestData()}
+	racingSvc := &racingService{racesRepo: racesRepo, watchInterval: time.Millisecond, watches: newRaceWatches(), clock: clock.System}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	streams := []*mockWatchRacesServer{
+		{ctx: ctx, sent: make(chan *racing.WatchRacesResponse, 10)},
+		{ctx: ctx, sent: make(chan *racing.WatchRacesResponse, 10)},
+	}
+
+	done := make(chan error)
+	for _, stream := range streams {
+		go func(stream *mockWatchRacesServer) {
+			done <- racingSvc.WatchRaces(&racing.WatchRacesRequest{Filter: &racing.ListRacesRequestFilter{MeetingIds: []int64{1}}}, stream)
+		}(stream)
+
+		// Watchers joining a watch get the snapshot of the races it polled
+		for range getAllTestData() {
+			assert.Equal(t, racing.ChangeType_SNAPSHOT, (<-stream.sent).ChangeType)
+		}
+	}
+
+	// Both watchers share the polling of the filter
+	racingSvc.watches.mu.Lock()
+	assert.Len(t, racingSvc.watches.watches, 1)
+	racingSvc.watches.mu.Unlock()
+
+	races := getAllTestData()
+	races[0].Name = "Renamed race"
+	racesRepo.setRaces(races)
+
+	for _, stream := range streams {
+		response := <-stream.sent
+		assert.Equal(t, racing.ChangeType_UPDATED, response.ChangeType)
+		assert.Equal(t, races[0], response.Race)
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.NoError(t, <-done)
+
+	// The watch is stopped once nobody watches it
+	assert.Empty(t, racingSvc.watches.watches)
+}
+
+// blockingRacesRepo is a MockRacesRepo whose List only returns once its context is done.
+type blockingRacesRepo struct {
+	MockRacesRepo
+	listing chan struct{}
+}
+
+func (m *blockingRacesRepo) List(ctx context.Context, filter *racing.ListRacesRequestFilter, orderBy []*racing.ListRacesRequestOrderBy, pageSize int32, pageToken string, readMask *fieldmaskpb.FieldMask, currentDate time.Time) ([]*racing.Race, string, error) {
+	close(m.listing)
+	<-ctx.Done()
+	return nil, "", ctx.Err()
+}
+
+func TestRaceWatches_PublishError(t *testing.T) {
+	watches := newRaceWatches()
+
+	polling := make(chan context.Context, 1)
+	updates, stop, err := watches.watch(&racing.ListRacesRequestFilter{}, func(ctx context.Context, watch *raceWatch) {
+		polling <- ctx
+		watches.publish(watch, nil, status.Error(codes.Internal, "failed"))
+	})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	defer stop()
+
+	// The error ends the watch, which stops polling
+	assert.Equal(t, codes.Internal, status.Code((<-updates).err))
+	assert.ErrorIs(t, (<-polling).Err(), context.Canceled)
+
+	watches.mu.Lock()
+	assert.Empty(t, watches.watches)
+	watches.mu.Unlock()
+}
+
+func TestRacingService_WatchRaces_CancelDuringPoll(t *testing.T) {
+	racesRepo := &blockingRacesRepo{listing: make(chan struct{})}
+	racingSvc := &racingService{racesRepo: racesRepo, watchInterval: time.Millisecond, watches: newRaceWatches(), clock: clock.System}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &mockWatchRacesServer{ctx: ctx, sent: make(chan *racing.WatchRacesResponse, 10)}
+
+	done := make(chan error)
+	go func() {
+		done <- racingSvc.WatchRaces(&racing.WatchRacesRequest{}, stream)
+	}()
+
+	// Clients going away during a poll close their stream without an error
+	<-racesRepo.listing
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestRacingService_WatchRaces_SlowClient(t *testing.T) {
+	racesRepo := &watchRacesRepo{races: getAllTestData()}
+	racingSvc := &racingService{racesRepo: racesRepo, watchInterval: time.Millisecond, watches: newRaceWatches(), clock: clock.System}
+
+	// The client never reads the changes, so its updates pile up
+	watcher, stop, err := racingSvc.watches.watch(nil, racingSvc.pollRaces)
+	if err != nil {
+		t.Fatalf("failed to watch races: %v", err)
+	}
+	defer stop()
+
+	for i := 0; len(watcher) < cap(watcher) && i < 1000; i++ {
+		races := getAllTestData()
+		races[0].Name = fmt.Sprintf("Renamed race %d", i)
+		racesRepo.setRaces(races)
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	var update raceWatchUpdate
+	for update = range watcher {
+		if update.err != nil {
+			break
+		}
+	}
+	assert.Equal(t, codes.ResourceExhausted, status.Code(update.err))
+}
+
+// clockRacesRepo is a MockRacesRepo deriving the statuses of its races from the time it is given, as the repository
+// does.
+type clockRacesRepo struct {
+	MockRacesRepo
+}
+
+func (m *clockRacesRepo) List(ctx context.Context, filter *racing.ListRacesRequestFilter, orderBy []*racing.ListRacesRequestOrderBy, pageSize int32, pageToken string, readMask *fieldmaskpb.FieldMask, currentDate time.Time) ([]*racing.Race, string, error) {
+	races := getAllTestData()
+	for _, race := range races {
+		race.Status = racing.RaceStatus_OPEN
+		if !race.AdvertisedStartTime.AsTime().After(currentDate) {
+			race.Status = racing.RaceStatus_CLOSED
+		}
+	}
+	return races, "", nil
+}
+
+func TestRacingService_WatchRaces_Clock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	racingSvc := &racingService{racesRepo: &clockRacesRepo{}, watchInterval: time.Millisecond, watches: newRaceWatches(), clock: fake}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &mockWatchRacesServer{ctx: ctx, sent: make(chan *racing.WatchRacesResponse, 10)}
+
+	done := make(chan error)
+	go func() {
+		done <- racingSvc.WatchRaces(&racing.WatchRacesRequest{}, stream)
+	}()
+
+	var snapshot []*racing.Race
+	for range getAllTestData() {
+		snapshot = append(snapshot, (<-stream.sent).Race)
+	}
+	assert.Equal(t, racing.RaceStatus_OPEN, snapshot[2].Status)
+
+	// Moving the clock to the start of a race closes it
+	fake.Set(snapshot[2].AdvertisedStartTime.AsTime())
+
+	response := <-stream.sent
+	assert.Equal(t, racing.ChangeType_STATUS_CHANGED, response.ChangeType)
+	assert.Equal(t, int64(3), response.Race.Id)
+	assert.Equal(t, racing.RaceStatus_CLOSED, response.Race.Status)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestDiffRaces(t *testing.T) {
+	testCases := []struct {
+		name            string
+		latest          func(races []*racing.Race) []*racing.Race
+		expectedChanges []racing.ChangeType
+	}{
+		{
+			name:            "NoChanges",
+			latest:          func(races []*racing.Race) []*racing.Race { return races },
+			expectedChanges: nil,
+		},
+		{
+			name: "Created",
+			latest: func(races []*racing.Race) []*racing.Race {
+				return append(races, &racing.Race{Id: 4, Name: "New race", Status: racing.RaceStatus_OPEN})
+			},
+			expectedChanges: []racing.ChangeType{racing.ChangeType_CREATED},
+		},
+		{
+			name: "Updated",
+			latest: func(races []*racing.Race) []*racing.Race {
+				races[0].Name = "Renamed race"
+				return races
+			},
+			expectedChanges: []racing.ChangeType{racing.ChangeType_UPDATED},
+		},
+		{
+			name: "StatusChanged",
+			latest: func(races []*racing.Race) []*racing.Race {
+				races[2].Status = racing.RaceStatus_CLOSED
+				return races
+			},
+			expectedChanges: []racing.ChangeType{racing.ChangeType_STATUS_CHANGED},
+		},
+		{
+			name: "Removed",
+			latest: func(races []*racing.Race) []*racing.Race {
+				return races[1:]
+			},
+			expectedChanges: []racing.ChangeType{racing.ChangeType_REMOVED},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			known := make(map[int64]*racing.Race)
+			for _, race := range getAllTestData() {
+				known[race.Id] = race
+			}
+
+			latest := tc.latest(getAllTestData())
+			changes := diffRaces(known, latest)
+
+			var changeTypes []racing.ChangeType
+			for _, change := range changes {
+				changeTypes = append(changeTypes, change.ChangeType)
+			}
+			assert.Equal(t, tc.expectedChanges, changeTypes)
+
+			// Once diffed, the known races reflect the latest ones
+			assert.Len(t, known, len(latest))
+		})
+	}
+}
+
+func TestGetPageSize(t *testing.T) {
+	testCases := []struct {
+		name             string
+		requested        int32
+		expectedPageSize int32
+		expectedErr      bool
+	}{
+		{name: "Default", requested: 0, expectedPageSize: defaultPageSize},
+		{name: "Requested", requested: 10, expectedPageSize: 10},
+		{name: "CappedToMax", requested: maxPageSize + 1, expectedPageSize: maxPageSize},
+		{name: "Negative", requested: -1, expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pageSize, err := getPageSize(tc.requested)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assert.Equal(t, tc.expectedPageSize, pageSize)
+		})
+	}
+}
+
+func TestValidateStartTimes(t *testing.T) {
+	from := timestamppb.New(time.Date(2023, 7, 15, 0, 0, 0, 0, time.UTC))
+	to := timestamppb.New(time.Date(2023, 7, 16, 0, 0, 0, 0, time.UTC))
+
+	testCases := []struct {
+		name         string
+		filter       *racing.ListRacesRequestFilter
+		expectedCode codes.Code
+	}{
+		{name: "NoFilter", expectedCode: codes.OK},
+		{name: "Range", filter: &racing.ListRacesRequestFilter{StartTimeFrom: from, StartTimeTo: to}, expectedCode: codes.OK},
+		{name: "OnlyFrom", filter: &racing.ListRacesRequestFilter{StartTimeFrom: from}, expectedCode: codes.OK},
+		{name: "WithinMinutes", filter: &racing.ListRacesRequestFilter{StartsWithinMinutes: 60}, expectedCode: codes.OK},
+		{name: "FromAfterTo", filter: &racing.ListRacesRequestFilter{StartTimeFrom: to, StartTimeTo: from}, expectedCode: codes.InvalidArgument},
+		{name: "EmptyRange", filter: &racing.ListRacesRequestFilter{StartTimeFrom: from, StartTimeTo: from}, expectedCode: codes.InvalidArgument},
+		{name: "InvalidTimestamp", filter: &racing.ListRacesRequestFilter{StartTimeTo: &timestamppb.Timestamp{Nanos: -1}}, expectedCode: codes.InvalidArgument},
+		{name: "NegativeMinutes", filter: &racing.ListRacesRequestFilter{StartsWithinMinutes: -1}, expectedCode: codes.InvalidArgument},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateStartTimes(tc.filter)
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+
+	"github.com/golang/protobuf/ptypes"
+
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SubmitRaceResult stores the placings of a race. An interim result moves a CLOSED race to INTERIM,
+// a final result moves it on to FINAL. Once final, the result can only be replaced with override set.
+func (s *racingAdminService) SubmitRaceResult(ctx context.Context, in *racing.SubmitRaceResultRequest) (*racing.SubmitRaceResultResponse, error) {
+	race, err := s.racesRepo.Get(ctx, in.RaceId, nil, s.clock.Now())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// If the race is not found, return a 404 status code
+			return nil, status.Errorf(codes.NotFound, "Race with ID %d not found", in.RaceId)
+		}
+		return nil, err
+	}
+
+	to, err := resultStatus(race, in.Final, in.Override)
+	if err != nil {
+		return nil, err
+	}
+
+	runners, err := s.runnersRepo.List(ctx, in.RaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateRaceResult(in, runners); err != nil {
+		return nil, err
+	}
+
+	updatedTime, err := ptypes.TimestampProto(s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// The race is moved to its new status along with the result, so a failed save leaves it as it was
+	if err := s.resultsRepo.Save(ctx, &racing.RaceResult{
+		RaceId:       in.RaceId,
+		Final:        in.Final,
+		OfficialTime: in.OfficialTime,
+		Placings:     in.Placings,
+		UpdatedTime:  updatedTime,
+	}, race.Status, to, s.clock.Now()); err != nil {
+		if errors.Is(err, db.ErrStatusChanged) {
+			return nil, status.Errorf(codes.Aborted, "Race with ID %d status was changed by someone else, try again", in.RaceId)
+		}
+		return nil, err
+	}
+
+	result, err := s.resultsRepo.Get(ctx, in.RaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.SubmitRaceResultResponse{Result: result}, nil
+}
+
+// resultStatus returns the status a race moves to when its result is submitted. A final result moves a CLOSED race
+// straight to FINAL, as the race is never seen in between.
+func resultStatus(race *racing.Race, final bool, override bool) (racing.RaceStatus, error) {
+	switch race.Status {
+	case racing.RaceStatus_CLOSED, racing.RaceStatus_INTERIM:
+		if final {
+			return racing.RaceStatus_FINAL, nil
+		}
+		return racing.RaceStatus_INTERIM, nil
+	case racing.RaceStatus_FINAL:
+		if !override {
+			return 0, status.Errorf(codes.FailedPrecondition, "Race with ID %d already has a final result, set override to replace it", race.Id)
+		}
+		if !final {
+			return 0, status.Errorf(codes.FailedPrecondition, "Race with ID %d already has a final result, it can't be replaced with an interim one", race.Id)
+		}
+		return racing.RaceStatus_FINAL, nil
+	default:
+		return 0, status.Errorf(codes.FailedPrecondition, "Race with ID %d is %s, results can only be submitted once it is CLOSED", race.Id, race.Status)
+	}
+}
+
+// validateRaceResult checks the placings are for runners of the race and their positions add up.
+// Runners in a dead heat share a position, and the position after them is skipped, e.g. 1, 2, 2, 4.
+func validateRaceResult(in *racing.SubmitRaceResultRequest, runners []*racing.Runner) error {
+	if in.OfficialTime == nil {
+		return status.Error(codes.InvalidArgument, "official_time is required")
+	}
+	if officialTime, err := ptypes.Duration(in.OfficialTime); err != nil || officialTime <= 0 {
+		return status.Error(codes.InvalidArgument, "official_time must be a positive duration")
+	}
+
+	if len(in.Placings) == 0 {
+		return status.Error(codes.InvalidArgument, "placings are required")
+	}
+
+	raceRunners := make(map[int64]*racing.Runner, len(runners))
+	for _, runner := range runners {
+		raceRunners[runner.Id] = runner
+	}
+
+	placed := make(map[int64]bool, len(in.Placings))
+	positions := make(map[int64][]*racing.Placing)
+
+	for _, placing := range in.Placings {
+		runner, ok := raceRunners[placing.RunnerId]
+		switch {
+		case !ok:
+			return status.Errorf(codes.InvalidArgument, "Runner with ID %d isn't running in race %d", placing.RunnerId, in.RaceId)
+		case runner.Scratched:
+			return status.Errorf(codes.InvalidArgument, "Runner with ID %d was scratched and can't be placed", placing.RunnerId)
+		case placed[placing.RunnerId]:
+			return status.Errorf(codes.InvalidArgument, "Runner with ID %d is placed more than once", placing.RunnerId)
+		case placing.Position < 1:
+			return status.Errorf(codes.InvalidArgument, "Runner with ID %d has an invalid position %d", placing.RunnerId, placing.Position)
+		case placing.Margin < 0:
+			return status.Errorf(codes.InvalidArgument, "Runner with ID %d has a negative margin", placing.RunnerId)
+		case placing.Position == 1 && placing.Margin != 0:
+			return status.Errorf(codes.InvalidArgument, "Runner with ID %d won the race and can't have a margin", placing.RunnerId)
+		}
+
+		placed[placing.RunnerId] = true
+		positions[placing.Position] = append(positions[placing.Position], placing)
+	}
+
+	var sorted []int64
+	for position := range positions {
+		sorted = append(sorted, position)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	expected := int64(1)
+	for _, position := range sorted {
+		if position != expected {
+			return status.Errorf(codes.InvalidArgument, "position %d is missing from the placings", expected)
+		}
+
+		placings := positions[position]
+		for _, placing := range placings {
+			if placing.DeadHeat != (len(placings) > 1) {
+				return status.Errorf(codes.InvalidArgument, "dead_heat must be set only when runners share position %d", position)
+			}
+		}
+
+		expected += int64(len(placings))
+	}
+
+	return nil
+}
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"git.neds.sh/matty/entain/racing/db"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"testing"
+	"time"
+)
+
+// MockResultsRepo is a mock implementation of the db.ResultsRepo interface keeping the results it saves.
+type MockResultsRepo struct {
+	results map[int64]*racing.RaceResult
+	// races are the races whose status is changed along with their results
+	races map[int64]*racing.Race
+	// err makes the saves fail
+	err error
+}
+
+func (m *MockResultsRepo) Init() error {
+	return nil
+}
+
+func (m *MockResultsRepo) Get(ctx context.Context, raceID int64) (*racing.RaceResult, error) {
+	result, ok := m.results[raceID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return result, nil
+}
+
+func (m *MockResultsRepo) Save(ctx context.Context, result *racing.RaceResult, from racing.RaceStatus, to racing.RaceStatus, currentDate time.Time) error {
+	if m.err != nil {
+		return m.err
+	}
+	if race := m.races[result.RaceId]; race != nil {
+		if race.Status != from {
+			return db.ErrStatusChanged
+		}
+		race.Status = to
+	}
+	if m.results == nil {
+		m.results = make(map[int64]*racing.RaceResult)
+	}
+	m.results[result.RaceId] = result
+	return nil
+}
+
+// resultRunnersRepo is a MockRunnersRepo with runners for the closed test race.
+type resultRunnersRepo struct {
+	MockRunnersRepo
+}
+
+func (m *resultRunnersRepo) List(ctx context.Context, raceID int64) ([]*racing.Runner, error) {
+	if raceID != 1 {
+		return m.MockRunnersRepo.List(context.Background(), raceID)
+	}
+	return []*racing.Runner{
+		{Id: 11, RaceId: 1, SaddleNumber: 1, Name: "Royal Charm"},
+		{Id: 12, RaceId: 1, SaddleNumber: 2, Name: "Desert Comet"},
+		{Id: 13, RaceId: 1, SaddleNumber: 3, Name: "Bold Rebel"},
+		{Id: 14, RaceId: 1, SaddleNumber: 4, Name: "Lucky Gem", Scratched: true},
+	}, nil
+}
+
+func getTestPlacings() []*racing.Placing {
+	return []*racing.Placing{
+		{Position: 1, RunnerId: 12},
+		{Position: 2, RunnerId: 11, Margin: 1.5},
+		{Position: 3, RunnerId: 13, Margin: 0.25},
+	}
+}
+
+func TestRacingService_GetRaceResult(t *testing.T) {
+	result := &racing.RaceResult{RaceId: 1, Final: true, OfficialTime: durationpb.New(95 * time.Second), Placings: getTestPlacings()}
+	racingSvc := NewRacingService(&MockRacesRepo{}, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{results: map[int64]*racing.RaceResult{1: result}}, &MockPricesRepo{})
+
+	t.Run("GetByRaceId", func(t *testing.T) {
+		response, err := racingSvc.GetRaceResult(context.Background(), &racing.GetRaceResultRequest{RaceId: 1})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, result, response.Result)
+	})
+
+	t.Run("RaceWithoutResult", func(t *testing.T) {
+		_, err := racingSvc.GetRaceResult(context.Background(), &racing.GetRaceResultRequest{RaceId: 2})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("RaceNotFound", func(t *testing.T) {
+		_, err := racingSvc.GetRaceResult(context.Background(), &racing.GetRaceResultRequest{RaceId: 999})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func TestRacingAdminService_SubmitRaceResult(t *testing.T) {
+	testCases := []struct {
+		name           string
+		raceStatus     racing.RaceStatus
+		final          bool
+		override       bool
+		expectedCode   codes.Code
+		expectedStatus racing.RaceStatus
+	}{
+		{
+			name:           "InterimFromClosed",
+			raceStatus:     racing.RaceStatus_CLOSED,
+			expectedCode:   codes.OK,
+			expectedStatus: racing.RaceStatus_INTERIM,
+		},
+		{
+			name:           "FinalFromClosed",
+			raceStatus:     racing.RaceStatus_CLOSED,
+			final:          true,
+			expectedCode:   codes.OK,
+			expectedStatus: racing.RaceStatus_FINAL,
+		},
+		{
+			name:           "InterimReplaced",
+			raceStatus:     racing.RaceStatus_INTERIM,
+			expectedCode:   codes.OK,
+			expectedStatus: racing.RaceStatus_INTERIM,
+		},
+		{
+			name:           "FinalFromInterim",
+			raceStatus:     racing.RaceStatus_INTERIM,
+			final:          true,
+			expectedCode:   codes.OK,
+			expectedStatus: racing.RaceStatus_FINAL,
+		},
+		{
+			name:         "FinalWithoutOverride",
+			raceStatus:   racing.RaceStatus_FINAL,
+			final:        true,
+			expectedCode: codes.FailedPrecondition,
+		},
+		{
+			name:           "FinalWithOverride",
+			raceStatus:     racing.RaceStatus_FINAL,
+			final:          true,
+			override:       true,
+			expectedCode:   codes.OK,
+			expectedStatus: racing.RaceStatus_FINAL,
+		},
+		{
+			name:         "InterimAfterFinal",
+			raceStatus:   racing.RaceStatus_FINAL,
+			override:     true,
+			expectedCode: codes.FailedPrecondition,
+		},
+		{
+			name:         "RaceStillOpen",
+			raceStatus:   racing.RaceStatus_OPEN,
+			expectedCode: codes.FailedPrecondition,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			racesRepo := newAdminRacesRepo()
+			racesRepo.races[1].Status = tc.raceStatus
+			resultsRepo := &MockResultsRepo{races: racesRepo.races}
+			adminSvc := NewRacingAdminService(racesRepo, &resultRunnersRepo{}, &MockMeetingsRepo{}, resultsRepo, &MockPricesRepo{})
+
+			response, err := adminSvc.SubmitRaceResult(context.Background(), &racing.SubmitRaceResultRequest{
+				RaceId:       1,
+				Final:        tc.final,
+				OfficialTime: durationpb.New(95 * time.Second),
+				Placings:     getTestPlacings(),
+				Override:     tc.override,
+			})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode == codes.OK {
+				assert.Equal(t, tc.final, response.Result.Final)
+				assert.Equal(t, getTestPlacings(), response.Result.Placings)
+				assert.Equal(t, tc.expectedStatus, racesRepo.races[1].Status)
+			} else {
+				assert.Empty(t, resultsRepo.results)
+			}
+		})
+	}
+
+	t.Run("SaveFailed", func(t *testing.T) {
+		racesRepo := newAdminRacesRepo()
+		racesRepo.races[1].Status = racing.RaceStatus_CLOSED
+		resultsRepo := &MockResultsRepo{races: racesRepo.races, err: errors.New("failed")}
+		adminSvc := NewRacingAdminService(racesRepo, &resultRunnersRepo{}, &MockMeetingsRepo{}, resultsRepo, &MockPricesRepo{})
+
+		_, err := adminSvc.SubmitRaceResult(context.Background(), &racing.SubmitRaceResultRequest{
+			RaceId:       1,
+			OfficialTime: durationpb.New(95 * time.Second),
+			Placings:     getTestPlacings(),
+		})
+
+		// The race isn't moved without its result
+		assert.Error(t, err)
+		assert.Equal(t, racing.RaceStatus_CLOSED, racesRepo.races[1].Status)
+	})
+
+	t.Run("StatusChanged", func(t *testing.T) {
+		racesRepo := newAdminRacesRepo()
+		racesRepo.races[1].Status = racing.RaceStatus_CLOSED
+		// The race is abandoned by someone else between the submission reading and saving it
+		resultsRepo := &MockResultsRepo{races: map[int64]*racing.Race{1: {Id: 1, Status: racing.RaceStatus_ABANDONED}}}
+		adminSvc := NewRacingAdminService(racesRepo, &resultRunnersRepo{}, &MockMeetingsRepo{}, resultsRepo, &MockPricesRepo{})
+
+		_, err := adminSvc.SubmitRaceResult(context.Background(), &racing.SubmitRaceResultRequest{
+			RaceId:       1,
+			OfficialTime: durationpb.New(95 * time.Second),
+			Placings:     getTestPlacings(),
+		})
+
+		assert.Equal(t, codes.Aborted, status.Code(err))
+		assert.Empty(t, resultsRepo.results)
+	})
+
+	t.Run("RaceNotFound", func(t *testing.T) {
+		adminSvc := NewRacingAdminService(newAdminRacesRepo(), &resultRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{})
+
+		_, err := adminSvc.SubmitRaceResult(context.Background(), &racing.SubmitRaceResultRequest{RaceId: 999})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func TestValidateRaceResult(t *testing.T) {
+	runners, _ := (&resultRunnersRepo{}).List(context.Background(), 1)
+
+	testCases := []struct {
+		name         string
+		officialTime *durationpb.Duration
+		placings     []*racing.Placing
+		expectedCode codes.Code
+	}{
+		{
+			name:         "Valid",
+			officialTime: durationpb.New(95 * time.Second),
+			placings:     getTestPlacings(),
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "DeadHeat",
+			officialTime: durationpb.New(95 * time.Second),
+			placings: []*racing.Placing{
+				{Position: 1, RunnerId: 12},
+				{Position: 2, RunnerId: 11, Margin: 0.5, DeadHeat: true},
+				{Position: 2, RunnerId: 13, DeadHeat: true},
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "DeadHeatNotFlagged",
+			officialTime: durationpb.New(95 * time.Second),
+			placings: []*racing.Placing{
+				{Position: 1, RunnerId: 12},
+				{Position: 1, RunnerId: 11},
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "PositionAfterDeadHeatNotSkipped",
+			officialTime: durationpb.New(95 * time.Second),
+			placings: []*racing.Placing{
+				{Position: 1, RunnerId: 12, DeadHeat: true},
+				{Position: 1, RunnerId: 11, DeadHeat: true},
+				{Position: 2, RunnerId: 13, Margin: 1},
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "MissingPosition",
+			officialTime: durationpb.New(95 * time.Second),
+			placings: []*racing.Placing{
+				{Position: 2, RunnerId: 12, Margin: 1},
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "RunnerFromAnotherRace",
+			officialTime: durationpb.New(95 * time.Second),
+			placings:     []*racing.Placing{{Position: 1, RunnerId: 1}},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "ScratchedRunner",
+			officialTime: durationpb.New(95 * time.Second),
+			placings:     []*racing.Placing{{Position: 1, RunnerId: 14}},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "RunnerPlacedTwice",
+			officialTime: durationpb.New(95 * time.Second),
+			placings: []*racing.Placing{
+				{Position: 1, RunnerId: 12},
+				{Position: 2, RunnerId: 12, Margin: 1},
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "WinnerWithMargin",
+			officialTime: durationpb.New(95 * time.Second),
+			placings:     []*racing.Placing{{Position: 1, RunnerId: 12, Margin: 1}},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "MissingOfficialTime",
+			placings:     getTestPlacings(),
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "MissingPlacings",
+			officialTime: durationpb.New(95 * time.Second),
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateRaceResult(&racing.SubmitRaceResultRequest{RaceId: 1, OfficialTime: tc.officialTime, Placings: tc.placings}, runners)
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"strings"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultSearchLimit is the number of races found when no limit is requested.
+	defaultSearchLimit = 20
+	// maxSearchLimit is the largest number of races that can be found at once.
+	maxSearchLimit = 100
+)
+
+func (s *racingService) Search(ctx context.Context, in *racing.SearchRacesRequest) (*racing.SearchRacesResponse, error) {
+	if strings.TrimSpace(in.Query) == "" {
+		return nil, status.Error(codes.InvalidArgument, "query is required")
+	}
+
+	limit := in.Limit
+	switch {
+	case limit < 0:
+		return nil, status.Error(codes.InvalidArgument, "limit can't be negative")
+	case limit == 0:
+		limit = defaultSearchLimit
+	case limit > maxSearchLimit:
+		limit = maxSearchLimit
+	}
+
+	results, err := s.racesRepo.Search(ctx, in.Query, limit, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &racing.SearchRacesResponse{Results: results}, nil
+}
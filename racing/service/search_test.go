@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestRacingService_Search(t *testing.T) {
+	testCases := []struct {
+		name          string
+		query         string
+		limit         int32
+		expectedCode  codes.Code
+		expectedIds   []int64
+		expectedLimit int32
+	}{
+		{
+			name:          "Found",
+			query:         "griffins",
+			expectedCode:  codes.OK,
+			expectedIds:   []int64{2},
+			expectedLimit: defaultSearchLimit,
+		},
+		{
+			name:          "NotFound",
+			query:         "unknown",
+			limit:         5,
+			expectedCode:  codes.OK,
+			expectedLimit: 5,
+		},
+		{
+			name:          "LimitCappedToMax",
+			query:         "griffins",
+			limit:         maxSearchLimit + 1,
+			expectedCode:  codes.OK,
+			expectedIds:   []int64{2},
+			expectedLimit: maxSearchLimit,
+		},
+		{
+			name:         "MissingQuery",
+			query:        "  ",
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "NegativeLimit",
+			query:        "griffins",
+			limit:        -1,
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			racesRepo := &MockRacesRepo{}
+			racingSvc := NewRacingService(racesRepo, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{})
+
+			response, err := racingSvc.Search(context.Background(), &racing.SearchRacesRequest{Query: tc.query, Limit: tc.limit})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode != codes.OK {
+				return
+			}
+
+			var ids []int64
+			for _, result := range response.Results {
+				ids = append(ids, result.Race.Id)
+			}
+			assert.Equal(t, tc.expectedIds, ids)
+			assert.Equal(t, tc.expectedLimit, racesRepo.searchLimit)
+		})
+	}
+}
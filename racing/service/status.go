@@ -0,0 +1,31 @@
+package service
+
+import "git.neds.sh/matty/entain/racing/proto/racing"
+
+// raceStatusTransitions lists the statuses a race can move to from each status.
+// FINAL and ABANDONED are final, so they can't be left.
+var raceStatusTransitions = map[racing.RaceStatus][]racing.RaceStatus{
+	racing.RaceStatus_OPEN:      {racing.RaceStatus_SUSPENDED, racing.RaceStatus_CLOSED, racing.RaceStatus_ABANDONED, racing.RaceStatus_POSTPONED},
+	racing.RaceStatus_SUSPENDED: {racing.RaceStatus_OPEN, racing.RaceStatus_CLOSED, racing.RaceStatus_ABANDONED, racing.RaceStatus_POSTPONED},
+	racing.RaceStatus_CLOSED:    {racing.RaceStatus_OPEN, racing.RaceStatus_INTERIM, racing.RaceStatus_ABANDONED},
+	racing.RaceStatus_INTERIM:   {racing.RaceStatus_FINAL, racing.RaceStatus_ABANDONED},
+	racing.RaceStatus_POSTPONED: {racing.RaceStatus_OPEN, racing.RaceStatus_ABANDONED},
+}
+
+// resultedStatuses are the statuses a race only moves to when its result is submitted, so the status of a race
+// always tells whether it has an interim or a final result.
+var resultedStatuses = map[racing.RaceStatus]bool{
+	racing.RaceStatus_INTERIM: true,
+	racing.RaceStatus_FINAL:   true,
+}
+
+// canTransition returns whether a race can move from a status to another.
+func canTransition(from racing.RaceStatus, to racing.RaceStatus) bool {
+	for _, status := range raceStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
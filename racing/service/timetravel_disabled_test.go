@@ -0,0 +1,21 @@
+//go:build !timetravel
+// +build !timetravel
+
+package service
+
+import (
+	"context"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestRacingAdminService_TimeTravel(t *testing.T) {
+	adminSvc := NewRacingAdminService(&MockRacesRepo{}, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{})
+
+	_, err := adminSvc.TimeTravel(context.Background(), &racing.TimeTravelRequest{Present: true})
+
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
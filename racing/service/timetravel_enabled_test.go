@@ -0,0 +1,82 @@
+//go:build timetravel
+// +build timetravel
+
+package service
+
+import (
+	"context"
+	"git.neds.sh/matty/entain/common/clock"
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+)
+
+func TestRacingAdminService_TimeTravel(t *testing.T) {
+	start := time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)
+	base := clock.NewFake(start)
+	adminSvc := NewRacingAdminService(&MockRacesRepo{}, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{}, WithAdminClock(clock.NewTravel(base)))
+
+	jump := time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name         string
+		request      *racing.TimeTravelRequest
+		expectedCode codes.Code
+		expectedNow  time.Time
+	}{
+		{
+			name:         "Time",
+			request:      &racing.TimeTravelRequest{Time: timestamppb.New(jump)},
+			expectedCode: codes.OK,
+			expectedNow:  jump,
+		},
+		{
+			name:         "Advance",
+			request:      &racing.TimeTravelRequest{Advance: durationpb.New(-time.Hour)},
+			expectedCode: codes.OK,
+			expectedNow:  jump.Add(-time.Hour),
+		},
+		{
+			name:         "Present",
+			request:      &racing.TimeTravelRequest{Present: true},
+			expectedCode: codes.OK,
+			expectedNow:  start,
+		},
+		{
+			name:         "Nothing",
+			request:      &racing.TimeTravelRequest{},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "TimeAndAdvance",
+			request:      &racing.TimeTravelRequest{Time: timestamppb.New(jump), Advance: durationpb.New(time.Hour)},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "InvalidTime",
+			request:      &racing.TimeTravelRequest{Time: &timestamppb.Timestamp{Nanos: -1}},
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			response, err := adminSvc.TimeTravel(context.Background(), tc.request)
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if err == nil {
+				assert.Equal(t, tc.expectedNow, response.Now.AsTime())
+			}
+		})
+	}
+
+	t.Run("SystemClock", func(t *testing.T) {
+		_, err := NewRacingAdminService(&MockRacesRepo{}, &MockRunnersRepo{}, &MockMeetingsRepo{}, &MockResultsRepo{}, &MockPricesRepo{}).TimeTravel(context.Background(), &racing.TimeTravelRequest{Present: true})
+
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"git.neds.sh/matty/entain/racing/proto/racing"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultWatchInterval is how often the races being watched are compared against the repository.
+// Statuses are derived from the current time, so changes can't be pushed by the repository itself.
+const defaultWatchInterval = time.Second
+
+// watchOrderBy keeps the snapshot and every batch of changes ordered by start time.
+var watchOrderBy = []*racing.ListRacesRequestOrderBy{
+	{FieldName: "advertisedStartTime", Direction: racing.OrderByDirection_ASC},
+}
+
+// watchBufferSize is how many batches of changes are buffered for a client. Clients falling further behind are
+// disconnected, so they can't hold up the other clients watching the same races.
+const watchBufferSize = 16
+
+// WatchRaces sends every race matching the filter as a SNAPSHOT and then keeps polling the repository,
+// sending only the races that were created, updated, had their status changed or stopped matching the filter.
+// Clients watching the same filter share the polling, so the races are listed once per interval however many
+// clients there are. The stream is closed when the client goes away.
+func (s *racingService) WatchRaces(in *racing.WatchRacesRequest, stream racing.Racing_WatchRacesServer) error {
+	ctx := stream.Context()
+
+	watcher, stop, err := s.watches.watch(in.Filter, s.pollRaces)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case update := <-watcher:
+			if update.err != nil {
+				return update.err
+			}
+
+			for _, response := range update.responses {
+				if err := stream.Send(response); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					return err
+				}
+			}
+		}
+	}
+}
+
+// pollRaces lists the races matching the filter of a watch every interval, publishing their changes to its
+// watchers until the last one goes away or the races can't be listed.
+func (s *racingService) pollRaces(ctx context.Context, watch *raceWatch) {
+	ticker := time.NewTicker(s.watchInterval)
+	defer ticker.Stop()
+
+	for {
+		races, _, err := s.racesRepo.List(ctx, watch.filter, watchOrderBy, 0, "", nil, s.clock.Now())
+		if ctx.Err() != nil {
+			// The last watcher went away during the poll
+			return
+		}
+
+		s.watches.publish(watch, races, err)
+		if err != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// raceWatchUpdate is a batch of changes sent to a watcher, or the error ending its stream.
+type raceWatchUpdate struct {
+	responses []*racing.WatchRacesResponse
+	err       error
+}
+
+// raceWatch polls the races matching a filter on behalf of every client watching them.
+type raceWatch struct {
+	key    string
+	filter *racing.ListRacesRequestFilter
+	cancel context.CancelFunc
+
+	// The fields below are guarded by the mutex of the raceWatches the watch belongs to
+	watchers map[chan raceWatchUpdate]bool
+	polled   bool
+	latest   []*racing.Race
+	known    map[int64]*racing.Race
+}
+
+// raceWatches are the watches of the races being watched, keyed by their filter.
+type raceWatches struct {
+	mu      sync.Mutex
+	watches map[string]*raceWatch
+}
+
+func newRaceWatches() *raceWatches {
+	return &raceWatches{watches: make(map[string]*raceWatch)}
+}
+
+// watch returns the channel of the updates of the races matching the filter, starting with their snapshot, and
+// the function to call once they aren't watched anymore. The watch of the filter is started with poll when
+// nobody is watching it yet, and stopped when the last watcher stops.
+func (w *raceWatches) watch(filter *racing.ListRacesRequestFilter, poll func(ctx context.Context, watch *raceWatch)) (<-chan raceWatchUpdate, func(), error) {
+	key, err := proto.MarshalOptions{Deterministic: true}.Marshal(filter)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "filter can't be watched: %s", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	watch, ok := w.watches[string(key)]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		watch = &raceWatch{key: string(key), filter: filter, cancel: cancel, watchers: make(map[chan raceWatchUpdate]bool)}
+		w.watches[watch.key] = watch
+		go poll(ctx, watch)
+	}
+
+	watcher := make(chan raceWatchUpdate, watchBufferSize)
+	watch.watchers[watcher] = true
+	// Watchers joining a watch that already polled are sent the latest races, and the next changes like the others
+	if watch.polled {
+		watcher <- raceWatchUpdate{responses: raceSnapshot(watch.latest)}
+	}
+
+	stop := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		delete(watch.watchers, watcher)
+		if len(watch.watchers) == 0 && w.watches[watch.key] == watch {
+			delete(w.watches, watch.key)
+			watch.cancel()
+		}
+	}
+
+	return watcher, stop, nil
+}
+
+// publish sends the watchers of a watch the snapshot of the races on its first poll, and then their changes. An
+// error is sent to every watcher and ends the watch, so the next watchers start a new one.
+func (w *raceWatches) publish(watch *raceWatch, races []*racing.Race, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var update raceWatchUpdate
+	switch {
+	case err != nil:
+		update.err = err
+		if w.watches[watch.key] == watch {
+			delete(w.watches, watch.key)
+		}
+		// The watch is over, so it stops polling even though its watchers haven't stopped yet
+		watch.cancel()
+	case !watch.polled:
+		watch.polled = true
+		watch.known = make(map[int64]*racing.Race, len(races))
+		for _, race := range races {
+			watch.known[race.Id] = race
+		}
+		update.responses = raceSnapshot(races)
+	default:
+		update.responses = diffRaces(watch.known, races)
+	}
+	watch.latest = races
+
+	if update.err == nil && len(update.responses) == 0 {
+		return
+	}
+
+	for watcher := range watch.watchers {
+		// The last buffered update is kept for the error disconnecting a watcher that fell behind
+		if len(watcher) >= cap(watcher)-1 && update.err == nil {
+			watcher <- raceWatchUpdate{err: status.Error(codes.ResourceExhausted, "client is too slow to receive the changes of the races")}
+			delete(watch.watchers, watcher)
+			continue
+		}
+
+		watcher <- update
+	}
+}
+
+// raceSnapshot returns the races as a SNAPSHOT.
+func raceSnapshot(races []*racing.Race) []*racing.WatchRacesResponse {
+	responses := make([]*racing.WatchRacesResponse, 0, len(races))
+	for _, race := range races {
+		responses = append(responses, &racing.WatchRacesResponse{ChangeType: racing.ChangeType_SNAPSHOT, Race: race})
+	}
+
+	return responses
+}
+
+// diffRaces compares the latest races with the ones previously sent, returning the changes between them.
+// known is updated in place so it reflects the latest races once diffRaces returns.
+func diffRaces(known map[int64]*racing.Race, latest []*racing.Race) []*racing.WatchRacesResponse {
+	var changes []*racing.WatchRacesResponse
+
+	seen := make(map[int64]bool, len(latest))
+	for _, race := range latest {
+		seen[race.Id] = true
+
+		previous, ok := known[race.Id]
+		switch {
+		case !ok:
+			changes = append(changes, &racing.WatchRacesResponse{ChangeType: racing.ChangeType_CREATED, Race: race})
+		case previous.Status != race.Status:
+			changes = append(changes, &racing.WatchRacesResponse{ChangeType: racing.ChangeType_STATUS_CHANGED, Race: race})
+		case !proto.Equal(previous, race):
+			changes = append(changes, &racing.WatchRacesResponse{ChangeType: racing.ChangeType_UPDATED, Race: race})
+		default:
+			continue
+		}
+
+		known[race.Id] = race
+	}
+
+	var removed []int64
+	for id := range known {
+		if !seen[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i] < removed[j] })
+
+	for _, id := range removed {
+		changes = append(changes, &racing.WatchRacesResponse{ChangeType: racing.ChangeType_REMOVED, Race: known[id]})
+		delete(known, id)
+	}
+
+	return changes
+}
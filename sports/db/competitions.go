@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+// CompetitionsRepo provides repository access to competitions.
+type CompetitionsRepo interface {
+	// Init will initialise our competitions repository.
+	Init() error
+
+	// List will return a list of competitions, ordered by sport and name.
+	List(ctx context.Context, filter *sports.ListCompetitionsRequestFilter) ([]*sports.Competition, error)
+
+	// Get will return a single competition. It will return an error if no competition is found
+	Get(ctx context.Context, id int64) (*sports.Competition, error)
+
+	// ListParticipants will return the teams or players of a competition, ordered by id.
+	ListParticipants(ctx context.Context, competitionID int64) ([]*sports.Participant, error)
+}
+
+type competitionsRepo struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+}
+
+// NewCompetitionsRepo creates a new competitions repository.
+func NewCompetitionsRepo(db *sql.DB, opts ...Option) CompetitionsRepo {
+	return &competitionsRepo{db: db, dialect: newOptions(opts).dialect}
+}
+
+// Init prepares the competitions repository. Its tables are created by the migrations.
+func (r *competitionsRepo) Init() error {
+	return nil
+}
+
+// List Returns a list of competitions
+func (r *competitionsRepo) List(ctx context.Context, filter *sports.ListCompetitionsRequestFilter) ([]*sports.Competition, error) {
+	query, args := r.applyFilter(getCompetitionsQueries()[competitionsList], filter)
+	query += " ORDER BY sport_id, name"
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.scanCompetitions(rows)
+}
+
+// Get Return a single competition by id
+func (r *competitionsRepo) Get(ctx context.Context, id int64) (*sports.Competition, error) {
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(getCompetitionsQueries()[competitionsList]+" WHERE id = ?"), id)
+	if err != nil {
+		return nil, err
+	}
+
+	competitions, err := r.scanCompetitions(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(competitions) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return competitions[0], nil
+}
+
+// ListParticipants Returns the participants of a competition
+func (r *competitionsRepo) ListParticipants(ctx context.Context, competitionID int64) ([]*sports.Participant, error) {
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(getCompetitionsQueries()[participantsList]+" WHERE competition_id = ? ORDER BY id"), competitionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participants []*sports.Participant
+
+	for rows.Next() {
+		var participant sports.Participant
+
+		if err := rows.Scan(&participant.Id, &participant.Name); err != nil {
+			return nil, err
+		}
+
+		participants = append(participants, &participant)
+	}
+
+	return participants, rows.Err()
+}
+
+func (r *competitionsRepo) applyFilter(query string, filter *sports.ListCompetitionsRequestFilter) (string, []interface{}) {
+	var (
+		clauses []string
+		args    []interface{}
+	)
+
+	if filter == nil {
+		return query, args
+	}
+
+	if len(filter.SportIds) > 0 {
+		sportIDs := make([]interface{}, 0, len(filter.SportIds))
+		for _, sportID := range filter.SportIds {
+			sportIDs = append(sportIDs, sportID)
+		}
+
+		clause, inArgs := r.dialect.In("sport_id", sportIDs)
+		clauses = append(clauses, clause)
+		args = append(args, inArgs...)
+	}
+
+	if len(clauses) != 0 {
+		query += " WHERE " + strings.Join(clauses, " AND ")
+	}
+
+	return query, args
+}
+
+func (r *competitionsRepo) scanCompetitions(rows *sql.Rows) ([]*sports.Competition, error) {
+	defer rows.Close()
+
+	var competitions []*sports.Competition
+
+	for rows.Next() {
+		var competition sports.Competition
+
+		if err := rows.Scan(&competition.Id, &competition.SportId, &competition.Name, &competition.Country); err != nil {
+			return nil, err
+		}
+
+		competitions = append(competitions, &competition)
+	}
+
+	return competitions, rows.Err()
+}
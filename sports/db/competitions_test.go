@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCompetitionsRepo_List(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	// Only the dummy competitions are seeded
+	if err := initTestCompetitionsDB(db); err != nil {
+		t.Fatalf("failed to initialize competitions: %v", err)
+	}
+	competitionsRepo := NewCompetitionsRepo(db)
+
+	testCases := []struct {
+		name                 string
+		filter               *sports.ListCompetitionsRequestFilter
+		expectedCompetitions []int64
+	}{
+		{
+			name:                 "NoFilter",
+			filter:               nil,
+			expectedCompetitions: []int64{2, 1, 3, 4, 5, 6, 7, 8},
+		},
+		{
+			name:                 "FilterBySportIDs",
+			filter:               &sports.ListCompetitionsRequestFilter{SportIds: []int64{3, 4}},
+			expectedCompetitions: []int64{5, 6, 7},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			competitions, err := competitionsRepo.List(context.Background(), tc.filter)
+			if err != nil {
+				t.Fatalf("failed to list competitions: %v", err)
+			}
+
+			var ids []int64
+			for _, competition := range competitions {
+				ids = append(ids, competition.Id)
+			}
+
+			assert.Equal(t, tc.expectedCompetitions, ids)
+		})
+	}
+
+	t.Run("Fields", func(t *testing.T) {
+		competitions, err := competitionsRepo.List(context.Background(), &sports.ListCompetitionsRequestFilter{SportIds: []int64{2}})
+		if err != nil {
+			t.Fatalf("failed to list competitions: %v", err)
+		}
+
+		assert.Equal(t, []*sports.Competition{{Id: 4, SportId: 2, Name: "AFL", Country: "AU"}}, competitions)
+	})
+}
+
+func TestCompetitionsRepo_Get(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	if err := initTestCompetitionsDB(db); err != nil {
+		t.Fatalf("failed to initialize competitions: %v", err)
+	}
+	competitionsRepo := NewCompetitionsRepo(db)
+
+	competition, err := competitionsRepo.Get(context.Background(), 4)
+	if err != nil {
+		t.Fatalf("failed to get competition: %v", err)
+	}
+	assert.Equal(t, &sports.Competition{Id: 4, SportId: 2, Name: "AFL", Country: "AU"}, competition)
+
+	_, err = competitionsRepo.Get(context.Background(), 999)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	participants, err := competitionsRepo.ListParticipants(context.Background(), 4)
+	if err != nil {
+		t.Fatalf("failed to list participants: %v", err)
+	}
+	assert.NotEmpty(t, participants)
+	for _, participant := range participants {
+		assert.NotZero(t, participant.Id)
+		assert.NotEmpty(t, participant.Name)
+	}
+
+	participants, err = competitionsRepo.ListParticipants(context.Background(), 999)
+	if err != nil {
+		t.Fatalf("failed to list participants: %v", err)
+	}
+	assert.Empty(t, participants)
+}
+
+// initTestCompetitionsDB creates the tables of a test database and seeds the dummy competitions.
+func initTestCompetitionsDB(db *sql.DB) error {
+	if err := migrateTestDB(db); err != nil {
+		return err
+	}
+
+	return (&competitionsRepo{db: db}).seed()
+}
@@ -0,0 +1,267 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/common/db/migrations"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+// postgresTestDSN is the environment variable with the DSN of a Postgres database the conformance suite is run
+// against too, e.g. postgres://localhost/sports_test?sslmode=disable. Its tables are dropped and created again.
+const postgresTestDSN = "SPORTS_TEST_POSTGRES_DSN"
+
+// conformanceDB is a database the conformance suite is run against.
+type conformanceDB struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+}
+
+// conformanceDBs opens an in-memory SQLite database, along with the Postgres database in postgresTestDSN when it
+// is set, each with empty tables.
+func conformanceDBs(t *testing.T) []conformanceDB {
+	sqliteDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	// Every connection to :memory: has a database of its own
+	sqliteDB.SetMaxOpenConns(1)
+
+	dbs := []conformanceDB{{db: sqliteDB, dialect: dialect.SQLite}}
+
+	if dsn := os.Getenv(postgresTestDSN); dsn != "" {
+		postgresDB, err := sql.Open("postgres", dsn)
+		if err != nil {
+			t.Fatalf("failed to open postgres database: %v", err)
+		}
+
+		if _, err := migrations.Down(postgresDB, Migrations(), dialect.Postgres, math.MaxInt32); err != nil {
+			t.Fatalf("failed to drop postgres tables: %v", err)
+		}
+
+		dbs = append(dbs, conformanceDB{db: postgresDB, dialect: dialect.Postgres})
+	}
+
+	for _, conformance := range dbs {
+		if _, err := migrations.Up(conformance.db, Migrations(), conformance.dialect); err != nil {
+			t.Fatalf("failed to migrate %s database: %v", conformance.dialect, err)
+		}
+	}
+
+	return dbs
+}
+
+// TestEventsRepo_Conformance runs the same checks against the events repository of every dialect, so the SQL
+// generated for each of them returns the same events. Without postgresTestDSN it only runs against SQLite, which
+// still checks the statements shared by the dialects.
+func TestEventsRepo_Conformance(t *testing.T) {
+	for _, conformance := range conformanceDBs(t) {
+		t.Run(conformance.dialect.String(), func(t *testing.T) {
+			defer conformance.db.Close()
+
+			eventsRepo := NewEventsRepo(conformance.db, WithDialect(conformance.dialect))
+			if err := eventsRepo.Init(); err != nil {
+				t.Fatalf("failed to initialize events: %v", err)
+			}
+
+			// Events are created through the repository, so the test data doesn't depend on the dialect
+			if err := (&competitionsRepo{db: conformance.db, dialect: conformance.dialect}).seed(); err != nil {
+				t.Fatalf("failed to seed competitions: %v", err)
+			}
+			for _, event := range getAllTestData() {
+				if _, err := eventsRepo.Create(context.Background(), event); err != nil {
+					t.Fatalf("failed to create event: %v", err)
+				}
+			}
+
+			testEventsRepoConformance(t, eventsRepo)
+		})
+	}
+}
+
+func testEventsRepoConformance(t *testing.T, eventsRepo EventsRepo) {
+	t.Run("ListFilter", func(t *testing.T) {
+		testCases := []struct {
+			name             string
+			filter           *sports.ListEventsRequestFilter
+			expectedEventIds []int64
+		}{
+			{
+				name:             "SportIds",
+				filter:           &sports.ListEventsRequestFilter{SportIds: []int64{1, 4}},
+				expectedEventIds: []int64{2, 3},
+			},
+			{
+				name:             "CompetitionIds",
+				filter:           &sports.ListEventsRequestFilter{CompetitionIds: []int64{5, 8, 99}},
+				expectedEventIds: []int64{1, 3},
+			},
+			{
+				name:             "Visible",
+				filter:           &sports.ListEventsRequestFilter{VisibilityStatus: sports.VisibilityStatus_VISIBLE},
+				expectedEventIds: []int64{2},
+			},
+			{
+				name: "StartTimeRange",
+				filter: &sports.ListEventsRequestFilter{
+					StartTimeFrom: timestamppb.New(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)),
+					StartTimeTo:   timestamppb.New(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+				},
+				expectedEventIds: []int64{2},
+			},
+			{
+				// Searches ignore case in every dialect
+				name:             "SearchIgnoresCase",
+				filter:           &sports.ListEventsRequestFilter{Search: "CONNECTICUT"},
+				expectedEventIds: []int64{2},
+			},
+			{
+				name:             "Combined",
+				filter:           &sports.ListEventsRequestFilter{SportIds: []int64{2, 4}, Search: "ghosts"},
+				expectedEventIds: []int64{3},
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				events, _, err := eventsRepo.List(context.Background(), tc.filter, nil, 0, "", nil, getDateNow())
+				if err != nil {
+					t.Fatalf("failed to list events: %v", err)
+				}
+
+				assert.Equal(t, tc.expectedEventIds, eventIds(events))
+			})
+		}
+	})
+
+	t.Run("ListPages", func(t *testing.T) {
+		testCases := []struct {
+			name             string
+			orderBy          []*sports.ListEventsRequestOrderBy
+			expectedEventIds []int64
+		}{
+			{
+				name:             "AdvertisedStartTimeDesc",
+				orderBy:          []*sports.ListEventsRequestOrderBy{{FieldName: "advertisedStartTime", Direction: sports.OrderByDirection_DESC}},
+				expectedEventIds: []int64{3, 2, 1},
+			},
+			{
+				name:             "VisibleDesc",
+				orderBy:          []*sports.ListEventsRequestOrderBy{{FieldName: "visible", Direction: sports.OrderByDirection_DESC}},
+				expectedEventIds: []int64{2, 1, 3},
+			},
+			{
+				name:             "DerivedStatus",
+				orderBy:          []*sports.ListEventsRequestOrderBy{{FieldName: "status"}},
+				expectedEventIds: []int64{1, 2, 3},
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				var (
+					ids       []int64
+					pageToken string
+				)
+
+				// An event per page, so every event is read after a page token
+				for {
+					events, nextPageToken, err := eventsRepo.List(context.Background(), nil, tc.orderBy, 1, pageToken, nil, getDateNow())
+					if err != nil {
+						t.Fatalf("failed to list events: %v", err)
+					}
+
+					ids = append(ids, eventIds(events)...)
+					if nextPageToken == "" || len(ids) > len(tc.expectedEventIds) {
+						break
+					}
+					pageToken = nextPageToken
+				}
+
+				assert.Equal(t, tc.expectedEventIds, ids)
+			})
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		event, err := eventsRepo.Get(context.Background(), 2, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get event: %v", err)
+		}
+
+		expected := getAllTestData()[1]
+		assert.Equal(t, expected.Name, event.Name)
+		assert.Equal(t, expected.CompetitionId, event.CompetitionId)
+		assert.Equal(t, expected.Visible, event.Visible)
+		assert.True(t, expected.AdvertisedStartTime.AsTime().Equal(event.AdvertisedStartTime.AsTime()))
+		assert.Equal(t, "OPEN", event.Status)
+		assert.Equal(t, int64(1), event.Version)
+
+		_, err = eventsRepo.Get(context.Background(), 999, nil, getDateNow())
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		events, err := eventsRepo.BatchGet(context.Background(), []int64{3, 999, 1}, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get events: %v", err)
+		}
+		assert.ElementsMatch(t, []int64{1, 3}, eventIds(events))
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		results, err := eventsRepo.Search(context.Background(), "rhode", 10, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to search events: %v", err)
+		}
+
+		if assert.Len(t, results, 1) {
+			assert.Equal(t, int64(3), results[0].Event.Id)
+			assert.Contains(t, results[0].NameSnippet, highlightStart)
+		}
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		id, err := eventsRepo.Create(context.Background(), &sports.Event{
+			SportId:             1,
+			CompetitionId:       1,
+			Name:                "Arsenal v Chelsea",
+			Visible:             true,
+			AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 16, 14, 30, 0, 0, time.UTC)),
+			Participants:        []*sports.Participant{{Id: 1, Side: sports.ParticipantSide_HOME}, {Id: 2, Side: sports.ParticipantSide_AWAY}},
+		})
+		if err != nil {
+			t.Fatalf("failed to create event: %v", err)
+		}
+		assert.Equal(t, int64(4), id)
+
+		err = eventsRepo.Update(context.Background(), &sports.Event{Id: id, Name: "Chelsea v Arsenal", Visible: false, Version: 1}, []string{"name", "visible"})
+		assert.NoError(t, err)
+
+		err = eventsRepo.Update(context.Background(), &sports.Event{Id: id, Name: "Stale", Version: 1}, []string{"name"})
+		assert.ErrorIs(t, err, ErrVersionChanged)
+
+		err = eventsRepo.Update(context.Background(), &sports.Event{Id: 999, Name: "Missing", Version: 1}, []string{"name"})
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		event, err := eventsRepo.Get(context.Background(), id, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get event: %v", err)
+		}
+		assert.Equal(t, "Chelsea v Arsenal", event.Name)
+		assert.False(t, event.Visible)
+		assert.Equal(t, int64(2), event.Version)
+		assert.Equal(t, []*sports.Participant{
+			{Id: 1, Name: "Arsenal", Side: sports.ParticipantSide_HOME},
+			{Id: 2, Name: "Chelsea", Side: sports.ParticipantSide_AWAY},
+		}, event.Participants)
+
+		assert.NoError(t, eventsRepo.Delete(context.Background(), id))
+		assert.ErrorIs(t, eventsRepo.Delete(context.Background(), id), sql.ErrNoRows)
+	})
+}
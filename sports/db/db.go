@@ -1,29 +1,354 @@
 package db
 
 import (
+	"database/sql"
+	"fmt"
+	"math"
+	"math/rand"
 	"time"
 
 	"syreclabs.com/go/faker"
+
+	"git.neds.sh/matty/entain/sports/proto/sports"
 )
 
-func (r *eventsRepo) seed() error {
-	statement, err := r.db.Prepare(`CREATE TABLE IF NOT EXISTS events (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, visible INTEGER, advertised_start_time DATETIME)`)
-	if err == nil {
-		_, err = statement.Exec()
+// Seed fills the database with dummy sports, competitions, events and markets, for test/example purposes. The
+// tables must have been created by the migrations. Dummy data that is already there is kept, so it can be run again.
+func Seed(db *sql.DB, opts ...Option) error {
+	o := newOptions(opts)
+	d := o.dialect
+	// Start times are seeded around the time of the clock, which is read once so all of them are around the same time
+	now := o.clock.Now()
+
+	// Competitions belong to the sports, events to the competitions and markets to the events, so they are seeded in
+	// that order
+	seeds := []func() error{
+		(&sportsRepo{db: db, dialect: d}).seed,
+		(&competitionsRepo{db: db, dialect: d}).seed,
+		func() error { return (&eventsRepo{db: db, dialect: d}).seed(now) },
+		(&marketsRepo{db: db, dialect: d}).seed,
+	}
+
+	for _, seed := range seeds {
+		if err := seed(); err != nil {
+			return err
+		}
+	}
+
+	// Sports, competitions, participants and events are seeded with their ids, so the ids generated for new ones must
+	// come after them
+	for _, table := range []string{"sports", "competitions", "participants", "events"} {
+		if query := d.SyncID(table); query != "" {
+			if _, err := db.Exec(query); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *eventsRepo) seed(now time.Time) error {
+	statement, err := r.db.Prepare(r.dialect.Rebind(`INSERT INTO events(id, sport_id, competition_id, name, visible, advertised_start_time, home_participant_id, away_participant_id) VALUES (?,?,?,?,?,?,?,?) ON CONFLICT DO NOTHING`))
+	if err != nil {
+		return err
 	}
+	defer statement.Close()
 
 	for i := 1; i <= 100; i++ {
-		statement, err = r.db.Prepare(`INSERT OR IGNORE INTO events(id, meeting_id, name, visible, advertised_start_time) VALUES (?,?,?,?,?)`)
-		if err == nil {
-			_, err = statement.Exec(
-				i,
-				faker.Number().Between(1, 10),
-				faker.Lorem().Sentence(20),
-				faker.Number().Between(0, 1),
-				faker.Time().Between(time.Now().AddDate(0, 0, -1), time.Now().AddDate(0, 0, 2)).Format(time.RFC3339),
-			)
+		// Events are played between two different participants of one of the dummy competitions
+		competition := faker.RandomInt(0, len(seedCompetitions)-1)
+		sides := rand.Perm(len(seedCompetitions[competition].participants))
+		home, away := sides[0], sides[1]
+
+		if _, err := statement.Exec(
+			i,
+			seedCompetitions[competition].sportID,
+			competition+1,
+			seedCompetitions[competition].participants[home]+" v "+seedCompetitions[competition].participants[away],
+			faker.Number().Between(0, 1),
+			// Stored in UTC so start times sort and compare correctly as text
+			faker.Time().Between(now.AddDate(0, 0, -1), now.AddDate(0, 0, 2)).UTC().Format(time.RFC3339),
+			seedParticipantID(competition, home),
+			seedParticipantID(competition, away),
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// seedSports are the dummy sports, their ids are their position in the list starting from 1.
+var seedSports = []*sports.Sport{
+	{Name: "Soccer"},
+	{Name: "Australian Rules"},
+	{Name: "Basketball"},
+	{Name: "Tennis"},
+	{Name: "Rugby League"},
+}
+
+func (r *sportsRepo) seed() error {
+	statement, err := r.db.Prepare(r.dialect.Rebind(`INSERT INTO sports(id, name) VALUES (?,?) ON CONFLICT DO NOTHING`))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for i, sport := range seedSports {
+		if _, err := statement.Exec(i+1, sport.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// seedCompetition is a dummy competition along with the teams or players taking part in it.
+type seedCompetition struct {
+	sportID      int64
+	name         string
+	country      string
+	participants []string
+}
+
+// seedCompetitions are the dummy competitions, their ids are their position in the list starting from 1.
+// Participant ids follow the same order, across all competitions.
+var seedCompetitions = []seedCompetition{
+	{
+		sportID: 1, name: "English Premier League", country: "GB",
+		participants: []string{"Arsenal", "Chelsea", "Liverpool", "Manchester City", "Manchester United", "Tottenham Hotspur", "Newcastle United", "Aston Villa"},
+	},
+	{
+		sportID: 1, name: "A-League Men", country: "AU",
+		participants: []string{"Sydney FC", "Melbourne Victory", "Melbourne City", "Western Sydney Wanderers", "Adelaide United", "Brisbane Roar", "Central Coast Mariners", "Wellington Phoenix"},
+	},
+	{
+		sportID: 1, name: "UEFA Champions League",
+		participants: []string{"Real Madrid", "Barcelona", "Bayern Munich", "Paris Saint-Germain", "Inter Milan", "Juventus", "Borussia Dortmund", "Benfica"},
+	},
+	{
+		sportID: 2, name: "AFL", country: "AU",
+		participants: []string{"Collingwood", "Carlton", "Richmond", "Essendon", "Geelong Cats", "Sydney Swans", "West Coast Eagles", "Brisbane Lions"},
+	},
+	{
+		sportID: 3, name: "NBA", country: "US",
+		participants: []string{"Los Angeles Lakers", "Boston Celtics", "Golden State Warriors", "Chicago Bulls", "Miami Heat", "Denver Nuggets", "Milwaukee Bucks", "Phoenix Suns"},
+	},
+	{
+		sportID: 3, name: "NBL", country: "AU",
+		participants: []string{"Sydney Kings", "Melbourne United", "Perth Wildcats", "Brisbane Bullets", "Adelaide 36ers", "New Zealand Breakers"},
+	},
+	{
+		sportID: 4, name: "Australian Open", country: "AU",
+		participants: []string{"Novak Djokovic", "Carlos Alcaraz", "Jannik Sinner", "Daniil Medvedev", "Alex de Minaur", "Casper Ruud", "Stefanos Tsitsipas", "Holger Rune"},
+	},
+	{
+		sportID: 5, name: "NRL", country: "AU",
+		participants: []string{"Penrith Panthers", "Melbourne Storm", "Sydney Roosters", "South Sydney Rabbitohs", "Brisbane Broncos", "Parramatta Eels", "Cronulla Sharks", "North Queensland Cowboys"},
+	},
+}
+
+// seedParticipantID returns the id of a participant given its position in seedCompetitions.
+func seedParticipantID(competition int, participant int) int {
+	id := participant + 1
+	for _, previous := range seedCompetitions[:competition] {
+		id += len(previous.participants)
+	}
+
+	return id
+}
+
+func (r *competitionsRepo) seed() error {
+	insertCompetition, err := r.db.Prepare(r.dialect.Rebind(`INSERT INTO competitions(id, sport_id, name, country) VALUES (?,?,?,?) ON CONFLICT DO NOTHING`))
+	if err != nil {
+		return err
+	}
+	defer insertCompetition.Close()
+
+	insertParticipant, err := r.db.Prepare(r.dialect.Rebind(`INSERT INTO participants(id, competition_id, name) VALUES (?,?,?) ON CONFLICT DO NOTHING`))
+	if err != nil {
+		return err
+	}
+	defer insertParticipant.Close()
+
+	for i, competition := range seedCompetitions {
+		if _, err := insertCompetition.Exec(i+1, competition.sportID, competition.name, competition.country); err != nil {
+			return err
+		}
+
+		for j, participant := range competition.participants {
+			if _, err := insertParticipant.Exec(seedParticipantID(i, j), i+1, participant); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// seedMarketRules shapes the dummy markets of each sport, keyed by sport id.
+var seedMarketRules = map[int64]struct {
+	// draw is whether the head to head market has a draw selection
+	draw bool
+	// maxLine is the handicap of the biggest favourite
+	maxLine float64
+	// total is the usual combined score of an event
+	total float64
+	// unit is what the score of the sport is counted in
+	unit string
+}{
+	1: {draw: true, maxLine: 2.5, total: 2.5, unit: "Goals"},
+	2: {maxLine: 40.5, total: 165.5, unit: "Points"},
+	3: {maxLine: 15.5, total: 210.5, unit: "Points"},
+	4: {maxLine: 5.5, total: 22.5, unit: "Games"},
+	5: {maxLine: 16.5, total: 40.5, unit: "Points"},
+}
+
+// seedMargin is the bookmaker margin built into the dummy prices.
+const seedMargin = 1.05
+
+// seedPrice returns the decimal price for a probability, including the margin and rounded to cents.
+func seedPrice(probability float64) float64 {
+	return math.Round(100/(probability*seedMargin)) / 100
+}
+
+// seedEvent is an event the dummy markets are seeded for.
+type seedEvent struct {
+	id       int64
+	sportID  int64
+	homeID   int64
+	homeName string
+	awayID   int64
+	awayName string
+}
+
+func (r *marketsRepo) seed() error {
+	// Prices are random, so markets are only seeded when there are none yet
+	var count int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM markets`).Scan(&count); err != nil || count > 0 {
+		return err
+	}
+
+	events, err := r.seedEvents()
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insertMarket, err := tx.Prepare(r.dialect.Rebind(`INSERT INTO markets(event_id, name, type, line) VALUES (?,?,?,?) RETURNING id`))
+	if err != nil {
+		return err
+	}
+	defer insertMarket.Close()
+
+	insertSelection, err := tx.Prepare(r.dialect.Rebind(`INSERT INTO selections(market_id, name, price, participant_id) VALUES (?,?,?,?)`))
+	if err != nil {
+		return err
+	}
+	defer insertSelection.Close()
+
+	for _, event := range events {
+		rules := seedMarketRules[event.sportID]
+
+		// The chance of the home side winning drives every market of the event
+		home := 0.2 + rand.Float64()*0.6
+
+		markets := []*sports.Market{{Name: "Head to Head", Type: sports.MarketType_HEAD_TO_HEAD}}
+		if rules.draw {
+			draw := 0.22 + rand.Float64()*0.08
+			markets[0].Selections = []*sports.Selection{
+				{Name: event.homeName, Price: seedPrice(home * (1 - draw)), ParticipantId: event.homeID},
+				{Name: "Draw", Price: seedPrice(draw)},
+				{Name: event.awayName, Price: seedPrice((1 - home) * (1 - draw)), ParticipantId: event.awayID},
+			}
+		} else {
+			markets[0].Selections = []*sports.Selection{
+				{Name: event.homeName, Price: seedPrice(home), ParticipantId: event.homeID},
+				{Name: event.awayName, Price: seedPrice(1 - home), ParticipantId: event.awayID},
+			}
+		}
+
+		// The favourite gives away a start proportional to how likely it is to win, always ending in .5 so there are no ties
+		line := math.Floor(math.Abs(home-0.5)/0.3*rules.maxLine) + 0.5
+		if home > 0.5 {
+			line = -line
+		}
+		cover := 0.47 + rand.Float64()*0.06
+		markets = append(markets, &sports.Market{
+			Name: "Line",
+			Type: sports.MarketType_LINE,
+			Line: line,
+			Selections: []*sports.Selection{
+				{Name: fmt.Sprintf("%s %+.1f", event.homeName, line), Price: seedPrice(cover), ParticipantId: event.homeID},
+				{Name: fmt.Sprintf("%s %+.1f", event.awayName, -line), Price: seedPrice(1 - cover), ParticipantId: event.awayID},
+			},
+		})
+
+		total := math.Floor(rules.total*(0.9+rand.Float64()*0.2)) + 0.5
+		over := 0.47 + rand.Float64()*0.06
+		markets = append(markets, &sports.Market{
+			Name: "Total " + rules.unit,
+			Type: sports.MarketType_TOTALS,
+			Line: total,
+			Selections: []*sports.Selection{
+				{Name: fmt.Sprintf("Over %.1f", total), Price: seedPrice(over)},
+				{Name: fmt.Sprintf("Under %.1f", total), Price: seedPrice(1 - over)},
+			},
+		})
+
+		for _, market := range markets {
+			var marketID int64
+			if err := insertMarket.QueryRow(event.id, market.Name, market.Type.String(), market.Line).Scan(&marketID); err != nil {
+				return err
+			}
+
+			for _, selection := range market.Selections {
+				var participantID interface{}
+				if selection.ParticipantId != 0 {
+					participantID = selection.ParticipantId
+				}
+
+				if _, err := insertSelection.Exec(marketID, selection.Name, selection.Price, participantID); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// seedEvents returns the events the markets are seeded for, along with their participants.
+func (r *marketsRepo) seedEvents() ([]seedEvent, error) {
+	rows, err := r.db.Query(`
+		SELECT 
+			id, 
+			sport_id, 
+			home_participant_id, 
+			(SELECT name FROM participants WHERE participants.id = events.home_participant_id), 
+			away_participant_id, 
+			(SELECT name FROM participants WHERE participants.id = events.away_participant_id) 
+		FROM events
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []seedEvent
+	for rows.Next() {
+		var event seedEvent
+		if err := rows.Scan(&event.id, &event.sportID, &event.homeID, &event.homeName, &event.awayID, &event.awayName); err != nil {
+			return nil, err
 		}
+		events = append(events, event)
 	}
 
-	return err
+	return events, rows.Err()
 }
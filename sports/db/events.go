@@ -1,14 +1,21 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/golang/protobuf/ptypes"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/common/order"
 	"git.neds.sh/matty/entain/sports/proto/sports"
 )
 
@@ -17,80 +24,203 @@ type EventsRepo interface {
 	// Init will initialise our events repository.
 	Init() error
 
-	// List will return a list of events.
-	List(filter *sports.ListEventsRequestFilter, orderBy []*sports.ListEventsRequestOrderBy, currentDate time.Time) ([]*sports.Event, error)
+	// List will return a page of events, along with the token for the next page.
+	// A pageSize of 0 returns every event, the token is empty when there are no more events.
+	// Only the fields in the read mask are read, every field is read when it is nil or empty.
+	List(ctx context.Context, filter *sports.ListEventsRequestFilter, orderBy []*sports.ListEventsRequestOrderBy, pageSize int32, pageToken string, readMask *fieldmaskpb.FieldMask, currentDate time.Time) ([]*sports.Event, string, error)
 
-	// Get will return a single event. It will return an error if no event is found
-	Get(id int64, currentDate time.Time) (*sports.Event, error)
+	// Get will return a single event with the fields in the read mask. It will return an error if no event is found
+	Get(ctx context.Context, id int64, readMask *fieldmaskpb.FieldMask, currentDate time.Time) (*sports.Event, error)
+
+	// BatchGet will return the events with the given ids, in no particular order. Events that aren't found are left out.
+	BatchGet(ctx context.Context, ids []int64, currentDate time.Time) ([]*sports.Event, error)
+
+	// Search will return the events whose name match every word of the query, the most relevant first.
+	Search(ctx context.Context, query string, limit int32, currentDate time.Time) ([]*sports.EventSearchResult, error)
+
+	// Create will add an event, returning its id.
+	Create(ctx context.Context, event *sports.Event) (int64, error)
+
+	// Update will change the given fields of an event, as named in EventWriteFields, and increment its version.
+	// The event is only changed at its version, otherwise ErrVersionChanged is returned.
+	// It will return an error if no event is found
+	Update(ctx context.Context, event *sports.Event, fields []string) error
+
+	// Delete will remove an event along with its markets and their selections.
+	// It will return an error if no event is found
+	Delete(ctx context.Context, id int64) error
 }
 
+// ErrVersionChanged is returned when an event was changed by someone else since the version it was read at.
+var ErrVersionChanged = errors.New("event version has changed")
+
+// eventStatus is the status of an event, derived from its advertised start time.
+// It expects the current time as its argument.
+const eventStatus = "CASE WHEN advertised_start_time < ? THEN 'CLOSED' ELSE 'OPEN' END"
+
 type eventsRepo struct {
-	db   *sql.DB
-	init sync.Once
+	db      *sql.DB
+	dialect dialect.Dialect
+	init    sync.Once
 }
 
 // NewEventsRepo creates a new sports repository.
-func NewEventsRepo(db *sql.DB) EventsRepo {
-	return &eventsRepo{db: db}
+func NewEventsRepo(db *sql.DB, opts ...Option) EventsRepo {
+	return &eventsRepo{db: db, dialect: newOptions(opts).dialect}
 }
 
-// Init prepares the event repository dummy data.
+// Init prepares the search index of the event repository. Its tables are created by the migrations.
 func (r *eventsRepo) Init() error {
 	var err error
 
 	r.init.Do(func() {
-		// For test/example purposes, we seed the DB with some dummy events.
-		err = r.seed()
+		// The index depends on whether SQLite has FTS5, so it is set up by the build rather than migrated
+		err = r.initSearch()
 	})
 
 	return err
 }
 
-// List Returns a list of events
-func (r *eventsRepo) List(filter *sports.ListEventsRequestFilter, orderBy []*sports.ListEventsRequestOrderBy, currentDate time.Time) ([]*sports.Event, error) {
+// List Returns a page of events
+func (r *eventsRepo) List(ctx context.Context, filter *sports.ListEventsRequestFilter, orderBy []*sports.ListEventsRequestOrderBy, pageSize int32, pageToken string, readMask *fieldmaskpb.FieldMask, currentDate time.Time) ([]*sports.Event, string, error) {
 	var (
-		err   error
-		query string
-		args  []interface{}
+		err    error
+		query  string
+		args   []interface{}
+		cursor *pageCursor
 	)
 
-	query = getEventsQueries()[eventsList]
+	columns, err := r.orderColumns(orderBy, currentDate)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The order columns are needed for the page token
+	orderFieldNames := make([]string, 0, len(columns))
+	for _, column := range columns {
+		orderFieldNames = append(orderFieldNames, column.Name)
+	}
 
-	query, args = r.applyFilter(query, filter)
+	selection, err := newReadSelection(readMask, orderFieldNames...)
+	if err != nil {
+		return nil, "", err
+	}
 
-	query = r.applyOrderBy(query, orderBy)
+	query = selection.query()
 
-	rows, err := r.db.Query(query, args...)
+	fingerprint, err := queryFingerprint(filter, columns)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	if pageToken != "" {
+		if cursor, err = decodePageToken(pageToken, fingerprint, columns); err != nil {
+			return nil, "", err
+		}
+	}
+
+	query, args = r.applyFilter(query, filter, cursor, columns, currentDate)
+
+	query, orderArgs := r.applyOrderBy(query, columns)
+	args = append(args, orderArgs...)
+
+	if pageSize > 0 {
+		// Fetch an extra event to find out if there is a next page
+		query += " LIMIT ?"
+		args = append(args, pageSize+1)
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, "", err
 	}
+	defer rows.Close()
 
-	return r.scanEvents(rows, currentDate)
+	events, err := r.scanEvents(rows, selection, currentDate)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var nextPageToken string
+	if pageSize > 0 && len(events) > int(pageSize) {
+		events = events[:pageSize]
+
+		if nextPageToken, err = encodePageToken(fingerprint, columns, events[len(events)-1]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	selection.prune(events)
+
+	return events, nextPageToken, nil
 }
 
-func (r *eventsRepo) applyFilter(query string, filter *sports.ListEventsRequestFilter) (string, []interface{}) {
+func (r *eventsRepo) applyFilter(query string, filter *sports.ListEventsRequestFilter, cursor *pageCursor, columns []order.Column, currentDate time.Time) (string, []interface{}) {
 	var (
 		clauses []string
 		args    []interface{}
 	)
 
-	if filter == nil {
-		return query, args
+	if cursor != nil {
+		clause, cursorArgs := cursor.clause(columns)
+		clauses = append(clauses, clause)
+		args = append(args, cursorArgs...)
 	}
 
-	if len(filter.MeetingIds) > 0 {
-		clauses = append(clauses, "meeting_id IN ("+strings.Repeat("?,", len(filter.MeetingIds)-1)+"?)")
+	if filter != nil {
+		if len(filter.SportIds) > 0 {
+			sportIDs := make([]interface{}, 0, len(filter.SportIds))
+			for _, sportID := range filter.SportIds {
+				sportIDs = append(sportIDs, sportID)
+			}
 
-		for _, meetingID := range filter.MeetingIds {
-			args = append(args, meetingID)
+			clause, inArgs := r.dialect.In("sport_id", sportIDs)
+			clauses = append(clauses, clause)
+			args = append(args, inArgs...)
 		}
-	}
 
-	switch filter.VisibilityStatus {
-	case sports.VisibilityStatus_VISIBLE:
-		clauses = append(clauses, "visible = 1")
-	case sports.VisibilityStatus_HIDDEN:
-		clauses = append(clauses, "visible = 0")
+		if len(filter.CompetitionIds) > 0 {
+			competitionIDs := make([]interface{}, 0, len(filter.CompetitionIds))
+			for _, competitionID := range filter.CompetitionIds {
+				competitionIDs = append(competitionIDs, competitionID)
+			}
+
+			clause, inArgs := r.dialect.In("competition_id", competitionIDs)
+			clauses = append(clauses, clause)
+			args = append(args, inArgs...)
+		}
+
+		switch filter.VisibilityStatus {
+		case sports.VisibilityStatus_VISIBLE:
+			clauses = append(clauses, "visible = 1")
+		case sports.VisibilityStatus_HIDDEN:
+			clauses = append(clauses, "visible = 0")
+		}
+
+		if terms := searchTerms(filter.Search); len(terms) > 0 {
+			clause, searchArgs := r.searchClause(terms)
+			clauses = append(clauses, clause)
+			args = append(args, searchArgs...)
+		}
+
+		// Start times are compared directly, so the advertised_start_time index can be used
+		if filter.StartTimeFrom != nil {
+			clauses = append(clauses, "advertised_start_time >= ?")
+			args = append(args, formatStartTime(filter.StartTimeFrom.AsTime()))
+		}
+
+		if filter.StartTimeTo != nil {
+			clauses = append(clauses, "advertised_start_time < ?")
+			args = append(args, formatStartTime(filter.StartTimeTo.AsTime()))
+		}
+
+		if filter.StartsWithinMinutes > 0 {
+			clauses = append(clauses, "advertised_start_time >= ?", "advertised_start_time < ?")
+			args = append(args,
+				formatStartTime(currentDate),
+				formatStartTime(currentDate.Add(time.Duration(filter.StartsWithinMinutes)*time.Minute)),
+			)
+		}
 	}
 
 	if len(clauses) != 0 {
@@ -100,67 +230,268 @@ func (r *eventsRepo) applyFilter(query string, filter *sports.ListEventsRequestF
 	return query, args
 }
 
-func (r *eventsRepo) applyOrderBy(query string, orderBy []*sports.ListEventsRequestOrderBy) string {
+// formatStartTime formats a time the way start times are stored. Start times are stored to the second,
+// so the time is rounded up to the next second to keep the bounds the same.
+func formatStartTime(t time.Time) string {
+	if truncated := t.Truncate(time.Second); !truncated.Equal(t) {
+		t = truncated.Add(time.Second)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// orderColumns returns the columns to sort events by, which must be in the whitelist. The id is always
+// the last one, so events with the same values are kept in the same order across pages.
+func (r *eventsRepo) orderColumns(orderBy []*sports.ListEventsRequestOrderBy, currentDate time.Time) ([]order.Column, error) {
+	by := make([]order.By, 0, len(orderBy))
+	for _, orderByClause := range orderBy {
+		by = append(by, order.By{Field: orderByClause.FieldName, Desc: orderByClause.Direction == sports.OrderByDirection_DESC})
+	}
+
+	return orderFields.Columns(by, currentDate)
+}
+
+func (r *eventsRepo) applyOrderBy(query string, columns []order.Column) (string, []interface{}) {
 	var (
 		clauses []string
+		args    []interface{}
 	)
 
-	if orderBy == nil {
-		return query
-	}
-
-	for _, orderByClause := range orderBy {
-		if strings.ToLower(orderByClause.FieldName) == "advertisedstarttime" {
-			if orderByClause.Direction == sports.OrderByDirection_DESC {
-				clauses = append(clauses, "advertised_start_time desc")
-			} else {
-				clauses = append(clauses, "advertised_start_time")
-			}
+	for _, column := range columns {
+		if column.Desc {
+			clauses = append(clauses, column.SQL+" desc")
+		} else {
+			clauses = append(clauses, column.SQL)
 		}
+		args = append(args, column.Args...)
 	}
 
 	if len(clauses) != 0 {
 		query += " ORDER BY " + strings.Join(clauses, ",")
 	}
 
-	return query
+	return query, args
 }
 
 // Get Return a single event by id
-func (r *eventsRepo) Get(id int64, currentDate time.Time) (*sports.Event, error) {
+func (r *eventsRepo) Get(ctx context.Context, id int64, readMask *fieldmaskpb.FieldMask, currentDate time.Time) (*sports.Event, error) {
 	var (
 		err   error
 		query string
 		args  []interface{}
 	)
 
-	query = getEventsQueries()[eventsList]
+	selection, err := newReadSelection(readMask)
+	if err != nil {
+		return nil, err
+	}
+
+	query = selection.query()
 	query += " WHERE Id = ?"
 	args = append(args, id)
 
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	events, err := r.scanEvents(rows, currentDate)
+	events, err := r.scanEvents(rows, selection, currentDate)
+	if err != nil {
+		return nil, err
+	}
+	selection.prune(events)
 
 	if len(events) == 1 {
-		return events[0], err
+		return events[0], nil
 	} else {
 		// in case a event is not found return an error for no rows
 		return nil, sql.ErrNoRows
 	}
 }
 
-func (r *eventsRepo) scanEvents(rows *sql.Rows, currentDate time.Time) ([]*sports.Event, error) {
+// BatchGet Returns the events with the given ids
+func (r *eventsRepo) BatchGet(ctx context.Context, ids []int64, currentDate time.Time) ([]*sports.Event, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	selection, err := newReadSelection(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	clause, args := r.dialect.In("id", args)
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(selection.query()+" WHERE "+clause), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanEvents(rows, selection, currentDate)
+}
+
+// EventWriteFields are the fields of an event that can be written, as named in update masks.
+var EventWriteFields = []string{"sport_id", "competition_id", "name", "visible", "advertised_start_time", "participants"}
+
+// eventWriteValues returns the columns a field of an event is stored in along with their values,
+// for the fields in EventWriteFields. Participants are stored by side, a missing side is stored as NULL.
+func eventWriteValues(event *sports.Event, field string) ([]string, []interface{}, bool) {
+	switch field {
+	case "sport_id":
+		return []string{"sport_id"}, []interface{}{event.SportId}, true
+	case "competition_id":
+		return []string{"competition_id"}, []interface{}{event.CompetitionId}, true
+	case "name":
+		return []string{"name"}, []interface{}{event.Name}, true
+	case "visible":
+		return []string{"visible"}, []interface{}{storedFlag(event.Visible)}, true
+	case "advertised_start_time":
+		return []string{"advertised_start_time"}, []interface{}{formatStartTime(event.AdvertisedStartTime.AsTime())}, true
+	case "participants":
+		var home, away interface{}
+		for _, participant := range event.Participants {
+			switch participant.Side {
+			case sports.ParticipantSide_HOME:
+				home = participant.Id
+			case sports.ParticipantSide_AWAY:
+				away = participant.Id
+			}
+		}
+		return []string{"home_participant_id", "away_participant_id"}, []interface{}{home, away}, true
+	default:
+		return nil, nil, false
+	}
+}
+
+// Create Adds an event
+func (r *eventsRepo) Create(ctx context.Context, event *sports.Event) (int64, error) {
+	var (
+		columns []string
+		args    []interface{}
+	)
+
+	for _, field := range EventWriteFields {
+		fieldColumns, values, _ := eventWriteValues(event, field)
+		columns = append(columns, fieldColumns...)
+		args = append(args, values...)
+	}
+
+	// The id is returned by the insert itself, as Postgres has no last insert id
+	query := "INSERT INTO events (" + strings.Join(columns, ", ") + ") VALUES (?" + strings.Repeat(", ?", len(columns)-1) + ") RETURNING id"
+
+	var id int64
+	err := r.db.QueryRowContext(ctx, r.dialect.Rebind(query), args...).Scan(&id)
+
+	return id, err
+}
+
+// Update Changes the given fields of an event
+func (r *eventsRepo) Update(ctx context.Context, event *sports.Event, fields []string) error {
+	var (
+		clauses []string
+		args    []interface{}
+	)
+
+	for _, field := range fields {
+		columns, values, ok := eventWriteValues(event, field)
+		if !ok {
+			return fmt.Errorf("event field %q can't be updated", field)
+		}
+		for _, column := range columns {
+			clauses = append(clauses, column+" = ?")
+		}
+		args = append(args, values...)
+	}
+
+	if len(clauses) == 0 {
+		return nil
+	}
+
+	// The version is checked in the same statement, so concurrent changes can't be overwritten
+	query := "UPDATE events SET " + strings.Join(clauses, ", ") + ", version = version + 1 WHERE id = ? AND version = ?"
+	args = append(args, event.Id, event.Version)
+
+	result, err := r.db.ExecContext(ctx, r.dialect.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if updated == 0 {
+		return r.notUpdated(ctx, event.Id)
+	}
+
+	return nil
+}
+
+// notUpdated returns why an event wasn't updated, sql.ErrNoRows if it doesn't exist and ErrVersionChanged otherwise.
+func (r *eventsRepo) notUpdated(ctx context.Context, id int64) error {
+	var exists int
+	if err := r.db.QueryRowContext(ctx, r.dialect.Rebind("SELECT 1 FROM events WHERE id = ?"), id).Scan(&exists); err != nil {
+		return err
+	}
+
+	return ErrVersionChanged
+}
+
+// Delete Removes an event with its markets and their selections
+func (r *eventsRepo) Delete(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, r.dialect.Rebind("DELETE FROM events WHERE id = ?"), id)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+
+	// Markets and selections are only read through their event, so they would be left behind otherwise
+	if _, err := tx.ExecContext(ctx, r.dialect.Rebind("DELETE FROM selections WHERE market_id IN (SELECT id FROM markets WHERE event_id = ?)"), id); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, r.dialect.Rebind("DELETE FROM markets WHERE event_id = ?"), id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// scanEvents reads the events from the selected columns. The status is only derived when the advertised start
+// time is selected.
+func (r *eventsRepo) scanEvents(rows *sql.Rows, selection *readSelection, currentDate time.Time) ([]*sports.Event, error) {
 	var events []*sports.Event
 
 	for rows.Next() {
-		var event sports.Event
-		var advertisedStart time.Time
+		var row eventRow
 
-		if err := rows.Scan(&event.Id, &event.MeetingId, &event.Name, &event.Visible, &advertisedStart); err != nil {
+		dest := make([]interface{}, 0, len(selection.columns))
+		for _, column := range selection.columns {
+			dest = append(dest, column.dest(&row))
+		}
+
+		if err := rows.Scan(dest...); err != nil {
 			if err == sql.ErrNoRows {
 				return nil, nil
 			}
@@ -168,19 +499,52 @@ func (r *eventsRepo) scanEvents(rows *sql.Rows, currentDate time.Time) ([]*sport
 			return nil, err
 		}
 
-		ts, err := ptypes.TimestampProto(advertisedStart)
-		if err != nil {
-			return nil, err
-		}
+		event := &row.event
+		event.Participants = participants(row.home, row.away)
+		if !row.advertisedStart.IsZero() {
+			ts, err := ptypes.TimestampProto(row.advertisedStart)
+			if err != nil {
+				return nil, err
+			}
 
-		event.AdvertisedStartTime = ts
-		if advertisedStart.Before(currentDate) {
-			event.Status = "CLOSED"
-		} else {
-			event.Status = "OPEN"
+			event.AdvertisedStartTime = ts
+			if row.advertisedStart.Before(currentDate) {
+				event.Status = "CLOSED"
+			} else {
+				event.Status = "OPEN"
+			}
 		}
-		events = append(events, &event)
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// storedFlag returns a flag the way it is stored, as 0 or 1 in every dialect.
+func storedFlag(flag bool) int64 {
+	if flag {
+		return 1
+	}
+	return 0
+}
+
+// scannedParticipant is a participant as read alongside an event, it is NULL if the participant doesn't exist.
+type scannedParticipant struct {
+	id   sql.NullInt64
+	name sql.NullString
+}
+
+// participants returns the participants of an event, the home side first.
+func participants(home scannedParticipant, away scannedParticipant) []*sports.Participant {
+	var result []*sports.Participant
+
+	if home.id.Valid && home.name.Valid {
+		result = append(result, &sports.Participant{Id: home.id.Int64, Name: home.name.String, Side: sports.ParticipantSide_HOME})
+	}
+
+	if away.id.Valid && away.name.Valid {
+		result = append(result, &sports.Participant{Id: away.id.Int64, Name: away.name.String, Side: sports.ParticipantSide_AWAY})
 	}
 
-	return events, nil
+	return result
 }
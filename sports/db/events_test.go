@@ -1,10 +1,16 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"git.neds.sh/matty/entain/common/clock"
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/common/db/instrument"
+	"git.neds.sh/matty/entain/common/db/migrations"
 	"git.neds.sh/matty/entain/sports/proto/sports"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"testing"
 	"time"
@@ -39,68 +45,108 @@ func TestEventsRepo_List(t *testing.T) {
 			expectedEvents: []*sports.Event{
 				{
 					Id:                  1,
-					MeetingId:           5,
+					SportId:             2,
+					CompetitionId:       5,
 					Name:                "North Dakota foes",
 					Visible:             false,
 					Status:              "CLOSED",
 					AdvertisedStartTime: timestamppb.New(time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 				{
 					Id:                  2,
-					MeetingId:           1,
+					SportId:             1,
+					CompetitionId:       1,
 					Name:                "Connecticut griffins",
 					Visible:             true,
 					Status:              "OPEN",
 					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 				{
 					Id:                  3,
-					MeetingId:           8,
+					SportId:             4,
+					CompetitionId:       8,
 					Name:                "Rhode Island ghosts",
 					Visible:             false,
 					Status:              "OPEN",
 					AdvertisedStartTime: timestamppb.New(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 			},
 		},
 		{
-			name: "FilterByMeetingIDs",
+			name: "FilterByCompetitionIDs",
 			filter: &sports.ListEventsRequestFilter{
-				MeetingIds: []int64{5, 8},
+				CompetitionIds: []int64{5, 8},
 			},
 			expectedEvents: []*sports.Event{
 				{
 					Id:                  1,
-					MeetingId:           5,
+					SportId:             2,
+					CompetitionId:       5,
 					Name:                "North Dakota foes",
 					Visible:             false,
 					Status:              "CLOSED",
 					AdvertisedStartTime: timestamppb.New(time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 				{
 					Id:                  3,
-					MeetingId:           8,
+					SportId:             4,
+					CompetitionId:       8,
 					Name:                "Rhode Island ghosts",
 					Visible:             false,
 					Status:              "OPEN",
 					AdvertisedStartTime: timestamppb.New(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 			},
 		},
 		{
-			name: "FilterByMeetingIDsAndVisibility",
+			name: "FilterBySportIDs",
 			filter: &sports.ListEventsRequestFilter{
-				MeetingIds:       []int64{5, 1},
+				SportIds: []int64{1, 4},
+			},
+			expectedEvents: []*sports.Event{
+				{
+					Id:                  2,
+					SportId:             1,
+					CompetitionId:       1,
+					Name:                "Connecticut griffins",
+					Visible:             true,
+					Status:              "OPEN",
+					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
+				},
+				{
+					Id:                  3,
+					SportId:             4,
+					CompetitionId:       8,
+					Name:                "Rhode Island ghosts",
+					Visible:             false,
+					Status:              "OPEN",
+					AdvertisedStartTime: timestamppb.New(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
+				},
+			},
+		},
+		{
+			name: "FilterByCompetitionIDsAndVisibility",
+			filter: &sports.ListEventsRequestFilter{
+				CompetitionIds:   []int64{5, 1},
 				VisibilityStatus: sports.VisibilityStatus_VISIBLE,
 			},
 			expectedEvents: []*sports.Event{
 				{
 					Id:                  2,
-					MeetingId:           1,
+					SportId:             1,
+					CompetitionId:       1,
 					Name:                "Connecticut griffins",
 					Visible:             true,
 					Status:              "OPEN",
 					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 			},
 		},
@@ -113,11 +159,13 @@ func TestEventsRepo_List(t *testing.T) {
 			expectedEvents: []*sports.Event{
 				{
 					Id:                  2,
-					MeetingId:           1,
+					SportId:             1,
+					CompetitionId:       1,
 					Name:                "Connecticut griffins",
 					Visible:             true,
 					Status:              "OPEN",
 					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 			},
 		},
@@ -129,19 +177,60 @@ func TestEventsRepo_List(t *testing.T) {
 			expectedEvents: []*sports.Event{
 				{
 					Id:                  1,
-					MeetingId:           5,
+					SportId:             2,
+					CompetitionId:       5,
 					Name:                "North Dakota foes",
 					Visible:             false,
 					Status:              "CLOSED",
 					AdvertisedStartTime: timestamppb.New(time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 				{
 					Id:                  3,
-					MeetingId:           8,
+					SportId:             4,
+					CompetitionId:       8,
 					Name:                "Rhode Island ghosts",
 					Visible:             false,
 					Status:              "OPEN",
 					AdvertisedStartTime: timestamppb.New(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
+				},
+			},
+		},
+		{
+			name: "FilterByStartTimeRange",
+			filter: &sports.ListEventsRequestFilter{
+				StartTimeFrom: timestamppb.New(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)),
+				StartTimeTo:   timestamppb.New(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)),
+			},
+			expectedEvents: []*sports.Event{
+				{
+					Id:                  2,
+					SportId:             1,
+					CompetitionId:       1,
+					Name:                "Connecticut griffins",
+					Visible:             true,
+					Status:              "OPEN",
+					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
+				},
+			},
+		},
+		{
+			name: "FilterByStartsWithinMinutes",
+			filter: &sports.ListEventsRequestFilter{
+				StartsWithinMinutes: 60,
+			},
+			expectedEvents: []*sports.Event{
+				{
+					Id:                  2,
+					SportId:             1,
+					CompetitionId:       1,
+					Name:                "Connecticut griffins",
+					Visible:             true,
+					Status:              "OPEN",
+					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 			},
 		},
@@ -155,27 +244,33 @@ func TestEventsRepo_List(t *testing.T) {
 			expectedEvents: []*sports.Event{
 				{
 					Id:                  3,
-					MeetingId:           8,
+					SportId:             4,
+					CompetitionId:       8,
 					Name:                "Rhode Island ghosts",
 					Visible:             false,
 					Status:              "OPEN",
 					AdvertisedStartTime: timestamppb.New(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 				{
 					Id:                  2,
-					MeetingId:           1,
+					SportId:             1,
+					CompetitionId:       1,
 					Name:                "Connecticut griffins",
 					Visible:             true,
 					Status:              "OPEN",
 					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 				{
 					Id:                  1,
-					MeetingId:           5,
+					SportId:             2,
+					CompetitionId:       5,
 					Name:                "North Dakota foes",
 					Visible:             false,
 					Status:              "CLOSED",
 					AdvertisedStartTime: timestamppb.New(time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)),
+					Version:             1,
 				},
 			},
 		},
@@ -185,7 +280,7 @@ func TestEventsRepo_List(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// Call the List method with the filter
-			events, err := eventsRepo.List(tc.filter, tc.orderBy, getDateNow())
+			events, _, err := eventsRepo.List(context.Background(), tc.filter, tc.orderBy, 0, "", nil, getDateNow())
 			if err != nil {
 				t.Fatalf("failed to get events: %v", err)
 			}
@@ -201,6 +296,84 @@ func TestEventsRepo_List(t *testing.T) {
 	}
 }
 
+func TestEventsRepo_ListPagination(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	// Create a new EventsRepo using the test database
+	eventsRepo := NewEventsRepo(db)
+
+	// Initialize the test database with dummy data
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	orderBy := []*sports.ListEventsRequestOrderBy{
+		{FieldName: "advertisedStartTime", Direction: sports.OrderByDirection_DESC},
+	}
+
+	t.Run("PagesFollowOrderBy", func(t *testing.T) {
+		events, nextPageToken, err := eventsRepo.List(context.Background(), nil, orderBy, 2, "", nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get events: %v", err)
+		}
+		assert.Equal(t, []int64{3, 2}, eventIds(events))
+		assert.NotEmpty(t, nextPageToken)
+
+		events, nextPageToken, err = eventsRepo.List(context.Background(), nil, orderBy, 2, nextPageToken, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get events: %v", err)
+		}
+		assert.Equal(t, []int64{1}, eventIds(events))
+		assert.Empty(t, nextPageToken)
+	})
+
+	t.Run("PagesWithFilter", func(t *testing.T) {
+		filter := &sports.ListEventsRequestFilter{VisibilityStatus: sports.VisibilityStatus_HIDDEN}
+
+		events, nextPageToken, err := eventsRepo.List(context.Background(), filter, nil, 1, "", nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get events: %v", err)
+		}
+		assert.Equal(t, []int64{1}, eventIds(events))
+
+		events, nextPageToken, err = eventsRepo.List(context.Background(), filter, nil, 1, nextPageToken, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get events: %v", err)
+		}
+		assert.Equal(t, []int64{3}, eventIds(events))
+		assert.Empty(t, nextPageToken)
+	})
+
+	t.Run("LastPageIsFull", func(t *testing.T) {
+		events, nextPageToken, err := eventsRepo.List(context.Background(), nil, nil, 3, "", nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get events: %v", err)
+		}
+		assert.Len(t, events, 3)
+		assert.Empty(t, nextPageToken)
+	})
+
+	t.Run("InvalidPageToken", func(t *testing.T) {
+		_, _, err := eventsRepo.List(context.Background(), nil, orderBy, 2, "not a token", nil, getDateNow())
+		assert.ErrorIs(t, err, ErrInvalidPageToken)
+	})
+
+	t.Run("PageTokenForDifferentQuery", func(t *testing.T) {
+		_, nextPageToken, err := eventsRepo.List(context.Background(), nil, orderBy, 2, "", nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get events: %v", err)
+		}
+
+		_, _, err = eventsRepo.List(context.Background(), nil, nil, 2, nextPageToken, nil, getDateNow())
+		assert.ErrorIs(t, err, ErrInvalidPageToken)
+	})
+}
+
 func TestEventsRepo_Get(t *testing.T) {
 	// Open an in-memory SQLite database for testing
 	db, err := sql.Open("sqlite3", ":memory:")
@@ -219,7 +392,7 @@ func TestEventsRepo_Get(t *testing.T) {
 
 	t.Run("GetById", func(t *testing.T) {
 		// Call the List method with the filter
-		event, err := eventsRepo.Get(2, getDateNow())
+		event, err := eventsRepo.Get(context.Background(), 2, nil, getDateNow())
 		if err != nil {
 			t.Fatalf("failed to get events: %v", err)
 		}
@@ -227,38 +400,147 @@ func TestEventsRepo_Get(t *testing.T) {
 		// Compare if the right event was returned
 		expectedRace := sports.Event{
 			Id:                  2,
-			MeetingId:           1,
+			SportId:             1,
+			CompetitionId:       1,
 			Name:                "Connecticut griffins",
 			Visible:             true,
 			Status:              "OPEN",
 			AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
+			Version:             1,
 		}
 		assert.Equal(t, event, &expectedRace)
 	})
 
 	t.Run("GetByIdNotFound", func(t *testing.T) {
 		// Call the List method with the filter
-		_, err := eventsRepo.Get(999, getDateNow())
+		_, err := eventsRepo.Get(context.Background(), 999, nil, getDateNow())
 		if err != sql.ErrNoRows {
 			t.Fatalf("failed to get events: %v", err)
 		}
 	})
 }
 
+func TestEventsRepo_BatchGet(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	eventsRepo := NewEventsRepo(db)
+
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	t.Run("FoundOnly", func(t *testing.T) {
+		events, err := eventsRepo.BatchGet(context.Background(), []int64{3, 999, 1}, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get events: %v", err)
+		}
+
+		assert.ElementsMatch(t, []int64{1, 3}, eventIds(events))
+		for _, event := range events {
+			assert.NotNil(t, event.AdvertisedStartTime)
+		}
+	})
+
+	t.Run("NoIds", func(t *testing.T) {
+		events, err := eventsRepo.BatchGet(context.Background(), nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get events: %v", err)
+		}
+
+		assert.Empty(t, events)
+	})
+}
+
+func TestSeed_Clock(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	if err := migrateTestDB(db); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	// Start times are seeded from the day before the clock to two days after it
+	now := getDateNow()
+	if err := Seed(db, WithClock(clock.NewFake(now))); err != nil {
+		t.Fatalf("failed to seed test database: %v", err)
+	}
+
+	events, _, err := NewEventsRepo(db).List(context.Background(), nil, nil, 0, "", nil, now)
+	if err != nil {
+		t.Fatalf("failed to list events: %v", err)
+	}
+
+	assert.NotEmpty(t, events)
+	for _, event := range events {
+		startTime := event.AdvertisedStartTime.AsTime()
+		assert.False(t, startTime.Before(now.AddDate(0, 0, -1)), "event %d starts at %s", event.Id, startTime)
+		assert.False(t, startTime.After(now.AddDate(0, 0, 2)), "event %d starts at %s", event.Id, startTime)
+	}
+}
+
+func TestEventsRepo_ContextDone(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	eventsRepo := NewEventsRepo(db)
+
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	// Queries aren't run once the request they are for is cancelled or out of time
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
+	_, _, err = eventsRepo.List(cancelled, nil, nil, 0, "", nil, getDateNow())
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = eventsRepo.Get(expired, 2, nil, getDateNow())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	err = eventsRepo.Update(expired, &sports.Event{Id: 2, Name: "Renamed", Version: 1}, []string{"name"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	event, err := eventsRepo.Get(context.Background(), 2, nil, getDateNow())
+	if err != nil {
+		t.Fatalf("failed to get event: %v", err)
+	}
+	assert.NotEqual(t, "Renamed", event.Name)
+}
+
+// migrateTestDB creates the tables of a test database with the migrations.
+func migrateTestDB(db *sql.DB) error {
+	_, err := migrations.Up(db, Migrations(), dialect.SQLite)
+	return err
+}
+
 func initTestDB(db *sql.DB) error {
-	statement, err := db.Prepare(`CREATE TABLE IF NOT EXISTS events (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, visible INTEGER, advertised_start_time DATETIME)`)
-	if err == nil {
-		_, err = statement.Exec()
+	if err := migrateTestDB(db); err != nil {
+		return err
 	}
 
 	events := getAllTestData()
 
 	for _, s := range events {
-		statement, err = db.Prepare(`INSERT OR IGNORE INTO events(id, meeting_id, name, visible, advertised_start_time) VALUES (?,?,?,?,?)`)
+		statement, err := db.Prepare(`INSERT OR IGNORE INTO events(id, sport_id, competition_id, name, visible, advertised_start_time) VALUES (?,?,?,?,?,?)`)
 		if err == nil {
 			_, err = statement.Exec(
 				s.Id,
-				s.MeetingId,
+				s.SportId,
+				s.CompetitionId,
 				s.Name,
 				s.Visible,
 				s.AdvertisedStartTime.AsTime().Format(time.RFC3339),
@@ -269,25 +551,37 @@ func initTestDB(db *sql.DB) error {
 	return nil
 }
 
+// initTestSeededDB creates the tables of a test database and seeds the dummy data.
+func initTestSeededDB(db *sql.DB) error {
+	if err := migrateTestDB(db); err != nil {
+		return err
+	}
+
+	return Seed(db)
+}
+
 func getAllTestData() []*sports.Event {
 	return []*sports.Event{
 		{
 			Id:                  1,
-			MeetingId:           5,
+			SportId:             2,
+			CompetitionId:       5,
 			Name:                "North Dakota foes",
 			Visible:             false,
 			AdvertisedStartTime: timestamppb.New(time.Date(2022, 7, 15, 12, 0, 0, 0, time.UTC)),
 		},
 		{
 			Id:                  2,
-			MeetingId:           1,
+			SportId:             1,
+			CompetitionId:       1,
 			Name:                "Connecticut griffins",
 			Visible:             true,
 			AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
 		},
 		{
 			Id:                  3,
-			MeetingId:           8,
+			SportId:             4,
+			CompetitionId:       8,
 			Name:                "Rhode Island ghosts",
 			Visible:             false,
 			AdvertisedStartTime: timestamppb.New(time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)),
@@ -295,6 +589,421 @@ func getAllTestData() []*sports.Event {
 	}
 }
 
+func eventIds(events []*sports.Event) []int64 {
+	var ids []int64
+	for _, event := range events {
+		ids = append(ids, event.Id)
+	}
+	return ids
+}
+
 func getDateNow() time.Time {
 	return time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)
 }
+
+func TestEventsRepo_ContextCancelledWhileReading(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The request is cancelled once its query was sent, so it is only noticed while the events are read
+	db, err := instrument.OpenDB("sqlite3", ":memory:", func(queryCtx context.Context, query string) func(int64, error) {
+		if queryCtx == ctx {
+			cancel()
+		}
+		return func(int64, error) {}
+	})
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+	// Each connection would have a database of its own
+	db.SetMaxOpenConns(1)
+
+	eventsRepo := NewEventsRepo(db)
+
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	// The events read before the cancellation aren't returned as if they were all of them
+	events, _, err := eventsRepo.List(ctx, nil, nil, 0, "", nil, getDateNow())
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, events)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	event, err := eventsRepo.Get(ctx, 2, nil, getDateNow())
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, event)
+
+	// The connection is released, so the next requests still run
+	_, err = eventsRepo.Get(context.Background(), 2, nil, getDateNow())
+	assert.NoError(t, err)
+}
+
+func TestEventsRepo_Participants(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	// The dummy events are played between the participants of the dummy competitions
+	if err := initTestSeededDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	eventsRepo := NewEventsRepo(db)
+	if err := eventsRepo.Init(); err != nil {
+		t.Fatalf("failed to initialize events: %v", err)
+	}
+
+	events, _, err := eventsRepo.List(context.Background(), nil, nil, 0, "", nil, getDateNow())
+	if err != nil {
+		t.Fatalf("failed to list events: %v", err)
+	}
+
+	assert.Len(t, events, 100)
+	for _, event := range events {
+		if assert.Len(t, event.Participants, 2) {
+			home, away := event.Participants[0], event.Participants[1]
+
+			assert.Equal(t, sports.ParticipantSide_HOME, home.Side)
+			assert.Equal(t, sports.ParticipantSide_AWAY, away.Side)
+			assert.NotEqual(t, home.Id, away.Id)
+			assert.Equal(t, home.Name+" v "+away.Name, event.Name)
+		}
+	}
+}
+
+func TestEventsRepo_ListOrderBy(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	eventsRepo := NewEventsRepo(db)
+
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		orderBy     []*sports.ListEventsRequestOrderBy
+		expectedIDs []int64
+	}{
+		{
+			name: "MultipleFields",
+			orderBy: []*sports.ListEventsRequestOrderBy{
+				{FieldName: "visible", Direction: sports.OrderByDirection_DESC},
+				{FieldName: "name", Direction: sports.OrderByDirection_DESC},
+			},
+			expectedIDs: []int64{2, 3, 1},
+		},
+		{
+			name:        "SnakeCaseField",
+			orderBy:     []*sports.ListEventsRequestOrderBy{{FieldName: "sport_id", Direction: sports.OrderByDirection_DESC}},
+			expectedIDs: []int64{3, 1, 2},
+		},
+		{
+			name:        "DerivedStatus",
+			orderBy:     []*sports.ListEventsRequestOrderBy{{FieldName: "status", Direction: sports.OrderByDirection_DESC}},
+			expectedIDs: []int64{2, 3, 1},
+		},
+		{
+			name:        "IdDescending",
+			orderBy:     []*sports.ListEventsRequestOrderBy{{FieldName: "id", Direction: sports.OrderByDirection_DESC}},
+			expectedIDs: []int64{3, 2, 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Events are listed a page at a time, so the page tokens are checked for every order too
+			var (
+				ids       []int64
+				pageToken string
+			)
+			for {
+				events, nextPageToken, err := eventsRepo.List(context.Background(), nil, tc.orderBy, 1, pageToken, nil, getDateNow())
+				if err != nil {
+					t.Fatalf("failed to get events: %v", err)
+				}
+				for _, event := range events {
+					ids = append(ids, event.Id)
+				}
+				if nextPageToken == "" {
+					break
+				}
+				pageToken = nextPageToken
+			}
+
+			assert.Equal(t, tc.expectedIDs, ids)
+		})
+	}
+
+	t.Run("UnknownField", func(t *testing.T) {
+		_, _, err := eventsRepo.List(context.Background(), nil, []*sports.ListEventsRequestOrderBy{{FieldName: "startTime"}}, 0, "", nil, getDateNow())
+
+		var orderFieldErr *OrderFieldError
+		if assert.ErrorAs(t, err, &orderFieldErr) {
+			assert.Equal(t, "startTime", orderFieldErr.Field)
+			assert.Contains(t, err.Error(), "id, name, sportId, competitionId, visible, status, advertisedStartTime")
+		}
+	})
+}
+
+func TestEventsRepo_ReadMask(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	eventsRepo := NewEventsRepo(db)
+
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	t.Run("ListRequestedFieldsOnly", func(t *testing.T) {
+		readMask := &fieldmaskpb.FieldMask{Paths: []string{"name", "advertisedStartTime"}}
+
+		events, _, err := eventsRepo.List(context.Background(), nil, nil, 0, "", readMask, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get events: %v", err)
+		}
+
+		if assert.Len(t, events, 3) {
+			assert.Equal(t, "North Dakota foes", events[0].Name)
+			assert.NotNil(t, events[0].AdvertisedStartTime)
+			// The id is read to sort the events, but it isn't returned
+			assert.Zero(t, events[0].Id)
+			assert.Zero(t, events[0].SportId)
+			assert.Empty(t, events[0].Status)
+			assert.Empty(t, events[0].Participants)
+		}
+	})
+
+	t.Run("ListPagesByFieldsNotRequested", func(t *testing.T) {
+		var (
+			names     []string
+			pageToken string
+		)
+
+		readMask := &fieldmaskpb.FieldMask{Paths: []string{"name"}}
+		orderBy := []*sports.ListEventsRequestOrderBy{{FieldName: "status"}, {FieldName: "id", Direction: sports.OrderByDirection_DESC}}
+
+		for {
+			events, nextPageToken, err := eventsRepo.List(context.Background(), nil, orderBy, 1, pageToken, readMask, getDateNow())
+			if err != nil {
+				t.Fatalf("failed to get events: %v", err)
+			}
+			for _, event := range events {
+				names = append(names, event.Name)
+			}
+			if nextPageToken == "" {
+				break
+			}
+			pageToken = nextPageToken
+		}
+
+		assert.Equal(t, []string{"North Dakota foes", "Rhode Island ghosts", "Connecticut griffins"}, names)
+	})
+
+	t.Run("GetDerivedStatus", func(t *testing.T) {
+		event, err := eventsRepo.Get(context.Background(), 3, &fieldmaskpb.FieldMask{Paths: []string{"status"}}, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get event: %v", err)
+		}
+
+		assert.Equal(t, "OPEN", event.Status)
+		assert.Nil(t, event.AdvertisedStartTime)
+		assert.Empty(t, event.Name)
+	})
+
+	t.Run("GetEveryField", func(t *testing.T) {
+		event, err := eventsRepo.Get(context.Background(), 2, &fieldmaskpb.FieldMask{Paths: []string{"*"}}, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get event: %v", err)
+		}
+
+		assert.Equal(t, int64(2), event.Id)
+		assert.Equal(t, "Connecticut griffins", event.Name)
+		assert.NotNil(t, event.AdvertisedStartTime)
+	})
+
+	t.Run("UnknownPath", func(t *testing.T) {
+		_, err := eventsRepo.Get(context.Background(), 2, &fieldmaskpb.FieldMask{Paths: []string{"name", "markets.name"}}, getDateNow())
+
+		var readMaskErr *ReadMaskError
+		if assert.ErrorAs(t, err, &readMaskErr) {
+			assert.Equal(t, "markets.name", readMaskErr.Path)
+		}
+	})
+}
+
+func TestEventsRepo_Write(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	eventsRepo := NewEventsRepo(db)
+
+	if err := initTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	for _, query := range []string{
+		`INSERT INTO participants (id, competition_id, name) VALUES (1, 1, 'Arsenal'), (2, 1, 'Chelsea')`,
+		`INSERT INTO markets (id, event_id, name, type) VALUES (1, 2, 'Head to Head', 'HEAD_TO_HEAD'), (2, 3, 'Head to Head', 'HEAD_TO_HEAD')`,
+		`INSERT INTO selections (market_id, name, price) VALUES (1, 'Home', 1.8), (1, 'Away', 2.1), (2, 'Home', 1.5)`,
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("failed to initialize test database: %v", err)
+		}
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		id, err := eventsRepo.Create(context.Background(), &sports.Event{
+			SportId:             1,
+			CompetitionId:       1,
+			Name:                "Arsenal v Chelsea",
+			Visible:             true,
+			AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 16, 14, 30, 0, 0, time.UTC)),
+			Participants: []*sports.Participant{
+				{Id: 2, Side: sports.ParticipantSide_AWAY},
+				{Id: 1, Side: sports.ParticipantSide_HOME},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to create event: %v", err)
+		}
+
+		event, err := eventsRepo.Get(context.Background(), id, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get event: %v", err)
+		}
+
+		assert.Equal(t, int64(1), event.SportId)
+		assert.Equal(t, int64(1), event.CompetitionId)
+		assert.Equal(t, "Arsenal v Chelsea", event.Name)
+		assert.True(t, event.Visible)
+		assert.Equal(t, time.Date(2023, 7, 16, 14, 30, 0, 0, time.UTC), event.AdvertisedStartTime.AsTime())
+		assert.Equal(t, "OPEN", event.Status)
+		if assert.Len(t, event.Participants, 2) {
+			assert.Equal(t, "Arsenal", event.Participants[0].Name)
+			assert.Equal(t, sports.ParticipantSide_HOME, event.Participants[0].Side)
+			assert.Equal(t, "Chelsea", event.Participants[1].Name)
+			assert.Equal(t, sports.ParticipantSide_AWAY, event.Participants[1].Side)
+		}
+	})
+
+	t.Run("UpdateGivenFieldsOnly", func(t *testing.T) {
+		err := eventsRepo.Update(context.Background(), &sports.Event{Id: 2, Name: "Renamed", SportId: 99, Version: 1}, []string{"name"})
+		if err != nil {
+			t.Fatalf("failed to update event: %v", err)
+		}
+
+		event, err := eventsRepo.Get(context.Background(), 2, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get event: %v", err)
+		}
+
+		assert.Equal(t, "Renamed", event.Name)
+		assert.Equal(t, int64(1), event.SportId)
+	})
+
+	t.Run("UpdateParticipants", func(t *testing.T) {
+		err := eventsRepo.Update(context.Background(), &sports.Event{Id: 2, Participants: []*sports.Participant{{Id: 1, Side: sports.ParticipantSide_HOME}}, Version: 2}, []string{"participants"})
+		if err != nil {
+			t.Fatalf("failed to update event: %v", err)
+		}
+
+		event, err := eventsRepo.Get(context.Background(), 2, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get event: %v", err)
+		}
+
+		// The away side is cleared as it wasn't given
+		if assert.Len(t, event.Participants, 1) {
+			assert.Equal(t, int64(1), event.Participants[0].Id)
+			assert.Equal(t, sports.ParticipantSide_HOME, event.Participants[0].Side)
+		}
+	})
+
+	t.Run("UpdateAtVersion", func(t *testing.T) {
+		event, err := eventsRepo.Get(context.Background(), 3, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get event: %v", err)
+		}
+
+		if err := eventsRepo.Update(context.Background(), &sports.Event{Id: 3, Name: "First", Version: event.Version}, []string{"name"}); err != nil {
+			t.Fatalf("failed to update event: %v", err)
+		}
+
+		// The event was changed since it was read
+		err = eventsRepo.Update(context.Background(), &sports.Event{Id: 3, Name: "Second", Version: event.Version}, []string{"name"})
+		assert.Equal(t, ErrVersionChanged, err)
+
+		updated, err := eventsRepo.Get(context.Background(), 3, nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get event: %v", err)
+		}
+
+		assert.Equal(t, "First", updated.Name)
+		assert.Equal(t, event.Version+1, updated.Version)
+	})
+
+	t.Run("UpdateWithoutVersion", func(t *testing.T) {
+		// Events are always updated at a version, so one without it can't overwrite changes
+		err := eventsRepo.Update(context.Background(), &sports.Event{Id: 3, Name: "Unversioned"}, []string{"name"})
+		assert.Equal(t, ErrVersionChanged, err)
+	})
+
+	t.Run("UpdateNotFound", func(t *testing.T) {
+		err := eventsRepo.Update(context.Background(), &sports.Event{Id: 999, Name: "Renamed"}, []string{"name"})
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+
+	t.Run("UpdateNotFoundAtVersion", func(t *testing.T) {
+		err := eventsRepo.Update(context.Background(), &sports.Event{Id: 999, Name: "Renamed", Version: 1}, []string{"name"})
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+
+	t.Run("UpdateFieldNotWritable", func(t *testing.T) {
+		err := eventsRepo.Update(context.Background(), &sports.Event{Id: 2, Status: "CLOSED"}, []string{"status"})
+		assert.Error(t, err)
+	})
+
+	t.Run("DeleteWithMarketsAndSelections", func(t *testing.T) {
+		if err := eventsRepo.Delete(context.Background(), 2); err != nil {
+			t.Fatalf("failed to delete event: %v", err)
+		}
+
+		_, err := eventsRepo.Get(context.Background(), 2, nil, getDateNow())
+		assert.Equal(t, sql.ErrNoRows, err)
+
+		var markets, selections int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM markets`).Scan(&markets); err != nil {
+			t.Fatalf("failed to count markets: %v", err)
+		}
+		if err := db.QueryRow(`SELECT COUNT(*) FROM selections`).Scan(&selections); err != nil {
+			t.Fatalf("failed to count selections: %v", err)
+		}
+
+		// The markets of other events are kept
+		assert.Equal(t, 1, markets)
+		assert.Equal(t, 1, selections)
+	})
+
+	t.Run("DeleteNotFound", func(t *testing.T) {
+		assert.Equal(t, sql.ErrNoRows, eventsRepo.Delete(context.Background(), 999))
+	})
+}
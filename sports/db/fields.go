@@ -0,0 +1,201 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"git.neds.sh/matty/entain/common/order"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+// eventRow is an event as it is scanned from the selected columns.
+type eventRow struct {
+	event           sports.Event
+	advertisedStart time.Time
+	home, away      scannedParticipant
+}
+
+// eventColumn is a column of the events, along with where it is scanned to.
+type eventColumn struct {
+	name string
+	// sql selects the column, it is the name unless the column is looked up in another table.
+	sql  string
+	dest func(row *eventRow) interface{}
+}
+
+// eventColumns are the columns events can be read from, in the order they are selected. Participant names are
+// looked up with sub-queries rather than joins, so the columns events are filtered and sorted by don't need
+// to be qualified.
+var eventColumns = []eventColumn{
+	{name: "id", dest: func(row *eventRow) interface{} { return &row.event.Id }},
+	{name: "sport_id", dest: func(row *eventRow) interface{} { return &row.event.SportId }},
+	{name: "competition_id", dest: func(row *eventRow) interface{} { return &row.event.CompetitionId }},
+	{name: "name", dest: func(row *eventRow) interface{} { return &row.event.Name }},
+	{name: "visible", dest: func(row *eventRow) interface{} { return &row.event.Visible }},
+	{name: "advertised_start_time", dest: func(row *eventRow) interface{} { return &row.advertisedStart }},
+	{name: "home_participant_id", dest: func(row *eventRow) interface{} { return &row.home.id }},
+	{
+		name: "home_participant_name",
+		sql:  "(SELECT name FROM participants WHERE participants.id = events.home_participant_id)",
+		dest: func(row *eventRow) interface{} { return &row.home.name },
+	},
+	{name: "away_participant_id", dest: func(row *eventRow) interface{} { return &row.away.id }},
+	{
+		name: "away_participant_name",
+		sql:  "(SELECT name FROM participants WHERE participants.id = events.away_participant_id)",
+		dest: func(row *eventRow) interface{} { return &row.away.name },
+	},
+	{name: "version", dest: func(row *eventRow) interface{} { return &row.event.Version }},
+}
+
+// readFields has the columns each field of an event is read from, as named in read masks. Statuses are derived
+// from the advertised start time, and markets are read separately. Paths are matched ignoring case and
+// underscores, so both advertisedStartTime and advertised_start_time are accepted.
+var readFields = map[string][]string{
+	"id":                    {"id"},
+	"name":                  {"name"},
+	"visible":               {"visible"},
+	"advertised_start_time": {"advertised_start_time"},
+	"status":                {"advertised_start_time"},
+	"sport_id":              {"sport_id"},
+	"competition_id":        {"competition_id"},
+	"participants":          {"home_participant_id", "home_participant_name", "away_participant_id", "away_participant_name"},
+	"markets":               nil,
+	"version":               {"version"},
+}
+
+// ReadMaskError is returned when a read mask has a path that isn't a field of an event.
+type ReadMaskError struct {
+	// Path is the requested path.
+	Path string
+}
+
+func (e *ReadMaskError) Error() string {
+	return fmt.Sprintf("read_mask path %q is not a field of an event", e.Path)
+}
+
+// findReadField returns the name of the field of an event matching a read mask path, or "" if there is none.
+func findReadField(path string) string {
+	normalised := order.NormaliseName(path)
+
+	for field := range readFields {
+		if order.NormaliseName(field) == normalised {
+			return field
+		}
+	}
+
+	return ""
+}
+
+// readSelection has the columns selected for a read mask, and the fields the events are returned with.
+type readSelection struct {
+	columns []eventColumn
+	// fields is nil when every field is returned.
+	fields map[string]bool
+}
+
+// newReadSelection selects the columns of the fields in the read mask. Every field is read when the mask
+// is empty or has "*". The id, and the fields in extra, are always selected as they are needed to read
+// the events, but they are only returned if they are in the mask.
+func newReadSelection(readMask *fieldmaskpb.FieldMask, extra ...string) (*readSelection, error) {
+	selection := &readSelection{}
+
+	paths := readMask.GetPaths()
+	for _, path := range paths {
+		if path == "*" {
+			paths = nil
+			break
+		}
+	}
+
+	if len(paths) == 0 {
+		selection.columns = eventColumns
+		return selection, nil
+	}
+
+	selected := map[string]bool{"id": true}
+	selection.fields = make(map[string]bool, len(paths))
+
+	for _, path := range paths {
+		field := findReadField(path)
+		if field == "" {
+			return nil, &ReadMaskError{Path: path}
+		}
+
+		selection.fields[field] = true
+		for _, column := range readFields[field] {
+			selected[column] = true
+		}
+	}
+
+	for _, field := range extra {
+		for _, column := range readFields[field] {
+			selected[column] = true
+		}
+	}
+
+	for _, column := range eventColumns {
+		if selected[column.name] {
+			selection.columns = append(selection.columns, column)
+		}
+	}
+
+	return selection, nil
+}
+
+// query returns the query selecting the columns from the events.
+func (s *readSelection) query() string {
+	names := make([]string, 0, len(s.columns))
+	for _, column := range s.columns {
+		if column.sql != "" {
+			names = append(names, column.sql)
+		} else {
+			names = append(names, column.name)
+		}
+	}
+
+	return fmt.Sprintf(getEventsQueries()[eventsList], strings.Join(names, ", "))
+}
+
+// prune clears the fields of the events that weren't requested.
+func (s *readSelection) prune(events []*sports.Event) {
+	if s.fields == nil {
+		return
+	}
+
+	for _, event := range events {
+		msg := event.ProtoReflect()
+
+		var cleared []protoreflect.FieldDescriptor
+		msg.Range(func(field protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+			if !s.fields[string(field.Name())] {
+				cleared = append(cleared, field)
+			}
+			return true
+		})
+
+		for _, field := range cleared {
+			msg.Clear(field)
+		}
+	}
+}
+
+// ReadsField reports whether a field of an event is returned for a read mask.
+func ReadsField(readMask *fieldmaskpb.FieldMask, field string) bool {
+	paths := readMask.GetPaths()
+	if len(paths) == 0 {
+		return true
+	}
+
+	for _, path := range paths {
+		if path == "*" || findReadField(path) == field {
+			return true
+		}
+	}
+
+	return false
+}
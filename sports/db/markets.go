@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+// MarketsRepo provides repository access to markets and their selections.
+type MarketsRepo interface {
+	// Init will initialise our markets repository.
+	Init() error
+
+	// List will return the markets of an event with their selections, ordered by id.
+	List(ctx context.Context, eventID int64) ([]*sports.Market, error)
+}
+
+type marketsRepo struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+}
+
+// NewMarketsRepo creates a new markets repository.
+func NewMarketsRepo(db *sql.DB, opts ...Option) MarketsRepo {
+	return &marketsRepo{db: db, dialect: newOptions(opts).dialect}
+}
+
+// Init prepares the markets repository. Its tables are created by the migrations.
+func (r *marketsRepo) Init() error {
+	return nil
+}
+
+// List Returns the markets of an event
+func (r *marketsRepo) List(ctx context.Context, eventID int64) ([]*sports.Market, error) {
+	query := getMarketsQueries()[marketsList]
+	query += " WHERE event_id = ? ORDER BY id"
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(query), eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	markets, err := r.scanMarkets(rows)
+	if err != nil || len(markets) == 0 {
+		return markets, err
+	}
+
+	// The selections of every market are fetched at once, rather than a query per market
+	query = getMarketsQueries()[selectionsList]
+	query += " WHERE market_id IN (SELECT id FROM markets WHERE event_id = ?) ORDER BY id"
+
+	rows, err = r.db.QueryContext(ctx, r.dialect.Rebind(query), eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	selections, err := r.scanSelections(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*sports.Market, len(markets))
+	for _, market := range markets {
+		byID[market.Id] = market
+	}
+
+	for _, selection := range selections {
+		if market, ok := byID[selection.MarketId]; ok {
+			market.Selections = append(market.Selections, selection)
+		}
+	}
+
+	return markets, nil
+}
+
+func (r *marketsRepo) scanMarkets(rows *sql.Rows) ([]*sports.Market, error) {
+	defer rows.Close()
+
+	var markets []*sports.Market
+
+	for rows.Next() {
+		var market sports.Market
+		var marketType string
+
+		if err := rows.Scan(&market.Id, &market.EventId, &market.Name, &marketType, &market.Line); err != nil {
+			return nil, err
+		}
+
+		market.Type = sports.MarketType(sports.MarketType_value[marketType])
+		markets = append(markets, &market)
+	}
+
+	return markets, rows.Err()
+}
+
+func (r *marketsRepo) scanSelections(rows *sql.Rows) ([]*sports.Selection, error) {
+	defer rows.Close()
+
+	var selections []*sports.Selection
+
+	for rows.Next() {
+		var selection sports.Selection
+		var participantID sql.NullInt64
+
+		if err := rows.Scan(&selection.Id, &selection.MarketId, &selection.Name, &selection.Price, &participantID); err != nil {
+			return nil, err
+		}
+
+		selection.ParticipantId = participantID.Int64
+		selections = append(selections, &selection)
+	}
+
+	return selections, rows.Err()
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMarketsRepo_List(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	// Markets are seeded for the dummy events
+	if err := initTestSeededDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	eventsRepo := NewEventsRepo(db)
+	marketsRepo := NewMarketsRepo(db)
+
+	events, _, err := eventsRepo.List(context.Background(), nil, nil, 0, "", nil, getDateNow())
+	if err != nil {
+		t.Fatalf("failed to list events: %v", err)
+	}
+
+	for _, event := range events {
+		markets, err := marketsRepo.List(context.Background(), event.Id)
+		if err != nil {
+			t.Fatalf("failed to list markets: %v", err)
+		}
+
+		if !assert.Len(t, markets, 3) {
+			continue
+		}
+
+		home, away := event.Participants[0], event.Participants[1]
+
+		headToHead := markets[0]
+		assert.Equal(t, sports.MarketType_HEAD_TO_HEAD, headToHead.Type)
+		if event.SportId == 1 {
+			// Soccer can be drawn
+			assert.Len(t, headToHead.Selections, 3)
+		} else {
+			assert.Len(t, headToHead.Selections, 2)
+		}
+		assert.Equal(t, home.Id, headToHead.Selections[0].ParticipantId)
+		assert.Equal(t, away.Id, headToHead.Selections[len(headToHead.Selections)-1].ParticipantId)
+
+		line := markets[1]
+		assert.Equal(t, sports.MarketType_LINE, line.Type)
+		assert.NotZero(t, line.Line)
+		assert.Equal(t, []int64{home.Id, away.Id}, []int64{line.Selections[0].ParticipantId, line.Selections[1].ParticipantId})
+
+		totals := markets[2]
+		assert.Equal(t, sports.MarketType_TOTALS, totals.Type)
+		assert.Len(t, totals.Selections, 2)
+
+		for _, market := range markets {
+			assert.Equal(t, event.Id, market.EventId)
+			for _, selection := range market.Selections {
+				assert.Equal(t, market.Id, selection.MarketId)
+				assert.Greater(t, selection.Price, 1.0)
+			}
+		}
+	}
+
+	t.Run("EventWithoutMarkets", func(t *testing.T) {
+		markets, err := marketsRepo.List(context.Background(), 999)
+		if err != nil {
+			t.Fatalf("failed to list markets: %v", err)
+		}
+
+		assert.Empty(t, markets)
+	})
+}
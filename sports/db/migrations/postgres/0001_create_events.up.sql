@@ -0,0 +1,2 @@
+-- Flags are stored as 0 or 1 and times as timestamps, so the rows are read the same way as from SQLite.
+CREATE TABLE IF NOT EXISTS events (id BIGSERIAL PRIMARY KEY, meeting_id BIGINT, name TEXT, visible INTEGER, advertised_start_time TIMESTAMPTZ);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS sports (id BIGSERIAL PRIMARY KEY, name TEXT);
+
+CREATE TABLE IF NOT EXISTS competitions (id BIGSERIAL PRIMARY KEY, sport_id BIGINT, name TEXT, country TEXT);
+
+CREATE TABLE IF NOT EXISTS participants (id BIGSERIAL PRIMARY KEY, competition_id BIGINT, name TEXT);
+
+-- Events belong to a competition of a sport and are played between two of its participants, rather than at a meeting
+ALTER TABLE events ADD COLUMN sport_id BIGINT;
+ALTER TABLE events ADD COLUMN competition_id BIGINT;
+ALTER TABLE events ADD COLUMN home_participant_id BIGINT;
+ALTER TABLE events ADD COLUMN away_participant_id BIGINT;
+ALTER TABLE events DROP COLUMN meeting_id;
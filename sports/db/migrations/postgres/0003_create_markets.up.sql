@@ -0,0 +1,3 @@
+CREATE TABLE IF NOT EXISTS markets (id BIGSERIAL PRIMARY KEY, event_id BIGINT, name TEXT, type TEXT, line DOUBLE PRECISION);
+
+CREATE TABLE IF NOT EXISTS selections (id BIGSERIAL PRIMARY KEY, market_id BIGINT, name TEXT, price DOUBLE PRECISION, participant_id BIGINT);
@@ -0,0 +1,2 @@
+-- Events are listed by start time ranges, e.g. the next hour of sports
+CREATE INDEX IF NOT EXISTS events_advertised_start_time ON events (advertised_start_time);
@@ -0,0 +1,2 @@
+-- The events table is only created when it doesn't exist, so databases created before the migrations are taken over as is.
+CREATE TABLE IF NOT EXISTS events (id INTEGER PRIMARY KEY, meeting_id INTEGER, name TEXT, visible INTEGER, advertised_start_time DATETIME);
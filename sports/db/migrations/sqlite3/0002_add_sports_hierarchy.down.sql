@@ -0,0 +1,9 @@
+ALTER TABLE events ADD COLUMN meeting_id INTEGER;
+ALTER TABLE events DROP COLUMN away_participant_id;
+ALTER TABLE events DROP COLUMN home_participant_id;
+ALTER TABLE events DROP COLUMN competition_id;
+ALTER TABLE events DROP COLUMN sport_id;
+
+DROP TABLE IF EXISTS participants;
+DROP TABLE IF EXISTS competitions;
+DROP TABLE IF EXISTS sports;
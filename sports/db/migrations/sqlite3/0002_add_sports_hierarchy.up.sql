@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS sports (id INTEGER PRIMARY KEY, name TEXT);
+
+CREATE TABLE IF NOT EXISTS competitions (id INTEGER PRIMARY KEY, sport_id INTEGER, name TEXT, country TEXT);
+
+CREATE TABLE IF NOT EXISTS participants (id INTEGER PRIMARY KEY, competition_id INTEGER, name TEXT);
+
+-- Events belong to a competition of a sport and are played between two of its participants, rather than at a meeting
+ALTER TABLE events ADD COLUMN sport_id INTEGER;
+ALTER TABLE events ADD COLUMN competition_id INTEGER;
+ALTER TABLE events ADD COLUMN home_participant_id INTEGER;
+ALTER TABLE events ADD COLUMN away_participant_id INTEGER;
+ALTER TABLE events DROP COLUMN meeting_id;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS selections;
+DROP TABLE IF EXISTS markets;
@@ -0,0 +1,3 @@
+CREATE TABLE IF NOT EXISTS markets (id INTEGER PRIMARY KEY, event_id INTEGER, name TEXT, type TEXT, line REAL);
+
+CREATE TABLE IF NOT EXISTS selections (id INTEGER PRIMARY KEY, market_id INTEGER, name TEXT, price REAL, participant_id INTEGER);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS events_advertised_start_time;
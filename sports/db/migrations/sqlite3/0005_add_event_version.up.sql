@@ -0,0 +1,2 @@
+-- Events are updated at the version they were read at, so concurrent changes aren't overwritten
+ALTER TABLE events ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
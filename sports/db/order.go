@@ -0,0 +1,73 @@
+package db
+
+import (
+	"time"
+
+	"git.neds.sh/matty/entain/common/order"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+// orderFields is the whitelist of fields events can be sorted by.
+var orderFields = order.Fields{
+	{
+		Name:   "id",
+		Column: "id",
+		Value: eventValue(func(event *sports.Event) interface{} {
+			return event.Id
+		}),
+	},
+	{
+		Name:   "name",
+		Column: "name",
+		Value: eventValue(func(event *sports.Event) interface{} {
+			return event.Name
+		}),
+	},
+	{
+		Name:   "sportId",
+		Column: "sport_id",
+		Value: eventValue(func(event *sports.Event) interface{} {
+			return event.SportId
+		}),
+	},
+	{
+		Name:   "competitionId",
+		Column: "competition_id",
+		Value: eventValue(func(event *sports.Event) interface{} {
+			return event.CompetitionId
+		}),
+	},
+	{
+		Name:   "visible",
+		Column: "visible",
+		Value: eventValue(func(event *sports.Event) interface{} {
+			return storedFlag(event.Visible)
+		}),
+	},
+	{
+		// Statuses are derived from the advertised start time, so CLOSED events are sorted first
+		Name:       "status",
+		Column:     "status",
+		Expression: eventStatus,
+		Value: eventValue(func(event *sports.Event) interface{} {
+			return event.Status
+		}),
+	},
+	{
+		Name:   "advertisedStartTime",
+		Column: "advertised_start_time",
+		Value: eventValue(func(event *sports.Event) interface{} {
+			return event.AdvertisedStartTime.AsTime().UTC().Format(time.RFC3339)
+		}),
+	},
+}
+
+// eventValue adapts a function returning the value of a field for a event to the order package.
+func eventValue(value func(event *sports.Event) interface{}) func(entity interface{}) interface{} {
+	return func(entity interface{}) interface{} {
+		return value(entity.(*sports.Event))
+	}
+}
+
+// OrderFieldError is returned when events are sorted by a field that isn't in the whitelist.
+type OrderFieldError = order.FieldError
@@ -1,19 +1,74 @@
 package db
 
 const (
-	eventsList = "list"
+	eventsList       = "list"
+	sportsList       = "list"
+	competitionsList = "list"
+	participantsList = "participants"
+	marketsList      = "list"
+	selectionsList   = "selections"
 )
 
 func getEventsQueries() map[string]string {
 	return map[string]string{
+		// The columns are selected according to the read mask, see readSelection
 		eventsList: `
+			SELECT 
+				%s 
+			FROM events
+		`,
+	}
+}
+
+func getSportsQueries() map[string]string {
+	return map[string]string{
+		sportsList: `
 			SELECT 
 				id, 
-				meeting_id, 
+				name 
+			FROM sports
+		`,
+	}
+}
+
+func getCompetitionsQueries() map[string]string {
+	return map[string]string{
+		competitionsList: `
+			SELECT 
+				id, 
+				sport_id, 
 				name, 
-				visible, 
-				advertised_start_time 
-			FROM events
+				country 
+			FROM competitions
+		`,
+		participantsList: `
+			SELECT 
+				id, 
+				name 
+			FROM participants
+		`,
+	}
+}
+
+func getMarketsQueries() map[string]string {
+	return map[string]string{
+		marketsList: `
+			SELECT 
+				id, 
+				event_id, 
+				name, 
+				type, 
+				line 
+			FROM markets
+		`,
+		selectionsList: `
+			SELECT 
+				id, 
+				market_id, 
+				name, 
+				price, 
+				participant_id 
+			FROM selections
 		`,
 	}
 }
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+// Matched words are wrapped in highlightStart and highlightEnd in the search snippets.
+const (
+	highlightStart = "<mark>"
+	highlightEnd   = "</mark>"
+)
+
+// eventsLikeSearch selects the names of the events, to rank the ones matched with LIKE.
+const eventsLikeSearch = `
+	SELECT
+		id,
+		name
+	FROM events
+`
+
+// eventMatch is an event found by a search, before the event itself is read.
+type eventMatch struct {
+	id          int64
+	score       float64
+	nameSnippet string
+}
+
+// searchTerms splits a search query into its words in lower case, ignoring punctuation.
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Search Returns the events matching a query, the most relevant first
+func (r *eventsRepo) Search(ctx context.Context, query string, limit int32, currentDate time.Time) ([]*sports.EventSearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	matches, err := r.searchMatches(ctx, terms, limit)
+	if err != nil || len(matches) == 0 {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, match.id)
+	}
+
+	events, err := r.BatchGet(ctx, ids, currentDate)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*sports.Event, len(events))
+	for _, event := range events {
+		byID[event.Id] = event
+	}
+
+	results := make([]*sports.EventSearchResult, 0, len(matches))
+	for _, match := range matches {
+		event, ok := byID[match.id]
+		if !ok {
+			continue
+		}
+
+		results = append(results, &sports.EventSearchResult{
+			Event:       event,
+			Score:       match.score,
+			NameSnippet: match.nameSnippet,
+		})
+	}
+
+	return results, nil
+}
+
+// likeSearchClause returns the condition selecting the events matching every term anywhere in their names.
+func (r *eventsRepo) likeSearchClause(terms []string) (string, []interface{}) {
+	var (
+		clauses []string
+		args    []interface{}
+	)
+
+	for _, term := range terms {
+		clauses = append(clauses, "name "+r.dialect.Like()+" ?")
+		args = append(args, "%"+term+"%")
+	}
+
+	return strings.Join(clauses, " AND "), args
+}
+
+// likeSearchMatches returns the events matching every term anywhere in their names. Events are ranked by how many
+// times the terms match.
+func (r *eventsRepo) likeSearchMatches(ctx context.Context, terms []string, limit int32) ([]*eventMatch, error) {
+	clause, args := r.likeSearchClause(terms)
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.Rebind(eventsLikeSearch+" WHERE "+clause), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pattern := termsPattern(terms)
+
+	var matches []*eventMatch
+	for rows.Next() {
+		var (
+			match eventMatch
+			name  string
+		)
+
+		if err := rows.Scan(&match.id, &name); err != nil {
+			return nil, err
+		}
+
+		match.score = float64(len(pattern.FindAllStringIndex(name, -1)))
+		match.nameSnippet = pattern.ReplaceAllString(name, highlightStart+"$0"+highlightEnd)
+		matches = append(matches, &match)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].id < matches[j].id
+	})
+
+	if len(matches) > int(limit) {
+		matches = matches[:limit]
+	}
+
+	return matches, nil
+}
+
+// termsPattern returns the case insensitive pattern matching any of the terms.
+func termsPattern(terms []string) *regexp.Regexp {
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, regexp.QuoteMeta(term))
+	}
+
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
@@ -0,0 +1,99 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package db
+
+import (
+	"context"
+	"strings"
+
+	"git.neds.sh/matty/entain/common/db/dialect"
+)
+
+// searchIndexQueries create the full-text index of the events, along with the triggers keeping it up to date.
+var searchIndexQueries = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS events_search USING fts5(name, tokenize = 'unicode61 remove_diacritics 2')`,
+	`CREATE TRIGGER IF NOT EXISTS events_search_insert AFTER INSERT ON events BEGIN
+		INSERT INTO events_search(rowid, name) VALUES (new.id, new.name);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS events_search_update AFTER UPDATE OF name ON events BEGIN
+		DELETE FROM events_search WHERE rowid = old.id;
+		INSERT INTO events_search(rowid, name) VALUES (new.id, new.name);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS events_search_delete AFTER DELETE ON events BEGIN
+		DELETE FROM events_search WHERE rowid = old.id;
+	END`,
+	// Events stored before the triggers existed are indexed again
+	`DELETE FROM events_search`,
+	`INSERT INTO events_search(rowid, name) SELECT id, name FROM events`,
+}
+
+const eventsSearch = `
+	SELECT
+		rowid,
+		-bm25(events_search),
+		snippet(events_search, 0, '` + highlightStart + `', '` + highlightEnd + `', '…', 16)
+	FROM events_search
+	WHERE events_search MATCH ?
+	ORDER BY bm25(events_search), rowid
+	LIMIT ?
+`
+
+// initSearch creates the full-text index of the events.
+func (r *eventsRepo) initSearch() error {
+	// FTS5 is only in SQLite, other databases are searched with LIKE
+	if r.dialect != dialect.SQLite {
+		return nil
+	}
+
+	for _, query := range searchIndexQueries {
+		if _, err := r.db.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matchExpression returns the full-text query matching every term at the start of a word.
+func matchExpression(terms []string) string {
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+term+`"*`)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// searchClause returns the condition selecting the events matching every term.
+func (r *eventsRepo) searchClause(terms []string) (string, []interface{}) {
+	if r.dialect != dialect.SQLite {
+		return r.likeSearchClause(terms)
+	}
+
+	return "id IN (SELECT rowid FROM events_search WHERE events_search MATCH ?)", []interface{}{matchExpression(terms)}
+}
+
+// searchMatches returns the events matching every term, ranked by relevance.
+func (r *eventsRepo) searchMatches(ctx context.Context, terms []string, limit int32) ([]*eventMatch, error) {
+	if r.dialect != dialect.SQLite {
+		return r.likeSearchMatches(ctx, terms, limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, eventsSearch, matchExpression(terms), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []*eventMatch
+	for rows.Next() {
+		var match eventMatch
+		if err := rows.Scan(&match.id, &match.score, &match.nameSnippet); err != nil {
+			return nil, err
+		}
+		matches = append(matches, &match)
+	}
+
+	return matches, rows.Err()
+}
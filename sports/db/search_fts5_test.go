@@ -0,0 +1,73 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEventsRepo_SearchFTS5(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	eventsRepo, err := initTestSearchDB(db)
+	if err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	search := func(query string) ([]int64, []string) {
+		results, err := eventsRepo.Search(context.Background(), query, 10, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to search events: %v", err)
+		}
+
+		var (
+			ids      []int64
+			snippets []string
+		)
+		for _, result := range results {
+			ids = append(ids, result.Event.Id)
+			snippets = append(snippets, result.NameSnippet)
+		}
+		return ids, snippets
+	}
+
+	t.Run("Snippets", func(t *testing.T) {
+		_, snippets := search("conn griff")
+		assert.Equal(t, []string{"<mark>Connecticut</mark> <mark>griffins</mark>"}, snippets)
+	})
+
+	t.Run("ShorterNamesRankedFirst", func(t *testing.T) {
+		// The new event is indexed by the insert trigger
+		if _, err := db.Exec(`INSERT INTO events(id, sport_id, competition_id, name, visible, advertised_start_time) VALUES (4, 1, 1, 'Connecticut griffins v Rhode Island ghosts', 1, '2023-07-15T13:00:00Z')`); err != nil {
+			t.Fatalf("failed to insert event: %v", err)
+		}
+
+		ids, _ := search("griffins")
+		assert.Equal(t, []int64{2, 4}, ids)
+	})
+
+	t.Run("IndexFollowsUpdates", func(t *testing.T) {
+		if _, err := db.Exec(`UPDATE events SET name = 'Rhode Island ghosts v North Dakota foes' WHERE id = 4`); err != nil {
+			t.Fatalf("failed to update event: %v", err)
+		}
+
+		ids, _ := search("griffins")
+		assert.Equal(t, []int64{2}, ids)
+
+		if _, err := db.Exec(`DELETE FROM events WHERE id = 2`); err != nil {
+			t.Fatalf("failed to delete event: %v", err)
+		}
+
+		ids, _ = search("griffins")
+		assert.Empty(t, ids)
+	})
+}
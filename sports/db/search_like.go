@@ -0,0 +1,39 @@
+//go:build !sqlite_fts5
+// +build !sqlite_fts5
+
+package db
+
+import (
+	"context"
+	"git.neds.sh/matty/entain/common/db/dialect"
+)
+
+// searchTriggers keep the full-text index up to date when built with FTS5. SQLite only has FTS5 when built with the
+// sqlite_fts5 tag, so without it the events are searched with LIKE instead.
+var searchTriggers = []string{"events_search_insert", "events_search_update", "events_search_delete"}
+
+// initSearch drops the triggers of the full-text index, as the database may have been indexed by a build with
+// FTS5 and the events can't be written while they exist. The index is rebuilt the next time FTS5 is used.
+func (r *eventsRepo) initSearch() error {
+	if r.dialect != dialect.SQLite {
+		return nil
+	}
+
+	for _, trigger := range searchTriggers {
+		if _, err := r.db.Exec(`DROP TRIGGER IF EXISTS ` + trigger); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// searchClause returns the condition selecting the events matching every term.
+func (r *eventsRepo) searchClause(terms []string) (string, []interface{}) {
+	return r.likeSearchClause(terms)
+}
+
+// searchMatches returns the events matching every term, ranked by relevance.
+func (r *eventsRepo) searchMatches(ctx context.Context, terms []string, limit int32) ([]*eventMatch, error) {
+	return r.likeSearchMatches(ctx, terms, limit)
+}
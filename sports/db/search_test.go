@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEventsRepo_Search(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	eventsRepo, err := initTestSearchDB(db)
+	if err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		query       string
+		expectedIds []int64
+	}{
+		{
+			name:        "EventName",
+			query:       "griffins",
+			expectedIds: []int64{2},
+		},
+		{
+			name:        "IgnoresCase",
+			query:       "CONNECTICUT",
+			expectedIds: []int64{2},
+		},
+		{
+			name:        "StartOfWord",
+			query:       "ghost",
+			expectedIds: []int64{3},
+		},
+		{
+			name:        "EveryWord",
+			query:       "north, dakota!",
+			expectedIds: []int64{1},
+		},
+		{
+			name:  "NotEveryWordMatched",
+			query: "north ghosts",
+		},
+		{
+			name:  "NoWords",
+			query: "!!",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := eventsRepo.Search(context.Background(), tc.query, 10, getDateNow())
+			if err != nil {
+				t.Fatalf("failed to search events: %v", err)
+			}
+
+			var ids []int64
+			for _, result := range results {
+				ids = append(ids, result.Event.Id)
+				assert.Greater(t, result.Score, 0.0)
+			}
+			assert.Equal(t, tc.expectedIds, ids)
+		})
+	}
+
+	t.Run("EventIsReturned", func(t *testing.T) {
+		results, err := eventsRepo.Search(context.Background(), "griffins", 10, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to search events: %v", err)
+		}
+
+		assert.Equal(t, getAllTestData()[1].Name, results[0].Event.Name)
+		assert.Equal(t, "OPEN", results[0].Event.Status)
+		assert.Contains(t, results[0].NameSnippet, highlightStart)
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		results, err := eventsRepo.Search(context.Background(), "g", 1, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to search events: %v", err)
+		}
+
+		assert.Len(t, results, 1)
+	})
+
+	t.Run("ListFilter", func(t *testing.T) {
+		events, _, err := eventsRepo.List(context.Background(), &sports.ListEventsRequestFilter{Search: "ghosts"}, nil, 0, "", nil, getDateNow())
+		if err != nil {
+			t.Fatalf("failed to get events: %v", err)
+		}
+
+		assert.Equal(t, []int64{3}, eventIds(events))
+	})
+}
+
+// initTestSearchDB creates the test events and indexes them for searching.
+func initTestSearchDB(db *sql.DB) (*eventsRepo, error) {
+	if err := initTestDB(db); err != nil {
+		return nil, err
+	}
+
+	eventsRepo := &eventsRepo{db: db}
+	return eventsRepo, eventsRepo.initSearch()
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+)
+
+// SportsRepo provides repository access to sports.
+type SportsRepo interface {
+	// Init will initialise our sports repository.
+	Init() error
+
+	// List will return every sport, ordered by name.
+	List(ctx context.Context) ([]*sports.Sport, error)
+}
+
+type sportsRepo struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+}
+
+// NewSportsRepo creates a new sports repository.
+func NewSportsRepo(db *sql.DB, opts ...Option) SportsRepo {
+	return &sportsRepo{db: db, dialect: newOptions(opts).dialect}
+}
+
+// Init prepares the sports repository. Its tables are created by the migrations.
+func (r *sportsRepo) Init() error {
+	return nil
+}
+
+// List Returns every sport
+func (r *sportsRepo) List(ctx context.Context) ([]*sports.Sport, error) {
+	query := getSportsQueries()[sportsList]
+	query += " ORDER BY name"
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*sports.Sport
+
+	for rows.Next() {
+		var sport sports.Sport
+
+		if err := rows.Scan(&sport.Id, &sport.Name); err != nil {
+			return nil, err
+		}
+
+		result = append(result, &sport)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSportsRepo_List(t *testing.T) {
+	// Open an in-memory SQLite database for testing
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	// Only the dummy sports are seeded
+	if err := migrateTestDB(db); err != nil {
+		t.Fatalf("failed to initialize test database: %v", err)
+	}
+	if err := (&sportsRepo{db: db}).seed(); err != nil {
+		t.Fatalf("failed to initialize sports: %v", err)
+	}
+	sportsRepo := NewSportsRepo(db)
+
+	result, err := sportsRepo.List(context.Background())
+	if err != nil {
+		t.Fatalf("failed to list sports: %v", err)
+	}
+
+	assert.Equal(t, []*sports.Sport{
+		{Id: 2, Name: "Australian Rules"},
+		{Id: 3, Name: "Basketball"},
+		{Id: 5, Name: "Rugby League"},
+		{Id: 1, Name: "Soccer"},
+		{Id: 4, Name: "Tennis"},
+	}, result)
+}
@@ -3,22 +3,59 @@ package main
 import (
 	"database/sql"
 	"flag"
+	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
 
+	"git.neds.sh/matty/entain/common/clock"
+	"git.neds.sh/matty/entain/common/db/dialect"
+	"git.neds.sh/matty/entain/common/db/instrument"
+	"git.neds.sh/matty/entain/common/db/migrations"
+	"git.neds.sh/matty/entain/common/interceptor"
+	"git.neds.sh/matty/entain/common/tracing"
 	"git.neds.sh/matty/entain/sports/db"
 	"git.neds.sh/matty/entain/sports/proto/sports"
 	"git.neds.sh/matty/entain/sports/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 )
 
 var (
-	grpcEndpoint = flag.String("grpc-sports-endpoint", "localhost:9001", "gRPC sports server endpoint")
+	dbDriver        = flag.String("db-driver", "sqlite3", "Driver of the sports database, sqlite3 or postgres")
+	dbDSN           = flag.String("db-dsn", "./db/sports.db", "Data source name of the sports database, a file path for sqlite3 or a connection string for postgres")
+	grpcEndpoint    = flag.String("grpc-sports-endpoint", "localhost:9001", "gRPC sports server endpoint")
+	grpcAdmin       = flag.String("grpc-sports-admin-endpoint", "localhost:9003", "gRPC sports admin server endpoint, which must only be reachable from the internal network")
+	maxBatchIDs     = flag.Int("max-batch-ids", service.DefaultMaxBatchIDs, "Largest number of events BatchGetEvents returns at once")
+	metricsEndpoint = flag.String("metrics-endpoint", "localhost:9101", "Endpoint serving the Prometheus metrics on /metrics, empty to not serve them")
+	otlpEndpoint    = flag.String("otlp-endpoint", "localhost:4317", "Endpoint of the OTLP gRPC collector the spans are exported to with -trace-exporter=otlp")
+	seed            = flag.Bool("seed", true, "Seed the database with dummy sports, competitions, events and markets. Defaults to false with -db-driver=postgres")
+	rpcTimeout      = flag.Duration("rpc-timeout", interceptor.DefaultTimeout, "Longest time a unary RPC can run for, streams are not limited. 0 for no limit")
+	rpcTimeouts     = methodTimeouts{}
+	traceExporter   = flag.String("trace-exporter", "none", "Exporter of the spans, none, stdout or otlp")
+	traceSample     = flag.Float64("trace-sample-ratio", 1, "Ratio of the traces started by the server which are sampled, the ones of callers are sampled like the callers")
 )
 
+func init() {
+	flag.Var(rpcTimeouts, "rpc-method-timeout", "Timeout of a single RPC overriding -rpc-timeout, as <method>=<duration>, e.g. ListEvents=2s. It can be repeated")
+}
+
 func main() {
 	flag.Parse()
 
+	if flag.Arg(0) == "migrate" {
+		if err := migrate(flag.Args()[1:]); err != nil {
+			log.Fatalf("failed migrating database: %s\n", err)
+		}
+		return
+	}
+
 	if err := run(); err != nil {
 		log.Fatalf("failed running grpc server: %s\n", err)
 	}
@@ -30,25 +67,119 @@ func run() error {
 		return err
 	}
 
-	sportsDB, err := sql.Open("sqlite3", "./db/sports.db")
+	// Metrics are served on an endpoint of their own, so they aren't exposed along with the API
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	queryMetrics := instrument.NewQueryMetrics(registry)
+	rpcMetrics := interceptor.NewMetrics(registry)
+
+	tracerProvider, stopTracing, err := tracing.NewProvider("sports", *traceExporter, *otlpEndpoint, *traceSample)
+	if err != nil {
+		return err
+	}
+	defer stopTracing()
+
+	d, err := dialect.ForDriver(*dbDriver)
+	if err != nil {
+		return err
+	}
+	queryTracer := instrument.NewQueryTracer(tracerProvider, d)
+
+	sportsDB, err := openDB(d, queryMetrics.Hook, queryTracer.Hook)
 	if err != nil {
 		return err
 	}
+	registry.MustRegister(collectors.NewDBStatsCollector(sportsDB, "sports"))
+
+	if *metricsEndpoint != "" {
+		if err := serveMetrics(registry); err != nil {
+			return err
+		}
+	}
 
-	eventsRepo := db.NewEventsRepo(sportsDB)
+	if err := migrations.Migrate(sportsDB, db.Migrations(), d); err != nil {
+		return err
+	}
+
+	sportsRepo := db.NewSportsRepo(sportsDB, db.WithDialect(d))
+	if err := sportsRepo.Init(); err != nil {
+		return err
+	}
+
+	competitionsRepo := db.NewCompetitionsRepo(sportsDB, db.WithDialect(d))
+	if err := competitionsRepo.Init(); err != nil {
+		return err
+	}
+
+	eventsRepo := db.NewEventsRepo(sportsDB, db.WithDialect(d))
 	if err := eventsRepo.Init(); err != nil {
 		return err
 	}
 
-	grpcServer := grpc.NewServer()
+	marketsRepo := db.NewMarketsRepo(sportsDB, db.WithDialect(d))
+	if err := marketsRepo.Init(); err != nil {
+		return err
+	}
+
+	// The services, repositories and seeding tell the time with the same clock, which can be moved in builds with
+	// the timetravel tag
+	var clk clock.Clock = clock.System
+	if service.TimeTravelEnabled {
+		clk = clock.NewTravel(clock.System)
+		log.Println("time travel is enabled, this build must not be used in production")
+	}
+
+	// For test/example purposes, the DB is seeded with dummy data unless it is turned off
+	if seedEnabled(d) {
+		if err := db.Seed(sportsDB, db.WithDialect(d), db.WithClock(clk)); err != nil {
+			return err
+		}
+	}
+
+	// RPCs are traced in spans continuing the traces of their callers, whose context is in the metadata of the RPCs.
+	// The spans of the queries are children of the ones of the RPCs running them
+	traceOptions := []otelgrpc.Option{otelgrpc.WithTracerProvider(tracerProvider), otelgrpc.WithPropagators(tracing.Propagator)}
+	serverOptions := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			otelgrpc.UnaryServerInterceptor(traceOptions...),
+			rpcMetrics.UnaryInterceptor(),
+			interceptor.UnaryTimeout(*rpcTimeout, rpcTimeouts),
+		),
+		grpc.ChainStreamInterceptor(
+			otelgrpc.StreamServerInterceptor(traceOptions...),
+			rpcMetrics.StreamInterceptor(),
+		),
+	}
+	grpcServer := grpc.NewServer(serverOptions...)
 
 	sports.RegisterSportsServer(
 		grpcServer,
 		service.NewSportsService(
 			eventsRepo,
+			sportsRepo,
+			competitionsRepo,
+			marketsRepo,
+			service.WithMaxBatchIDs(*maxBatchIDs),
+			service.WithClock(clk),
+		),
+	)
+
+	// SportsAdmin changes the events, so it is served by a server of its own on the internal admin endpoint rather
+	// than along with the public Sports service
+	adminServer := grpc.NewServer(serverOptions...)
+	sports.RegisterSportsAdminServer(
+		adminServer,
+		service.NewSportsAdminService(
+			eventsRepo,
+			competitionsRepo,
+			service.WithAdminClock(clk),
 		),
 	)
 
+	if err := serveAdmin(adminServer); err != nil {
+		return err
+	}
+
 	log.Printf("gRPC sports server listening on: %s\n", *grpcEndpoint)
 
 	if err := grpcServer.Serve(conn); err != nil {
@@ -57,3 +188,99 @@ func run() error {
 
 	return nil
 }
+
+// openDB opens the sports database of the db-dsn flag, which is migrated when the server starts or by the migrate
+// command. The dialect is the one of the db-driver flag. The hooks are called around each query run on it.
+func openDB(d dialect.Dialect, hooks ...instrument.QueryHook) (*sql.DB, error) {
+	return instrument.OpenDB(d.Driver(), *dbDSN, hooks...)
+}
+
+// seedEnabled reports whether the database is seeded. Unless the seed flag is given, only SQLite databases are
+// seeded, so dummy data doesn't end up in a PostgreSQL database by accident.
+func seedEnabled(d dialect.Dialect) bool {
+	enabled := *seed && d != dialect.Postgres
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			enabled = *seed
+		}
+	})
+
+	return enabled
+}
+
+// migrate applies or rolls back the migrations of the sports database without starting the server.
+func migrate(args []string) error {
+	d, err := dialect.ForDriver(*dbDriver)
+	if err != nil {
+		return err
+	}
+
+	return migrations.Command(args, func() (*sql.DB, error) { return openDB(d) }, db.Migrations(), d)
+}
+
+// serveAdmin serves the admin server on the admin endpoint in the background.
+func serveAdmin(server *grpc.Server) error {
+	conn, err := net.Listen("tcp", *grpcAdmin)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := server.Serve(conn); err != nil {
+			log.Printf("failed serving grpc admin server: %s\n", err)
+		}
+	}()
+
+	log.Printf("gRPC sports admin server listening on: %s\n", *grpcAdmin)
+
+	return nil
+}
+
+// serveMetrics serves the metrics of the registry on /metrics of the metrics endpoint, in the background.
+func serveMetrics(registry *prometheus.Registry) error {
+	conn, err := net.Listen("tcp", *metricsEndpoint)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	go func() {
+		if err := http.Serve(conn, mux); err != nil {
+			log.Printf("failed serving metrics: %s\n", err)
+		}
+	}()
+
+	log.Printf("metrics listening on: %s\n", *metricsEndpoint)
+
+	return nil
+}
+
+// methodTimeouts are the timeouts of single RPCs, keyed by method. It is set by repeating a flag.
+type methodTimeouts map[string]time.Duration
+
+func (t methodTimeouts) String() string {
+	timeouts := make([]string, 0, len(t))
+	for method, timeout := range t {
+		timeouts = append(timeouts, method+"="+timeout.String())
+	}
+	sort.Strings(timeouts)
+
+	return strings.Join(timeouts, ",")
+}
+
+func (t methodTimeouts) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("method timeout %q must be <method>=<duration>", value)
+	}
+
+	timeout, err := time.ParseDuration(parts[1])
+	if err != nil {
+		return err
+	}
+
+	t[parts[0]] = timeout
+	return nil
+}
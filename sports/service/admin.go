@@ -0,0 +1,361 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"git.neds.sh/matty/entain/common/clock"
+	"git.neds.sh/matty/entain/sports/db"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type SportsAdmin interface {
+	// CreateEvent will add an event to a competition
+	CreateEvent(ctx context.Context, in *sports.CreateEventRequest) (*sports.CreateEventResponse, error)
+	// UpdateEvent will change the fields of an event in the update mask
+	UpdateEvent(ctx context.Context, in *sports.UpdateEventRequest) (*sports.UpdateEventResponse, error)
+	// DeleteEvent will remove an event
+	DeleteEvent(ctx context.Context, in *sports.DeleteEventRequest) (*sports.DeleteEventResponse, error)
+	// SetEventVisibility will show or hide an event
+	SetEventVisibility(ctx context.Context, in *sports.SetEventVisibilityRequest) (*sports.SetEventVisibilityResponse, error)
+	// TimeTravel will move the clock of the service, in builds with the timetravel tag
+	TimeTravel(ctx context.Context, in *sports.TimeTravelRequest) (*sports.TimeTravelResponse, error)
+}
+
+// sportsAdminService implements the SportsAdmin interface.
+type sportsAdminService struct {
+	eventsRepo       db.EventsRepo
+	competitionsRepo db.CompetitionsRepo
+	// clock tells the time the statuses of the events are derived at
+	clock clock.Clock
+}
+
+// AdminOption configures the sportsAdminService.
+type AdminOption func(s *sportsAdminService)
+
+// WithAdminClock sets the clock the admin service tells the time with, the system clock by default.
+func WithAdminClock(c clock.Clock) AdminOption {
+	return func(s *sportsAdminService) {
+		s.clock = c
+	}
+}
+
+// NewSportsAdminService instantiates and returns a new sportsAdminService.
+func NewSportsAdminService(eventsRepo db.EventsRepo, competitionsRepo db.CompetitionsRepo, opts ...AdminOption) SportsAdmin {
+	s := &sportsAdminService{
+		eventsRepo:       eventsRepo,
+		competitionsRepo: competitionsRepo,
+		clock:            clock.System,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *sportsAdminService) CreateEvent(ctx context.Context, in *sports.CreateEventRequest) (*sports.CreateEventResponse, error) {
+	if in.Event == nil {
+		return nil, status.Error(codes.InvalidArgument, "event is required")
+	}
+
+	violations, err := s.validateEvent(ctx, in.Event, db.EventWriteFields)
+	if err != nil {
+		return nil, err
+	}
+	if len(violations) > 0 {
+		return nil, invalidArgument("event is invalid", violations)
+	}
+
+	id, err := s.eventsRepo.Create(ctx, in.Event)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := s.eventsRepo.Get(ctx, id, nil, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &sports.CreateEventResponse{Event: event}, nil
+}
+
+// UpdateEvent validates the fields in the update mask against the rest of the event, so e.g. the competition
+// can be changed on its own as long as it is a competition of the sport of the event. The event must be at the
+// version it was read at, so changes made in the meantime aren't overwritten.
+func (s *sportsAdminService) UpdateEvent(ctx context.Context, in *sports.UpdateEventRequest) (*sports.UpdateEventResponse, error) {
+	if in.Event == nil {
+		return nil, status.Error(codes.InvalidArgument, "event is required")
+	}
+
+	fields, violations := updateFields(in.UpdateMask.GetPaths())
+	if in.Event.Version <= 0 {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "event.version",
+			Description: "version is required, it must be the version the event was read at",
+		})
+	}
+
+	current, err := s.eventsRepo.Get(ctx, in.Event.Id, nil, s.clock.Now())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "Event with ID %d not found", in.Event.Id)
+		}
+		return nil, err
+	}
+
+	// The rest of the event has changed too, so the masked fields would be checked against the wrong event
+	if in.Event.Version > 0 && in.Event.Version != current.Version {
+		return nil, eventVersionChanged(in.Event.Id, in.Event.Version)
+	}
+
+	updated := proto.Clone(current).(*sports.Event)
+	mergeFields(updated, in.Event, fields)
+
+	fieldViolations, err := s.validateEvent(ctx, updated, fields)
+	if err != nil {
+		return nil, err
+	}
+	if violations = append(violations, fieldViolations...); len(violations) > 0 {
+		return nil, invalidArgument("event is invalid", violations)
+	}
+
+	event, err := s.updateEvent(ctx, updated, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sports.UpdateEventResponse{Event: event}, nil
+}
+
+func (s *sportsAdminService) DeleteEvent(ctx context.Context, in *sports.DeleteEventRequest) (*sports.DeleteEventResponse, error) {
+	if err := s.eventsRepo.Delete(ctx, in.Id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "Event with ID %d not found", in.Id)
+		}
+		return nil, err
+	}
+
+	return &sports.DeleteEventResponse{}, nil
+}
+
+func (s *sportsAdminService) SetEventVisibility(ctx context.Context, in *sports.SetEventVisibilityRequest) (*sports.SetEventVisibilityResponse, error) {
+	if in.Version <= 0 {
+		return nil, invalidArgument("request is invalid", []*errdetails.BadRequest_FieldViolation{{
+			Field:       "version",
+			Description: "version is required, it must be the version the event was read at",
+		}})
+	}
+
+	event, err := s.updateEvent(ctx, &sports.Event{Id: in.Id, Visible: in.Visible, Version: in.Version}, []string{"visible"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &sports.SetEventVisibilityResponse{Event: event}, nil
+}
+
+// updateEvent writes the fields of an event at its version, returning the event as it was stored.
+func (s *sportsAdminService) updateEvent(ctx context.Context, event *sports.Event, fields []string) (*sports.Event, error) {
+	if err := s.eventsRepo.Update(ctx, event, fields); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "Event with ID %d not found", event.Id)
+		}
+		if errors.Is(err, db.ErrVersionChanged) {
+			return nil, eventVersionChanged(event.Id, event.Version)
+		}
+		return nil, err
+	}
+
+	updated, err := s.eventsRepo.Get(ctx, event.Id, nil, s.clock.Now())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "Event with ID %d not found", event.Id)
+		}
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// mergeFields sets the fields of an event to the ones of another, clearing those that aren't set there.
+func mergeFields(event *sports.Event, from *sports.Event, fields []string) {
+	msg, fromMsg := event.ProtoReflect(), from.ProtoReflect()
+	for _, field := range fields {
+		descriptor := msg.Descriptor().Fields().ByName(protoreflect.Name(field))
+		if fromMsg.Has(descriptor) {
+			msg.Set(descriptor, fromMsg.Get(descriptor))
+		} else {
+			msg.Clear(descriptor)
+		}
+	}
+}
+
+// updateFields returns the fields of an event to update for the paths of an update mask, every field that can be
+// written when there are none. Paths that can't be updated are returned as violations.
+func updateFields(paths []string) ([]string, []*errdetails.BadRequest_FieldViolation) {
+	if len(paths) == 0 {
+		return db.EventWriteFields, nil
+	}
+
+	var (
+		fields     []string
+		violations []*errdetails.BadRequest_FieldViolation
+	)
+
+	for _, path := range paths {
+		// The version is what the update is made to rather than a field to write, the gateway adds it to the mask
+		// along with the rest of the body
+		if path == "version" {
+			continue
+		}
+		if !isEventWriteField(path) {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       "update_mask",
+				Description: fmt.Sprintf("field %q can't be updated, it must be one of: %s", path, strings.Join(db.EventWriteFields, ", ")),
+			})
+			continue
+		}
+		fields = append(fields, path)
+	}
+
+	if len(fields) == 0 && len(violations) == 0 {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       "update_mask",
+			Description: "there are no fields to update, it must have some of: " + strings.Join(db.EventWriteFields, ", "),
+		})
+	}
+
+	return fields, violations
+}
+
+func isEventWriteField(field string) bool {
+	for _, writeField := range db.EventWriteFields {
+		if writeField == field {
+			return true
+		}
+	}
+	return false
+}
+
+// validateEvent checks the given fields of an event, returning a violation for each invalid one. The sport,
+// competition and participants are checked together, as they must match each other.
+func (s *sportsAdminService) validateEvent(ctx context.Context, event *sports.Event, fields []string) ([]*errdetails.BadRequest_FieldViolation, error) {
+	var (
+		violations []*errdetails.BadRequest_FieldViolation
+		checked    = make(map[string]bool)
+	)
+
+	violate := func(field string, description string) {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: "event." + field, Description: description})
+	}
+
+	for _, field := range fields {
+		checked[field] = true
+
+		switch field {
+		case "name":
+			if strings.TrimSpace(event.Name) == "" {
+				violate(field, "name is required")
+			}
+		case "advertised_start_time":
+			if event.AdvertisedStartTime == nil {
+				violate(field, "advertised_start_time is required")
+			} else if err := event.AdvertisedStartTime.CheckValid(); err != nil {
+				violate(field, "advertised_start_time is invalid: "+err.Error())
+			}
+		case "sport_id":
+			if event.SportId <= 0 {
+				violate(field, "sport_id is required")
+			}
+		case "competition_id":
+			if event.CompetitionId <= 0 {
+				violate(field, "competition_id is required")
+			}
+		case "participants":
+			sides := make(map[sports.ParticipantSide]bool)
+			for i, participant := range event.Participants {
+				switch {
+				case participant.Side != sports.ParticipantSide_HOME && participant.Side != sports.ParticipantSide_AWAY:
+					violate(fmt.Sprintf("participants[%d].side", i), "side must be HOME or AWAY")
+				case sides[participant.Side]:
+					violate(fmt.Sprintf("participants[%d].side", i), fmt.Sprintf("there can only be one %s participant", participant.Side))
+				}
+				sides[participant.Side] = true
+			}
+		}
+	}
+
+	if !(checked["sport_id"] || checked["competition_id"] || checked["participants"]) || event.SportId <= 0 || event.CompetitionId <= 0 {
+		return violations, nil
+	}
+
+	competition, err := s.competitionsRepo.Get(ctx, event.CompetitionId)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		violate("competition_id", fmt.Sprintf("Competition with ID %d not found", event.CompetitionId))
+		return violations, nil
+	}
+
+	if competition.SportId != event.SportId {
+		violate("competition_id", fmt.Sprintf("Competition with ID %d isn't a competition of sport %d", event.CompetitionId, event.SportId))
+	}
+
+	if len(event.Participants) == 0 {
+		return violations, nil
+	}
+
+	participants, err := s.competitionsRepo.ListParticipants(ctx, event.CompetitionId)
+	if err != nil {
+		return nil, err
+	}
+
+	inCompetition := make(map[int64]bool, len(participants))
+	for _, participant := range participants {
+		inCompetition[participant.Id] = true
+	}
+
+	for i, participant := range event.Participants {
+		if !inCompetition[participant.Id] {
+			violate(fmt.Sprintf("participants[%d].id", i), fmt.Sprintf("Participant with ID %d isn't a participant of competition %d", participant.Id, event.CompetitionId))
+		}
+	}
+
+	return violations, nil
+}
+
+// eventVersionChanged returns an Aborted error for a write made to a stale version of an event. The
+// PreconditionFailure details tell it apart from other conflicts, so the gateway can answer with 412 Precondition
+// Failed.
+func eventVersionChanged(id int64, version int64) error {
+	message := fmt.Sprintf("Event with ID %d was changed since version %d", id, version)
+
+	st, err := status.New(codes.Aborted, message).WithDetails(&errdetails.PreconditionFailure{
+		Violations: []*errdetails.PreconditionFailure_Violation{{Type: "VERSION", Subject: fmt.Sprintf("event/%d", id), Description: message}},
+	})
+	if err != nil {
+		return status.Error(codes.Aborted, message)
+	}
+	return st.Err()
+}
+
+// invalidArgument returns an InvalidArgument error with the violations as BadRequest details, so clients can
+// show each of them next to its field.
+func invalidArgument(message string, violations []*errdetails.BadRequest_FieldViolation) error {
+	st, err := status.New(codes.InvalidArgument, message).WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, message)
+	}
+	return st.Err()
+}
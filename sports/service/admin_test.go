@@ -0,0 +1,302 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"git.neds.sh/matty/entain/sports/db"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+)
+
+// adminEventsRepo is a MockEventsRepo keeping the events it updates.
+type adminEventsRepo struct {
+	MockEventsRepo
+	events map[int64]*sports.Event
+}
+
+func newAdminEventsRepo() *adminEventsRepo {
+	events := make(map[int64]*sports.Event)
+	for _, event := range getAllTestData() {
+		event.Version = 1
+		events[event.Id] = event
+	}
+	return &adminEventsRepo{events: events}
+}
+
+func (m *adminEventsRepo) Get(ctx context.Context, id int64, readMask *fieldmaskpb.FieldMask, currentDate time.Time) (*sports.Event, error) {
+	event, ok := m.events[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return event, nil
+}
+
+func (m *adminEventsRepo) Create(ctx context.Context, event *sports.Event) (int64, error) {
+	id := int64(len(m.events) + 1)
+	for m.events[id] != nil {
+		id++
+	}
+
+	created := proto.Clone(event).(*sports.Event)
+	created.Id = id
+	created.Status = "OPEN"
+	m.events[id] = created
+	return id, nil
+}
+
+func (m *adminEventsRepo) Update(ctx context.Context, event *sports.Event, fields []string) error {
+	updated, ok := m.events[event.Id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	if event.Version != updated.Version {
+		return db.ErrVersionChanged
+	}
+
+	updated = proto.Clone(updated).(*sports.Event)
+	mergeFields(updated, event, fields)
+	updated.Version++
+	m.events[event.Id] = updated
+	return nil
+}
+
+func (m *adminEventsRepo) Delete(ctx context.Context, id int64) error {
+	if _, ok := m.events[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.events, id)
+	return nil
+}
+
+// preconditionTypes returns the types of the PreconditionFailure details of an error.
+func preconditionTypes(err error) []string {
+	var types []string
+	for _, detail := range status.Convert(err).Details() {
+		if failure, ok := detail.(*errdetails.PreconditionFailure); ok {
+			for _, violation := range failure.Violations {
+				types = append(types, violation.Type)
+			}
+		}
+	}
+	return types
+}
+
+// violatedFields returns the fields of the BadRequest details of an error.
+func violatedFields(err error) []string {
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				fields = append(fields, violation.Field)
+			}
+		}
+	}
+	return fields
+}
+
+func TestSportsAdminService_CreateEvent(t *testing.T) {
+	startTime := timestamppb.New(time.Date(2023, 7, 16, 14, 30, 0, 0, time.UTC))
+
+	testCases := []struct {
+		name           string
+		event          *sports.Event
+		expectedCode   codes.Code
+		expectedFields []string
+	}{
+		{
+			name: "Created",
+			event: &sports.Event{SportId: 1, CompetitionId: 1, Name: "Arsenal v Chelsea", Visible: true, AdvertisedStartTime: startTime,
+				Participants: []*sports.Participant{{Id: 1, Side: sports.ParticipantSide_HOME}, {Id: 2, Side: sports.ParticipantSide_AWAY}}},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "NoEvent",
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:           "Invalid",
+			event:          &sports.Event{Name: " "},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"event.name", "event.sport_id", "event.competition_id", "event.advertised_start_time"},
+		},
+		{
+			name:           "UnknownCompetition",
+			event:          &sports.Event{SportId: 1, CompetitionId: 999, Name: "Arsenal v Chelsea", AdvertisedStartTime: startTime},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"event.competition_id"},
+		},
+		{
+			name:           "CompetitionOfOtherSport",
+			event:          &sports.Event{SportId: 1, CompetitionId: 5, Name: "Arsenal v Chelsea", AdvertisedStartTime: startTime},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"event.competition_id"},
+		},
+		{
+			name: "ParticipantOfOtherCompetition",
+			event: &sports.Event{SportId: 1, CompetitionId: 1, Name: "Arsenal v Celtics", AdvertisedStartTime: startTime,
+				Participants: []*sports.Participant{{Id: 1, Side: sports.ParticipantSide_HOME}, {Id: 3, Side: sports.ParticipantSide_AWAY}}},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"event.participants[1].id"},
+		},
+		{
+			name: "InvalidSides",
+			event: &sports.Event{SportId: 1, CompetitionId: 1, Name: "Arsenal v Chelsea", AdvertisedStartTime: startTime,
+				Participants: []*sports.Participant{{Id: 1, Side: sports.ParticipantSide_HOME}, {Id: 2, Side: sports.ParticipantSide_HOME}, {Id: 2}}},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"event.participants[1].side", "event.participants[2].side"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			eventsRepo := newAdminEventsRepo()
+			adminSvc := NewSportsAdminService(eventsRepo, &MockCompetitionsRepo{})
+
+			response, err := adminSvc.CreateEvent(context.Background(), &sports.CreateEventRequest{Event: tc.event})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			assert.ElementsMatch(t, tc.expectedFields, violatedFields(err))
+			if err == nil {
+				assert.NotZero(t, response.Event.Id)
+				assert.Equal(t, tc.event.Name, response.Event.Name)
+				assert.Equal(t, response.Event, eventsRepo.events[response.Event.Id])
+			}
+		})
+	}
+}
+
+func TestSportsAdminService_UpdateEvent(t *testing.T) {
+	testCases := []struct {
+		name           string
+		event          *sports.Event
+		updateMask     []string
+		expectedCode   codes.Code
+		expectedFields []string
+	}{
+		{
+			name:         "Renamed",
+			event:        &sports.Event{Id: 2, Name: "Renamed", Version: 1},
+			updateMask:   []string{"name"},
+			expectedCode: codes.OK,
+		},
+		{
+			name:           "OnlyMaskedFieldsValidated",
+			event:          &sports.Event{Id: 2, Name: "Renamed", Version: 1},
+			updateMask:     []string{"name", "advertised_start_time"},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"event.advertised_start_time"},
+		},
+		{
+			name:           "CompetitionCheckedAgainstSport",
+			event:          &sports.Event{Id: 2, CompetitionId: 5, Version: 1},
+			updateMask:     []string{"competition_id"},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"event.competition_id"},
+		},
+		{
+			name:           "FieldNotWritable",
+			event:          &sports.Event{Id: 2, Status: "CLOSED", Version: 1},
+			updateMask:     []string{"status"},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"update_mask"},
+		},
+		{
+			name:         "VersionInMask",
+			event:        &sports.Event{Id: 2, Name: "Renamed", Version: 1},
+			updateMask:   []string{"name", "version"},
+			expectedCode: codes.OK,
+		},
+		{
+			name:           "OnlyVersionInMask",
+			event:          &sports.Event{Id: 2, Version: 1},
+			updateMask:     []string{"version"},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"update_mask"},
+		},
+		{
+			name:           "NoVersion",
+			event:          &sports.Event{Id: 2, Name: "Renamed"},
+			updateMask:     []string{"name"},
+			expectedCode:   codes.InvalidArgument,
+			expectedFields: []string{"event.version"},
+		},
+		{
+			name:         "StaleVersion",
+			event:        &sports.Event{Id: 2, Name: "Renamed", Version: 2},
+			updateMask:   []string{"name"},
+			expectedCode: codes.Aborted,
+		},
+		{
+			name:         "NotFound",
+			event:        &sports.Event{Id: 999, Name: "Renamed", Version: 1},
+			updateMask:   []string{"name"},
+			expectedCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			eventsRepo := newAdminEventsRepo()
+			adminSvc := NewSportsAdminService(eventsRepo, &MockCompetitionsRepo{})
+
+			response, err := adminSvc.UpdateEvent(context.Background(), &sports.UpdateEventRequest{Event: tc.event, UpdateMask: &fieldmaskpb.FieldMask{Paths: tc.updateMask}})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			assert.ElementsMatch(t, tc.expectedFields, violatedFields(err))
+			if tc.expectedCode == codes.Aborted {
+				assert.Equal(t, []string{"VERSION"}, preconditionTypes(err))
+			}
+			if err == nil {
+				assert.Equal(t, "Renamed", response.Event.Name)
+				// Fields that aren't in the mask are kept
+				assert.Equal(t, int64(1), response.Event.CompetitionId)
+				assert.Equal(t, int64(2), response.Event.Version)
+			}
+		})
+	}
+}
+
+func TestSportsAdminService_DeleteEvent(t *testing.T) {
+	eventsRepo := newAdminEventsRepo()
+	adminSvc := NewSportsAdminService(eventsRepo, &MockCompetitionsRepo{})
+
+	_, err := adminSvc.DeleteEvent(context.Background(), &sports.DeleteEventRequest{Id: 2})
+	assert.NoError(t, err)
+	assert.NotContains(t, eventsRepo.events, int64(2))
+
+	_, err = adminSvc.DeleteEvent(context.Background(), &sports.DeleteEventRequest{Id: 2})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestSportsAdminService_SetEventVisibility(t *testing.T) {
+	eventsRepo := newAdminEventsRepo()
+	adminSvc := NewSportsAdminService(eventsRepo, &MockCompetitionsRepo{})
+
+	response, err := adminSvc.SetEventVisibility(context.Background(), &sports.SetEventVisibilityRequest{Id: 1, Visible: true, Version: 1})
+	assert.NoError(t, err)
+	assert.True(t, response.Event.Visible)
+	assert.Equal(t, "North Dakota foes", response.Event.Name)
+
+	_, err = adminSvc.SetEventVisibility(context.Background(), &sports.SetEventVisibilityRequest{Id: 999, Visible: true, Version: 1})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// The version is required, so the visibility can't be set over changes the caller hasn't seen
+	_, err = adminSvc.SetEventVisibility(context.Background(), &sports.SetEventVisibilityRequest{Id: 1, Visible: false})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = adminSvc.SetEventVisibility(context.Background(), &sports.SetEventVisibilityRequest{Id: 1, Visible: false, Version: 1})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	response, err = adminSvc.SetEventVisibility(context.Background(), &sports.SetEventVisibilityRequest{Id: 1, Visible: false, Version: 2})
+	assert.NoError(t, err)
+	assert.False(t, response.Event.Visible)
+}
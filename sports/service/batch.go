@@ -0,0 +1,59 @@
+package service
+
+import (
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultMaxBatchIDs is the largest number of events BatchGetEvents returns at once, unless configured otherwise.
+const DefaultMaxBatchIDs = 50
+
+func (s *sportsService) BatchGetEvents(ctx context.Context, in *sports.BatchGetEventsRequest) (*sports.BatchGetEventsResponse, error) {
+	ids := uniqueIDs(in.Ids)
+
+	switch {
+	case len(ids) == 0:
+		return nil, status.Error(codes.InvalidArgument, "ids are required")
+	case len(ids) > s.maxBatchIDs:
+		return nil, status.Errorf(codes.InvalidArgument, "no more than %d ids can be requested at once", s.maxBatchIDs)
+	}
+
+	events, err := s.eventsRepo.BatchGet(ctx, ids, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*sports.Event, len(events))
+	for _, event := range events {
+		byID[event.Id] = event
+	}
+
+	// Events are returned in the order they were requested, so the betslip doesn't have to sort them again
+	response := &sports.BatchGetEventsResponse{Events: make([]*sports.Event, 0, len(events))}
+	for _, id := range ids {
+		if event, ok := byID[id]; ok {
+			response.Events = append(response.Events, event)
+		} else {
+			response.MissingIds = append(response.MissingIds, id)
+		}
+	}
+
+	return response, nil
+}
+
+// uniqueIDs returns the ids without the repeated ones, keeping their order.
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	unique := make([]int64, 0, len(ids))
+
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
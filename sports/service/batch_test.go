@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestSportsService_BatchGetEvents(t *testing.T) {
+	sportsSvc := NewSportsService(&MockEventsRepo{}, &MockSportsRepo{}, &MockCompetitionsRepo{}, &MockMarketsRepo{}, WithMaxBatchIDs(3))
+
+	testCases := []struct {
+		name               string
+		ids                []int64
+		expectedCode       codes.Code
+		expectedIds        []int64
+		expectedMissingIds []int64
+	}{
+		{
+			name:         "InRequestedOrder",
+			ids:          []int64{3, 1},
+			expectedCode: codes.OK,
+			expectedIds:  []int64{3, 1},
+		},
+		{
+			name:               "SomeMissing",
+			ids:                []int64{2, 999, 1},
+			expectedCode:       codes.OK,
+			expectedIds:        []int64{2, 1},
+			expectedMissingIds: []int64{999},
+		},
+		{
+			name:               "AllMissing",
+			ids:                []int64{998, 999},
+			expectedCode:       codes.OK,
+			expectedMissingIds: []int64{998, 999},
+		},
+		{
+			name:         "RepeatedIdsCountOnce",
+			ids:          []int64{1, 2, 1, 3, 2},
+			expectedCode: codes.OK,
+			expectedIds:  []int64{1, 2, 3},
+		},
+		{
+			name:         "TooManyIds",
+			ids:          []int64{1, 2, 3, 4},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "NoIds",
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			response, err := sportsSvc.BatchGetEvents(context.Background(), &sports.BatchGetEventsRequest{Ids: tc.ids})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode != codes.OK {
+				return
+			}
+
+			var ids []int64
+			for _, event := range response.Events {
+				ids = append(ids, event.Id)
+			}
+			assert.Equal(t, tc.expectedIds, ids)
+			assert.Equal(t, tc.expectedMissingIds, response.MissingIds)
+		})
+	}
+}
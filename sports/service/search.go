@@ -0,0 +1,40 @@
+package service
+
+import (
+	"strings"
+
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultSearchLimit is the number of events found when no limit is requested.
+	defaultSearchLimit = 20
+	// maxSearchLimit is the largest number of events that can be found at once.
+	maxSearchLimit = 100
+)
+
+func (s *sportsService) Search(ctx context.Context, in *sports.SearchEventsRequest) (*sports.SearchEventsResponse, error) {
+	if strings.TrimSpace(in.Query) == "" {
+		return nil, status.Error(codes.InvalidArgument, "query is required")
+	}
+
+	limit := in.Limit
+	switch {
+	case limit < 0:
+		return nil, status.Error(codes.InvalidArgument, "limit can't be negative")
+	case limit == 0:
+		limit = defaultSearchLimit
+	case limit > maxSearchLimit:
+		limit = maxSearchLimit
+	}
+
+	results, err := s.eventsRepo.Search(ctx, in.Query, limit, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &sports.SearchEventsResponse{Results: results}, nil
+}
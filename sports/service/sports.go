@@ -3,6 +3,7 @@ package service
 import (
 	"database/sql"
 	"errors"
+	"git.neds.sh/matty/entain/common/clock"
 	"git.neds.sh/matty/entain/sports/db"
 	"git.neds.sh/matty/entain/sports/proto/sports"
 	"golang.org/x/net/context"
@@ -11,41 +12,208 @@ import (
 	"time"
 )
 
+const (
+	// defaultPageSize is the number of events listed when no page size is requested.
+	defaultPageSize = 100
+	// maxPageSize is the largest number of events that can be listed at once.
+	maxPageSize = 1000
+)
+
 type Sports interface {
 	// ListEvents will return a collection of events.
 	ListEvents(ctx context.Context, in *sports.ListEventsRequest) (*sports.ListEventsResponse, error)
 	// GetEvent will return a single event by id
 	GetEvent(ctx context.Context, in *sports.GetEventRequest) (*sports.GetEventResponse, error)
+	// BatchGetEvents will return the events with the given ids, along with the ids that weren't found
+	BatchGetEvents(ctx context.Context, in *sports.BatchGetEventsRequest) (*sports.BatchGetEventsResponse, error)
+	// Search will return the events matching a query, the most relevant first
+	Search(ctx context.Context, in *sports.SearchEventsRequest) (*sports.SearchEventsResponse, error)
+	// ListMarkets will return the markets of an event
+	ListMarkets(ctx context.Context, in *sports.ListMarketsRequest) (*sports.ListMarketsResponse, error)
+	// ListSports will return every sport
+	ListSports(ctx context.Context, in *sports.ListSportsRequest) (*sports.ListSportsResponse, error)
+	// ListCompetitions will return a collection of competitions
+	ListCompetitions(ctx context.Context, in *sports.ListCompetitionsRequest) (*sports.ListCompetitionsResponse, error)
+	// WatchEvents will stream a snapshot of events followed by their changes
+	WatchEvents(in *sports.WatchEventsRequest, stream sports.Sports_WatchEventsServer) error
 }
 
 // sportsService implements the Sports interface.
 type sportsService struct {
-	eventsRepo db.EventsRepo
+	eventsRepo       db.EventsRepo
+	sportsRepo       db.SportsRepo
+	competitionsRepo db.CompetitionsRepo
+	marketsRepo      db.MarketsRepo
+	// watchInterval is how often WatchEvents checks the repository for changes
+	watchInterval time.Duration
+	// watches are polling the events being watched, shared by the clients watching the same filter
+	watches *eventWatches
+	// maxBatchIDs is the largest number of events BatchGetEvents returns at once
+	maxBatchIDs int
+	// clock tells the time the statuses of the events are derived at
+	clock clock.Clock
+}
+
+// Option configures the sportsService.
+type Option func(s *sportsService)
+
+// WithMaxBatchIDs sets the largest number of events BatchGetEvents returns at once.
+func WithMaxBatchIDs(maxBatchIDs int) Option {
+	return func(s *sportsService) {
+		s.maxBatchIDs = maxBatchIDs
+	}
+}
+
+// WithClock sets the clock the service tells the time with, the system clock by default.
+func WithClock(c clock.Clock) Option {
+	return func(s *sportsService) {
+		s.clock = c
+	}
 }
 
 // NewSportsService instantiates and returns a new sportsService.
-func NewSportsService(eventsRepo db.EventsRepo) Sports {
-	return &sportsService{eventsRepo}
+func NewSportsService(eventsRepo db.EventsRepo, sportsRepo db.SportsRepo, competitionsRepo db.CompetitionsRepo, marketsRepo db.MarketsRepo, opts ...Option) Sports {
+	s := &sportsService{
+		eventsRepo:       eventsRepo,
+		sportsRepo:       sportsRepo,
+		competitionsRepo: competitionsRepo,
+		marketsRepo:      marketsRepo,
+		watchInterval:    defaultWatchInterval,
+		watches:          newEventWatches(),
+		maxBatchIDs:      DefaultMaxBatchIDs,
+		clock:            clock.System,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 func (s *sportsService) ListEvents(ctx context.Context, in *sports.ListEventsRequest) (*sports.ListEventsResponse, error) {
-	events, err := s.eventsRepo.List(in.Filter, in.OrderBy, time.Now())
+	pageSize, err := getPageSize(in.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateStartTimes(in.Filter); err != nil {
+		return nil, err
+	}
+
+	events, nextPageToken, err := s.eventsRepo.List(ctx, in.Filter, in.OrderBy, pageSize, in.PageToken, in.ReadMask, s.clock.Now())
 	if err != nil {
+		if errors.Is(err, db.ErrInvalidPageToken) {
+			return nil, status.Error(codes.InvalidArgument, "page_token is invalid or doesn't match the request")
+		}
+		var orderFieldErr *db.OrderFieldError
+		if errors.As(err, &orderFieldErr) {
+			return nil, status.Error(codes.InvalidArgument, orderFieldErr.Error())
+		}
+		var readMaskErr *db.ReadMaskError
+		if errors.As(err, &readMaskErr) {
+			return nil, status.Error(codes.InvalidArgument, readMaskErr.Error())
+		}
 		return nil, err
 	}
 
-	return &sports.ListEventsResponse{Events: events}, nil
+	return &sports.ListEventsResponse{Events: events, NextPageToken: nextPageToken}, nil
+}
+
+// validateStartTimes checks the start time bounds of the filter make a valid range.
+func validateStartTimes(filter *sports.ListEventsRequestFilter) error {
+	if filter == nil {
+		return nil
+	}
+
+	if filter.StartTimeFrom != nil {
+		if err := filter.StartTimeFrom.CheckValid(); err != nil {
+			return status.Errorf(codes.InvalidArgument, "start_time_from is invalid: %v", err)
+		}
+	}
+	if filter.StartTimeTo != nil {
+		if err := filter.StartTimeTo.CheckValid(); err != nil {
+			return status.Errorf(codes.InvalidArgument, "start_time_to is invalid: %v", err)
+		}
+	}
+	if filter.StartTimeFrom != nil && filter.StartTimeTo != nil && !filter.StartTimeFrom.AsTime().Before(filter.StartTimeTo.AsTime()) {
+		return status.Error(codes.InvalidArgument, "start_time_from must be before start_time_to")
+	}
+	if filter.StartsWithinMinutes < 0 {
+		return status.Error(codes.InvalidArgument, "starts_within_minutes can't be negative")
+	}
+
+	return nil
+}
+
+// getPageSize returns the number of events to list, applying the default and maximum page sizes.
+func getPageSize(requested int32) (int32, error) {
+	switch {
+	case requested < 0:
+		return 0, status.Error(codes.InvalidArgument, "page_size can't be negative")
+	case requested == 0:
+		return defaultPageSize, nil
+	case requested > maxPageSize:
+		return maxPageSize, nil
+	default:
+		return requested, nil
+	}
 }
 
 func (s *sportsService) GetEvent(ctx context.Context, in *sports.GetEventRequest) (*sports.GetEventResponse, error) {
-	event, err := s.eventsRepo.Get(in.Id, time.Now())
+	event, err := s.eventsRepo.Get(ctx, in.Id, in.ReadMask, s.clock.Now())
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			// If the event is not found, return a 404 status code
 			return nil, status.Errorf(codes.NotFound, "Event with ID %d not found", in.Id)
 		}
+		var readMaskErr *db.ReadMaskError
+		if errors.As(err, &readMaskErr) {
+			return nil, status.Error(codes.InvalidArgument, readMaskErr.Error())
+		}
 		return nil, err
 	}
 
+	if in.IncludeMarkets && db.ReadsField(in.ReadMask, "markets") {
+		if event.Markets, err = s.marketsRepo.List(ctx, in.Id); err != nil {
+			return nil, err
+		}
+	}
+
 	return &sports.GetEventResponse{Event: event}, nil
 }
+
+func (s *sportsService) ListMarkets(ctx context.Context, in *sports.ListMarketsRequest) (*sports.ListMarketsResponse, error) {
+	// An unknown event is a 404, rather than an event without markets
+	if _, err := s.eventsRepo.Get(ctx, in.EventId, nil, s.clock.Now()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "Event with ID %d not found", in.EventId)
+		}
+		return nil, err
+	}
+
+	markets, err := s.marketsRepo.List(ctx, in.EventId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sports.ListMarketsResponse{Markets: markets}, nil
+}
+
+func (s *sportsService) ListSports(ctx context.Context, in *sports.ListSportsRequest) (*sports.ListSportsResponse, error) {
+	result, err := s.sportsRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sports.ListSportsResponse{Sports: result}, nil
+}
+
+func (s *sportsService) ListCompetitions(ctx context.Context, in *sports.ListCompetitionsRequest) (*sports.ListCompetitionsResponse, error) {
+	competitions, err := s.competitionsRepo.List(ctx, in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sports.ListCompetitionsResponse{Competitions: competitions}, nil
+}
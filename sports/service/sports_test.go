@@ -2,22 +2,35 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"git.neds.sh/matty/entain/common/clock"
+	"git.neds.sh/matty/entain/sports/db"
 	"git.neds.sh/matty/entain/sports/proto/sports"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
 // MockEventsRepo is a mock implementation of the db.EventsRepo interface.
-type MockEventsRepo struct{}
+type MockEventsRepo struct {
+	// searchLimit is the limit of the last search
+	searchLimit int32
+}
 
 func (m *MockEventsRepo) Init() error {
 	return nil
 }
 
-func (m *MockEventsRepo) List(filter *sports.ListEventsRequestFilter, orderBy []*sports.ListEventsRequestOrderBy, currentDate time.Time) ([]*sports.Event, error) {
+func (m *MockEventsRepo) List(ctx context.Context, filter *sports.ListEventsRequestFilter, orderBy []*sports.ListEventsRequestOrderBy, pageSize int32, pageToken string, readMask *fieldmaskpb.FieldMask, currentDate time.Time) ([]*sports.Event, string, error) {
 	// Mock the behavior here and return a predefined response.
 	// For simplicity, we'll return a predefined list of events.
 	events := getAllTestData()
@@ -35,11 +48,25 @@ func (m *MockEventsRepo) List(filter *sports.ListEventsRequestFilter, orderBy []
 				continue
 			}
 
-			if len(filter.MeetingIds) > 0 {
-				// Skip events that don't match meeting ids
+			if len(filter.SportIds) > 0 {
+				// Skip events that don't match sport ids
 				var result = false
-				for _, x := range filter.MeetingIds {
-					if x == event.MeetingId {
+				for _, x := range filter.SportIds {
+					if x == event.SportId {
+						result = true
+						break
+					}
+				}
+				if !result {
+					continue
+				}
+			}
+
+			if len(filter.CompetitionIds) > 0 {
+				// Skip events that don't match competition ids
+				var result = false
+				for _, x := range filter.CompetitionIds {
+					if x == event.CompetitionId {
 						result = true
 						break
 					}
@@ -70,7 +97,7 @@ func (m *MockEventsRepo) List(filter *sports.ListEventsRequestFilter, orderBy []
 		})
 	}
 
-	return filteredEvents, nil
+	return filteredEvents, "", nil
 }
 
 // Compare a field in the event using sports.ListEventsRequestOrderBy considering the direction ASC or DESC
@@ -98,14 +125,54 @@ func compareField(orderBy *sports.ListEventsRequestOrderBy, a *sports.Event, b *
 	return 0
 }
 
-func (m *MockEventsRepo) Get(id int64, currentDate time.Time) (*sports.Event, error) {
+func (m *MockEventsRepo) Get(ctx context.Context, id int64, readMask *fieldmaskpb.FieldMask, currentDate time.Time) (*sports.Event, error) {
+	if readMask != nil && !readMask.IsValid(&sports.Event{}) {
+		return nil, &db.ReadMaskError{Path: readMask.Paths[0]}
+	}
+
 	events := getAllTestData()
 	for _, event := range events {
 		if event.Id == id {
 			return event, nil
 		}
 	}
-	return nil, nil
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockEventsRepo) BatchGet(ctx context.Context, ids []int64, currentDate time.Time) ([]*sports.Event, error) {
+	var events []*sports.Event
+	for _, event := range getAllTestData() {
+		for _, id := range ids {
+			if event.Id == id {
+				events = append(events, event)
+			}
+		}
+	}
+	return events, nil
+}
+
+func (m *MockEventsRepo) Search(ctx context.Context, query string, limit int32, currentDate time.Time) ([]*sports.EventSearchResult, error) {
+	m.searchLimit = limit
+
+	var results []*sports.EventSearchResult
+	for _, event := range getAllTestData() {
+		if strings.Contains(strings.ToLower(event.Name), strings.ToLower(query)) {
+			results = append(results, &sports.EventSearchResult{Event: event, Score: 1, NameSnippet: event.Name})
+		}
+	}
+	return results, nil
+}
+
+func (m *MockEventsRepo) Create(ctx context.Context, event *sports.Event) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockEventsRepo) Update(ctx context.Context, event *sports.Event, fields []string) error {
+	return nil
+}
+
+func (m *MockEventsRepo) Delete(ctx context.Context, id int64) error {
+	return nil
 }
 
 func TestSportsService_ListEvents(t *testing.T) {
@@ -126,14 +193,15 @@ func TestSportsService_ListEvents(t *testing.T) {
 			expectedErr:    false,
 		},
 		{
-			name: "FilterByMeetingIDs",
+			name: "FilterByCompetitionIDs",
 			filter: &sports.ListEventsRequestFilter{
-				MeetingIds: []int64{1},
+				CompetitionIds: []int64{1},
 			},
 			expectedEvents: []*sports.Event{
 				{
 					Id:                  2,
-					MeetingId:           1,
+					SportId:             1,
+					CompetitionId:       1,
 					Name:                "Connecticut griffins",
 					Visible:             true,
 					Status:              "OPEN",
@@ -150,7 +218,8 @@ func TestSportsService_ListEvents(t *testing.T) {
 			expectedEvents: []*sports.Event{
 				{
 					Id:                  2,
-					MeetingId:           1,
+					SportId:             1,
+					CompetitionId:       1,
 					Name:                "Connecticut griffins",
 					Visible:             true,
 					Status:              "OPEN",
@@ -166,7 +235,8 @@ func TestSportsService_ListEvents(t *testing.T) {
 			},
 			expectedEvents: []*sports.Event{
 				{Id: 1,
-					MeetingId:           5,
+					SportId:             2,
+					CompetitionId:       5,
 					Name:                "North Dakota foes",
 					Visible:             false,
 					Status:              "CLOSED",
@@ -174,7 +244,8 @@ func TestSportsService_ListEvents(t *testing.T) {
 				},
 				{
 					Id:                  3,
-					MeetingId:           8,
+					SportId:             4,
+					CompetitionId:       8,
 					Name:                "Rhode Island ghosts",
 					Visible:             false,
 					Status:              "OPEN",
@@ -193,7 +264,8 @@ func TestSportsService_ListEvents(t *testing.T) {
 			expectedEvents: []*sports.Event{
 				{
 					Id:                  3,
-					MeetingId:           8,
+					SportId:             4,
+					CompetitionId:       8,
 					Name:                "Rhode Island ghosts",
 					Visible:             false,
 					Status:              "OPEN",
@@ -201,14 +273,16 @@ func TestSportsService_ListEvents(t *testing.T) {
 				},
 				{
 					Id:                  2,
-					MeetingId:           1,
+					SportId:             1,
+					CompetitionId:       1,
 					Name:                "Connecticut griffins",
 					Visible:             true,
 					Status:              "OPEN",
 					AdvertisedStartTime: timestamppb.New(time.Date(2023, 7, 15, 12, 0, 0, 0, time.UTC)),
 				},
 				{Id: 1,
-					MeetingId:           5,
+					SportId:             2,
+					CompetitionId:       5,
 					Name:                "North Dakota foes",
 					Visible:             false,
 					Status:              "CLOSED",
@@ -221,7 +295,7 @@ func TestSportsService_ListEvents(t *testing.T) {
 
 	// Create a mock EventsRepo and pass it to the sportsService
 	eventsRepo := &MockEventsRepo{}
-	sportsSvc := NewSportsService(eventsRepo)
+	sportsSvc := NewSportsService(eventsRepo, &MockSportsRepo{}, &MockCompetitionsRepo{}, &MockMarketsRepo{})
 
 	// Run the test cases
 	for _, tc := range testCases {
@@ -253,7 +327,7 @@ func TestSportsService_ListEvents(t *testing.T) {
 func TestSportsService_GetEvent(t *testing.T) {
 	t.Run("GetById", func(t *testing.T) {
 		eventsRepo := &MockEventsRepo{}
-		sportsSvc := NewSportsService(eventsRepo)
+		sportsSvc := NewSportsService(eventsRepo, &MockSportsRepo{}, &MockCompetitionsRepo{}, &MockMarketsRepo{})
 
 		// Prepare the request
 		request := &sports.GetEventRequest{
@@ -271,7 +345,8 @@ func TestSportsService_GetEvent(t *testing.T) {
 		// Compare if the right event was returned
 		expectedRace := sports.Event{
 			Id:                  2,
-			MeetingId:           1,
+			SportId:             1,
+			CompetitionId:       1,
 			Name:                "Connecticut griffins",
 			Visible:             true,
 			Status:              "OPEN",
@@ -280,12 +355,228 @@ func TestSportsService_GetEvent(t *testing.T) {
 
 		assert.Equal(t, response.Event, &expectedRace)
 	})
+
+	t.Run("GetByIdWithMarkets", func(t *testing.T) {
+		sportsSvc := NewSportsService(&MockEventsRepo{}, &MockSportsRepo{}, &MockCompetitionsRepo{}, &MockMarketsRepo{})
+
+		response, err := sportsSvc.GetEvent(context.Background(), &sports.GetEventRequest{Id: 2, IncludeMarkets: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, getAllTestMarkets(), response.Event.Markets)
+	})
+
+	t.Run("GetMarketsNotInReadMask", func(t *testing.T) {
+		sportsSvc := NewSportsService(&MockEventsRepo{}, &MockSportsRepo{}, &MockCompetitionsRepo{}, &MockMarketsRepo{})
+
+		readMask := &fieldmaskpb.FieldMask{Paths: []string{"id", "name"}}
+		response, err := sportsSvc.GetEvent(context.Background(), &sports.GetEventRequest{Id: 2, IncludeMarkets: true, ReadMask: readMask})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Empty(t, response.Event.Markets)
+	})
+
+	t.Run("GetInvalidReadMask", func(t *testing.T) {
+		sportsSvc := NewSportsService(&MockEventsRepo{}, &MockSportsRepo{}, &MockCompetitionsRepo{}, &MockMarketsRepo{})
+
+		readMask := &fieldmaskpb.FieldMask{Paths: []string{"score"}}
+		_, err := sportsSvc.GetEvent(context.Background(), &sports.GetEventRequest{Id: 2, ReadMask: readMask})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("GetByIdNotFound", func(t *testing.T) {
+		sportsSvc := NewSportsService(&MockEventsRepo{}, &MockSportsRepo{}, &MockCompetitionsRepo{}, &MockMarketsRepo{})
+
+		_, err := sportsSvc.GetEvent(context.Background(), &sports.GetEventRequest{Id: 999})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+// MockMarketsRepo is a mock implementation of the db.MarketsRepo interface.
+type MockMarketsRepo struct{}
+
+func (m *MockMarketsRepo) Init() error {
+	return nil
+}
+
+func (m *MockMarketsRepo) List(ctx context.Context, eventID int64) ([]*sports.Market, error) {
+	var markets []*sports.Market
+	for _, market := range getAllTestMarkets() {
+		if market.EventId == eventID {
+			markets = append(markets, market)
+		}
+	}
+	return markets, nil
+}
+
+func TestSportsService_ListMarkets(t *testing.T) {
+	sportsSvc := NewSportsService(&MockEventsRepo{}, &MockSportsRepo{}, &MockCompetitionsRepo{}, &MockMarketsRepo{})
+
+	t.Run("ListByEventId", func(t *testing.T) {
+		response, err := sportsSvc.ListMarkets(context.Background(), &sports.ListMarketsRequest{EventId: 2})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, getAllTestMarkets(), response.Markets)
+	})
+
+	t.Run("EventWithoutMarkets", func(t *testing.T) {
+		response, err := sportsSvc.ListMarkets(context.Background(), &sports.ListMarketsRequest{EventId: 1})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Empty(t, response.Markets)
+	})
+
+	t.Run("EventNotFound", func(t *testing.T) {
+		_, err := sportsSvc.ListMarkets(context.Background(), &sports.ListMarketsRequest{EventId: 999})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func getAllTestMarkets() []*sports.Market {
+	return []*sports.Market{
+		{
+			Id:      1,
+			EventId: 2,
+			Name:    "Head to Head",
+			Type:    sports.MarketType_HEAD_TO_HEAD,
+			Selections: []*sports.Selection{
+				{Id: 1, MarketId: 1, Name: "Arsenal", Price: 2.1, ParticipantId: 1},
+				{Id: 2, MarketId: 1, Name: "Draw", Price: 3.4},
+				{Id: 3, MarketId: 1, Name: "Chelsea", Price: 3.25, ParticipantId: 2},
+			},
+		},
+		{
+			Id:      2,
+			EventId: 2,
+			Name:    "Total Goals",
+			Type:    sports.MarketType_TOTALS,
+			Line:    2.5,
+			Selections: []*sports.Selection{
+				{Id: 4, MarketId: 2, Name: "Over 2.5", Price: 1.85},
+				{Id: 5, MarketId: 2, Name: "Under 2.5", Price: 1.95},
+			},
+		},
+	}
+}
+
+// MockSportsRepo is a mock implementation of the db.SportsRepo interface.
+type MockSportsRepo struct{}
+
+func (m *MockSportsRepo) Init() error {
+	return nil
+}
+
+func (m *MockSportsRepo) List(ctx context.Context) ([]*sports.Sport, error) {
+	return []*sports.Sport{{Id: 2, Name: "Basketball"}, {Id: 1, Name: "Soccer"}}, nil
+}
+
+// MockCompetitionsRepo is a mock implementation of the db.CompetitionsRepo interface.
+type MockCompetitionsRepo struct{}
+
+func (m *MockCompetitionsRepo) Init() error {
+	return nil
+}
+
+func (m *MockCompetitionsRepo) List(ctx context.Context, filter *sports.ListCompetitionsRequestFilter) ([]*sports.Competition, error) {
+	var competitions []*sports.Competition
+	for _, competition := range getAllTestCompetitions() {
+		if len(filter.GetSportIds()) > 0 && competition.SportId != filter.SportIds[0] {
+			continue
+		}
+		competitions = append(competitions, competition)
+	}
+	return competitions, nil
+}
+
+func (m *MockCompetitionsRepo) Get(ctx context.Context, id int64) (*sports.Competition, error) {
+	for _, competition := range getAllTestCompetitions() {
+		if competition.Id == id {
+			return competition, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockCompetitionsRepo) ListParticipants(ctx context.Context, competitionID int64) ([]*sports.Participant, error) {
+	var participants []*sports.Participant
+	for _, participant := range getAllTestParticipants() {
+		if participant.competitionID == competitionID {
+			participants = append(participants, &sports.Participant{Id: participant.id, Name: participant.name})
+		}
+	}
+	return participants, nil
+}
+
+func TestSportsService_ListSports(t *testing.T) {
+	sportsSvc := NewSportsService(&MockEventsRepo{}, &MockSportsRepo{}, &MockCompetitionsRepo{}, &MockMarketsRepo{})
+
+	response, err := sportsSvc.ListSports(context.Background(), &sports.ListSportsRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assert.Equal(t, []*sports.Sport{{Id: 2, Name: "Basketball"}, {Id: 1, Name: "Soccer"}}, response.Sports)
+}
+
+func TestSportsService_ListCompetitions(t *testing.T) {
+	sportsSvc := NewSportsService(&MockEventsRepo{}, &MockSportsRepo{}, &MockCompetitionsRepo{}, &MockMarketsRepo{})
+
+	t.Run("NoFilter", func(t *testing.T) {
+		response, err := sportsSvc.ListCompetitions(context.Background(), &sports.ListCompetitionsRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, getAllTestCompetitions(), response.Competitions)
+	})
+
+	t.Run("FilterBySportIDs", func(t *testing.T) {
+		response, err := sportsSvc.ListCompetitions(context.Background(), &sports.ListCompetitionsRequest{
+			Filter: &sports.ListCompetitionsRequestFilter{SportIds: []int64{2}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		assert.Equal(t, []*sports.Competition{getAllTestCompetitions()[1]}, response.Competitions)
+	})
+}
+
+func getAllTestCompetitions() []*sports.Competition {
+	return []*sports.Competition{
+		{Id: 1, SportId: 1, Name: "English Premier League", Country: "GB"},
+		{Id: 5, SportId: 2, Name: "NBA", Country: "US"},
+	}
+}
+
+// testParticipant is a participant of a competition.
+type testParticipant struct {
+	id            int64
+	competitionID int64
+	name          string
+}
+
+func getAllTestParticipants() []testParticipant {
+	return []testParticipant{
+		{id: 1, competitionID: 1, name: "Arsenal"},
+		{id: 2, competitionID: 1, name: "Chelsea"},
+		{id: 3, competitionID: 5, name: "Boston Celtics"},
+	}
 }
 
 func getAllTestData() []*sports.Event {
 	return []*sports.Event{
 		{Id: 1,
-			MeetingId:           5,
+			SportId:             2,
+			CompetitionId:       5,
 			Name:                "North Dakota foes",
 			Visible:             false,
 			Status:              "CLOSED",
@@ -293,7 +584,8 @@ func getAllTestData() []*sports.Event {
 		},
 		{
 			Id:                  2,
-			MeetingId:           1,
+			SportId:             1,
+			CompetitionId:       1,
 			Name:                "Connecticut griffins",
 			Visible:             true,
 			Status:              "OPEN",
@@ -301,7 +593,8 @@ func getAllTestData() []*sports.Event {
 		},
 		{
 			Id:                  3,
-			MeetingId:           8,
+			SportId:             4,
+			CompetitionId:       8,
 			Name:                "Rhode Island ghosts",
 			Visible:             false,
 			Status:              "OPEN",
@@ -309,3 +602,291 @@ func getAllTestData() []*sports.Event {
 		},
 	}
 }
+
+// watchEventsRepo is a MockEventsRepo whose events can be changed while they are being watched.
+type watchEventsRepo struct {
+	MockEventsRepo
+	mu     sync.Mutex
+	events []*sports.Event
+}
+
+func (m *watchEventsRepo) List(ctx context.Context, filter *sports.ListEventsRequestFilter, orderBy []*sports.ListEventsRequestOrderBy, pageSize int32, pageToken string, readMask *fieldmaskpb.FieldMask, currentDate time.Time) ([]*sports.Event, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.events, "", nil
+}
+
+func (m *watchEventsRepo) setEvents(events []*sports.Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = events
+}
+
+// mockWatchEventsServer collects the messages sent through a WatchEvents stream.
+type mockWatchEventsServer struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *sports.WatchEventsResponse
+}
+
+func (m *mockWatchEventsServer) Context() context.Context {
+	return m.ctx
+}
+
+func (m *mockWatchEventsServer) Send(response *sports.WatchEventsResponse) error {
+	m.sent <- response
+	return nil
+}
+
+func TestSportsService_WatchEvents(t *testing.T) {
+	eventsRepo := &watchEventsRepo{events: getAllTestData()}
+	sportsSvc := &sportsService{eventsRepo: eventsRepo, watchInterval: time.Millisecond, watches: newEventWatches(), clock: clock.System}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &mockWatchEventsServer{ctx: ctx, sent: make(chan *sports.WatchEventsResponse, 10)}
+
+	done := make(chan error)
+	go func() {
+		done <- sportsSvc.WatchEvents(&sports.WatchEventsRequest{}, stream)
+	}()
+
+	// The initial snapshot contains every event
+	for _, event := range getAllTestData() {
+		response := <-stream.sent
+		assert.Equal(t, sports.ChangeType_SNAPSHOT, response.ChangeType)
+		assert.Equal(t, event, response.Event)
+	}
+
+	// Removing an event is pushed as a removal
+	events := getAllTestData()
+	eventsRepo.setEvents(events[:2])
+
+	response := <-stream.sent
+	assert.Equal(t, sports.ChangeType_REMOVED, response.ChangeType)
+	assert.Equal(t, events[2], response.Event)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestSportsService_WatchEvents_Shared(t *testing.T) {
+	eventsRepo := &watchEventsRepo{events: getAllTestData()}
+	sportsSvc := &sportsService{eventsRepo: eventsRepo, watchInterval: time.Millisecond, watches: newEventWatches(), clock: clock.System}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	streams := []*mockWatchEventsServer{
+		{ctx: ctx, sent: make(chan *sports.WatchEventsResponse, 10)},
+		{ctx: ctx, sent: make(chan *sports.WatchEventsResponse, 10)},
+	}
+
+	done := make(chan error)
+	for _, stream := range streams {
+		go func(stream *mockWatchEventsServer) {
+			done <- sportsSvc.WatchEvents(&sports.WatchEventsRequest{Filter: &sports.ListEventsRequestFilter{SportIds: []int64{1}}}, stream)
+		}(stream)
+
+		// Watchers joining a watch get the snapshot of the events it polled
+		for range getAllTestData() {
+			assert.Equal(t, sports.ChangeType_SNAPSHOT, (<-stream.sent).ChangeType)
+		}
+	}
+
+	// Both watchers share the polling of the filter
+	sportsSvc.watches.mu.Lock()
+	assert.Len(t, sportsSvc.watches.watches, 1)
+	sportsSvc.watches.mu.Unlock()
+
+	events := getAllTestData()
+	events[0].Name = "Renamed event"
+	eventsRepo.setEvents(events)
+
+	for _, stream := range streams {
+		response := <-stream.sent
+		assert.Equal(t, sports.ChangeType_UPDATED, response.ChangeType)
+		assert.Equal(t, events[0], response.Event)
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.NoError(t, <-done)
+
+	// The watch is stopped once nobody watches it
+	assert.Empty(t, sportsSvc.watches.watches)
+}
+
+// blockingEventsRepo is a MockEventsRepo whose List only returns once its context is done.
+type blockingEventsRepo struct {
+	MockEventsRepo
+	listing chan struct{}
+}
+
+func (m *blockingEventsRepo) List(ctx context.Context, filter *sports.ListEventsRequestFilter, orderBy []*sports.ListEventsRequestOrderBy, pageSize int32, pageToken string, readMask *fieldmaskpb.FieldMask, currentDate time.Time) ([]*sports.Event, string, error) {
+	close(m.listing)
+	<-ctx.Done()
+	return nil, "", ctx.Err()
+}
+
+func TestEventWatches_PublishError(t *testing.T) {
+	watches := newEventWatches()
+
+	polling := make(chan context.Context, 1)
+	updates, stop, err := watches.watch(&sports.ListEventsRequestFilter{}, func(ctx context.Context, watch *eventWatch) {
+		polling <- ctx
+		watches.publish(watch, nil, status.Error(codes.Internal, "failed"))
+	})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	defer stop()
+
+	// The error ends the watch, which stops polling
+	assert.Equal(t, codes.Internal, status.Code((<-updates).err))
+	assert.ErrorIs(t, (<-polling).Err(), context.Canceled)
+
+	watches.mu.Lock()
+	assert.Empty(t, watches.watches)
+	watches.mu.Unlock()
+}
+
+func TestSportsService_WatchEvents_CancelDuringPoll(t *testing.T) {
+	eventsRepo := &blockingEventsRepo{listing: make(chan struct{})}
+	sportsSvc := &sportsService{eventsRepo: eventsRepo, watchInterval: time.Millisecond, watches: newEventWatches(), clock: clock.System}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &mockWatchEventsServer{ctx: ctx, sent: make(chan *sports.WatchEventsResponse, 10)}
+
+	done := make(chan error)
+	go func() {
+		done <- sportsSvc.WatchEvents(&sports.WatchEventsRequest{}, stream)
+	}()
+
+	// Clients going away during a poll close their stream without an error
+	<-eventsRepo.listing
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestSportsService_WatchEvents_SlowClient(t *testing.T) {
+	eventsRepo := &watchEventsRepo{events: getAllTestData()}
+	sportsSvc := &sportsService{eventsRepo: eventsRepo, watchInterval: time.Millisecond, watches: newEventWatches(), clock: clock.System}
+
+	// The client never reads the changes, so its updates pile up
+	watcher, stop, err := sportsSvc.watches.watch(nil, sportsSvc.pollEvents)
+	if err != nil {
+		t.Fatalf("failed to watch events: %v", err)
+	}
+	defer stop()
+
+	for i := 0; len(watcher) < cap(watcher) && i < 1000; i++ {
+		events := getAllTestData()
+		events[0].Name = fmt.Sprintf("Renamed event %d", i)
+		eventsRepo.setEvents(events)
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	var update eventWatchUpdate
+	for update = range watcher {
+		if update.err != nil {
+			break
+		}
+	}
+	assert.Equal(t, codes.ResourceExhausted, status.Code(update.err))
+}
+
+// clockEventsRepo is a MockEventsRepo deriving the statuses of its events from the time it is given, as the repository
+// does.
+type clockEventsRepo struct {
+	MockEventsRepo
+}
+
+func (m *clockEventsRepo) List(ctx context.Context, filter *sports.ListEventsRequestFilter, orderBy []*sports.ListEventsRequestOrderBy, pageSize int32, pageToken string, readMask *fieldmaskpb.FieldMask, currentDate time.Time) ([]*sports.Event, string, error) {
+	events := getAllTestData()
+	for _, event := range events {
+		event.Status = "OPEN"
+		if !event.AdvertisedStartTime.AsTime().After(currentDate) {
+			event.Status = "CLOSED"
+		}
+	}
+	return events, "", nil
+}
+
+func TestSportsService_WatchEvents_Clock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sportsSvc := &sportsService{eventsRepo: &clockEventsRepo{}, watchInterval: time.Millisecond, watches: newEventWatches(), clock: fake}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &mockWatchEventsServer{ctx: ctx, sent: make(chan *sports.WatchEventsResponse, 10)}
+
+	done := make(chan error)
+	go func() {
+		done <- sportsSvc.WatchEvents(&sports.WatchEventsRequest{}, stream)
+	}()
+
+	var snapshot []*sports.Event
+	for range getAllTestData() {
+		snapshot = append(snapshot, (<-stream.sent).Event)
+	}
+	assert.Equal(t, "OPEN", snapshot[2].Status)
+
+	// Moving the clock to the start of an event closes it
+	fake.Set(snapshot[2].AdvertisedStartTime.AsTime())
+
+	response := <-stream.sent
+	assert.Equal(t, sports.ChangeType_STATUS_CHANGED, response.ChangeType)
+	assert.Equal(t, int64(3), response.Event.Id)
+	assert.Equal(t, "CLOSED", response.Event.Status)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestGetPageSize(t *testing.T) {
+	testCases := []struct {
+		name             string
+		requested        int32
+		expectedPageSize int32
+		expectedErr      bool
+	}{
+		{name: "Default", requested: 0, expectedPageSize: defaultPageSize},
+		{name: "Requested", requested: 10, expectedPageSize: 10},
+		{name: "CappedToMax", requested: maxPageSize + 1, expectedPageSize: maxPageSize},
+		{name: "Negative", requested: -1, expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pageSize, err := getPageSize(tc.requested)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assert.Equal(t, tc.expectedPageSize, pageSize)
+		})
+	}
+}
+
+func TestValidateStartTimes(t *testing.T) {
+	from := timestamppb.New(time.Date(2023, 7, 15, 0, 0, 0, 0, time.UTC))
+	to := timestamppb.New(time.Date(2023, 7, 16, 0, 0, 0, 0, time.UTC))
+
+	testCases := []struct {
+		name         string
+		filter       *sports.ListEventsRequestFilter
+		expectedCode codes.Code
+	}{
+		{name: "NoFilter", expectedCode: codes.OK},
+		{name: "Range", filter: &sports.ListEventsRequestFilter{StartTimeFrom: from, StartTimeTo: to}, expectedCode: codes.OK},
+		{name: "OnlyFrom", filter: &sports.ListEventsRequestFilter{StartTimeFrom: from}, expectedCode: codes.OK},
+		{name: "WithinMinutes", filter: &sports.ListEventsRequestFilter{StartsWithinMinutes: 60}, expectedCode: codes.OK},
+		{name: "FromAfterTo", filter: &sports.ListEventsRequestFilter{StartTimeFrom: to, StartTimeTo: from}, expectedCode: codes.InvalidArgument},
+		{name: "EmptyRange", filter: &sports.ListEventsRequestFilter{StartTimeFrom: from, StartTimeTo: from}, expectedCode: codes.InvalidArgument},
+		{name: "InvalidTimestamp", filter: &sports.ListEventsRequestFilter{StartTimeTo: &timestamppb.Timestamp{Nanos: -1}}, expectedCode: codes.InvalidArgument},
+		{name: "NegativeMinutes", filter: &sports.ListEventsRequestFilter{StartsWithinMinutes: -1}, expectedCode: codes.InvalidArgument},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateStartTimes(tc.filter)
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !timetravel
+// +build !timetravel
+
+package service
+
+import (
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TimeTravelEnabled is whether the clock can be moved with TimeTravel, which is only built in with the timetravel
+// tag, so production builds always tell the time of the system.
+const TimeTravelEnabled = false
+
+func (s *sportsAdminService) TimeTravel(ctx context.Context, in *sports.TimeTravelRequest) (*sports.TimeTravelResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "time travel is only available in builds with the timetravel tag")
+}
@@ -0,0 +1,21 @@
+//go:build !timetravel
+// +build !timetravel
+
+package service
+
+import (
+	"context"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestSportsAdminService_TimeTravel(t *testing.T) {
+	adminSvc := NewSportsAdminService(&MockEventsRepo{}, &MockCompetitionsRepo{})
+
+	_, err := adminSvc.TimeTravel(context.Background(), &sports.TimeTravelRequest{Present: true})
+
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
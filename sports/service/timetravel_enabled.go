@@ -0,0 +1,51 @@
+//go:build timetravel
+// +build timetravel
+
+package service
+
+import (
+	"git.neds.sh/matty/entain/common/clock"
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TimeTravelEnabled is whether the clock can be moved with TimeTravel, which is only built in with the timetravel
+// tag, so production builds always tell the time of the system.
+const TimeTravelEnabled = true
+
+func (s *sportsAdminService) TimeTravel(ctx context.Context, in *sports.TimeTravelRequest) (*sports.TimeTravelResponse, error) {
+	travel, ok := s.clock.(*clock.Travel)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "the clock of the service can't be moved")
+	}
+
+	set := 0
+	for _, ok := range []bool{in.Time != nil, in.Advance != nil, in.Present} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, status.Error(codes.InvalidArgument, "one of time, advance or present must be set")
+	}
+
+	switch {
+	case in.Time != nil:
+		if err := in.Time.CheckValid(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "time is invalid")
+		}
+		travel.Set(in.Time.AsTime())
+	case in.Advance != nil:
+		if err := in.Advance.CheckValid(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "advance is invalid")
+		}
+		travel.Add(in.Advance.AsDuration())
+	default:
+		travel.Reset()
+	}
+
+	return &sports.TimeTravelResponse{Now: timestamppb.New(travel.Now())}, nil
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"git.neds.sh/matty/entain/sports/proto/sports"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultWatchInterval is how often the events being watched are compared against the repository.
+// Statuses are derived from the current time, so changes can't be pushed by the repository itself.
+const defaultWatchInterval = time.Second
+
+// watchOrderBy keeps the snapshot and every batch of changes ordered by start time.
+var watchOrderBy = []*sports.ListEventsRequestOrderBy{
+	{FieldName: "advertisedStartTime", Direction: sports.OrderByDirection_ASC},
+}
+
+// watchBufferSize is how many batches of changes are buffered for a client. Clients falling further behind are
+// disconnected, so they can't hold up the other clients watching the same events.
+const watchBufferSize = 16
+
+// WatchEvents sends every event matching the filter as a SNAPSHOT and then keeps polling the repository,
+// sending only the events that were created, updated, had their status changed or stopped matching the filter.
+// Clients watching the same filter share the polling, so the events are listed once per interval however many
+// clients there are. The stream is closed when the client goes away.
+func (s *sportsService) WatchEvents(in *sports.WatchEventsRequest, stream sports.Sports_WatchEventsServer) error {
+	ctx := stream.Context()
+
+	watcher, stop, err := s.watches.watch(in.Filter, s.pollEvents)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case update := <-watcher:
+			if update.err != nil {
+				return update.err
+			}
+
+			for _, response := range update.responses {
+				if err := stream.Send(response); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					return err
+				}
+			}
+		}
+	}
+}
+
+// pollEvents lists the events matching the filter of a watch every interval, publishing their changes to its
+// watchers until the last one goes away or the events can't be listed.
+func (s *sportsService) pollEvents(ctx context.Context, watch *eventWatch) {
+	ticker := time.NewTicker(s.watchInterval)
+	defer ticker.Stop()
+
+	for {
+		events, _, err := s.eventsRepo.List(ctx, watch.filter, watchOrderBy, 0, "", nil, s.clock.Now())
+		if ctx.Err() != nil {
+			// The last watcher went away during the poll
+			return
+		}
+
+		s.watches.publish(watch, events, err)
+		if err != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// eventWatchUpdate is a batch of changes sent to a watcher, or the error ending its stream.
+type eventWatchUpdate struct {
+	responses []*sports.WatchEventsResponse
+	err       error
+}
+
+// eventWatch polls the events matching a filter on behalf of every client watching them.
+type eventWatch struct {
+	key    string
+	filter *sports.ListEventsRequestFilter
+	cancel context.CancelFunc
+
+	// The fields below are guarded by the mutex of the eventWatches the watch belongs to
+	watchers map[chan eventWatchUpdate]bool
+	polled   bool
+	latest   []*sports.Event
+	known    map[int64]*sports.Event
+}
+
+// eventWatches are the watches of the events being watched, keyed by their filter.
+type eventWatches struct {
+	mu      sync.Mutex
+	watches map[string]*eventWatch
+}
+
+func newEventWatches() *eventWatches {
+	return &eventWatches{watches: make(map[string]*eventWatch)}
+}
+
+// watch returns the channel of the updates of the events matching the filter, starting with their snapshot, and
+// the function to call once they aren't watched anymore. The watch of the filter is started with poll when
+// nobody is watching it yet, and stopped when the last watcher stops.
+func (w *eventWatches) watch(filter *sports.ListEventsRequestFilter, poll func(ctx context.Context, watch *eventWatch)) (<-chan eventWatchUpdate, func(), error) {
+	key, err := proto.MarshalOptions{Deterministic: true}.Marshal(filter)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "filter can't be watched: %s", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	watch, ok := w.watches[string(key)]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		watch = &eventWatch{key: string(key), filter: filter, cancel: cancel, watchers: make(map[chan eventWatchUpdate]bool)}
+		w.watches[watch.key] = watch
+		go poll(ctx, watch)
+	}
+
+	watcher := make(chan eventWatchUpdate, watchBufferSize)
+	watch.watchers[watcher] = true
+	// Watchers joining a watch that already polled are sent the latest events, and the next changes like the others
+	if watch.polled {
+		watcher <- eventWatchUpdate{responses: eventSnapshot(watch.latest)}
+	}
+
+	stop := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		delete(watch.watchers, watcher)
+		if len(watch.watchers) == 0 && w.watches[watch.key] == watch {
+			delete(w.watches, watch.key)
+			watch.cancel()
+		}
+	}
+
+	return watcher, stop, nil
+}
+
+// publish sends the watchers of a watch the snapshot of the events on its first poll, and then their changes. An
+// error is sent to every watcher and ends the watch, so the next watchers start a new one.
+func (w *eventWatches) publish(watch *eventWatch, events []*sports.Event, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var update eventWatchUpdate
+	switch {
+	case err != nil:
+		update.err = err
+		if w.watches[watch.key] == watch {
+			delete(w.watches, watch.key)
+		}
+		// The watch is over, so it stops polling even though its watchers haven't stopped yet
+		watch.cancel()
+	case !watch.polled:
+		watch.polled = true
+		watch.known = make(map[int64]*sports.Event, len(events))
+		for _, event := range events {
+			watch.known[event.Id] = event
+		}
+		update.responses = eventSnapshot(events)
+	default:
+		update.responses = diffEvents(watch.known, events)
+	}
+	watch.latest = events
+
+	if update.err == nil && len(update.responses) == 0 {
+		return
+	}
+
+	for watcher := range watch.watchers {
+		// The last buffered update is kept for the error disconnecting a watcher that fell behind
+		if len(watcher) >= cap(watcher)-1 && update.err == nil {
+			watcher <- eventWatchUpdate{err: status.Error(codes.ResourceExhausted, "client is too slow to receive the changes of the events")}
+			delete(watch.watchers, watcher)
+			continue
+		}
+
+		watcher <- update
+	}
+}
+
+// eventSnapshot returns the events as a SNAPSHOT.
+func eventSnapshot(events []*sports.Event) []*sports.WatchEventsResponse {
+	responses := make([]*sports.WatchEventsResponse, 0, len(events))
+	for _, event := range events {
+		responses = append(responses, &sports.WatchEventsResponse{ChangeType: sports.ChangeType_SNAPSHOT, Event: event})
+	}
+
+	return responses
+}
+
+// diffEvents compares the latest events with the ones previously sent, returning the changes between them.
+// known is updated in place so it reflects the latest events once diffEvents returns.
+func diffEvents(known map[int64]*sports.Event, latest []*sports.Event) []*sports.WatchEventsResponse {
+	var changes []*sports.WatchEventsResponse
+
+	seen := make(map[int64]bool, len(latest))
+	for _, event := range latest {
+		seen[event.Id] = true
+
+		previous, ok := known[event.Id]
+		switch {
+		case !ok:
+			changes = append(changes, &sports.WatchEventsResponse{ChangeType: sports.ChangeType_CREATED, Event: event})
+		case previous.Status != event.Status:
+			changes = append(changes, &sports.WatchEventsResponse{ChangeType: sports.ChangeType_STATUS_CHANGED, Event: event})
+		case !proto.Equal(previous, event):
+			changes = append(changes, &sports.WatchEventsResponse{ChangeType: sports.ChangeType_UPDATED, Event: event})
+		default:
+			continue
+		}
+
+		known[event.Id] = event
+	}
+
+	var removed []int64
+	for id := range known {
+		if !seen[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i] < removed[j] })
+
+	for _, id := range removed {
+		changes = append(changes, &sports.WatchEventsResponse{ChangeType: sports.ChangeType_REMOVED, Event: known[id]})
+		delete(known, id)
+	}
+
+	return changes
+}